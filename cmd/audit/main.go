@@ -0,0 +1,12 @@
+// Command audit verifies the hash chain of the audit log. It exits with 1 if
+// the log has been tampered with.
+package main
+
+import (
+	"AvitoTech/internal/app"
+	"os"
+)
+
+func main() {
+	os.Exit(app.VerifyAudit())
+}
@@ -0,0 +1,13 @@
+// Command reconcile checks every balance against the ledger and prints the
+// report as JSON. It exits with 1 if any balance differs, the report can then
+// be approved through /api/admin/reconciliations/{id}/approve.
+package main
+
+import (
+	"AvitoTech/internal/app"
+	"os"
+)
+
+func main() {
+	os.Exit(app.Reconcile())
+}
@@ -2,27 +2,249 @@ CREATE TABLE IF NOT EXISTS users (
     user_id SERIAL PRIMARY KEY,
     username TEXT NOT NULL,
     password TEXT NOT NULL,
-    balance INTEGER NOT NULL
+    balance INTEGER NOT NULL,
+    -- signup_balance is the balance the user started with, the base the
+    -- reconciliation recomputes the balance from
+    signup_balance INTEGER NOT NULL DEFAULT 1000,
+    -- held is the part of the balance locked by active holds, only the rest
+    -- can be spent
+    held INTEGER NOT NULL DEFAULT 0 CHECK (held >= 0),
+    token_version INTEGER NOT NULL DEFAULT 0,
+    role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin', 'auditor')),
+    disabled_at TIMESTAMPTZ,
+    -- deleted users keep their row, so that the history naming them stays
+    -- intact
+    deleted_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (held <= balance)
 );
 
 CREATE TABLE IF NOT EXISTS history (
     id SERIAL PRIMARY KEY,
     sender_name TEXT NOT NULL,
     receiver_name TEXT NOT NULL,
-    amount INTEGER
+    amount INTEGER,
+    -- reason explains operations of the system account and released holds,
+    -- it is NULL for other transfers between users
+    reason TEXT,
+    -- created_at dates the coins received, unspent coins expire by it
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
 );
 
 CREATE TABLE IF NOT EXISTS inventory (
     id SERIAL PRIMARY KEY,
     owner_id INTEGER NOT NULL,
-    item TEXT NOT NULL
+    item TEXT NOT NULL,
+    -- price is what the item cost, 0 for welcome items. It is NULL for
+    -- purchases made before prices were kept, the catalog price applies.
+    price INTEGER,
+    -- created_at dates the purchase for the spending limits
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
 );
 
-CREATE INDEX sender
+-- The columns below were added to the tables above after their first release.
+-- A database initialised by an older version of this file gets them when the
+-- file is run again, every statement here is safe to repeat.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS signup_balance INTEGER NOT NULL DEFAULT 1000,
+    ADD COLUMN IF NOT EXISTS held INTEGER NOT NULL DEFAULT 0 CHECK (held >= 0) CHECK (held <= balance),
+    ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin', 'auditor')),
+    ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+ALTER TABLE history
+    ADD COLUMN IF NOT EXISTS reason TEXT,
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+-- purchases made before inventory.created_at existed are dated at the epoch,
+-- so that they don't count against the spending limits
+ALTER TABLE inventory
+    ADD COLUMN IF NOT EXISTS price INTEGER,
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT 'epoch';
+ALTER TABLE inventory
+    ALTER COLUMN created_at SET DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS users_username_prefix
+    ON users (username text_pattern_ops);
+
+CREATE INDEX IF NOT EXISTS sender
     ON history(sender_name);
 
-CREATE INDEX receiver
+CREATE INDEX IF NOT EXISTS receiver
     ON history(receiver_name);
 
-CREATE INDEX idx_owner_id_item
-    ON inventory (owner_id, item);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_owner_id_item
+    ON inventory (owner_id, item);
+
+CREATE TABLE IF NOT EXISTS login_failures (
+    id BIGSERIAL PRIMARY KEY,
+    username TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS login_failures_username
+    ON login_failures (username, created_at);
+
+CREATE INDEX IF NOT EXISTS login_failures_ip
+    ON login_failures (ip, created_at);
+
+CREATE TABLE IF NOT EXISTS lockouts (
+    id SERIAL PRIMARY KEY,
+    username TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    failures INTEGER NOT NULL,
+    locked_until TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    unlocked_at TIMESTAMPTZ,
+    unlocked_by TEXT
+);
+
+CREATE INDEX IF NOT EXISTS lockouts_username
+    ON lockouts (username, locked_until);
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    used_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS password_reset_tokens_user_id
+    ON password_reset_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL,
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    target TEXT NOT NULL,
+    -- payload is kept as written, the hash covers its exact text
+    payload TEXT NOT NULL,
+    prev_hash TEXT NOT NULL,
+    hash TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_actor
+    ON audit_log (actor, id);
+
+CREATE INDEX IF NOT EXISTS audit_log_target
+    ON audit_log (target, id);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TABLE IF NOT EXISTS reconciliations (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    -- users is the number of users checked
+    users INTEGER NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('clean', 'pending', 'applied')),
+    approved_by TEXT,
+    approved_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS reconciliation_discrepancies (
+    reconciliation_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    username TEXT NOT NULL,
+    balance INTEGER NOT NULL,
+    expected INTEGER NOT NULL,
+    corrected BOOLEAN NOT NULL DEFAULT false,
+    PRIMARY KEY (reconciliation_id, user_id)
+);
+
+-- job_runs records the runs of the scheduled jobs. A run is claimed by
+-- inserting it, so that every scheduled time is run once across replicas.
+CREATE TABLE IF NOT EXISTS job_runs (
+    id BIGSERIAL PRIMARY KEY,
+    job TEXT NOT NULL,
+    scheduled_at TIMESTAMPTZ NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at TIMESTAMPTZ,
+    error TEXT,
+    replica TEXT NOT NULL,
+    UNIQUE (job, scheduled_at)
+);
+
+-- coin_requests are requests of coins from another user. A pending request
+-- past expires_at is expired, whatever its status says.
+CREATE TABLE IF NOT EXISTS coin_requests (
+    id BIGSERIAL PRIMARY KEY,
+    requester_id INTEGER NOT NULL,
+    payer_id INTEGER NOT NULL,
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    memo TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    resolved_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS coin_requests_payer
+    ON coin_requests (payer_id, created_at);
+
+CREATE INDEX IF NOT EXISTS coin_requests_requester
+    ON coin_requests (requester_id, created_at);
+
+-- scheduled_transfers are transfers run later by the service: once at
+-- next_run_at if schedule is NULL, on schedule otherwise. next_run_at is NULL
+-- once the transfer is no longer active.
+CREATE TABLE IF NOT EXISTS scheduled_transfers (
+    id BIGSERIAL PRIMARY KEY,
+    sender_id INTEGER NOT NULL,
+    receiver_id INTEGER NOT NULL,
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    schedule TEXT,
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'completed', 'cancelled', 'failed')),
+    next_run_at TIMESTAMPTZ,
+    last_run_at TIMESTAMPTZ,
+    -- failures counts the failed runs since the last successful one
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_transfers_due
+    ON scheduled_transfers (next_run_at) WHERE status = 'active';
+
+CREATE INDEX IF NOT EXISTS scheduled_transfers_sender
+    ON scheduled_transfers (sender_id, created_at);
+
+-- holds lock coins of the sponsor until they are released to a recipient or
+-- cancelled back. The coins stay in the balance of the sponsor meanwhile,
+-- counted in users.held.
+CREATE TABLE IF NOT EXISTS holds (
+    id BIGSERIAL PRIMARY KEY,
+    sponsor_id INTEGER NOT NULL,
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    memo TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'released', 'cancelled')),
+    recipient_id INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    resolved_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS holds_sponsor
+    ON holds (sponsor_id, created_at);
+
+-- spending_limits override the configured spending limits for single users.
+-- An amount of 0 lifts the limit.
+CREATE TABLE IF NOT EXISTS spending_limits (
+    user_id INTEGER NOT NULL,
+    operation TEXT NOT NULL CHECK (operation IN ('transfer', 'purchase')),
+    period TEXT NOT NULL CHECK (period IN ('daily', 'weekly')),
+    amount INTEGER NOT NULL CHECK (amount >= 0),
+    PRIMARY KEY (user_id, operation, period)
+);
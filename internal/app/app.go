@@ -2,27 +2,44 @@
 package app
 
 import (
+	"AvitoTech/internal/cache"
 	"AvitoTech/internal/config"
 	"AvitoTech/internal/controller"
 	"AvitoTech/internal/entity"
+	"AvitoTech/internal/event"
+	"AvitoTech/internal/logging"
+	"AvitoTech/internal/metrics"
+	"AvitoTech/internal/ratelimit"
+	"AvitoTech/internal/repository"
 	"AvitoTech/internal/repository/postgres"
+	"AvitoTech/internal/scheduler"
 	"AvitoTech/internal/service"
+	"AvitoTech/internal/tracing"
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
-func waitForConnection(logger *zap.Logger, c *sql.DB) error {
+func waitForConnection(logger *zap.Logger, c postgres.DB) error {
 	var err error
 	for i := 0; i < 10; i++ {
-		err = c.Ping()
+		err = c.Ping(context.Background())
 		if err == nil {
 			logger.Info("connected to database")
 			return nil
@@ -34,34 +51,445 @@ func waitForConnection(logger *zap.Logger, c *sql.DB) error {
 	return err
 }
 
-func setupApp(logger *zap.Logger, db *sql.DB) (*controller.APIController, error) {
+// openDB creates the connection pool with the driver chosen in the
+// configuration.
+func openDB(ctx context.Context, dsn string) (postgres.DB, error) {
+	pgCfg := config.Configuration.Database
+
+	switch pgCfg.Driver {
+	case "sql":
+		db, err := sql.Open("pgx", dsn)
+		if err != nil {
+			return nil, err
+		}
+		db.SetMaxOpenConns(pgCfg.MaxConns)
+		db.SetMaxIdleConns(100)
+		db.SetConnMaxLifetime(time.Hour)
+		db.SetConnMaxIdleTime(5 * time.Minute)
+		return postgres.NewSQLDB(db), nil
+	case "pgx":
+		poolCfg, err := pgxpool.ParseConfig(dsn)
+		if err != nil {
+			return nil, err
+		}
+		poolCfg.MaxConns = int32(pgCfg.MaxConns)
+		poolCfg.MaxConnLifetime = time.Hour
+		poolCfg.MaxConnIdleTime = 5 * time.Minute
+		pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+		if err != nil {
+			return nil, err
+		}
+		return postgres.NewPgxDB(pool), nil
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", pgCfg.Driver)
+	}
+}
+
+// components are the parts of the application built by setupApp.
+type components struct {
+	api *controller.APIController
+	// collectors export the state of the components and are registered by
+	// the caller.
+	collectors []prometheus.Collector
+	workers    []Worker
+}
+
+// Register mounts the API on r.
+func (c components) Register(r chi.Router) {
+	c.api.Register(r)
+}
+
+// setupApp wires the API on top of db. logLevel is the level of logger, it is
+// exposed to the admin endpoints.
+func setupApp(logger *zap.Logger, logLevel zap.AtomicLevel, db postgres.DB) (*components, error) {
+	collectors := []prometheus.Collector{metrics.NewDBStatsCollector(db.Stats)}
+
 	userRepository := postgres.NewUserRepository(logger, db)
-	historyRepository := postgres.NewHistoryRepository(logger, db)
-	inventoryRepository := postgres.NewInventoryRepository(logger, db)
+	accountRepository := postgres.NewAccountRepository(logger, db)
+	loginAttemptRepository := postgres.NewLoginAttemptRepository(logger, db)
+	passwordResetRepository := postgres.NewPasswordResetRepository(logger, db)
+
+	auditService := service.NewAuditService(logger, postgres.NewAuditRepository(logger, db))
 
 	jwtService := service.NewJWTService(logger, config.Configuration.JwtSecret)
 
-	authService := service.NewAuthService(logger, userRepository, jwtService)
-	infoService := service.NewInfoService(logger, userRepository, historyRepository, inventoryRepository)
-	coinService := service.NewCoinService(logger, userRepository, inventoryRepository, historyRepository)
+	signupPolicy := service.DefaultSignupPolicy
+	if path := config.Configuration.Signup.PolicyPath; path != "" {
+		var err error
+		if signupPolicy, err = service.LoadSignupPolicy(path); err != nil {
+			return nil, err
+		}
+	}
+
+	authCfg := config.Configuration.Auth
+	authService := service.NewAuthService(logger, userRepository, jwtService, loginAttemptRepository, passwordResetRepository,
+		service.LoginPolicy{
+			MaxFailures:     authCfg.MaxFailures,
+			Window:          authCfg.FailureWindow,
+			LockoutDuration: authCfg.LockoutDuration,
+			FailureDelay:    authCfg.FailureDelay,
+			MaxDelay:        authCfg.MaxFailureDelay,
+		},
+		service.PasswordPolicy{
+			Cost:      authCfg.BcryptCost,
+			MinLength: authCfg.MinPasswordLength,
+			ResetTTL:  authCfg.ResetTokenTTL,
+		},
+		signupPolicy,
+		auditService,
+	)
+	events := event.NewBus()
+
+	limitsCfg := config.Configuration.Limits
+	limitService := service.NewLimitService(logger, postgres.NewLimitRepository(logger, db), userRepository, []entity.SpendingLimit{
+		{Operation: entity.LimitTransfer, Period: entity.LimitDaily, Amount: limitsCfg.TransferDaily},
+		{Operation: entity.LimitTransfer, Period: entity.LimitWeekly, Amount: limitsCfg.TransferWeekly},
+		{Operation: entity.LimitPurchase, Period: entity.LimitDaily, Amount: limitsCfg.PurchaseDaily},
+		{Operation: entity.LimitPurchase, Period: entity.LimitWeekly, Amount: limitsCfg.PurchaseWeekly},
+	}, events, auditService)
+
+	var infoService service.Info = service.NewInfoService(logger, accountRepository, limitService)
+	if cacheCfg := config.Configuration.Cache; cacheCfg.InfoSize > 0 {
+		store := cache.NewLRU[int, *entity.AccountInfo](cacheCfg.InfoSize, cacheCfg.InfoTTL)
+		infoService = service.NewCachedInfoService(logger, infoService, store, events)
+		collectors = append(collectors, metrics.NewCacheCollector("info", store.Stats))
+	}
+	coinService := service.NewCoinService(logger, userRepository, limitService, events)
+
+	adminService := service.NewAdminService(logger, userRepository, postgres.NewAdjustmentRepository(logger, db), events, auditService)
+	ctx, cancel := context.WithTimeout(context.Background(), config.Configuration.Server.ReadinessTimeout)
+	defer cancel()
+	if err := adminService.Bootstrap(ctx, config.Configuration.AdminUsernames); err != nil {
+		return nil, fmt.Errorf("bootstrap admins: %w", err)
+	}
+
+	reconciliationService := service.NewReconciliationService(logger, postgres.NewReconciliationRepository(logger, db), events)
+	jobRunRepository := postgres.NewJobRunRepository(logger, db)
+	jobs, err := setupScheduler(logger, db, jobRunRepository, reconciliationService,
+		service.NewAllowanceService(logger, postgres.NewAllowanceRepository(logger, db),
+			config.Configuration.Allowance.Amount, config.Configuration.Allowance.ExpiryMonths, events),
+	)
+	if err != nil {
+		return nil, err
+	}
+	workers := []Worker{jobs}
+
+	transferCfg := config.Configuration.Transfers
+	scheduledTransferService := service.NewScheduledTransferService(logger, postgres.NewScheduledTransferRepository(logger, db), userRepository, limitService,
+		service.TransferRetryPolicy{MaxFailures: transferCfg.MaxFailures, RetryDelay: transferCfg.RetryDelay},
+		events, auditService,
+	)
+	if transferCfg.PollInterval > 0 {
+		workers = append(workers, scheduler.NewPoller(logger, "scheduled_transfers", transferCfg.PollInterval, scheduledTransferService.RunDue))
+	}
 
-	apiController := controller.NewAPIController(logger, authService, infoService, coinService)
+	rateLimitCfg := config.Configuration.RateLimit
+	apiController := controller.NewAPIController(
+		logger,
+		authService,
+		infoService,
+		coinService,
+		service.NewCoinRequestService(logger, postgres.NewCoinRequestRepository(logger, db), userRepository, limitService,
+			config.Configuration.CoinRequest.TTL, events, auditService),
+		scheduledTransferService,
+		service.NewHoldService(logger, userRepository, limitService, events),
+		limitService,
+		adminService,
+		auditService,
+		reconciliationService,
+		service.NewJobService(logger, jobRunRepository),
+		config.Configuration.Server.RequestTimeout,
+		ratelimit.NewMemoryStore(),
+		ratelimit.Limit{PerMinute: rateLimitCfg.AuthPerMinute, Burst: rateLimitCfg.AuthBurst},
+		ratelimit.Limit{PerMinute: rateLimitCfg.APIPerMinute, Burst: rateLimitCfg.APIBurst},
+		logLevel,
+	)
 
-	return apiController, nil
+	return &components{
+		api:        apiController,
+		collectors: collectors,
+		workers:    workers,
+	}, nil
 }
 
-func Run() {
+// setupScheduler registers the configured jobs. Every replica runs the
+// scheduler, the jobs run on the one elected leader.
+func setupScheduler(
+	logger *zap.Logger,
+	db postgres.DB,
+	runs repository.JobRunRepository,
+	reconciliation service.Reconciliation,
+	allowance service.Allowance,
+) (*scheduler.Scheduler, error) {
+	replica, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("replica name: %w", err)
+	}
+	s := scheduler.New(logger, postgres.NewLeaderRepository(logger, db), runs, replica,
+		config.Configuration.Scheduler.PollInterval)
+
+	if spec := config.Configuration.Reconciliation.Schedule; spec != "" {
+		err = s.Add("reconciliation", spec, func(ctx context.Context) error {
+			_, err := reconciliation.Run(ctx)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	allowanceCfg := config.Configuration.Allowance
+	if allowanceCfg.Amount > 0 && allowanceCfg.Schedule != "" {
+		if err = s.Add("allowance", allowanceCfg.Schedule, allowance.TopUp); err != nil {
+			return nil, err
+		}
+	}
+	if allowanceCfg.ExpiryMonths > 0 && allowanceCfg.ExpirySchedule != "" {
+		if err = s.Add("expiry", allowanceCfg.ExpirySchedule, allowance.Expire); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Worker is a background process whose lifetime is bound to the App. Workers
+// are started after the server begins listening and stopped, in reverse
+// order, after it has drained.
+type Worker interface {
+	Start(ctx context.Context)
+	Stop(ctx context.Context) error
+}
+
+// App is the assembled service: the HTTP server, its background workers and
+// the database pool they share.
+type App struct {
+	l *zap.Logger
+
+	db         postgres.DB
+	server     *http.Server
+	health     service.Health
+	workers    []Worker
+	collectors []prometheus.Collector
+
+	listener net.Listener
+	errs     chan error
+}
+
+// New wires the application on top of an open database pool. The App takes
+// ownership of db and closes it on Stop. logLevel is the level of logger, it
+// is exposed to the admin endpoints.
+func New(logger *zap.Logger, logLevel zap.AtomicLevel, db postgres.DB) (*App, error) {
+	components, err := setupApp(logger, logLevel, db)
+	if err != nil {
+		return nil, err
+	}
+
+	healthService := service.NewHealthService(
+		logger,
+		postgres.NewHealthRepository(logger, db),
+		config.Configuration.Server.ReadinessTimeout,
+	)
+	healthController := controller.NewHealthController(logger, healthService)
+
+	r := chi.NewRouter()
+	healthController.Register(r)
+	components.Register(r)
+	r.Method(http.MethodGet, "/metrics", metrics.Handler())
+
+	for _, c := range components.collectors {
+		if err = metrics.Registry.Register(c); err != nil {
+			return nil, fmt.Errorf("register collector: %w", err)
+		}
+	}
+
+	return &App{
+		l:  logger,
+		db: db,
+		server: &http.Server{
+			Addr:    config.Configuration.Server.RESTAddr,
+			Handler: r,
+		},
+		health:     healthService,
+		workers:    components.workers,
+		collectors: components.collectors,
+		errs:       make(chan error, 1),
+	}, nil
+}
+
+// Start binds the listener and starts serving and the workers in the
+// background. A failure to bind is returned directly, later server failures
+// are delivered through Errors.
+func (a *App) Start() error {
+	listener, err := net.Listen("tcp", a.server.Addr)
+	if err != nil {
+		return err
+	}
+	a.listener = listener
+
+	go func() {
+		err := a.server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.errs <- err
+		}
+	}()
+
+	for _, w := range a.workers {
+		w.Start(context.Background())
+	}
+
+	a.l.Info("server started", zap.String("addr", listener.Addr().String()))
+	return nil
+}
+
+// Addr is the address the server listens on once started.
+func (a *App) Addr() net.Addr {
+	return a.listener.Addr()
+}
+
+// Errors reports a server that stopped serving on its own.
+func (a *App) Errors() <-chan error {
+	return a.errs
+}
+
+// Stop shuts the application down in order: readiness starts failing, the
+// server stops accepting connections and waits for in-flight requests, then
+// the workers are stopped and finally the database pool is closed. ctx bounds
+// the whole drain.
+func (a *App) Stop(ctx context.Context) error {
+	var errs []error
+
+	a.health.Drain()
+	select {
+	case <-time.After(config.Configuration.Server.DrainDelay):
+	case <-ctx.Done():
+	}
+
+	if err := a.server.Shutdown(ctx); err != nil {
+		a.l.Error("failed to drain server", zap.Error(err))
+		errs = append(errs, err)
+	}
+
+	for i := len(a.workers) - 1; i >= 0; i-- {
+		if err := a.workers[i].Stop(ctx); err != nil {
+			a.l.Error("failed to stop worker", zap.Error(err))
+			errs = append(errs, err)
+		}
+	}
+
+	for _, c := range a.collectors {
+		metrics.Registry.Unregister(c)
+	}
+
+	if err := a.db.Close(); err != nil {
+		a.l.Error("failed to close database connection", zap.Error(err))
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// loadConfig reads .env and the environment into config.Configuration.
+func loadConfig() {
 	if err := godotenv.Load(); err != nil {
 		log.Fatalf("Error loading .env file: %v", err)
 	}
-	c := zap.NewProductionConfig()
+	if err := cleanenv.ReadEnv(&config.Configuration); err != nil {
+		log.Fatalf("cannot load configuration: %v", err)
+	}
+}
 
-	c.Level = zap.NewAtomicLevelAt(zap.WarnLevel)
+func databaseDSN() string {
+	pgCfg := config.Configuration.Database
+	return fmt.Sprintf("postgres://%s:%s@%s/%s", pgCfg.Username, pgCfg.Password, pgCfg.Address, pgCfg.DBName)
+}
 
-	logger, err := c.Build()
+// VerifyAudit checks the hash chain of the audit log and returns the exit code
+// of the audit command: 0 for an intact log, 1 for a tampered one and 2 if it
+// could not be checked.
+func VerifyAudit() int {
+	loadConfig()
+
+	logCfg := config.Configuration.Log
+	logger, _, err := logging.New(logCfg.Level, logCfg.Format)
 	if err != nil {
-		fmt.Printf("cannot create zap logger: %v", err)
-		return
+		log.Fatalf("cannot create zap logger: %v", err)
+	}
+	defer func() { _ = logger.Sync() }()
+
+	db, err := openDB(context.Background(), databaseDSN())
+	if err != nil {
+		logger.Error("failed to connect to database", zap.Error(err))
+		return 2
+	}
+	defer func() { _ = db.Close() }()
+
+	auditService := service.NewAuditService(logger, postgres.NewAuditRepository(logger, db))
+	res, err := auditService.Verify(context.Background())
+	if err != nil {
+		logger.Error("failed to verify audit log", zap.Error(err))
+		return 2
+	}
+	if res.BrokenAt != 0 {
+		fmt.Printf("audit log tampered with: entry %d breaks the chain after %d intact entries\n", res.BrokenAt, res.Entries)
+		return 1
+	}
+	fmt.Printf("audit log intact: %d entries\n", res.Entries)
+	return 0
+}
+
+// Reconcile checks every balance against the ledger once, stores the report
+// for approval and prints it as JSON. It returns the exit code of the
+// reconcile command: 0 if the balances match, 1 if they don't and 2 if they
+// could not be checked.
+func Reconcile() int {
+	loadConfig()
+
+	logCfg := config.Configuration.Log
+	logger, _, err := logging.New(logCfg.Level, logCfg.Format)
+	if err != nil {
+		log.Fatalf("cannot create zap logger: %v", err)
+	}
+	defer func() { _ = logger.Sync() }()
+
+	if err = entity.LoadItems(logger, config.Configuration.ItemsPath); err != nil {
+		logger.Error("cannot load items", zap.Error(err))
+		return 2
+	}
+
+	db, err := openDB(context.Background(), databaseDSN())
+	if err != nil {
+		logger.Error("failed to connect to database", zap.Error(err))
+		return 2
+	}
+	defer func() { _ = db.Close() }()
+
+	// nothing is corrected here, so no one listens
+	reconciliationService := service.NewReconciliationService(logger, postgres.NewReconciliationRepository(logger, db), event.NewBus())
+	report, err := reconciliationService.Run(context.Background())
+	if err != nil {
+		logger.Error("failed to reconcile balances", zap.Error(err))
+		return 2
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(controller.NewReconciliationResponse(report)); err != nil {
+		logger.Error("failed to write report", zap.Error(err))
+		return 2
+	}
+	if report.Status == entity.ReconciliationPending {
+		return 1
+	}
+	return 0
+}
+
+func Run() {
+	loadConfig()
+
+	logCfg := config.Configuration.Log
+	logger, logLevel, err := logging.New(logCfg.Level, logCfg.Format)
+	if err != nil {
+		log.Fatalf("cannot create zap logger: %v", err)
 	}
 	defer func(logger *zap.Logger) {
 		err = logger.Sync()
@@ -70,63 +498,67 @@ func Run() {
 		}
 	}(logger)
 
-	err = cleanenv.ReadEnv(&config.Configuration)
+	err = entity.LoadItems(logger, config.Configuration.ItemsPath)
 	if err != nil {
-		logger.Fatal("cannot load configuration", zap.Error(err))
+		logger.Fatal("cannot load items", zap.Error(err))
 		return
 	}
 
-	err = entity.LoadItems(logger, config.Configuration.ItemsPath)
+	tracingCfg := config.Configuration.Tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:     tracingCfg.Exporter,
+		OTLPEndpoint: tracingCfg.OTLPEndpoint,
+		SampleRatio:  tracingCfg.SampleRatio,
+		ServiceName:  tracingCfg.ServiceName,
+	})
 	if err != nil {
-		logger.Fatal("cannot load items", zap.Error(err))
+		logger.Fatal("cannot setup tracing", zap.Error(err))
 		return
 	}
 
-	pgCfg := config.Configuration.Database
-
-	pgAddr := pgCfg.Address
-	pgDB := pgCfg.DBName
-	pgUser := pgCfg.Username
-	pgPass := pgCfg.Password
-
-	dsn := fmt.Sprintf("postgres://%s:%s@%s/%s", pgUser, pgPass, pgAddr, pgDB)
-
-	db, err := sql.Open("pgx", dsn)
+	dsn := databaseDSN()
+	db, err := openDB(context.Background(), dsn)
 	if err != nil {
 		logger.Fatal("failed to connect to database", zap.String("dsn", dsn), zap.Error(err))
 		return
 	}
-	db.SetMaxOpenConns(700)
-	db.SetMaxIdleConns(100)
-	db.SetConnMaxLifetime(time.Hour)
-	db.SetConnMaxIdleTime(5 * time.Minute)
 
 	err = waitForConnection(logger, db)
 
-	apiController, err := setupApp(logger, db)
-	defer func(db *sql.DB) {
-		err = db.Close()
-		if err != nil {
-			logger.Fatal("failed to close database connection", zap.Error(err))
-		}
-	}(db)
+	application, err := New(logger, logLevel, db)
 	if err != nil {
 		logger.Fatal("failed to setup app", zap.Error(err))
 		return
 	}
 
-	r := chi.NewRouter()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	apiController.Register(r)
+	err = application.Start()
+	if err != nil {
+		logger.Fatal("cannot start server", zap.Error(err))
+		return
+	}
 
-	server := http.Server{
-		Addr:    config.Configuration.Server.RESTAddr,
-		Handler: r,
+	select {
+	case <-ctx.Done():
+		logger.Info("shutdown signal received")
+	case err = <-application.Errors():
+		logger.Error("server stopped unexpectedly", zap.Error(err))
 	}
 
-	logger.Info("starting server", zap.String("addr", config.Configuration.Server.RESTAddr))
-	err = server.ListenAndServe()
+	shutdownTimeout := config.Configuration.Server.ShutdownTimeout
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err = application.Stop(shutdownCtx)
+	// spans of the drained requests are flushed last
+	if tracingErr := shutdownTracing(shutdownCtx); tracingErr != nil {
+		logger.Error("failed to flush traces", zap.Error(tracingErr))
+	}
 	if err != nil {
-		logger.Fatal("cannot start server", zap.Error(err))
+		logger.Error("unclean shutdown", zap.Error(err))
+		return
 	}
+	logger.Info("server stopped")
 }
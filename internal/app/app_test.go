@@ -4,7 +4,9 @@ import (
 	"AvitoTech/internal/config"
 	"AvitoTech/internal/controller"
 	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository/postgres"
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -20,13 +22,16 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 var (
-	db        *sql.DB
-	pool      *dockertest.Pool
-	itemsPath = "../config/items.json"
+	db          *sql.DB
+	databaseURL string
+	pool        *dockertest.Pool
+	itemsPath   = "../config/items.json"
 )
 
 func TestMain(m *testing.M) {
@@ -73,7 +78,7 @@ func TestMain(m *testing.M) {
 	}
 
 	hostAndPort := resource.GetHostPort("5432/tcp")
-	databaseURL := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", pgCfg.Username, pgCfg.Password, hostAndPort, pgCfg.DBName)
+	databaseURL = fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", pgCfg.Username, pgCfg.Password, hostAndPort, pgCfg.DBName)
 
 	if err = pool.Retry(func() error {
 		var err error
@@ -92,18 +97,136 @@ func TestMain(m *testing.M) {
 		user_id SERIAL PRIMARY KEY,
 		username TEXT NOT NULL,
 		password TEXT NOT NULL,
-		balance INTEGER NOT NULL
+		balance INTEGER NOT NULL,
+		signup_balance INTEGER NOT NULL DEFAULT 1000,
+		held INTEGER NOT NULL DEFAULT 0 CHECK (held >= 0),
+		token_version INTEGER NOT NULL DEFAULT 0,
+		role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin', 'auditor')),
+		disabled_at TIMESTAMPTZ,
+		-- deleted users keep their row, so that the history naming them stays
+		-- intact
+		deleted_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		CHECK (held <= balance)
 	);
 	CREATE TABLE IF NOT EXISTS inventory (
 		id SERIAL PRIMARY KEY,
 		owner_id INTEGER NOT NULL,
-		item TEXT NOT NULL
+		item TEXT NOT NULL,
+		price INTEGER,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
 	);
 	CREATE TABLE IF NOT EXISTS history (
 		id SERIAL PRIMARY KEY,
 		sender_name TEXT NOT NULL,
 		receiver_name TEXT NOT NULL,
-		amount INTEGER
+		amount INTEGER,
+		-- reason explains operations of the system account and released holds,
+		-- it is NULL for other transfers between users
+		reason TEXT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE TABLE IF NOT EXISTS login_failures (
+		id BIGSERIAL PRIMARY KEY,
+		username TEXT NOT NULL,
+		ip TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE TABLE IF NOT EXISTS lockouts (
+		id SERIAL PRIMARY KEY,
+		username TEXT NOT NULL,
+		ip TEXT NOT NULL,
+		failures INTEGER NOT NULL,
+		locked_until TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		unlocked_at TIMESTAMPTZ,
+		unlocked_by TEXT
+	);
+	CREATE TABLE IF NOT EXISTS password_reset_tokens (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		expires_at TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		used_at TIMESTAMPTZ
+	);
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id BIGSERIAL PRIMARY KEY,
+		created_at TIMESTAMPTZ NOT NULL,
+		actor TEXT NOT NULL,
+		action TEXT NOT NULL,
+		target TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		prev_hash TEXT NOT NULL,
+		hash TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS reconciliations (
+		id SERIAL PRIMARY KEY,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		users INTEGER NOT NULL,
+		status TEXT NOT NULL CHECK (status IN ('clean', 'pending', 'applied')),
+		approved_by TEXT,
+		approved_at TIMESTAMPTZ
+	);
+	CREATE TABLE IF NOT EXISTS reconciliation_discrepancies (
+		reconciliation_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		username TEXT NOT NULL,
+		balance INTEGER NOT NULL,
+		expected INTEGER NOT NULL,
+		corrected BOOLEAN NOT NULL DEFAULT false,
+		PRIMARY KEY (reconciliation_id, user_id)
+	);
+	CREATE TABLE IF NOT EXISTS job_runs (
+		id BIGSERIAL PRIMARY KEY,
+		job TEXT NOT NULL,
+		scheduled_at TIMESTAMPTZ NOT NULL,
+		started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		finished_at TIMESTAMPTZ,
+		error TEXT,
+		replica TEXT NOT NULL,
+		UNIQUE (job, scheduled_at)
+	);
+	CREATE TABLE IF NOT EXISTS coin_requests (
+		id BIGSERIAL PRIMARY KEY,
+		requester_id INTEGER NOT NULL,
+		payer_id INTEGER NOT NULL,
+		amount INTEGER NOT NULL CHECK (amount > 0),
+		memo TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined')),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		expires_at TIMESTAMPTZ NOT NULL,
+		resolved_at TIMESTAMPTZ
+	);
+	CREATE TABLE IF NOT EXISTS scheduled_transfers (
+		id BIGSERIAL PRIMARY KEY,
+		sender_id INTEGER NOT NULL,
+		receiver_id INTEGER NOT NULL,
+		amount INTEGER NOT NULL CHECK (amount > 0),
+		schedule TEXT,
+		status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'completed', 'cancelled', 'failed')),
+		next_run_at TIMESTAMPTZ,
+		last_run_at TIMESTAMPTZ,
+		failures INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE TABLE IF NOT EXISTS holds (
+		id BIGSERIAL PRIMARY KEY,
+		sponsor_id INTEGER NOT NULL,
+		amount INTEGER NOT NULL CHECK (amount > 0),
+		memo TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'released', 'cancelled')),
+		recipient_id INTEGER,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		resolved_at TIMESTAMPTZ
+	);
+	CREATE TABLE IF NOT EXISTS spending_limits (
+		user_id INTEGER NOT NULL,
+		operation TEXT NOT NULL CHECK (operation IN ('transfer', 'purchase')),
+		period TEXT NOT NULL CHECK (period IN ('daily', 'weekly')),
+		amount INTEGER NOT NULL CHECK (amount >= 0),
+		PRIMARY KEY (user_id, operation, period)
 	);
 	`)
 	if err != nil {
@@ -135,14 +258,14 @@ func TestApiAuth(t *testing.T) {
 	err := entity.LoadItems(logger, itemsPath)
 	require.NoError(t, err)
 
-	apiController, err := setupApp(logger, db)
+	routes, err := setupApp(logger, zap.NewAtomicLevel(), postgres.NewSQLDB(db))
 	if err != nil {
 		logger.Warn("Failed to create api controller", zap.Error(err))
 		return
 	}
 
 	r := chi.NewRouter()
-	apiController.Register(r)
+	routes.Register(r)
 
 	server := httptest.NewServer(r)
 	defer server.Close()
@@ -185,14 +308,14 @@ func TestApiInfo(t *testing.T) {
 	err := entity.LoadItems(logger, itemsPath)
 	require.NoError(t, err)
 
-	apiController, err := setupApp(logger, db)
+	routes, err := setupApp(logger, zap.NewAtomicLevel(), postgres.NewSQLDB(db))
 	if err != nil {
 		logger.Warn("Failed to create api controller", zap.Error(err))
 		return
 	}
 
 	r := chi.NewRouter()
-	apiController.Register(r)
+	routes.Register(r)
 
 	server := httptest.NewServer(r)
 	defer server.Close()
@@ -252,14 +375,14 @@ func TestApiAuth_InvalidCredentials(t *testing.T) {
 	err := entity.LoadItems(logger, itemsPath)
 	require.NoError(t, err)
 
-	apiController, err := setupApp(logger, db)
+	routes, err := setupApp(logger, zap.NewAtomicLevel(), postgres.NewSQLDB(db))
 	if err != nil {
 		logger.Warn("Failed to create api controller", zap.Error(err))
 		return
 	}
 
 	r := chi.NewRouter()
-	apiController.Register(r)
+	routes.Register(r)
 
 	server := httptest.NewServer(r)
 	defer server.Close()
@@ -309,14 +432,14 @@ func TestApiBuyItem(t *testing.T) {
 	err := entity.LoadItems(logger, itemsPath)
 	require.NoError(t, err)
 
-	apiController, err := setupApp(logger, db)
+	routes, err := setupApp(logger, zap.NewAtomicLevel(), postgres.NewSQLDB(db))
 	if err != nil {
 		logger.Warn("Failed to create api controller", zap.Error(err))
 		return
 	}
 
 	r := chi.NewRouter()
-	apiController.Register(r)
+	routes.Register(r)
 
 	server := httptest.NewServer(r)
 	defer server.Close()
@@ -371,14 +494,14 @@ func TestApiSendCoin(t *testing.T) {
 	err := entity.LoadItems(logger, itemsPath)
 	require.NoError(t, err)
 
-	apiController, err := setupApp(logger, db)
+	routes, err := setupApp(logger, zap.NewAtomicLevel(), postgres.NewSQLDB(db))
 	if err != nil {
 		logger.Warn("Failed to create api controller", zap.Error(err))
 		return
 	}
 
 	r := chi.NewRouter()
-	apiController.Register(r)
+	routes.Register(r)
 
 	server := httptest.NewServer(r)
 	defer server.Close()
@@ -456,14 +579,14 @@ func TestApiInfo_Unauthorized(t *testing.T) {
 	err := entity.LoadItems(logger, itemsPath)
 	require.NoError(t, err)
 
-	apiController, err := setupApp(logger, db)
+	routes, err := setupApp(logger, zap.NewAtomicLevel(), postgres.NewSQLDB(db))
 	if err != nil {
 		logger.Warn("Failed to create api controller", zap.Error(err))
 		return
 	}
 
 	r := chi.NewRouter()
-	apiController.Register(r)
+	routes.Register(r)
 
 	server := httptest.NewServer(r)
 	defer server.Close()
@@ -484,3 +607,277 @@ func TestApiInfo_Unauthorized(t *testing.T) {
 
 	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
 }
+
+func TestAppStartStop(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+
+	err := entity.LoadItems(logger, itemsPath)
+	require.NoError(t, err)
+
+	// the app owns and closes its pool, so it gets one of its own
+	appDB, err := sql.Open("pgx", databaseURL)
+	require.NoError(t, err)
+
+	restAddr := config.Configuration.Server.RESTAddr
+	config.Configuration.Server.RESTAddr = "127.0.0.1:0"
+	defer func() {
+		config.Configuration.Server.RESTAddr = restAddr
+	}()
+
+	application, err := New(logger, zap.NewAtomicLevel(), postgres.NewSQLDB(appDB))
+	require.NoError(t, err)
+	require.NoError(t, application.Start())
+
+	url := "http://" + application.Addr().String()
+	body, _ := json.Marshal(controller.AuthRequest{Username: "lifecycle", Password: "password"})
+	resp, err := http.Post(url+"/api/auth", "application/json", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, application.Stop(ctx))
+
+	_, err = http.Post(url+"/api/auth", "application/json", bytes.NewBuffer(body))
+	assert.Error(t, err)
+	assert.Error(t, appDB.Ping())
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+
+	err := entity.LoadItems(logger, itemsPath)
+	require.NoError(t, err)
+
+	appDB, err := sql.Open("pgx", databaseURL)
+	require.NoError(t, err)
+
+	restAddr := config.Configuration.Server.RESTAddr
+	config.Configuration.Server.RESTAddr = "127.0.0.1:0"
+	defer func() {
+		config.Configuration.Server.RESTAddr = restAddr
+	}()
+
+	application, err := New(logger, zap.NewAtomicLevel(), postgres.NewSQLDB(appDB))
+	require.NoError(t, err)
+	require.NoError(t, application.Start())
+
+	server := httptest.NewServer(application.server.Handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/healthz")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	resp, err = http.Get(server.URL + "/readyz")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, application.Stop(ctx))
+
+	// the handler outlives the app's own listener, readiness must now fail
+	resp, err = http.Get(server.URL + "/readyz")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	resp, err = http.Get(server.URL + "/healthz")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+
+	err := entity.LoadItems(logger, itemsPath)
+	require.NoError(t, err)
+
+	appDB, err := sql.Open("pgx", databaseURL)
+	require.NoError(t, err)
+
+	application, err := New(logger, zap.NewAtomicLevel(), postgres.NewSQLDB(appDB))
+	require.NoError(t, err)
+
+	server := httptest.NewServer(application.server.Handler)
+	defer server.Close()
+
+	body, _ := json.Marshal(controller.AuthRequest{Username: "metricsuser", Password: "metricspassword"})
+	resp, err := http.Post(server.URL+"/api/auth", "application/json", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	resp, err = http.Get(server.URL + "/metrics")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	exposition, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	assert.Contains(t, string(exposition), `avito_shop_http_request_duration_seconds_count{method="POST",route="/api/auth",status="200"}`)
+	assert.Contains(t, string(exposition), "avito_shop_signups_total")
+	assert.Contains(t, string(exposition), "avito_shop_db_open_connections")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, application.Stop(ctx))
+}
+
+func TestRequestIDAndLogLevel(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+
+	err := entity.LoadItems(logger, itemsPath)
+	require.NoError(t, err)
+
+	// the admin signs up before the application that promotes it starts
+	routes, err := setupApp(logger, zap.NewAtomicLevel(), postgres.NewSQLDB(db))
+	require.NoError(t, err)
+	r := chi.NewRouter()
+	routes.Register(r)
+	signupServer := httptest.NewServer(r)
+	signIn(t, signupServer.URL, "logadmin", "logadminpassword")
+	signupServer.Close()
+
+	adminUsernames := config.Configuration.AdminUsernames
+	config.Configuration.AdminUsernames = []string{"logadmin", "nobody"}
+	defer func() {
+		config.Configuration.AdminUsernames = adminUsernames
+	}()
+
+	appDB, err := sql.Open("pgx", databaseURL)
+	require.NoError(t, err)
+
+	logLevel := zap.NewAtomicLevelAt(zap.WarnLevel)
+	application, err := New(logger, logLevel, postgres.NewSQLDB(appDB))
+	require.NoError(t, err)
+
+	server := httptest.NewServer(application.server.Handler)
+	defer server.Close()
+
+	// a caller supplied ID is propagated, a missing one is generated
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/info", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Request-ID", "req-42")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, "req-42", resp.Header.Get("X-Request-ID"))
+	require.NoError(t, resp.Body.Close())
+
+	resp, err = http.Get(server.URL + "/api/info")
+	require.NoError(t, err)
+	assert.Len(t, resp.Header.Get("X-Request-ID"), 32)
+	require.NoError(t, resp.Body.Close())
+
+	// the log level endpoint needs the admin role
+	for token, status := range map[string]int{
+		signIn(t, server.URL, "loguser", "loguserpassword"):   http.StatusForbidden,
+		signIn(t, server.URL, "logadmin", "logadminpassword"): http.StatusOK,
+	} {
+		req, err = http.NewRequest(http.MethodPut, server.URL+"/api/admin/log/level", strings.NewReader(`{"level":"debug"}`))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		assert.Equal(t, status, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+	}
+	assert.Equal(t, zap.DebugLevel, logLevel.Level())
+
+	// the change is in the audit log, whose chain is intact
+	adminToken := signIn(t, server.URL, "logadmin", "logadminpassword")
+	req, err = http.NewRequest(http.MethodGet, server.URL+"/api/admin/audit?actor=logadmin&action="+entity.AuditSetLogLevel, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var audit controller.AuditListResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&audit))
+	require.NoError(t, resp.Body.Close())
+	require.Len(t, *audit.Entries, 1)
+	assert.JSONEq(t, `{"from":"warn","to":"debug"}`, string((*audit.Entries)[0].Payload))
+
+	req, err = http.NewRequest(http.MethodGet, server.URL+"/api/admin/audit/verify", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var verification controller.AuditVerificationResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&verification))
+	require.NoError(t, resp.Body.Close())
+	assert.True(t, *verification.Intact)
+
+	req, err = http.NewRequest(http.MethodGet, server.URL+"/api/admin/jobs/runs?job=reconciliation", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var jobRuns controller.JobRunListResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobRuns))
+	require.NoError(t, resp.Body.Close())
+	assert.NotNil(t, jobRuns.Runs)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, application.Stop(ctx))
+}
+
+// signIn signs up or in through /api/auth and returns the token.
+func signIn(t *testing.T, serverURL, username, password string) string {
+	t.Helper()
+
+	body, _ := json.Marshal(controller.AuthRequest{Username: username, Password: password})
+	resp, err := http.Post(serverURL+"/api/auth", "application/json", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var authResponse controller.AuthResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&authResponse))
+	return *authResponse.Token
+}
+
+func TestApiAuth_RateLimited(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+
+	err := entity.LoadItems(logger, itemsPath)
+	require.NoError(t, err)
+
+	rateLimit := config.Configuration.RateLimit
+	config.Configuration.RateLimit.AuthPerMinute = 1
+	config.Configuration.RateLimit.AuthBurst = 2
+	defer func() {
+		config.Configuration.RateLimit = rateLimit
+	}()
+
+	routes, err := setupApp(logger, zap.NewAtomicLevel(), postgres.NewSQLDB(db))
+	require.NoError(t, err)
+
+	r := chi.NewRouter()
+	routes.Register(r)
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	body, _ := json.Marshal(controller.AuthRequest{Username: "limiteduser", Password: "limitedpassword"})
+	for i := 0; i < 2; i++ {
+		resp, err := http.Post(server.URL+"/api/auth", "application/json", bytes.NewBuffer(body))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+	}
+
+	resp, err := http.Post(server.URL+"/api/auth", "application/json", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "60", resp.Header.Get("Retry-After"))
+	require.NoError(t, resp.Body.Close())
+}
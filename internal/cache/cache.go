@@ -0,0 +1,30 @@
+// Package cache provides the read-through cache stores used by services.
+package cache
+
+import "context"
+
+// Store is a key-value cache. Implementations must be safe for concurrent use.
+// The in-process LRU is the default; a shared external store can be plugged in
+// by implementing the same interface. Store errors are not reported: a store
+// that can't serve a key answers with a miss.
+type Store[K comparable, V any] interface {
+	Get(ctx context.Context, key K) (V, bool)
+	Set(ctx context.Context, key K, value V)
+	Delete(ctx context.Context, keys ...K)
+	Stats() Stats
+}
+
+// Stats are the cumulative lookup counters of a store.
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// HitRatio is the share of lookups served from the cache.
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU_GetSet(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU[int, string](2, 0)
+
+	c.Set(ctx, 1, "one")
+	value, ok := c.Get(ctx, 1)
+	assert.True(t, ok)
+	assert.Equal(t, "one", value)
+
+	_, ok = c.Get(ctx, 2)
+	assert.False(t, ok)
+
+	assert.Equal(t, Stats{Hits: 1, Misses: 1}, c.Stats())
+	assert.Equal(t, 0.5, c.Stats().HitRatio())
+}
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU[int, string](2, 0)
+
+	c.Set(ctx, 1, "one")
+	c.Set(ctx, 2, "two")
+	c.Get(ctx, 1)
+	c.Set(ctx, 3, "three")
+
+	_, ok := c.Get(ctx, 2)
+	assert.False(t, ok)
+	_, ok = c.Get(ctx, 1)
+	assert.True(t, ok)
+	_, ok = c.Get(ctx, 3)
+	assert.True(t, ok)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestLRU_Expiry(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU[int, string](2, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Set(ctx, 1, "one")
+	_, ok := c.Get(ctx, 1)
+	assert.True(t, ok)
+
+	now = now.Add(2 * time.Minute)
+	_, ok = c.Get(ctx, 1)
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestLRU_Delete(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU[int, string](4, 0)
+
+	c.Set(ctx, 1, "one")
+	c.Set(ctx, 2, "two")
+	c.Delete(ctx, 1, 2, 3)
+
+	_, ok := c.Get(ctx, 1)
+	assert.False(t, ok)
+	_, ok = c.Get(ctx, 2)
+	assert.False(t, ok)
+}
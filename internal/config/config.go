@@ -1,11 +1,28 @@
 // Package config provides config struct which are should be loaded from .env
 package config
 
+import "time"
+
 type Config struct {
 	JwtSecret string `env:"JWT_SECRET" env-required:"true"`
 	ItemsPath string `env:"ITEMS_PATH" env-required:"true"`
-	Database  databaseConfig
-	Server    serverConfig
+	// AdminUsernames are given the admin role at startup, the users have to
+	// have signed up before.
+	AdminUsernames []string `env:"ADMIN_USERNAMES" env-separator:","`
+	Database       databaseConfig
+	Server         serverConfig
+	Cache          cacheConfig
+	Tracing        tracingConfig
+	Log            logConfig
+	RateLimit      rateLimitConfig
+	Auth           authConfig
+	Signup         signupConfig
+	Reconciliation reconciliationConfig
+	Scheduler      schedulerConfig
+	Allowance      allowanceConfig
+	CoinRequest    coinRequestConfig
+	Transfers      scheduledTransferConfig
+	Limits         limitsConfig
 }
 
 type databaseConfig struct {
@@ -13,10 +30,125 @@ type databaseConfig struct {
 	DBName   string `env:"DATABASE_DB_NAME" env-required:"true"`
 	Username string `env:"DATABASE_USERNAME" env-required:"true"`
 	Password string `env:"DATABASE_PASSWORD" env-required:"true"`
+	// Driver selects the pool implementation: "sql" for database/sql or "pgx"
+	// for a native pgx pool.
+	Driver   string `env:"DATABASE_DRIVER" env-default:"sql"`
+	MaxConns int    `env:"DATABASE_MAX_CONNS" env-default:"700"`
 }
 
 type serverConfig struct {
-	RESTAddr string `env:"SERVER_REST_ADDR" env-required:"true"`
+	RESTAddr       string        `env:"SERVER_REST_ADDR" env-required:"true"`
+	RequestTimeout time.Duration `env:"SERVER_REQUEST_TIMEOUT" env-default:"5s"`
+	// ShutdownTimeout bounds the graceful drain after SIGTERM.
+	ShutdownTimeout time.Duration `env:"SERVER_SHUTDOWN_TIMEOUT" env-default:"30s"`
+	// DrainDelay is how long /readyz reports failure before the server stops
+	// accepting connections, giving the orchestrator time to notice.
+	DrainDelay       time.Duration `env:"SERVER_DRAIN_DELAY" env-default:"0s"`
+	ReadinessTimeout time.Duration `env:"SERVER_READINESS_TIMEOUT" env-default:"2s"`
+}
+
+type cacheConfig struct {
+	// InfoSize is the number of account overviews kept in memory, 0 disables
+	// the cache.
+	InfoSize int           `env:"CACHE_INFO_SIZE" env-default:"10000"`
+	InfoTTL  time.Duration `env:"CACHE_INFO_TTL" env-default:"1m"`
+}
+
+type tracingConfig struct {
+	// Exporter is where spans are sent: "none", "stdout" or "otlp".
+	Exporter     string  `env:"TRACING_EXPORTER" env-default:"none"`
+	OTLPEndpoint string  `env:"TRACING_OTLP_ENDPOINT" env-default:"http://localhost:4318"`
+	SampleRatio  float64 `env:"TRACING_SAMPLE_RATIO" env-default:"1"`
+	ServiceName  string  `env:"TRACING_SERVICE_NAME" env-default:"avito-shop"`
+}
+
+type logConfig struct {
+	// Level is the initial level, it can be changed at runtime through
+	// /api/admin/log/level.
+	Level  string `env:"LOG_LEVEL" env-default:"info"`
+	Format string `env:"LOG_FORMAT" env-default:"json"`
+}
+
+// rateLimitConfig holds the token buckets of the route groups. A zero rate
+// disables limiting for the group.
+type rateLimitConfig struct {
+	// AuthPerMinute limits /api/auth per client IP.
+	AuthPerMinute int `env:"RATE_LIMIT_AUTH_PER_MINUTE" env-default:"30"`
+	AuthBurst     int `env:"RATE_LIMIT_AUTH_BURST" env-default:"10"`
+	// APIPerMinute limits the authenticated routes per user.
+	APIPerMinute int `env:"RATE_LIMIT_API_PER_MINUTE" env-default:"600"`
+	APIBurst     int `env:"RATE_LIMIT_API_BURST" env-default:"100"`
+}
+
+// authConfig is the brute-force protection of /api/auth.
+type authConfig struct {
+	// MaxFailures wrong passwords within FailureWindow lock the account for
+	// LockoutDuration, 0 disables lockouts.
+	MaxFailures     int           `env:"AUTH_MAX_FAILURES" env-default:"5"`
+	FailureWindow   time.Duration `env:"AUTH_FAILURE_WINDOW" env-default:"15m"`
+	LockoutDuration time.Duration `env:"AUTH_LOCKOUT_DURATION" env-default:"15m"`
+	// FailureDelay is the delay after a recent failure, doubled with every
+	// further one up to MaxFailureDelay.
+	FailureDelay    time.Duration `env:"AUTH_FAILURE_DELAY" env-default:"250ms"`
+	MaxFailureDelay time.Duration `env:"AUTH_MAX_FAILURE_DELAY" env-default:"4s"`
+	// BcryptCost applies to new hashes, older ones are rehashed on login.
+	BcryptCost        int           `env:"AUTH_BCRYPT_COST" env-default:"10"`
+	MinPasswordLength int           `env:"AUTH_MIN_PASSWORD_LENGTH" env-default:"8"`
+	ResetTokenTTL     time.Duration `env:"AUTH_RESET_TOKEN_TTL" env-default:"1h"`
+}
+
+type signupConfig struct {
+	// PolicyPath is a JSON file with the starting balance, welcome items and
+	// campaigns of new users. Without it users start with 1000 coins.
+	PolicyPath string `env:"SIGNUP_POLICY_PATH"`
+}
+
+// The schedules below are cron specs or descriptors such as @daily, see
+// the scheduler package. An empty schedule disables the job.
+
+type reconciliationConfig struct {
+	// Schedule is when the balances are checked against the ledger.
+	Schedule string `env:"RECONCILIATION_SCHEDULE" env-default:"@daily"`
+}
+
+type schedulerConfig struct {
+	// PollInterval is how often the replicas try to take the leadership and
+	// the leader looks for due jobs.
+	PollInterval time.Duration `env:"SCHEDULER_POLL_INTERVAL" env-default:"10s"`
+}
+
+type allowanceConfig struct {
+	// Amount is granted to every active user on Schedule, 0 disables the
+	// allowance.
+	Amount   int    `env:"ALLOWANCE_AMOUNT" env-default:"0"`
+	Schedule string `env:"ALLOWANCE_SCHEDULE" env-default:"@monthly"`
+	// ExpiryMonths is the age at which unspent coins expire, 0 disables the
+	// expiry.
+	ExpiryMonths   int    `env:"EXPIRY_MONTHS" env-default:"0"`
+	ExpirySchedule string `env:"EXPIRY_SCHEDULE" env-default:"@daily"`
+}
+
+type coinRequestConfig struct {
+	// TTL is how long a request for coins waits for the payer.
+	TTL time.Duration `env:"COIN_REQUEST_TTL" env-default:"72h"`
+}
+
+type scheduledTransferConfig struct {
+	// PollInterval is how often every replica looks for due transfers, 0
+	// disables running them.
+	PollInterval time.Duration `env:"SCHEDULED_TRANSFER_POLL_INTERVAL" env-default:"30s"`
+	// MaxFailures consecutive failed runs give a transfer up.
+	MaxFailures int           `env:"SCHEDULED_TRANSFER_MAX_FAILURES" env-default:"3"`
+	RetryDelay  time.Duration `env:"SCHEDULED_TRANSFER_RETRY_DELAY" env-default:"1h"`
+}
+
+// limitsConfig caps what every user sends and spends within the last day
+// and week, 0 for no limit. Admins can override the limits per user.
+type limitsConfig struct {
+	TransferDaily  int `env:"LIMIT_TRANSFER_DAILY" env-default:"0"`
+	TransferWeekly int `env:"LIMIT_TRANSFER_WEEKLY" env-default:"0"`
+	PurchaseDaily  int `env:"LIMIT_PURCHASE_DAILY" env-default:"0"`
+	PurchaseWeekly int `env:"LIMIT_PURCHASE_WEEKLY" env-default:"0"`
 }
 
 var Configuration Config
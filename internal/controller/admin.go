@@ -0,0 +1,350 @@
+package controller
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"AvitoTech/internal/service"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+)
+
+// registerAdmin mounts the operator endpoints. r is expected to authenticate
+// the caller already, every route checks its own permission.
+func (a APIController) registerAdmin(r chi.Router) {
+	r.Route("/api/admin", func(r chi.Router) {
+		r.With(a.authorize(entity.PermissionViewSettings)).Get("/log/level", a.logLevel.ServeHTTP)
+		r.With(a.authorize(entity.PermissionManageSettings)).Put("/log/level", a.setLogLevel)
+		r.With(a.authorize(entity.PermissionViewSettings)).Get("/jobs/runs", a.listJobRuns)
+
+		r.Group(func(r chi.Router) {
+			r.Use(a.authorize(entity.PermissionViewUsers))
+
+			r.Get("/users", a.listUsers)
+			r.Get("/users/{username}", a.getUser)
+			r.Get("/users/{username}/limits", a.getUserLimits)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(a.authorize(entity.PermissionManageUsers))
+
+			r.Post("/users/{username}/unlock", a.unlockUser)
+			r.Post("/users/{username}/password-reset", a.issuePasswordReset)
+			r.Post("/users/{username}/disable", a.setDisabled(true))
+			r.Post("/users/{username}/enable", a.setDisabled(false))
+			r.Delete("/users/{username}", a.deleteUser)
+		})
+
+		r.With(a.authorize(entity.PermissionManageRoles)).Put("/users/{username}/role", a.setRole)
+
+		r.Group(func(r chi.Router) {
+			r.Use(a.authorize(entity.PermissionManageCoins))
+
+			r.Post("/grants", a.adjustBalances(1))
+			r.Post("/deductions", a.adjustBalances(-1))
+			r.Put("/users/{username}/limits/{operation}/{period}", a.setUserLimit)
+			r.Delete("/users/{username}/limits/{operation}/{period}", a.clearUserLimit)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(a.authorize(entity.PermissionViewAudit))
+
+			r.Get("/audit", a.listAudit)
+			r.Get("/audit/verify", a.verifyAudit)
+			r.Get("/reconciliations", a.listReconciliations)
+			r.Get("/reconciliations/{id}", a.getReconciliation)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(a.authorize(entity.PermissionManageCoins))
+
+			r.Post("/reconciliations", a.runReconciliation)
+			r.Post("/reconciliations/{id}/approve", a.approveReconciliation)
+		})
+	})
+}
+
+// setLogLevel changes the level of every logger of the process. The body is
+// {"level": "debug"}, the response is the level now in effect.
+func (a APIController) setLogLevel(w http.ResponseWriter, r *http.Request) {
+	previous := a.logLevel.Level()
+	a.logLevel.ServeHTTP(w, r)
+	if current := a.logLevel.Level(); current != previous {
+		actor := claims(r.Context()).Username
+		a.l.Warn("log level changed",
+			zap.Stringer("from", previous),
+			zap.Stringer("to", current),
+			zap.String("by", actor),
+		)
+		a.audit.Record(r.Context(), actor, entity.AuditSetLogLevel, "", map[string]string{
+			"from": previous.String(),
+			"to":   current.String(),
+		})
+	}
+}
+
+// unlockUser lifts the login lockout of a user ahead of time.
+func (a APIController) unlockUser(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+
+	err := a.auth.Unlock(r.Context(), username, claims(r.Context()).Username)
+	if err != nil {
+		if errors.Is(err, service.ErrNotLocked) {
+			a.writeError(w, http.StatusNotFound, "Account is not locked")
+			return
+		}
+		a.writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// issuePasswordReset creates a single-use reset token to be handed to the
+// user, who redeems it at /api/auth/password-reset.
+func (a APIController) issuePasswordReset(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+
+	token, expiresAt, err := a.auth.IssuePasswordReset(r.Context(), username, claims(r.Context()).Username)
+	if err != nil {
+		if errors.Is(err, repository.ErrorUserNotFound) {
+			a.writeError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		a.writeServiceError(w, err)
+		return
+	}
+
+	jsonResp, _ := json.Marshal(PasswordResetTokenResponse{Token: &token, ExpiresAt: &expiresAt})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if _, err = w.Write(jsonResp); err != nil {
+		a.l.Error("Failed to write response", zap.Error(err))
+	}
+}
+
+// setRole gives a user one of the roles user, admin or auditor. The body is
+// {"role": "auditor"}.
+func (a APIController) setRole(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+
+	var req SetRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Role == "" {
+		a.writeError(w, http.StatusBadRequest, "Invalid request: missing role")
+		return
+	}
+
+	err := a.admin.SetRole(r.Context(), username, entity.Role(req.Role), claims(r.Context()).Username)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidRole):
+			a.writeError(w, http.StatusBadRequest, "Unknown role")
+		case errors.Is(err, repository.ErrorUserNotFound):
+			a.writeError(w, http.StatusNotFound, "User not found")
+		default:
+			a.writeServiceError(w, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// adjustBalances grants coins to or, with sign -1, deducts them from the
+// listed users. The body is {"usernames": [...], "amount": 100, "reason": "..."}
+// with a positive amount, nothing changes unless every user can be adjusted.
+func (a APIController) adjustBalances(sign int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req AdjustmentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			a.writeError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if req.Amount <= 0 {
+			a.writeError(w, http.StatusBadRequest, "Amount must be positive")
+			return
+		}
+
+		err := a.admin.AdjustBalances(r.Context(), entity.Adjustment{
+			Usernames: req.Usernames,
+			Amount:    sign * req.Amount,
+			Reason:    req.Reason,
+		}, claims(r.Context()).Username)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrReasonRequired):
+				a.writeError(w, http.StatusBadRequest, "Reason is required")
+			case errors.Is(err, service.ErrNoUsers):
+				a.writeError(w, http.StatusBadRequest, "Usernames are required")
+			case errors.Is(err, repository.ErrorUserNotFound):
+				a.writeError(w, http.StatusNotFound, err.Error())
+			case errors.Is(err, repository.ErrorInsufficientBalance):
+				a.writeError(w, http.StatusBadRequest, err.Error())
+			default:
+				a.writeServiceError(w, err)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// listUsers returns a page of users. Query parameters: prefix of the username,
+// limit and offset of the page and deleted=true to include deleted users.
+func (a APIController) listUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := entity.UserFilter{
+		Prefix:         query.Get("prefix"),
+		IncludeDeleted: query.Get("deleted") == "true",
+		Limit:          20,
+	}
+	for name, dst := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				a.writeError(w, http.StatusBadRequest, "Invalid "+name)
+				return
+			}
+			*dst = n
+		}
+	}
+
+	users, total, err := a.admin.ListUsers(r.Context(), filter)
+	if err != nil {
+		a.writeServiceError(w, err)
+		return
+	}
+
+	records := make([]UserRecord, len(users))
+	for i := range users {
+		records[i] = userRecord(&users[i])
+	}
+	a.writeJSON(w, http.StatusOK, UserListResponse{Users: &records, Total: &total})
+}
+
+func (a APIController) getUser(w http.ResponseWriter, r *http.Request) {
+	user, err := a.admin.GetUser(r.Context(), chi.URLParam(r, "username"))
+	if err != nil {
+		if errors.Is(err, repository.ErrorUserNotFound) {
+			a.writeError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		a.writeServiceError(w, err)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, userRecord(user))
+}
+
+// setDisabled bans a user from signing in and from the API, or lifts the ban.
+func (a APIController) setDisabled(disabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := a.admin.SetDisabled(r.Context(), chi.URLParam(r, "username"), disabled, claims(r.Context()).Username)
+		if err != nil {
+			a.writeUserManagementError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// deleteUser soft-deletes a user: the history naming the user stays.
+func (a APIController) deleteUser(w http.ResponseWriter, r *http.Request) {
+	err := a.admin.DeleteUser(r.Context(), chi.URLParam(r, "username"), claims(r.Context()).Username)
+	if err != nil {
+		a.writeUserManagementError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listAudit returns entries of the audit log, newest first. Query parameters:
+// actor, action and target to filter on, limit of the page and before_id, the
+// ID of the last entry of the previous page.
+func (a APIController) listAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := entity.AuditFilter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Target: query.Get("target"),
+		Limit:  50,
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			a.writeError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		filter.Limit = n
+	}
+	if v := query.Get("before_id"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			a.writeError(w, http.StatusBadRequest, "Invalid before_id")
+			return
+		}
+		filter.BeforeID = n
+	}
+
+	entries, err := a.audit.List(r.Context(), filter)
+	if err != nil {
+		a.writeServiceError(w, err)
+		return
+	}
+
+	records := make([]AuditEntryRecord, len(entries))
+	for i := range entries {
+		e := &entries[i]
+		records[i] = AuditEntryRecord{
+			ID:        &e.ID,
+			CreatedAt: &e.CreatedAt,
+			Actor:     &e.Actor,
+			Action:    &e.Action,
+			Target:    &e.Target,
+			Payload:   e.Payload,
+			PrevHash:  &e.PrevHash,
+			Hash:      &e.Hash,
+		}
+	}
+	a.writeJSON(w, http.StatusOK, AuditListResponse{Entries: &records})
+}
+
+// verifyAudit checks the hash chain of the whole audit log. A broken chain is
+// reported in the body, with brokenAt naming the first entry off the chain.
+func (a APIController) verifyAudit(w http.ResponseWriter, r *http.Request) {
+	res, err := a.audit.Verify(r.Context())
+	if err != nil {
+		a.writeServiceError(w, err)
+		return
+	}
+
+	intact := res.BrokenAt == 0
+	resp := AuditVerificationResponse{Entries: &res.Entries, Intact: &intact}
+	if !intact {
+		resp.BrokenAt = &res.BrokenAt
+	}
+	a.writeJSON(w, http.StatusOK, resp)
+}
+
+func (a APIController) writeUserManagementError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrorUserNotFound):
+		a.writeError(w, http.StatusNotFound, "User not found")
+	case errors.Is(err, service.ErrSelfAction):
+		a.writeError(w, http.StatusBadRequest, "Can't disable or delete yourself")
+	default:
+		a.writeServiceError(w, err)
+	}
+}
+
+func userRecord(u *entity.User) UserRecord {
+	role := string(u.Role)
+	return UserRecord{
+		ID:         &u.ID,
+		Username:   &u.Username,
+		Balance:    &u.Balance,
+		Role:       &role,
+		DisabledAt: u.DisabledAt,
+		DeletedAt:  u.DeletedAt,
+	}
+}
@@ -2,29 +2,86 @@
 package controller
 
 import (
+	"AvitoTech/internal/ratelimit"
+	"AvitoTech/internal/repository"
 	"AvitoTech/internal/service"
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 	"io"
 	"net/http"
-	"strings"
+	"strconv"
+	"time"
 )
 
 type APIController struct {
 	l *zap.Logger
 
-	auth service.Auth
-	info service.Info
-	coin service.Coin
+	auth  service.Auth
+	info  service.Info
+	coin  service.Coin
+	admin service.Admin
+	audit service.Audit
+
+	coinRequests   service.CoinRequests
+	transfers      service.ScheduledTransfers
+	holds          service.Holds
+	limits         service.Limits
+	reconciliation service.Reconciliation
+	jobs           service.Jobs
+
+	requestTimeout time.Duration
+
+	limiter   ratelimit.Store
+	authLimit ratelimit.Limit
+	apiLimit  ratelimit.Limit
+
+	logLevel zap.AtomicLevel
 }
 
 func (a APIController) Register(r chi.Router) {
-	r.Post("/api/auth", a.apiAuth)
-	r.Get("/api/buy/{item}", a.apiBuyItem)
-	r.Get("/api/info", a.apiInfo)
-	r.Post("/api/sendCoin", a.apiSendCoin)
+	r.Group(func(r chi.Router) {
+		r.Use(a.trace)
+		r.Use(a.logRequests)
+		r.Use(a.instrument)
+		r.Use(a.withTimeout)
+
+		// signing in is limited per client address: bcrypt makes it the most
+		// expensive call of the API
+		r.Group(func(r chi.Router) {
+			r.Use(a.rateLimit("auth", a.authLimit, clientIP))
+
+			r.Post("/api/auth", a.apiAuth)
+			r.Post("/api/auth/password-reset", a.apiResetPassword)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(a.authenticate)
+			r.Use(a.rateLimit("api", a.apiLimit, func(r *http.Request) string {
+				return strconv.Itoa(userID(r.Context()))
+			}))
+
+			r.Get("/api/buy/{item}", a.apiBuyItem)
+			r.Get("/api/info", a.apiInfo)
+			r.Post("/api/sendCoin", a.apiSendCoin)
+			r.Get("/api/coinRequests", a.apiListCoinRequests)
+			r.Post("/api/coinRequests", a.apiRequestCoins)
+			r.Post("/api/coinRequests/{id}/accept", a.apiAcceptCoinRequest)
+			r.Post("/api/coinRequests/{id}/decline", a.apiDeclineCoinRequest)
+			r.Get("/api/scheduledTransfers", a.apiListScheduledTransfers)
+			r.Post("/api/scheduledTransfers", a.apiScheduleTransfer)
+			r.Delete("/api/scheduledTransfers/{id}", a.apiCancelScheduledTransfer)
+			r.Get("/api/holds", a.apiListHolds)
+			r.Post("/api/holds", a.apiCreateHold)
+			r.Post("/api/holds/{id}/release", a.apiReleaseHold)
+			r.Post("/api/holds/{id}/cancel", a.apiCancelHold)
+			r.Post("/api/account/password", a.apiChangePassword)
+
+			a.registerAdmin(r)
+		})
+	})
 }
 
 func (a APIController) apiAuth(w http.ResponseWriter, r *http.Request) {
@@ -42,13 +99,27 @@ func (a APIController) apiAuth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := a.auth.Authenticate(req.Username, req.Password)
+	token, err := a.auth.Authenticate(r.Context(), req.Username, req.Password, clientIP(r))
 	if err != nil {
 		if errors.Is(err, service.ErrUnauthorized) {
 			a.writeError(w, http.StatusUnauthorized, "User unauthorized")
 			return
 		}
-		a.writeError(w, http.StatusInternalServerError, "Internal server error")
+		if errors.Is(err, service.ErrAccountDisabled) {
+			a.writeError(w, http.StatusForbidden, "Account disabled")
+			return
+		}
+		if errors.Is(err, service.ErrWeakPassword) {
+			a.writeError(w, http.StatusBadRequest, "Password is too short")
+			return
+		}
+		var locked *service.AccountLockedError
+		if errors.As(err, &locked) {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(time.Until(locked.Until))))
+			a.writeError(w, http.StatusTooManyRequests, "Account temporarily locked")
+			return
+		}
+		a.writeServiceError(w, err)
 		return
 	}
 
@@ -68,46 +139,26 @@ func (a APIController) apiAuth(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a APIController) apiBuyItem(w http.ResponseWriter, r *http.Request) {
-	token := r.Header.Get("Authorization")
-	token = strings.TrimPrefix(token, "Bearer ")
-	if token == "" {
-		a.writeError(w, http.StatusBadRequest, "Missing token")
-		return
-	}
-	id, err := a.auth.VerifyJWT(token)
-	if err != nil {
-		a.writeError(w, http.StatusUnauthorized, "Invalid token")
-		return
-	}
+	id := userID(r.Context())
 
 	item := chi.URLParam(r, "item")
 	if item == "" {
 		a.writeError(w, http.StatusBadRequest, "Item can't be empty")
 	}
 
-	err = a.coin.BuyItem(id, item)
+	err := a.coin.BuyItem(r.Context(), id, item)
 	if err != nil {
-		a.writeError(w, http.StatusInternalServerError, "Internal server error")
+		a.writeCoinError(w, err)
 		return
 	}
 }
 
 func (a APIController) apiInfo(w http.ResponseWriter, r *http.Request) {
-	token := r.Header.Get("Authorization")
-	token = strings.TrimPrefix(token, "Bearer ")
-	if token == "" {
-		a.writeError(w, http.StatusBadRequest, "Missing token")
-		return
-	}
-	id, err := a.auth.VerifyJWT(token)
-	if err != nil {
-		a.writeError(w, http.StatusUnauthorized, "Invalid token")
-		return
-	}
+	id := userID(r.Context())
 
-	info, err := a.info.GetInfo(id)
+	info, err := a.info.GetInfo(r.Context(), id)
 	if err != nil {
-		a.writeError(w, http.StatusInternalServerError, err.Error())
+		a.writeServiceError(w, err)
 		return
 	}
 
@@ -115,22 +166,36 @@ func (a APIController) apiInfo(w http.ResponseWriter, r *http.Request) {
 	for i, item := range info.Sent {
 		sent[i].ToUser = &item.ToUser
 		sent[i].Amount = &item.Amount
+		if item.Reason != "" {
+			sent[i].Reason = &item.Reason
+		}
 	}
 
 	received := make([]ReceiveRecord, len(info.Received))
 	for i, item := range info.Received {
 		received[i].FromUser = &item.FromUser
 		received[i].Amount = &item.Amount
+		if item.Reason != "" {
+			received[i].Reason = &item.Reason
+		}
 	}
 
 	inventory := make([]InventoryRecord, 0, len(info.Inventory))
 	for key, value := range info.Inventory {
 		inventory = append(inventory, InventoryRecord{Quantity: &value, Type: &key})
 	}
+	limits := make([]LimitRecord, len(info.Limits))
+	for i, limit := range info.Limits {
+		remaining := limit.Remaining()
+		limits[i] = limitRecord(limit)
+		limits[i].Remaining = &remaining
+	}
 	resp := InfoResponse{
 		CoinHistory: &History{Sent: &sent, Received: &received},
 		Coins:       &info.Coins,
+		Held:        &info.Held,
 		Inventory:   &inventory,
+		Limits:      &limits,
 	}
 
 	jsonResp, err := json.Marshal(resp)
@@ -148,17 +213,7 @@ func (a APIController) apiInfo(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a APIController) apiSendCoin(w http.ResponseWriter, r *http.Request) {
-	token := r.Header.Get("Authorization")
-	token = strings.TrimPrefix(token, "Bearer ")
-	if token == "" {
-		a.writeError(w, http.StatusBadRequest, "Missing token")
-		return
-	}
-	id, err := a.auth.VerifyJWT(token)
-	if err != nil {
-		a.writeError(w, http.StatusUnauthorized, "Invalid token")
-		return
-	}
+	id := userID(r.Context())
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -178,13 +233,91 @@ func (a APIController) apiSendCoin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = a.coin.SendCoin(id, req.ToUser, req.Amount)
+	err = a.coin.SendCoin(r.Context(), id, req.ToUser, req.Amount)
+	if err != nil {
+		a.writeCoinError(w, err)
+		return
+	}
+}
+
+func (a APIController) writeCoinError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrCoinAmount):
+		a.writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, repository.ErrorInsufficientBalance):
+		a.writeError(w, http.StatusBadRequest, "Insufficient balance")
+	case errors.Is(err, repository.ErrorLimitExceeded):
+		a.writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, repository.ErrorUserNotFound):
+		a.writeError(w, http.StatusNotFound, "User not found")
+	default:
+		a.writeServiceError(w, err)
+	}
+}
+
+// apiChangePassword replaces the password of the caller. Every other session
+// is signed out, the response carries a token for the caller's own.
+func (a APIController) apiChangePassword(w http.ResponseWriter, r *http.Request) {
+	id := userID(r.Context())
+
+	var req ChangePasswordRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.CurrentPassword == "" || req.NewPassword == "" {
+		a.writeError(w, http.StatusBadRequest, "Invalid request: missing current or new password")
+		return
+	}
+
+	token, err := a.auth.ChangePassword(r.Context(), id, req.CurrentPassword, req.NewPassword)
 	if err != nil {
-		a.writeError(w, http.StatusInternalServerError, "Internal server error")
+		switch {
+		case errors.Is(err, service.ErrWeakPassword):
+			a.writeError(w, http.StatusBadRequest, "Password is too short")
+		case errors.Is(err, service.ErrWrongPassword):
+			a.writeError(w, http.StatusForbidden, "Current password is incorrect")
+		default:
+			a.writeServiceError(w, err)
+		}
+		return
+	}
+
+	jsonResp, err := json.Marshal(AuthResponse{Token: &token})
+	if err != nil {
+		a.writeError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(jsonResp)
+	if err != nil {
+		a.l.Error("Failed to write response", zap.Error(err))
 		return
 	}
 }
 
+// apiResetPassword sets a new password with a token issued by an operator.
+func (a APIController) apiResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Token == "" || req.NewPassword == "" {
+		a.writeError(w, http.StatusBadRequest, "Invalid request: missing token or new password")
+		return
+	}
+
+	err = a.auth.ResetPassword(r.Context(), req.Token, req.NewPassword)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrWeakPassword):
+			a.writeError(w, http.StatusBadRequest, "Password is too short")
+		case errors.Is(err, service.ErrInvalidResetToken):
+			a.writeError(w, http.StatusBadRequest, "Invalid or expired reset token")
+		default:
+			a.writeServiceError(w, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (a APIController) writeError(w http.ResponseWriter, code int, message string) {
 	w.WriteHeader(code)
 	errorMessage := message
@@ -198,16 +331,65 @@ func (a APIController) writeError(w http.ResponseWriter, code int, message strin
 	}
 }
 
+func (a APIController) writeJSON(w http.ResponseWriter, code int, v any) {
+	jsonResp, err := json.Marshal(v)
+	if err != nil {
+		a.writeError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err = w.Write(jsonResp); err != nil {
+		a.l.Error("Failed to write response", zap.Error(err))
+	}
+}
+
+// writeServiceError reports a failed service call. Requests that ran out of
+// their deadline get 504, everything else is an internal error.
+func (a APIController) writeServiceError(w http.ResponseWriter, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		a.writeError(w, http.StatusGatewayTimeout, "Request timed out")
+		return
+	}
+	a.writeError(w, http.StatusInternalServerError, "Internal server error")
+}
+
 func NewAPIController(
 	l *zap.Logger,
 	a service.Auth,
 	i service.Info,
 	c service.Coin,
+	coinRequests service.CoinRequests,
+	transfers service.ScheduledTransfers,
+	holds service.Holds,
+	limits service.Limits,
+	admin service.Admin,
+	audit service.Audit,
+	reconciliation service.Reconciliation,
+	jobs service.Jobs,
+	requestTimeout time.Duration,
+	limiter ratelimit.Store,
+	authLimit ratelimit.Limit,
+	apiLimit ratelimit.Limit,
+	logLevel zap.AtomicLevel,
 ) *APIController {
 	return &APIController{
-		l:    l,
-		auth: a,
-		info: i,
-		coin: c,
+		l:              l,
+		auth:           a,
+		info:           i,
+		coin:           c,
+		coinRequests:   coinRequests,
+		transfers:      transfers,
+		holds:          holds,
+		limits:         limits,
+		admin:          admin,
+		audit:          audit,
+		reconciliation: reconciliation,
+		jobs:           jobs,
+		requestTimeout: requestTimeout,
+		limiter:        limiter,
+		authLimit:      authLimit,
+		apiLimit:       apiLimit,
+		logLevel:       logLevel,
 	}
 }
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"AvitoTech/internal/service"
+	mocks "AvitoTech/test/mock"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// asUser returns r as sent by the authenticated user id.
+func asUser(r *http.Request, id int) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), claimsKey, entity.Claims{UserID: id}))
+}
+
+func TestAPISendCoin_Errors(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockCoin := new(mocks.MockCoin)
+	a := APIController{l: logger, coin: mockCoin}
+
+	mockCoin.On("SendCoin", 1, "bob", 0).Return(service.ErrCoinAmount)
+	mockCoin.On("SendCoin", 1, "bob", -10).Return(service.ErrCoinAmount)
+	mockCoin.On("SendCoin", 1, "bob", 5000).Return(repository.ErrorInsufficientBalance)
+	mockCoin.On("SendCoin", 1, "bob", 500).Return(fmt.Errorf("%w: daily transfer limit is 100, 0 left", repository.ErrorLimitExceeded))
+	mockCoin.On("SendCoin", 1, "nobody", 10).Return(repository.ErrorUserNotFound)
+	mockCoin.On("SendCoin", 1, "bob", 10).Return(nil)
+
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{"toUser": "bob", "amount": 0}`, http.StatusBadRequest},
+		{`{"toUser": "bob", "amount": -10}`, http.StatusBadRequest},
+		{`{"toUser": "bob", "amount": 5000}`, http.StatusBadRequest},
+		{`{"toUser": "bob", "amount": 500}`, http.StatusBadRequest},
+		{`{"toUser": "nobody", "amount": 10}`, http.StatusNotFound},
+		{`{"toUser": "bob", "amount": 10}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		a.apiSendCoin(w, asUser(httptest.NewRequest(http.MethodPost, "/api/sendCoin", strings.NewReader(tt.body)), 1))
+		assert.Equal(t, tt.code, w.Code, tt.body)
+	}
+	mockCoin.AssertExpectations(t)
+}
+
+func TestAPIBuyItem_Errors(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockCoin := new(mocks.MockCoin)
+	a := APIController{l: logger, coin: mockCoin}
+
+	r := chi.NewRouter()
+	r.Get("/api/buy/{item}", func(w http.ResponseWriter, r *http.Request) {
+		a.apiBuyItem(w, asUser(r, 1))
+	})
+
+	mockCoin.On("BuyItem", 1, "pink-hoody").Return(repository.ErrorInsufficientBalance)
+	mockCoin.On("BuyItem", 1, "cup").Return(repository.ErrorLimitExceeded)
+	mockCoin.On("BuyItem", 1, "pen").Return(nil)
+
+	for item, code := range map[string]int{"pink-hoody": http.StatusBadRequest, "cup": http.StatusBadRequest, "pen": http.StatusOK} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/buy/"+item, nil))
+		assert.Equal(t, code, w.Code, item)
+	}
+	mockCoin.AssertExpectations(t)
+}
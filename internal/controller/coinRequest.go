@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"AvitoTech/internal/service"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+)
+
+// apiRequestCoins asks another user for coins. The body is
+// {"fromUser": "bob", "amount": 50, "memo": "lunch"}.
+func (a APIController) apiRequestCoins(w http.ResponseWriter, r *http.Request) {
+	var req RequestCoinsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.FromUser == "" {
+		a.writeError(w, http.StatusBadRequest, "Invalid request: missing user")
+		return
+	}
+	memo := ""
+	if req.Memo != nil {
+		memo = *req.Memo
+	}
+
+	request, err := a.coinRequests.Request(r.Context(), userID(r.Context()), req.FromUser, req.Amount, memo)
+	if err != nil {
+		a.writeCoinRequestError(w, err)
+		return
+	}
+	a.writeJSON(w, http.StatusCreated, coinRequestRecord(request))
+}
+
+// apiListCoinRequests returns the requests addressed to the caller, newest
+// first. Query parameters: direction=outgoing for the requests made by the
+// caller instead, status and limit.
+func (a APIController) apiListCoinRequests(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := entity.CoinRequestFilter{
+		UserID: userID(r.Context()),
+		Status: entity.CoinRequestStatus(query.Get("status")),
+		Limit:  20,
+	}
+
+	switch query.Get("direction") {
+	case "", "incoming":
+	case "outgoing":
+		filter.Outgoing = true
+	default:
+		a.writeError(w, http.StatusBadRequest, "Invalid direction")
+		return
+	}
+	switch filter.Status {
+	case "", entity.CoinRequestPending, entity.CoinRequestAccepted, entity.CoinRequestDeclined, entity.CoinRequestExpired:
+	default:
+		a.writeError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			a.writeError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		filter.Limit = n
+	}
+
+	requests, err := a.coinRequests.List(r.Context(), filter)
+	if err != nil {
+		a.writeServiceError(w, err)
+		return
+	}
+
+	records := make([]CoinRequestRecord, len(requests))
+	for i := range requests {
+		records[i] = coinRequestRecord(&requests[i])
+	}
+	a.writeJSON(w, http.StatusOK, CoinRequestListResponse{Requests: &records})
+}
+
+// apiAcceptCoinRequest sends the requested coins to the requester.
+func (a APIController) apiAcceptCoinRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		a.writeError(w, http.StatusNotFound, "Request not found")
+		return
+	}
+
+	request, err := a.coinRequests.Accept(r.Context(), userID(r.Context()), id)
+	if err != nil {
+		a.writeCoinRequestError(w, err)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, coinRequestRecord(request))
+}
+
+func (a APIController) apiDeclineCoinRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		a.writeError(w, http.StatusNotFound, "Request not found")
+		return
+	}
+
+	request, err := a.coinRequests.Decline(r.Context(), userID(r.Context()), id)
+	if err != nil {
+		a.writeCoinRequestError(w, err)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, coinRequestRecord(request))
+}
+
+func (a APIController) writeCoinRequestError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrRequestAmount):
+		a.writeError(w, http.StatusBadRequest, "Amount must be positive")
+	case errors.Is(err, service.ErrMemoTooLong):
+		a.writeError(w, http.StatusBadRequest, "Memo is too long")
+	case errors.Is(err, service.ErrSelfRequest):
+		a.writeError(w, http.StatusBadRequest, "Can't request coins from yourself")
+	case errors.Is(err, repository.ErrorUserNotFound):
+		a.writeError(w, http.StatusNotFound, "User not found")
+	case errors.Is(err, repository.ErrorRequestNotFound):
+		a.writeError(w, http.StatusNotFound, "Request not found")
+	case errors.Is(err, repository.ErrorRequestNotPending):
+		a.writeError(w, http.StatusConflict, "Request is not pending")
+	case errors.Is(err, repository.ErrorInsufficientBalance):
+		a.writeError(w, http.StatusBadRequest, "Insufficient balance")
+	case errors.Is(err, repository.ErrorLimitExceeded):
+		a.writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		a.writeServiceError(w, err)
+	}
+}
+
+func coinRequestRecord(request *entity.CoinRequest) CoinRequestRecord {
+	status := string(request.Status)
+	return CoinRequestRecord{
+		ID:         &request.ID,
+		FromUser:   &request.Payer,
+		ToUser:     &request.Requester,
+		Amount:     &request.Amount,
+		Memo:       &request.Memo,
+		Status:     &status,
+		CreatedAt:  &request.CreatedAt,
+		ExpiresAt:  &request.ExpiresAt,
+		ResolvedAt: request.ResolvedAt,
+	}
+}
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"AvitoTech/internal/service"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// HealthController serves the probes used by the orchestrator. They are
+// registered outside of the API group and need no token.
+type HealthController struct {
+	l *zap.Logger
+
+	health service.Health
+}
+
+func (h HealthController) Register(r chi.Router) {
+	r.Get("/healthz", h.healthz)
+	r.Get("/readyz", h.readyz)
+}
+
+// healthz answers as long as the process is able to serve requests at all.
+func (h HealthController) healthz(w http.ResponseWriter, _ *http.Request) {
+	h.writeStatus(w, http.StatusOK, "ok")
+}
+
+func (h HealthController) readyz(w http.ResponseWriter, r *http.Request) {
+	err := h.health.Ready(r.Context())
+	if err != nil {
+		h.l.Info("instance is not ready", zap.Error(err))
+		h.writeStatus(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	h.writeStatus(w, http.StatusOK, "ok")
+}
+
+func (h HealthController) writeStatus(w http.ResponseWriter, code int, status string) {
+	jsonResp, _ := json.Marshal(HealthResponse{Status: &status})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, err := w.Write(jsonResp)
+	if err != nil {
+		h.l.Error("Failed to write response", zap.Error(err))
+	}
+}
+
+func NewHealthController(
+	l *zap.Logger,
+	h service.Health,
+) *HealthController {
+	return &HealthController{
+		l:      l,
+		health: h,
+	}
+}
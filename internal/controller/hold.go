@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"AvitoTech/internal/service"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+)
+
+// apiCreateHold locks coins of the caller for a bounty. The body is
+// {"amount": 100, "memo": "fix the build"}.
+func (a APIController) apiCreateHold(w http.ResponseWriter, r *http.Request) {
+	var req CreateHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.writeError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	memo := ""
+	if req.Memo != nil {
+		memo = *req.Memo
+	}
+
+	hold, err := a.holds.Hold(r.Context(), userID(r.Context()), req.Amount, memo)
+	if err != nil {
+		a.writeHoldError(w, err)
+		return
+	}
+	a.writeJSON(w, http.StatusCreated, holdRecord(hold))
+}
+
+// apiListHolds returns the holds of the caller, newest first. Query
+// parameter: limit.
+func (a APIController) apiListHolds(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			a.writeError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	holds, err := a.holds.List(r.Context(), userID(r.Context()), limit)
+	if err != nil {
+		a.writeServiceError(w, err)
+		return
+	}
+
+	records := make([]HoldRecord, len(holds))
+	for i := range holds {
+		records[i] = holdRecord(&holds[i])
+	}
+	a.writeJSON(w, http.StatusOK, HoldListResponse{Holds: &records})
+}
+
+// apiReleaseHold pays a hold of the caller out. The body is
+// {"toUser": "bob"}.
+func (a APIController) apiReleaseHold(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		a.writeError(w, http.StatusNotFound, "Hold not found")
+		return
+	}
+	var req ReleaseHoldRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil || req.ToUser == "" {
+		a.writeError(w, http.StatusBadRequest, "Invalid request: missing user")
+		return
+	}
+
+	hold, err := a.holds.Release(r.Context(), userID(r.Context()), id, req.ToUser)
+	if err != nil {
+		a.writeHoldError(w, err)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, holdRecord(hold))
+}
+
+func (a APIController) apiCancelHold(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		a.writeError(w, http.StatusNotFound, "Hold not found")
+		return
+	}
+
+	hold, err := a.holds.Cancel(r.Context(), userID(r.Context()), id)
+	if err != nil {
+		a.writeHoldError(w, err)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, holdRecord(hold))
+}
+
+func (a APIController) writeHoldError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrHoldAmount),
+		errors.Is(err, service.ErrMemoTooLong),
+		errors.Is(err, service.ErrSelfRelease):
+		a.writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, repository.ErrorInsufficientBalance):
+		a.writeError(w, http.StatusBadRequest, "Insufficient balance")
+	case errors.Is(err, repository.ErrorLimitExceeded):
+		a.writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, repository.ErrorUserNotFound):
+		a.writeError(w, http.StatusNotFound, "User not found")
+	case errors.Is(err, repository.ErrorHoldNotFound):
+		a.writeError(w, http.StatusNotFound, "Hold not found")
+	case errors.Is(err, repository.ErrorHoldNotActive):
+		a.writeError(w, http.StatusConflict, "Hold is not active")
+	default:
+		a.writeServiceError(w, err)
+	}
+}
+
+func holdRecord(h *entity.Hold) HoldRecord {
+	status := string(h.Status)
+	record := HoldRecord{
+		ID:         &h.ID,
+		Amount:     &h.Amount,
+		Status:     &status,
+		CreatedAt:  &h.CreatedAt,
+		ResolvedAt: h.ResolvedAt,
+	}
+	if h.Memo != "" {
+		record.Memo = &h.Memo
+	}
+	if h.Recipient != "" {
+		record.ToUser = &h.Recipient
+	}
+	return record
+}
@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// listJobRuns returns the latest runs of the scheduled jobs, newest first.
+// Query parameters: job, limit.
+func (a APIController) listJobRuns(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			a.writeError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	runs, err := a.jobs.ListRuns(r.Context(), r.URL.Query().Get("job"), limit)
+	if err != nil {
+		a.writeServiceError(w, err)
+		return
+	}
+
+	records := make([]JobRunRecord, len(runs))
+	for i, run := range runs {
+		records[i] = JobRunRecord{
+			Job:         &run.Job,
+			ScheduledAt: &run.ScheduledAt,
+			StartedAt:   &run.StartedAt,
+			FinishedAt:  run.FinishedAt,
+			Replica:     &run.Replica,
+		}
+		if run.Error != "" {
+			records[i].Error = &run.Error
+		}
+	}
+	a.writeJSON(w, http.StatusOK, JobRunListResponse{Runs: &records})
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"AvitoTech/internal/service"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+// getUserLimits returns every spending limit of a user, the configured ones
+// and the ones overridden for the user.
+func (a APIController) getUserLimits(w http.ResponseWriter, r *http.Request) {
+	limits, err := a.limits.Get(r.Context(), chi.URLParam(r, "username"))
+	if err != nil {
+		a.writeLimitError(w, err)
+		return
+	}
+
+	records := make([]LimitRecord, len(limits))
+	for i, limit := range limits {
+		records[i] = limitRecord(limit)
+		records[i].Override = &limit.Override
+	}
+	a.writeJSON(w, http.StatusOK, LimitListResponse{Limits: &records})
+}
+
+// setUserLimit overrides a spending limit for a user. The body is
+// {"amount": 500}, an amount of 0 lifts the limit.
+func (a APIController) setUserLimit(w http.ResponseWriter, r *http.Request) {
+	var req SetLimitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Amount == nil {
+		a.writeError(w, http.StatusBadRequest, "Invalid request: missing amount")
+		return
+	}
+
+	err := a.limits.SetOverride(r.Context(), chi.URLParam(r, "username"), entity.SpendingLimit{
+		Operation: entity.LimitOperation(chi.URLParam(r, "operation")),
+		Period:    entity.LimitPeriod(chi.URLParam(r, "period")),
+		Amount:    *req.Amount,
+	}, claims(r.Context()).Username)
+	if err != nil {
+		a.writeLimitError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clearUserLimit returns a user to the configured spending limit.
+func (a APIController) clearUserLimit(w http.ResponseWriter, r *http.Request) {
+	err := a.limits.ClearOverride(r.Context(), chi.URLParam(r, "username"),
+		entity.LimitOperation(chi.URLParam(r, "operation")),
+		entity.LimitPeriod(chi.URLParam(r, "period")),
+		claims(r.Context()).Username,
+	)
+	if err != nil {
+		a.writeLimitError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a APIController) writeLimitError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidLimit):
+		a.writeError(w, http.StatusBadRequest, "Invalid limit: operation must be transfer or purchase, period daily or weekly, amount not negative")
+	case errors.Is(err, repository.ErrorUserNotFound):
+		a.writeError(w, http.StatusNotFound, "User not found")
+	case errors.Is(err, repository.ErrorLimitNotFound):
+		a.writeError(w, http.StatusNotFound, "Limit is not overridden")
+	default:
+		a.writeServiceError(w, err)
+	}
+}
+
+func limitRecord(limit entity.SpendingLimit) LimitRecord {
+	operation, period := string(limit.Operation), string(limit.Period)
+	return LimitRecord{
+		Operation: &operation,
+		Period:    &period,
+		Limit:     &limit.Amount,
+	}
+}
@@ -1,5 +1,66 @@
 package controller
 
+import (
+	"encoding/json"
+	"time"
+)
+
+// AdjustmentRequest defines model for AdjustmentRequest.
+type AdjustmentRequest struct {
+	// Amount Количество монет, начисляемых или списываемых у каждого пользователя.
+	Amount int `json:"amount"`
+
+	// Reason Причина начисления или списания, видна пользователю.
+	Reason string `json:"reason"`
+
+	// Usernames Имена пользователей.
+	Usernames []string `json:"usernames"`
+}
+
+// AuditEntryRecord defines model for AuditEntryRecord.
+type AuditEntryRecord struct {
+	// Action Действие, например admin.disable или coins.grant.
+	Action *string `json:"action,omitempty"`
+
+	// Actor Имя пользователя, совершившего действие.
+	Actor *string `json:"actor,omitempty"`
+
+	// CreatedAt Момент действия.
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+
+	// Hash Хеш записи, включающий хеш предыдущей.
+	Hash *string `json:"hash,omitempty"`
+
+	// Id Номер записи в журнале.
+	ID *int64 `json:"id,omitempty"`
+
+	// Payload Подробности действия.
+	Payload json.RawMessage `json:"payload,omitempty"`
+
+	// PrevHash Хеш предыдущей записи.
+	PrevHash *string `json:"prevHash,omitempty"`
+
+	// Target Объект действия, обычно имя пользователя.
+	Target *string `json:"target,omitempty"`
+}
+
+// AuditListResponse defines model for AuditListResponse.
+type AuditListResponse struct {
+	Entries *[]AuditEntryRecord `json:"entries,omitempty"`
+}
+
+// AuditVerificationResponse defines model for AuditVerificationResponse.
+type AuditVerificationResponse struct {
+	// BrokenAt Номер первой записи, не совпадающей с цепочкой.
+	BrokenAt *int64 `json:"brokenAt,omitempty"`
+
+	// Entries Количество проверенных целых записей.
+	Entries *int64 `json:"entries,omitempty"`
+
+	// Intact Журнал не изменялся.
+	Intact *bool `json:"intact,omitempty"`
+}
+
 // AuthRequest defines model for AuthRequest.
 type AuthRequest struct {
 	// Password Пароль для аутентификации.
@@ -15,19 +76,132 @@ type AuthResponse struct {
 	Token *string `json:"token,omitempty"`
 }
 
+// ChangePasswordRequest defines model for ChangePasswordRequest.
+type ChangePasswordRequest struct {
+	// CurrentPassword Текущий пароль пользователя.
+	CurrentPassword string `json:"currentPassword"`
+
+	// NewPassword Новый пароль.
+	NewPassword string `json:"newPassword"`
+}
+
+// CoinRequestListResponse defines model for CoinRequestListResponse.
+type CoinRequestListResponse struct {
+	Requests *[]CoinRequestRecord `json:"requests,omitempty"`
+}
+
+// CoinRequestRecord defines model for CoinRequestRecord.
+type CoinRequestRecord struct {
+	// Amount Количество запрошенных монет.
+	Amount *int `json:"amount,omitempty"`
+
+	// CreatedAt Момент создания запроса.
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+
+	// ExpiresAt Момент, после которого запрос истекает.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+
+	// FromUser Имя пользователя, у которого запрошены монеты.
+	FromUser *string `json:"fromUser,omitempty"`
+
+	ID *int64 `json:"id,omitempty"`
+
+	// Memo Комментарий к запросу.
+	Memo *string `json:"memo,omitempty"`
+
+	// ResolvedAt Момент принятия или отклонения запроса.
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+
+	// Status Состояние запроса: pending, accepted, declined или expired.
+	Status *string `json:"status,omitempty"`
+
+	// ToUser Имя пользователя, запросившего монеты.
+	ToUser *string `json:"toUser,omitempty"`
+}
+
+// CreateHoldRequest defines model for CreateHoldRequest.
+type CreateHoldRequest struct {
+	// Amount Количество удерживаемых монет.
+	Amount int `json:"amount"`
+
+	// Memo Описание задачи, за которую будут выплачены монеты.
+	Memo *string `json:"memo,omitempty"`
+}
+
+// DiscrepancyRecord defines model for DiscrepancyRecord.
+type DiscrepancyRecord struct {
+	// Balance Баланс пользователя на момент сверки.
+	Balance *int `json:"balance,omitempty"`
+
+	// Corrected Баланс исправлен после подтверждения.
+	Corrected *bool `json:"corrected,omitempty"`
+
+	// Delta Исправление, приводящее баланс к ожидаемому.
+	Delta *int `json:"delta,omitempty"`
+
+	// Expected Баланс, пересчитанный по истории и покупкам.
+	Expected *int `json:"expected,omitempty"`
+
+	// UserId Идентификатор пользователя.
+	UserID *int `json:"userID,omitempty"`
+
+	// Username Имя пользователя.
+	Username *string `json:"username,omitempty"`
+}
+
 // ErrorResponse defines model for ErrorResponse.
 type ErrorResponse struct {
 	// Errors Сообщение об ошибке, описывающее проблему.
 	Errors *string `json:"errors,omitempty"`
 }
 
+// HealthResponse defines model for HealthResponse.
+type HealthResponse struct {
+	// Status Состояние сервиса: "ok" или причина, по которой он не готов.
+	Status *string `json:"status,omitempty"`
+}
+
+// HoldListResponse defines model for HoldListResponse.
+type HoldListResponse struct {
+	Holds *[]HoldRecord `json:"holds,omitempty"`
+}
+
+// HoldRecord defines model for HoldRecord.
+type HoldRecord struct {
+	// Amount Количество удерживаемых монет.
+	Amount *int `json:"amount,omitempty"`
+
+	// CreatedAt Момент создания удержания.
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+
+	ID *int64 `json:"id,omitempty"`
+
+	// Memo Описание задачи.
+	Memo *string `json:"memo,omitempty"`
+
+	// ResolvedAt Момент выплаты или отмены.
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+
+	// Status Состояние удержания: active, released или cancelled.
+	Status *string `json:"status,omitempty"`
+
+	// ToUser Имя получателя выплаченных монет.
+	ToUser *string `json:"toUser,omitempty"`
+}
+
 // InfoResponse defines model for InfoResponse.
 type InfoResponse struct {
 	CoinHistory *History `json:"coinHistory,omitempty"`
 
 	// Coins Количество доступных монет.
-	Coins     *int               `json:"coins,omitempty"`
+	Coins *int `json:"coins,omitempty"`
+
+	// Held Количество монет, удерживаемых до выплаты.
+	Held      *int               `json:"held,omitempty"`
 	Inventory *[]InventoryRecord `json:"inventory,omitempty"`
+
+	// Limits Действующие лимиты трат и их остаток.
+	Limits *[]LimitRecord `json:"limits,omitempty"`
 }
 
 type History struct {
@@ -43,22 +217,205 @@ type InventoryRecord struct {
 	Type *string `json:"type,omitempty"`
 }
 
+// JobRunListResponse defines model for JobRunListResponse.
+type JobRunListResponse struct {
+	Runs *[]JobRunRecord `json:"runs,omitempty"`
+}
+
+// JobRunRecord defines model for JobRunRecord.
+type JobRunRecord struct {
+	// Error Причина неудачного запуска.
+	Error *string `json:"error,omitempty"`
+
+	// FinishedAt Момент завершения, отсутствует у незавершённого запуска.
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+
+	// Job Название задачи.
+	Job *string `json:"job,omitempty"`
+
+	// Replica Реплика, выполнившая задачу.
+	Replica *string `json:"replica,omitempty"`
+
+	// ScheduledAt Момент, на который был назначен запуск.
+	ScheduledAt *time.Time `json:"scheduledAt,omitempty"`
+
+	// StartedAt Момент начала запуска.
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+}
+
+// LimitListResponse defines model for LimitListResponse.
+type LimitListResponse struct {
+	Limits *[]LimitRecord `json:"limits,omitempty"`
+}
+
+// LimitRecord defines model for LimitRecord.
+type LimitRecord struct {
+	// Limit Максимум монет за период, 0 — без ограничения.
+	Limit *int `json:"limit,omitempty"`
+
+	// Operation Вид трат: transfer или purchase.
+	Operation *string `json:"operation,omitempty"`
+
+	// Override Лимит задан для пользователя администратором.
+	Override *bool `json:"override,omitempty"`
+
+	// Period Скользящее окно лимита: daily или weekly.
+	Period *string `json:"period,omitempty"`
+
+	// Remaining Сколько монет ещё можно потратить за период.
+	Remaining *int `json:"remaining,omitempty"`
+}
+
+// PasswordResetRequest defines model for PasswordResetRequest.
+type PasswordResetRequest struct {
+	// NewPassword Новый пароль.
+	NewPassword string `json:"newPassword"`
+
+	// Token Одноразовый токен сброса пароля.
+	Token string `json:"token"`
+}
+
+// PasswordResetTokenResponse defines model for PasswordResetTokenResponse.
+type PasswordResetTokenResponse struct {
+	// ExpiresAt Момент, после которого токен недействителен.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+
+	// Token Одноразовый токен сброса пароля.
+	Token *string `json:"token,omitempty"`
+}
+
 type ReceiveRecord struct {
 	// Amount Количество полученных монет.
 	Amount *int `json:"amount,omitempty"`
 
 	// FromUser Имя пользователя, который отправил монеты.
 	FromUser *string `json:"fromUser,omitempty"`
+
+	// Reason Причина начисления монет системой.
+	Reason *string `json:"reason,omitempty"`
+}
+
+// ReconciliationListResponse defines model for ReconciliationListResponse.
+type ReconciliationListResponse struct {
+	Reports *[]ReconciliationResponse `json:"reports,omitempty"`
+}
+
+// ReconciliationResponse defines model for ReconciliationResponse.
+type ReconciliationResponse struct {
+	// ApprovedAt Момент подтверждения исправлений.
+	ApprovedAt *time.Time `json:"approvedAt,omitempty"`
+
+	// ApprovedBy Имя подтвердившего исправления.
+	ApprovedBy *string `json:"approvedBy,omitempty"`
+
+	// CreatedAt Момент сверки.
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+
+	Discrepancies *[]DiscrepancyRecord `json:"discrepancies,omitempty"`
+
+	// Id Номер сверки.
+	ID *int `json:"id,omitempty"`
+
+	// Status Состояние: clean, pending или applied.
+	Status *string `json:"status,omitempty"`
+
+	// Users Количество проверенных пользователей.
+	Users *int `json:"users,omitempty"`
+}
+
+// ReleaseHoldRequest defines model for ReleaseHoldRequest.
+type ReleaseHoldRequest struct {
+	// ToUser Имя получателя монет.
+	ToUser string `json:"toUser"`
 }
 
 type SendRecord struct {
 	// Amount Количество отправленных монет.
 	Amount *int `json:"amount,omitempty"`
 
+	// Reason Причина списания монет системой.
+	Reason *string `json:"reason,omitempty"`
+
 	// ToUser Имя пользователя, которому отправлены монеты.
 	ToUser *string `json:"toUser,omitempty"`
 }
 
+// RequestCoinsRequest defines model for RequestCoinsRequest.
+type RequestCoinsRequest struct {
+	// Amount Количество запрашиваемых монет.
+	Amount int `json:"amount"`
+
+	// FromUser Имя пользователя, у которого запрашиваются монеты.
+	FromUser string `json:"fromUser"`
+
+	// Memo Комментарий к запросу.
+	Memo *string `json:"memo,omitempty"`
+}
+
+// ScheduleTransferRequest defines model for ScheduleTransferRequest.
+type ScheduleTransferRequest struct {
+	// Amount Количество монет в каждом переводе.
+	Amount int `json:"amount"`
+
+	// RunAt Момент разового перевода.
+	RunAt *time.Time `json:"runAt,omitempty"`
+
+	// Schedule Расписание повторяющегося перевода: cron или "@every 168h".
+	Schedule *string `json:"schedule,omitempty"`
+
+	// ToUser Имя получателя.
+	ToUser string `json:"toUser"`
+}
+
+// ScheduledTransferListResponse defines model for ScheduledTransferListResponse.
+type ScheduledTransferListResponse struct {
+	Transfers *[]ScheduledTransferRecord `json:"transfers,omitempty"`
+}
+
+// ScheduledTransferRecord defines model for ScheduledTransferRecord.
+type ScheduledTransferRecord struct {
+	// Amount Количество монет в каждом переводе.
+	Amount *int `json:"amount,omitempty"`
+
+	// CreatedAt Момент создания перевода.
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+
+	// Failures Число неудачных попыток подряд.
+	Failures *int `json:"failures,omitempty"`
+
+	ID *int64 `json:"id,omitempty"`
+
+	// LastError Причина последней неудачной попытки.
+	LastError *string `json:"lastError,omitempty"`
+
+	// LastRunAt Момент последней попытки.
+	LastRunAt *time.Time `json:"lastRunAt,omitempty"`
+
+	// NextRunAt Момент следующей попытки, отсутствует у неактивного перевода.
+	NextRunAt *time.Time `json:"nextRunAt,omitempty"`
+
+	// Schedule Расписание повторяющегося перевода.
+	Schedule *string `json:"schedule,omitempty"`
+
+	// Status Состояние перевода: active, completed, cancelled или failed.
+	Status *string `json:"status,omitempty"`
+
+	// ToUser Имя получателя.
+	ToUser *string `json:"toUser,omitempty"`
+}
+
+// SetLimitRequest defines model for SetLimitRequest.
+type SetLimitRequest struct {
+	// Amount Максимум монет за период, 0 — без ограничения.
+	Amount *int `json:"amount"`
+}
+
+// SetRoleRequest defines model for SetRoleRequest.
+type SetRoleRequest struct {
+	// Role Роль пользователя: user, admin или auditor.
+	Role string `json:"role"`
+}
+
 // SendCoinRequest defines model for SendCoinRequest.
 type SendCoinRequest struct {
 	// Amount Количество монет, которые необходимо отправить.
@@ -68,8 +425,42 @@ type SendCoinRequest struct {
 	ToUser string `json:"toUser"`
 }
 
+// UserListResponse defines model for UserListResponse.
+type UserListResponse struct {
+	// Total Количество пользователей, подходящих под фильтр, на всех страницах.
+	Total *int `json:"total,omitempty"`
+
+	Users *[]UserRecord `json:"users,omitempty"`
+}
+
+type UserRecord struct {
+	// Balance Количество монет.
+	Balance *int `json:"balance,omitempty"`
+
+	// DeletedAt Момент удаления пользователя.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+
+	// DisabledAt Момент блокировки пользователя.
+	DisabledAt *time.Time `json:"disabledAt,omitempty"`
+
+	// Id Идентификатор пользователя.
+	ID *int `json:"id,omitempty"`
+
+	// Role Роль пользователя.
+	Role *string `json:"role,omitempty"`
+
+	// Username Имя пользователя.
+	Username *string `json:"username,omitempty"`
+}
+
 // PostAPIAuthJSONRequestBody defines body for apiAuth for application/json ContentType.
 type PostAPIAuthJSONRequestBody = AuthRequest
 
 // PostAPISendCoinJSONRequestBody defines body for apiSendCoin for application/json ContentType.
 type PostAPISendCoinJSONRequestBody = SendCoinRequest
+
+// PostAPIAccountPasswordJSONRequestBody defines body for apiChangePassword for application/json ContentType.
+type PostAPIAccountPasswordJSONRequestBody = ChangePasswordRequest
+
+// PostAPIAuthPasswordResetJSONRequestBody defines body for apiResetPassword for application/json ContentType.
+type PostAPIAuthPasswordResetJSONRequestBody = PasswordResetRequest
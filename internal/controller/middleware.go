@@ -0,0 +1,254 @@
+package controller
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/logging"
+	"AvitoTech/internal/metrics"
+	"AvitoTech/internal/ratelimit"
+	"AvitoTech/internal/service"
+	"AvitoTech/internal/tracing"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	requestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+)
+
+type ctxKey int
+
+const claimsKey ctxKey = iota
+
+// authenticate verifies the bearer token and stores its claims in the request
+// context.
+func (a APIController) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			a.writeError(w, http.StatusBadRequest, "Missing token")
+			return
+		}
+		c, err := a.auth.VerifyJWT(r.Context(), token)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrUnauthorized):
+				a.writeError(w, http.StatusUnauthorized, "Invalid token")
+			case errors.Is(err, service.ErrAccountDisabled):
+				a.writeError(w, http.StatusForbidden, "Account disabled")
+			default:
+				a.writeServiceError(w, err)
+			}
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey, c)))
+	})
+}
+
+// authorize lets through the requests whose role is granted p, the others get
+// 403. It must run after authenticate.
+func (a APIController) authorize(p entity.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c := claims(r.Context())
+			if !c.Role.Can(p) {
+				logging.FromContext(r.Context(), a.l).Warn("permission denied",
+					zap.Int("user id", c.UserID),
+					zap.String("role", string(c.Role)),
+					zap.String("permission", string(p)),
+				)
+				a.writeError(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// claims are the claims of the token of a request that went through
+// authenticate.
+func claims(ctx context.Context) entity.Claims {
+	c, _ := ctx.Value(claimsKey).(entity.Claims)
+	return c
+}
+
+// userID is the authenticated user of a request that went through
+// authenticate.
+func userID(ctx context.Context) int {
+	return claims(ctx).UserID
+}
+
+// rateLimit rejects requests over limit with 429 and a Retry-After header.
+// Requests share a bucket when key returns the same value for them within
+// group. A failing store lets requests through rather than failing the API.
+func (a APIController) rateLimit(group string, limit ratelimit.Limit, key func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := a.limiter.Allow(r.Context(), group+":"+key(r), limit)
+			if err != nil {
+				logging.FromContext(r.Context(), a.l).Error("rate limiter failed", zap.Error(err))
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !res.Allowed {
+				metrics.RateLimited.WithLabelValues(group).Inc()
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(res.RetryAfter)))
+				a.writeError(w, http.StatusTooManyRequests, "Too many requests")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// retryAfterSeconds rounds d up to the whole seconds of a Retry-After header.
+func retryAfterSeconds(d time.Duration) int {
+	return max(1, int(math.Ceil(d.Seconds())))
+}
+
+// clientIP is the address the request came from. Forwarding headers are not
+// trusted, the service is expected to be reached directly.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// logRequests tags every request with an ID, reusing X-Request-ID when the
+// caller sent a usable one, and echoes it in the response. The services find
+// a logger carrying the ID in the request context. One access log line is
+// written once the request is served.
+func (a APIController) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("http.request_id", requestID))
+
+		ctx := logging.WithLogger(r.Context(), a.l.With(zap.String("request_id", requestID)))
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := responseStatus(ww)
+		fields := []zap.Field{
+			zap.String("method", r.Method),
+			zap.String("route", chi.RouteContext(r.Context()).RoutePattern()),
+			zap.String("path", r.URL.Path),
+			zap.Int("status", status),
+			zap.Int("bytes", ww.BytesWritten()),
+			zap.Duration("duration", time.Since(start)),
+			zap.String("remote_addr", r.RemoteAddr),
+		}
+		l := logging.FromContext(ctx, a.l)
+		if status >= http.StatusInternalServerError {
+			l.Warn("request served", fields...)
+			return
+		}
+		l.Info("request served", fields...)
+	})
+}
+
+// validRequestID accepts caller supplied IDs that are safe to log as is.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// instrument records the duration and status of every request. Requests are
+// labelled by their route pattern rather than by path, so /api/buy/{item}
+// stays a single series.
+func (a APIController) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		metrics.HTTPRequestDuration.
+			WithLabelValues(r.Method, route, strconv.Itoa(responseStatus(ww))).
+			Observe(time.Since(start).Seconds())
+	})
+}
+
+// trace opens the server span of a request, continuing the caller's trace
+// when it sent a traceparent header. The span is renamed after the route once
+// chi has matched it.
+func (a APIController) trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Extract(r.Context(), r.Header)
+		ctx, span := tracing.Start(ctx, r.Method+" "+r.URL.Path, trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		status := responseStatus(ww)
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.HTTPRoute(route),
+			semconv.HTTPResponseStatusCode(status),
+		)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// responseStatus is the status written through ww; handlers that never call
+// WriteHeader answered 200.
+func responseStatus(ww middleware.WrapResponseWriter) int {
+	if status := ww.Status(); status != 0 {
+		return status
+	}
+	return http.StatusOK
+}
+
+// withTimeout bounds the request context by the configured deadline, so the
+// work started by a handler is cancelled once the budget is spent or the
+// client goes away.
+func (a APIController) withTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), a.requestTimeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+)
+
+// runReconciliation checks every balance against the ledger now and returns
+// the report. Discrepancies are only corrected on approval.
+func (a APIController) runReconciliation(w http.ResponseWriter, r *http.Request) {
+	report, err := a.reconciliation.Run(r.Context())
+	if err != nil {
+		a.writeServiceError(w, err)
+		return
+	}
+	a.writeJSON(w, http.StatusCreated, NewReconciliationResponse(report))
+}
+
+// listReconciliations returns the latest reports without their
+// discrepancies. Query parameter: limit.
+func (a APIController) listReconciliations(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			a.writeError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	reports, err := a.reconciliation.List(r.Context(), limit)
+	if err != nil {
+		a.writeServiceError(w, err)
+		return
+	}
+
+	records := make([]ReconciliationResponse, len(reports))
+	for i := range reports {
+		records[i] = NewReconciliationResponse(&reports[i])
+	}
+	a.writeJSON(w, http.StatusOK, ReconciliationListResponse{Reports: &records})
+}
+
+func (a APIController) getReconciliation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		a.writeError(w, http.StatusNotFound, "Report not found")
+		return
+	}
+
+	report, err := a.reconciliation.Get(r.Context(), id)
+	if err != nil {
+		a.writeReconciliationError(w, err)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, NewReconciliationResponse(report))
+}
+
+// approveReconciliation corrects the balances of a pending report. The
+// response tells which discrepancies were corrected, the ones that changed
+// since the report was made are left alone.
+func (a APIController) approveReconciliation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		a.writeError(w, http.StatusNotFound, "Report not found")
+		return
+	}
+
+	report, err := a.reconciliation.Approve(r.Context(), id, claims(r.Context()).Username)
+	if err != nil {
+		a.writeReconciliationError(w, err)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, NewReconciliationResponse(report))
+}
+
+func (a APIController) writeReconciliationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrorReportNotFound):
+		a.writeError(w, http.StatusNotFound, "Report not found")
+	case errors.Is(err, repository.ErrorReportNotPending):
+		a.writeError(w, http.StatusConflict, "Report is not pending")
+	default:
+		a.writeServiceError(w, err)
+	}
+}
+
+// NewReconciliationResponse is the JSON form of report, shared with the
+// reconcile command.
+func NewReconciliationResponse(report *entity.Reconciliation) ReconciliationResponse {
+	status := string(report.Status)
+	resp := ReconciliationResponse{
+		ID:         &report.ID,
+		CreatedAt:  &report.CreatedAt,
+		Users:      &report.Users,
+		Status:     &status,
+		ApprovedAt: report.ApprovedAt,
+	}
+	if report.ApprovedBy != "" {
+		resp.ApprovedBy = &report.ApprovedBy
+	}
+	if report.Discrepancies != nil {
+		records := make([]DiscrepancyRecord, len(report.Discrepancies))
+		for i := range report.Discrepancies {
+			d := &report.Discrepancies[i]
+			delta := d.Delta()
+			records[i] = DiscrepancyRecord{
+				UserID:    &d.UserID,
+				Username:  &d.Username,
+				Balance:   &d.Balance,
+				Expected:  &d.Expected,
+				Delta:     &delta,
+				Corrected: &d.Corrected,
+			}
+		}
+		resp.Discrepancies = &records
+	}
+	return resp
+}
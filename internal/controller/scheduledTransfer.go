@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"AvitoTech/internal/service"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+)
+
+// apiScheduleTransfer schedules a transfer from the caller. The body has
+// either runAt for a one-time transfer or schedule for a recurring one:
+// {"toUser": "bob", "amount": 10, "schedule": "0 9 * * MON"}.
+func (a APIController) apiScheduleTransfer(w http.ResponseWriter, r *http.Request) {
+	var req ScheduleTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ToUser == "" {
+		a.writeError(w, http.StatusBadRequest, "Invalid request: missing user")
+		return
+	}
+	spec := ""
+	if req.Schedule != nil {
+		spec = *req.Schedule
+	}
+
+	transfer, err := a.transfers.Schedule(r.Context(), userID(r.Context()), req.ToUser, req.Amount, req.RunAt, spec)
+	if err != nil {
+		a.writeScheduledTransferError(w, err)
+		return
+	}
+	a.writeJSON(w, http.StatusCreated, scheduledTransferRecord(transfer))
+}
+
+// apiListScheduledTransfers returns the transfers of the caller, newest
+// first. Query parameter: limit.
+func (a APIController) apiListScheduledTransfers(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			a.writeError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	transfers, err := a.transfers.List(r.Context(), userID(r.Context()), limit)
+	if err != nil {
+		a.writeServiceError(w, err)
+		return
+	}
+
+	records := make([]ScheduledTransferRecord, len(transfers))
+	for i := range transfers {
+		records[i] = scheduledTransferRecord(&transfers[i])
+	}
+	a.writeJSON(w, http.StatusOK, ScheduledTransferListResponse{Transfers: &records})
+}
+
+func (a APIController) apiCancelScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		a.writeError(w, http.StatusNotFound, "Transfer not found")
+		return
+	}
+
+	transfer, err := a.transfers.Cancel(r.Context(), userID(r.Context()), id)
+	if err != nil {
+		a.writeScheduledTransferError(w, err)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, scheduledTransferRecord(transfer))
+}
+
+func (a APIController) writeScheduledTransferError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrTransferAmount),
+		errors.Is(err, service.ErrInvalidSchedule),
+		errors.Is(err, service.ErrScheduleTooFrequent),
+		errors.Is(err, service.ErrRunAtPast),
+		errors.Is(err, service.ErrSelfTransfer):
+		a.writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, repository.ErrorUserNotFound):
+		a.writeError(w, http.StatusNotFound, "User not found")
+	case errors.Is(err, repository.ErrorTransferNotFound):
+		a.writeError(w, http.StatusNotFound, "Transfer not found")
+	case errors.Is(err, repository.ErrorTransferNotActive):
+		a.writeError(w, http.StatusConflict, "Transfer is not active")
+	default:
+		a.writeServiceError(w, err)
+	}
+}
+
+func scheduledTransferRecord(t *entity.ScheduledTransfer) ScheduledTransferRecord {
+	status := string(t.Status)
+	record := ScheduledTransferRecord{
+		ID:        &t.ID,
+		ToUser:    &t.Receiver,
+		Amount:    &t.Amount,
+		Status:    &status,
+		NextRunAt: t.NextRunAt,
+		LastRunAt: t.LastRunAt,
+		Failures:  &t.Failures,
+		CreatedAt: &t.CreatedAt,
+	}
+	if t.Recurring() {
+		record.Schedule = &t.Schedule
+	}
+	if t.LastError != "" {
+		record.LastError = &t.LastError
+	}
+	return record
+}
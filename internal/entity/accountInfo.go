@@ -1,10 +1,15 @@
 package entity
 
 type AccountInfo struct {
-	Received  []Operation
-	Sent      []Operation
+	Received []Operation
+	Sent     []Operation
+	// Coins are the coins the user can spend, Held the coins locked by
+	// active holds on top of them.
 	Coins     int
+	Held      int
 	Inventory map[string]int
+	// Limits are the spending limits in effect for the user.
+	Limits []SpendingLimit
 }
 
 type Operation struct {
@@ -12,4 +17,7 @@ type Operation struct {
 	FromUser string
 	ToUser   string
 	Amount   int
+	// Reason is set for grants and deductions by an operator and for
+	// released holds.
+	Reason string
 }
@@ -0,0 +1,14 @@
+package entity
+
+// SystemAccount is the counterpart of the operations that create or remove
+// coins. It isn't a user and can't be signed up.
+const SystemAccount = "system"
+
+// Adjustment changes the balances of Usernames by Amount each: positive
+// amounts are granted from the system account, negative ones are deducted to
+// it.
+type Adjustment struct {
+	Usernames []string
+	Amount    int
+	Reason    string
+}
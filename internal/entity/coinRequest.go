@@ -0,0 +1,43 @@
+package entity
+
+import "time"
+
+// CoinRequestStatus is the state of a coin request.
+type CoinRequestStatus string
+
+const (
+	// CoinRequestPending waits for the payer to accept or decline it.
+	CoinRequestPending CoinRequestStatus = "pending"
+	// CoinRequestAccepted has been paid.
+	CoinRequestAccepted CoinRequestStatus = "accepted"
+	// CoinRequestDeclined has been turned down by the payer.
+	CoinRequestDeclined CoinRequestStatus = "declined"
+	// CoinRequestExpired was left pending past its expiry.
+	CoinRequestExpired CoinRequestStatus = "expired"
+)
+
+// CoinRequest is a request by Requester to be sent Amount coins by Payer.
+type CoinRequest struct {
+	ID          int64
+	RequesterID int
+	Requester   string
+	PayerID     int
+	Payer       string
+	Amount      int
+	Memo        string
+	Status      CoinRequestStatus
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+	ResolvedAt  *time.Time
+}
+
+// CoinRequestFilter selects the requests of a user, newest first.
+type CoinRequestFilter struct {
+	UserID int
+	// Outgoing selects the requests made by the user instead of the ones
+	// addressed to them.
+	Outgoing bool
+	// Status matches every status if empty.
+	Status CoinRequestStatus
+	Limit  int
+}
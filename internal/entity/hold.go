@@ -0,0 +1,30 @@
+package entity
+
+import "time"
+
+// HoldStatus is the state of a hold.
+type HoldStatus string
+
+const (
+	// HoldActive keeps the coins locked in the balance of the sponsor.
+	HoldActive HoldStatus = "active"
+	// HoldReleased has been paid out to the recipient.
+	HoldReleased HoldStatus = "released"
+	// HoldCancelled has returned the coins to the sponsor.
+	HoldCancelled HoldStatus = "cancelled"
+)
+
+// Hold locks Amount coins of Sponsor until they are released to a recipient
+// or the hold is cancelled.
+type Hold struct {
+	ID          int64
+	SponsorID   int
+	Sponsor     string
+	Amount      int
+	Memo        string
+	Status      HoldStatus
+	RecipientID *int
+	Recipient   string
+	CreatedAt   time.Time
+	ResolvedAt  *time.Time
+}
@@ -0,0 +1,18 @@
+package entity
+
+import "time"
+
+// JobRun is a run of a scheduled job.
+type JobRun struct {
+	ID  int64
+	Job string
+	// ScheduledAt is the time the run was due, it identifies the run.
+	ScheduledAt time.Time
+	StartedAt   time.Time
+	FinishedAt  *time.Time
+	// Error is the failure of the run, empty for a successful or unfinished
+	// one.
+	Error string
+	// Replica is the host that ran the job.
+	Replica string
+}
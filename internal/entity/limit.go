@@ -0,0 +1,65 @@
+package entity
+
+import "time"
+
+// LimitOperation is the kind of spending a limit caps.
+type LimitOperation string
+
+const (
+	// LimitTransfer caps the coins sent to other users.
+	LimitTransfer LimitOperation = "transfer"
+	// LimitPurchase caps the coins spent on items.
+	LimitPurchase LimitOperation = "purchase"
+)
+
+// LimitPeriod is the rolling window a limit applies to.
+type LimitPeriod string
+
+const (
+	LimitDaily  LimitPeriod = "daily"
+	LimitWeekly LimitPeriod = "weekly"
+)
+
+// LimitOperations and LimitPeriods list every operation and period, in the
+// order limits are reported in.
+var (
+	LimitOperations = []LimitOperation{LimitTransfer, LimitPurchase}
+	LimitPeriods    = []LimitPeriod{LimitDaily, LimitWeekly}
+)
+
+// Valid reports whether o is a known operation.
+func (o LimitOperation) Valid() bool {
+	return o == LimitTransfer || o == LimitPurchase
+}
+
+// Valid reports whether p is a known period.
+func (p LimitPeriod) Valid() bool {
+	return p == LimitDaily || p == LimitWeekly
+}
+
+// Window is the length of the period.
+func (p LimitPeriod) Window() time.Duration {
+	if p == LimitWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// SpendingLimit caps the coins a user spends on Operation within the last
+// Period.
+type SpendingLimit struct {
+	Operation LimitOperation
+	Period    LimitPeriod
+	// Amount is the cap, 0 for no limit.
+	Amount int
+	// Override is set if Amount was set for the user instead of configured
+	// for everyone.
+	Override bool
+	// Used is what the user spent within the period.
+	Used int
+}
+
+// Remaining is what the user can still spend within the period.
+func (l SpendingLimit) Remaining() int {
+	return max(l.Amount-l.Used, 0)
+}
@@ -0,0 +1,16 @@
+package entity
+
+import "time"
+
+// Lockout bans signing in as Username until LockedUntil after too many wrong
+// passwords. Lockouts are kept as an audit trail, unlocking only marks them.
+type Lockout struct {
+	ID          int
+	Username    string
+	IP          string
+	Failures    int
+	LockedUntil time.Time
+	CreatedAt   time.Time
+	UnlockedAt  *time.Time
+	UnlockedBy  string
+}
@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// PasswordReset is a single-use token letting a user set a new password
+// without knowing the current one. Only a hash of the token is stored.
+type PasswordReset struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
@@ -0,0 +1,43 @@
+package entity
+
+import "time"
+
+// ReconciliationStatus is the state of a reconciliation report.
+type ReconciliationStatus string
+
+const (
+	// ReconciliationClean reports that every balance matched.
+	ReconciliationClean ReconciliationStatus = "clean"
+	// ReconciliationPending reports discrepancies waiting for approval.
+	ReconciliationPending ReconciliationStatus = "pending"
+	// ReconciliationApplied reports discrepancies corrected after approval.
+	ReconciliationApplied ReconciliationStatus = "applied"
+)
+
+// Reconciliation is the report of a check of every balance against the
+// balance recomputed from the signup grant, the history and the purchases.
+type Reconciliation struct {
+	ID        int
+	CreatedAt time.Time
+	// Users is the number of users checked.
+	Users         int
+	Status        ReconciliationStatus
+	Discrepancies []Discrepancy
+	ApprovedBy    string
+	ApprovedAt    *time.Time
+}
+
+// Discrepancy is a user whose balance differs from the recomputed one.
+type Discrepancy struct {
+	UserID   int
+	Username string
+	Balance  int
+	Expected int
+	// Corrected is set once the balance has been set to Expected.
+	Corrected bool
+}
+
+// Delta is the correction that makes the balance match.
+func (d Discrepancy) Delta() int {
+	return d.Expected - d.Balance
+}
@@ -0,0 +1,65 @@
+package entity
+
+// Role decides what a user may do besides using the shop.
+type Role string
+
+const (
+	RoleUser    Role = "user"
+	RoleAdmin   Role = "admin"
+	RoleAuditor Role = "auditor"
+)
+
+// Permission guards a group of operator routes.
+type Permission string
+
+const (
+	// PermissionViewSettings allows reading the runtime settings, such as the
+	// log level.
+	PermissionViewSettings   Permission = "settings:view"
+	PermissionManageSettings Permission = "settings:manage"
+	PermissionViewUsers      Permission = "users:view"
+	// PermissionManageUsers allows lifting lockouts, issuing password resets,
+	// disabling and deleting users.
+	PermissionManageUsers Permission = "users:manage"
+	PermissionManageRoles Permission = "roles:manage"
+	// PermissionManageCoins allows granting and deducting coins.
+	PermissionManageCoins Permission = "coins:manage"
+	// PermissionViewAudit allows reading and verifying the audit log.
+	PermissionViewAudit Permission = "audit:view"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin: {
+		PermissionViewSettings,
+		PermissionViewUsers,
+		PermissionManageSettings,
+		PermissionManageUsers,
+		PermissionManageRoles,
+		PermissionManageCoins,
+		PermissionViewAudit,
+	},
+	RoleAuditor: {
+		PermissionViewSettings,
+		PermissionViewUsers,
+		PermissionViewAudit,
+	},
+}
+
+// Valid reports whether r is one of the known roles.
+func (r Role) Valid() bool {
+	switch r {
+	case RoleUser, RoleAdmin, RoleAuditor:
+		return true
+	}
+	return false
+}
+
+// Can reports whether r is granted p.
+func (r Role) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package entity
+
+import "time"
+
+// ScheduledTransferStatus is the state of a scheduled transfer.
+type ScheduledTransferStatus string
+
+const (
+	// ScheduledTransferActive waits for its next run.
+	ScheduledTransferActive ScheduledTransferStatus = "active"
+	// ScheduledTransferCompleted is a one-time transfer that has run.
+	ScheduledTransferCompleted ScheduledTransferStatus = "completed"
+	// ScheduledTransferCancelled has been cancelled by the sender.
+	ScheduledTransferCancelled ScheduledTransferStatus = "cancelled"
+	// ScheduledTransferFailed was given up after failing repeatedly.
+	ScheduledTransferFailed ScheduledTransferStatus = "failed"
+)
+
+// ScheduledTransfer is a transfer of Amount coins from Sender to Receiver run
+// by the service, once or on a schedule.
+type ScheduledTransfer struct {
+	ID         int64
+	SenderID   int
+	Sender     string
+	ReceiverID int
+	Receiver   string
+	Amount     int
+	// Schedule is the cron spec or @every interval of a recurring transfer,
+	// empty for a one-time transfer.
+	Schedule string
+	Status   ScheduledTransferStatus
+	// NextRunAt is nil once the transfer is no longer active.
+	NextRunAt *time.Time
+	LastRunAt *time.Time
+	// Failures counts the failed runs since the last successful one.
+	Failures  int
+	LastError string
+	CreatedAt time.Time
+}
+
+// Recurring reports whether the transfer runs on a schedule.
+func (t ScheduledTransfer) Recurring() bool {
+	return t.Schedule != ""
+}
@@ -0,0 +1,10 @@
+package entity
+
+// Claims are the facts carried by an access token.
+type Claims struct {
+	UserID   int
+	Username string
+	// Version is the TokenVersion of the user when the token was issued.
+	Version int
+	Role    Role
+}
@@ -1,8 +1,33 @@
 package entity
 
+import "time"
+
 type User struct {
 	ID       int
 	Username string
 	Password string
 	Balance  int
+	// TokenVersion is bumped whenever the sessions of the user are revoked,
+	// tokens carrying an older version are refused.
+	TokenVersion int
+	Role         Role
+	// DisabledAt is set while the user is banned from signing in.
+	DisabledAt *time.Time
+	// DeletedAt is set for deleted users. Their row is kept for the history,
+	// the username can't be taken again.
+	DeletedAt *time.Time
+}
+
+// Active reports whether the user may sign in and use the API.
+func (u User) Active() bool {
+	return u.DisabledAt == nil && u.DeletedAt == nil
+}
+
+// UserFilter selects a page of users ordered by username.
+type UserFilter struct {
+	// Prefix matches the beginning of the username, empty matches everyone.
+	Prefix         string
+	IncludeDeleted bool
+	Limit          int
+	Offset         int
 }
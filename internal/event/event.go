@@ -0,0 +1,55 @@
+// Package event is an in-process bus for domain events. Services publish an
+// event once their change has been committed; subscribers such as caches react
+// to it before Publish returns.
+package event
+
+import (
+	"context"
+	"sync"
+)
+
+// BalanceChanged reports that the balances of the listed users were changed by
+// a committed operation.
+type BalanceChanged struct {
+	UserIDs []int
+}
+
+type Handler func(ctx context.Context, e any)
+
+type Publisher interface {
+	Publish(ctx context.Context, e any)
+}
+
+type Subscriber interface {
+	Subscribe(h Handler)
+}
+
+// Bus delivers every published event synchronously to all subscribers.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+// Publish calls the handlers in subscription order. The handlers keep running
+// even if ctx has been cancelled in the meantime, as the change they react to
+// is already committed.
+func (b *Bus) Publish(ctx context.Context, e any) {
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+
+	ctx = context.WithoutCancel(ctx)
+	for _, h := range handlers {
+		h(ctx, e)
+	}
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
@@ -0,0 +1,56 @@
+// Package logging builds the service logger and carries the per-request child
+// logger through the context.
+package logging
+
+import (
+	"AvitoTech/internal/tracing"
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type ctxKey struct{}
+
+// New builds the root logger. level is a zap level name and format is either
+// "json" or "console". The returned AtomicLevel changes the level of the
+// logger and of every child derived from it at runtime.
+func New(level, format string) (*zap.Logger, zap.AtomicLevel, error) {
+	atomicLevel, err := zap.ParseAtomicLevel(level)
+	if err != nil {
+		return nil, zap.AtomicLevel{}, err
+	}
+
+	var c zap.Config
+	switch format {
+	case "json":
+		c = zap.NewProductionConfig()
+	case "console":
+		c = zap.NewDevelopmentConfig()
+	default:
+		return nil, zap.AtomicLevel{}, fmt.Errorf("unknown log format %q", format)
+	}
+	c.Level = atomicLevel
+	c.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	logger, err := c.Build()
+	if err != nil {
+		return nil, zap.AtomicLevel{}, err
+	}
+	return logger, atomicLevel, nil
+}
+
+// WithLogger returns ctx carrying l as the logger of the request.
+func WithLogger(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the request logger stored in ctx, or fallback outside
+// of a request, annotated with the IDs of the current span.
+func FromContext(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	l, ok := ctx.Value(ctxKey{}).(*zap.Logger)
+	if !ok {
+		l = fallback
+	}
+	return tracing.Logger(ctx, l)
+}
@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"testing"
+)
+
+func TestFromContext(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	fallback := zap.New(core)
+
+	FromContext(context.Background(), fallback).Info("outside of a request")
+
+	ctx := WithLogger(context.Background(), fallback.With(zap.String("request_id", "abc")))
+	FromContext(ctx, fallback).Info("inside a request")
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	assert.NotContains(t, entries[0].ContextMap(), "request_id")
+	assert.Equal(t, "abc", entries[1].ContextMap()["request_id"])
+}
+
+func TestNew_LevelChangesAtRuntime(t *testing.T) {
+	logger, level, err := New("warn", "json")
+	require.NoError(t, err)
+
+	assert.False(t, logger.Core().Enabled(zapcore.InfoLevel))
+
+	level.SetLevel(zapcore.DebugLevel)
+	assert.True(t, logger.Core().Enabled(zapcore.DebugLevel))
+	assert.True(t, logger.With(zap.String("request_id", "abc")).Core().Enabled(zapcore.DebugLevel))
+}
+
+func TestNew_InvalidSettings(t *testing.T) {
+	_, _, err := New("loud", "json")
+	assert.Error(t, err)
+
+	_, _, err = New("info", "xml")
+	assert.Error(t, err)
+}
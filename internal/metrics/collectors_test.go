@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"AvitoTech/internal/cache"
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDBStatsCollector(t *testing.T) {
+	c := NewDBStatsCollector(func() sql.DBStats {
+		return sql.DBStats{
+			MaxOpenConnections: 10,
+			OpenConnections:    4,
+			InUse:              3,
+			Idle:               1,
+			WaitCount:          2,
+			WaitDuration:       1500 * time.Millisecond,
+		}
+	})
+
+	err := testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP avito_shop_db_in_use_connections Connections currently in use.
+# TYPE avito_shop_db_in_use_connections gauge
+avito_shop_db_in_use_connections 3
+# HELP avito_shop_db_wait_duration_seconds_total Time blocked waiting for a new connection.
+# TYPE avito_shop_db_wait_duration_seconds_total counter
+avito_shop_db_wait_duration_seconds_total 1.5
+`), "avito_shop_db_in_use_connections", "avito_shop_db_wait_duration_seconds_total")
+	require.NoError(t, err)
+	assert.Equal(t, 6, testutil.CollectAndCount(c))
+}
+
+func TestCacheCollector(t *testing.T) {
+	c := NewCacheCollector("info", func() cache.Stats {
+		return cache.Stats{Hits: 7, Misses: 3}
+	})
+
+	err := testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP avito_shop_cache_hits_total Cache lookups served from the cache.
+# TYPE avito_shop_cache_hits_total counter
+avito_shop_cache_hits_total{cache="info"} 7
+# HELP avito_shop_cache_misses_total Cache lookups that had to be read through.
+# TYPE avito_shop_cache_misses_total counter
+avito_shop_cache_misses_total{cache="info"} 3
+`))
+	require.NoError(t, err)
+}
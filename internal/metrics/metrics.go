@@ -0,0 +1,125 @@
+// Package metrics holds the Prometheus collectors of the service and the
+// registry they are exposed from on /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+const namespace = "avito_shop"
+
+// Registry holds every collector of the service. Collectors bound to an App
+// instance, such as the database pool, are registered by the App itself.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequestDuration = promauto.With(Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of HTTP requests by route and response status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	RateLimited = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected with 429 by route group.",
+	}, []string{"group"})
+
+	CoinsTransferred = promauto.With(Registry).NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "coins_transferred_total",
+		Help:      "Coins sent between users.",
+	})
+
+	CoinsAdjusted = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "coins_adjusted_total",
+		Help:      "Coins granted or deducted by operators.",
+	}, []string{"direction"})
+
+	Purchases = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "purchases_total",
+		Help:      "Items bought from the shop.",
+	}, []string{"item"})
+
+	Signups = promauto.With(Registry).NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "signups_total",
+		Help:      "Users created on their first authentication.",
+	})
+
+	FailedLogins = promauto.With(Registry).NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "failed_logins_total",
+		Help:      "Authentications rejected because of a wrong password.",
+	})
+
+	Lockouts = promauto.With(Registry).NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lockouts_total",
+		Help:      "Accounts locked after repeated failed logins.",
+	})
+
+	BalanceDiscrepancies = promauto.With(Registry).NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "balance_discrepancies",
+		Help:      "Users whose balance differed from the ledger at the last reconciliation.",
+	})
+
+	AuditFailures = promauto.With(Registry).NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audit_failures_total",
+		Help:      "Actions that could not be written to the audit log.",
+	})
+
+	CoinsAllowance = promauto.With(Registry).NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "coins_allowance_total",
+		Help:      "Coins granted by the periodic allowance.",
+	})
+
+	CoinsExpired = promauto.With(Registry).NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "coins_expired_total",
+		Help:      "Unspent coins taken away after expiring.",
+	})
+
+	ScheduledTransferFailures = promauto.With(Registry).NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scheduled_transfer_failures_total",
+		Help:      "Runs of scheduled transfers that moved no coins.",
+	})
+
+	SchedulerLeader = promauto.With(Registry).NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "leader",
+		Help:      "1 while this replica runs the scheduled jobs.",
+	})
+
+	JobRuns = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "job_runs_total",
+		Help:      "Runs of the scheduled jobs by job and outcome.",
+	}, []string{"job", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newTestStore() (*MemoryStore, *time.Time) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func TestMemoryStore_BurstThenRefill(t *testing.T) {
+	s, now := newTestStore()
+	ctx := context.Background()
+	limit := Limit{PerMinute: 60, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		res, err := s.Allow(ctx, "ip:1", limit)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+	}
+
+	res, err := s.Allow(ctx, "ip:1", limit)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+
+	*now = now.Add(500 * time.Millisecond)
+	res, err = s.Allow(ctx, "ip:1", limit)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+
+	*now = now.Add(500 * time.Millisecond)
+	res, err = s.Allow(ctx, "ip:1", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestMemoryStore_KeysAreIndependent(t *testing.T) {
+	s, _ := newTestStore()
+	ctx := context.Background()
+	limit := Limit{PerMinute: 1, Burst: 1}
+
+	res, _ := s.Allow(ctx, "user:1", limit)
+	assert.True(t, res.Allowed)
+	res, _ = s.Allow(ctx, "user:1", limit)
+	assert.False(t, res.Allowed)
+
+	res, _ = s.Allow(ctx, "user:2", limit)
+	assert.True(t, res.Allowed)
+}
+
+func TestMemoryStore_DisabledLimit(t *testing.T) {
+	s, _ := newTestStore()
+
+	for i := 0; i < 100; i++ {
+		res, err := s.Allow(context.Background(), "ip:1", Limit{})
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+	}
+	assert.Empty(t, s.buckets)
+}
+
+func TestMemoryStore_SweepsRefilledBuckets(t *testing.T) {
+	s, now := newTestStore()
+	ctx := context.Background()
+
+	_, _ = s.Allow(ctx, "ip:1", Limit{PerMinute: 60, Burst: 10})
+	for i := 0; i < 5; i++ {
+		_, _ = s.Allow(ctx, "ip:2", Limit{PerMinute: 1, Burst: 10})
+	}
+	require.Len(t, s.buckets, 2)
+
+	*now = now.Add(sweepInterval)
+	_, _ = s.Allow(ctx, "ip:3", Limit{PerMinute: 60, Burst: 10})
+
+	assert.NotContains(t, s.buckets, "ip:1")
+	assert.Contains(t, s.buckets, "ip:2")
+	assert.Contains(t, s.buckets, "ip:3")
+}
@@ -0,0 +1,33 @@
+// Package ratelimit implements token-bucket rate limiting behind a Store
+// interface, so that the in-memory store can be swapped for a shared one when
+// the service runs with several replicas.
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limit is a token bucket: Burst requests can be made at once and the bucket
+// refills at PerMinute tokens a minute. A zero Limit lets everything through.
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+func (l Limit) Disabled() bool {
+	return l.PerMinute <= 0 || l.Burst <= 0
+}
+
+// Result is the outcome of a single Allow call.
+type Result struct {
+	Allowed bool
+	// RetryAfter is how long until the next request would be allowed, it is
+	// only set when Allowed is false.
+	RetryAfter time.Duration
+}
+
+type Store interface {
+	// Allow takes a token from the bucket of key, created full on first use.
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
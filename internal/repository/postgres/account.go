@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go.uber.org/zap"
+)
+
+type AccountRepository struct {
+	l  *zap.Logger
+	db DB
+}
+
+// accountInfoQuery assembles the whole account overview in one statement:
+// history and inventory are aggregated to JSON next to the balance.
+const accountInfoQuery = `
+	WITH account AS (
+		SELECT user_id, username, balance, held
+		FROM users
+		WHERE user_id = $1
+	)
+	SELECT
+		account.balance - account.held,
+		account.held,
+		COALESCE((
+			SELECT json_agg(json_build_object(
+				'ID', h.id, 'FromUser', h.sender_name, 'ToUser', h.receiver_name, 'Amount', h.amount,
+				'Reason', COALESCE(h.reason, '')
+			) ORDER BY h.id)
+			FROM history h
+			WHERE h.sender_name = account.username
+		), '[]'),
+		COALESCE((
+			SELECT json_agg(json_build_object(
+				'ID', h.id, 'FromUser', h.sender_name, 'ToUser', h.receiver_name, 'Amount', h.amount,
+				'Reason', COALESCE(h.reason, '')
+			) ORDER BY h.id)
+			FROM history h
+			WHERE h.receiver_name = account.username
+		), '[]'),
+		COALESCE((
+			SELECT json_object_agg(i.item, i.count)
+			FROM (
+				SELECT item, count(*) AS count
+				FROM inventory
+				WHERE owner_id = account.user_id
+				GROUP BY item
+			) i
+		), '{}')
+	FROM account
+`
+
+// GetAccountInfo reads the account overview in a single round-trip. The read
+// runs in a read-only REPEATABLE READ transaction, so the balance always agrees
+// with the history it is shown with.
+func (a AccountRepository) GetAccountInfo(ctx context.Context, userID int) (*entity.AccountInfo, error) {
+	var info entity.AccountInfo
+	opts := TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+
+	err := withTxOptions(ctx, a.l, a.db, opts, func(tx Tx) error {
+		var sent, received, inventory []byte
+		err := tx.QueryRow(ctx, accountInfoQuery, userID).Scan(&info.Coins, &info.Held, &sent, &received, &inventory)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return repository.ErrorUserNotFound
+			}
+			a.l.Error("failed to query account info", zap.Int("user id", userID), zap.Error(err))
+			return err
+		}
+
+		if err = json.Unmarshal(sent, &info.Sent); err != nil {
+			return err
+		}
+		if err = json.Unmarshal(received, &info.Received); err != nil {
+			return err
+		}
+		return json.Unmarshal(inventory, &info.Inventory)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+func NewAccountRepository(
+	l *zap.Logger,
+	db DB,
+) repository.AccountRepository {
+	return &AccountRepository{
+		l:  l,
+		db: db,
+	}
+}
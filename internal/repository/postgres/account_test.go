@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestGetAccountInfo(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			users := NewUserRepository(logger, conn)
+			history := NewHistoryRepository(logger, conn)
+			inventory := NewInventoryRepository(logger, conn)
+			accounts := NewAccountRepository(logger, conn)
+
+			user, err := users.InsertUser(ctx, &entity.User{Username: "account_" + name, Password: "pass", Balance: 700})
+			assert.NoError(t, err)
+
+			sent, err := history.InsertOperation(ctx, entity.Operation{FromUser: user.Username, ToUser: "peer_" + name, Amount: 30})
+			assert.NoError(t, err)
+			received, err := history.InsertOperation(ctx, entity.Operation{FromUser: "peer_" + name, ToUser: user.Username, Amount: 50})
+			assert.NoError(t, err)
+			for _, title := range []string{"cup", "cup", "pen"} {
+				_, err = inventory.InsertItem(ctx, user.ID, title, 10)
+				assert.NoError(t, err)
+			}
+
+			info, err := accounts.GetAccountInfo(ctx, user.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, 700, info.Coins)
+			assert.Equal(t, []entity.Operation{*sent}, info.Sent)
+			assert.Equal(t, []entity.Operation{*received}, info.Received)
+			assert.Equal(t, map[string]int{"cup": 2, "pen": 1}, info.Inventory)
+		})
+	}
+}
+
+func TestGetAccountInfoEmpty(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	user, err := NewUserRepository(logger, sqlDB).InsertUser(ctx, &entity.User{Username: "account_empty", Password: "pass", Balance: 1000})
+	assert.NoError(t, err)
+
+	info, err := NewAccountRepository(logger, sqlDB).GetAccountInfo(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, info.Coins)
+	assert.Empty(t, info.Sent)
+	assert.Empty(t, info.Received)
+	assert.Empty(t, info.Inventory)
+}
+
+func TestGetAccountInfoUserNotFound(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+
+	info, err := NewAccountRepository(logger, sqlDB).GetAccountInfo(context.Background(), 999999)
+	assert.ErrorIs(t, err, repository.ErrorUserNotFound)
+	assert.Nil(t, info)
+}
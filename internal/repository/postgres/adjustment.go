@@ -0,0 +1,123 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+)
+
+type AdjustmentRepository struct {
+	l  *zap.Logger
+	db DB
+}
+
+// Apply locks every user of the adjustment before checking any balance, so
+// that a deduction can't overdraw a balance changed meanwhile.
+func (a AdjustmentRepository) Apply(ctx context.Context, adjustment entity.Adjustment, actor string) ([]int, error) {
+	var ids []int
+	err := retryOnConflict(ctx, a.l, func() error {
+		var err error
+		ids, err = a.apply(ctx, adjustment, actor)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (a AdjustmentRepository) apply(ctx context.Context, adjustment entity.Adjustment, actor string) ([]int, error) {
+	var ids []int
+	err := withTx(ctx, a.l, a.db, func(tx Tx) error {
+		rows, err := tx.Query(ctx, `
+		SELECT user_id, username, balance - held
+		FROM users
+		WHERE username = ANY($1) AND deleted_at IS NULL
+		ORDER BY user_id
+		FOR UPDATE
+	`, adjustment.Usernames)
+		if err != nil {
+			a.l.Error("Failed to lock balances", zap.Error(err))
+			return err
+		}
+
+		balances := make(map[string]int, len(adjustment.Usernames))
+		ids = make([]int, 0, len(adjustment.Usernames))
+		for rows.Next() {
+			var id, balance int
+			var username string
+			if err = rows.Scan(&id, &username, &balance); err != nil {
+				rows.Close()
+				return err
+			}
+			balances[username] = balance
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		for _, username := range adjustment.Usernames {
+			balance, ok := balances[username]
+			if !ok {
+				return fmt.Errorf("%w: %s", repository.ErrorUserNotFound, username)
+			}
+			if balance+adjustment.Amount < 0 {
+				return fmt.Errorf("%w: %s", repository.ErrorInsufficientBalance, username)
+			}
+		}
+
+		// grants are sent by the system account, deductions are sent to it
+		err = tx.ExecBatch(ctx,
+			Query{SQL: "UPDATE users SET balance = balance + $1 WHERE user_id = ANY($2)", Args: []any{adjustment.Amount, ids}},
+			Query{SQL: `
+		INSERT INTO history (sender_name, receiver_name, amount, reason)
+		SELECT
+			CASE WHEN $3 > 0 THEN $1::text ELSE u END,
+			CASE WHEN $3 > 0 THEN u ELSE $1::text END,
+			abs($3),
+			$4
+		FROM unnest($2::text[]) AS u
+	`, Args: []any{entity.SystemAccount, adjustment.Usernames, adjustment.Amount, adjustment.Reason}},
+		)
+		if err != nil {
+			a.l.Error("Failed to apply adjustment", zap.Error(err))
+			return err
+		}
+
+		// an entry per user, so that the trail of each one is found by target
+		action, amount := entity.AuditGrant, adjustment.Amount
+		if amount < 0 {
+			action, amount = entity.AuditDeduct, -amount
+		}
+		entries := make([]entity.AuditEntry, 0, len(adjustment.Usernames))
+		for _, username := range adjustment.Usernames {
+			entries = append(entries, newAuditEntry(actor, action, username, map[string]any{
+				"amount": amount,
+				"reason": adjustment.Reason,
+			}))
+		}
+		if _, err = appendAudit(ctx, tx, entries...); err != nil {
+			a.l.Error("Failed to write audit log", zap.Error(err))
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func NewAdjustmentRepository(
+	l *zap.Logger,
+	db DB,
+) repository.AdjustmentRepository {
+	return &AdjustmentRepository{
+		l:  l,
+		db: db,
+	}
+}
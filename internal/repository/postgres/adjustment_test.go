@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestApplyAdjustment(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			users := NewUserRepository(logger, conn)
+			adjustments := NewAdjustmentRepository(logger, conn)
+			accounts := NewAccountRepository(logger, conn)
+
+			rich, err := users.InsertUser(ctx, &entity.User{Username: "rich_" + name, Password: "pass", Balance: 100})
+			require.NoError(t, err)
+			poor, err := users.InsertUser(ctx, &entity.User{Username: "poor_" + name, Password: "pass", Balance: 10})
+			require.NoError(t, err)
+
+			ids, err := adjustments.Apply(ctx, entity.Adjustment{
+				Usernames: []string{rich.Username, poor.Username},
+				Amount:    50,
+				Reason:    "bonus",
+			}, "root")
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []int{rich.ID, poor.ID}, ids)
+
+			// the deduction would overdraw poor, so rich keeps its coins too
+			_, err = adjustments.Apply(ctx, entity.Adjustment{
+				Usernames: []string{rich.Username, poor.Username},
+				Amount:    -100,
+				Reason:    "penalty",
+			}, "root")
+			assert.ErrorIs(t, err, repository.ErrorInsufficientBalance)
+
+			_, err = adjustments.Apply(ctx, entity.Adjustment{
+				Usernames: []string{rich.Username, "missing_" + name},
+				Amount:    10,
+				Reason:    "bonus",
+			}, "root")
+			assert.ErrorIs(t, err, repository.ErrorUserNotFound)
+
+			_, err = adjustments.Apply(ctx, entity.Adjustment{Usernames: []string{rich.Username}, Amount: -30, Reason: "penalty"}, "root")
+			require.NoError(t, err)
+
+			info, err := accounts.GetAccountInfo(ctx, rich.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 120, info.Coins)
+			require.Len(t, info.Received, 1)
+			assert.Equal(t, entity.Operation{ID: info.Received[0].ID, FromUser: entity.SystemAccount, ToUser: rich.Username, Amount: 50, Reason: "bonus"}, info.Received[0])
+			require.Len(t, info.Sent, 1)
+			assert.Equal(t, entity.Operation{ID: info.Sent[0].ID, FromUser: rich.Username, ToUser: entity.SystemAccount, Amount: 30, Reason: "penalty"}, info.Sent[0])
+
+			info, err = accounts.GetAccountInfo(ctx, poor.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 60, info.Coins)
+
+			// the refused adjustments left no entries behind
+			entries, err := NewAuditRepository(logger, conn).List(ctx, entity.AuditFilter{Target: rich.Username, Limit: 10})
+			require.NoError(t, err)
+			require.Len(t, entries, 2)
+			assert.Equal(t, entity.AuditDeduct, entries[0].Action)
+			assert.Equal(t, "root", entries[0].Actor)
+			assert.JSONEq(t, `{"amount":30,"reason":"penalty"}`, string(entries[0].Payload))
+			assert.Equal(t, entity.AuditGrant, entries[1].Action)
+		})
+	}
+}
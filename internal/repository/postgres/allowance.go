@@ -0,0 +1,308 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"go.uber.org/zap"
+	"time"
+)
+
+// expiringCoinsQuery finds the coins of the users $4 received before $3 and
+// not spent since. Coins are spent oldest first, so whatever the user has sent
+// or bought counts against the old coins before the new ones, and the coins
+// expired by earlier runs count as sent. Held coins do not expire. $1 and $2
+// are the catalog, like in expectedBalancesQuery.
+const expiringCoinsQuery = `
+	WITH catalog AS (
+		SELECT item, price
+		FROM unnest($1::text[], $2::int[]) AS c(item, price)
+	), batch AS (
+		SELECT user_id, username, balance, held, signup_balance, created_at
+		FROM users
+		WHERE user_id = ANY($4)
+	), received AS (
+		SELECT receiver_name AS username, sum(amount) AS total
+		FROM history
+		WHERE created_at < $3 AND receiver_name IN (SELECT username FROM batch)
+		GROUP BY receiver_name
+	), sent AS (
+		SELECT sender_name AS username, sum(amount) AS total
+		FROM history
+		WHERE sender_name IN (SELECT username FROM batch)
+		GROUP BY sender_name
+	), spent AS (
+		SELECT i.owner_id AS user_id, sum(COALESCE(i.price, c.price, 0)) AS total
+		FROM inventory i
+		LEFT JOIN catalog c ON c.item = i.item
+		WHERE i.owner_id = ANY($4)
+		GROUP BY i.owner_id
+	), expiring AS (
+		SELECT u.user_id, u.username, LEAST(u.balance - u.held, GREATEST(0,
+			CASE WHEN u.created_at < $3 THEN u.signup_balance ELSE 0 END
+			+ COALESCE(r.total, 0) - COALESCE(s.total, 0) - COALESCE(p.total, 0)
+		)) AS amount
+		FROM batch u
+		LEFT JOIN received r ON r.username = u.username
+		LEFT JOIN sent s ON s.username = u.username
+		LEFT JOIN spent p ON p.user_id = u.user_id
+	)
+	SELECT user_id, username, amount
+	FROM expiring
+	WHERE amount > 0
+	ORDER BY user_id
+`
+
+// allowanceBatchSize is the number of users locked and changed by one
+// transaction of Grant and Expire.
+var allowanceBatchSize = 500
+
+type AllowanceRepository struct {
+	l  *zap.Logger
+	db DB
+}
+
+// Grant commits a transaction per batch of users, so that a run never locks
+// all of them at once, and records each batch in the audit log. The users granted by the batches committed before a
+// failure are returned along with the error.
+func (a AllowanceRepository) Grant(ctx context.Context, amount int, reason string) ([]int, error) {
+	var ids []int
+	after := 0
+	for {
+		var batch []int
+		var last int
+		err := retryOnConflict(ctx, a.l, func() error {
+			var err error
+			batch, last, err = a.grant(ctx, after, amount, reason)
+			return err
+		})
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, batch...)
+		if last == 0 {
+			return ids, nil
+		}
+		after = last
+	}
+}
+
+// grant tops up the active users of the batch that follows user after and
+// returns their IDs and the last user_id of the batch, 0 past the last user.
+func (a AllowanceRepository) grant(ctx context.Context, after int, amount int, reason string) ([]int, int, error) {
+	var ids []int
+	var last int
+	err := withTx(ctx, a.l, a.db, func(tx Tx) error {
+		rows, err := tx.Query(ctx, `
+		SELECT user_id, username
+		FROM users
+		WHERE user_id > $1 AND deleted_at IS NULL AND disabled_at IS NULL
+		ORDER BY user_id
+		LIMIT $2
+		FOR UPDATE
+	`, after, allowanceBatchSize)
+		if err != nil {
+			a.l.Error("failed to lock balances", zap.Error(err))
+			return err
+		}
+
+		ids, last = nil, 0
+		var usernames []string
+		for rows.Next() {
+			var id int
+			var username string
+			if err = rows.Scan(&id, &username); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+			usernames = append(usernames, username)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if len(ids) == allowanceBatchSize {
+			last = ids[len(ids)-1]
+		}
+
+		err = tx.ExecBatch(ctx,
+			Query{SQL: "UPDATE users SET balance = balance + $1 WHERE user_id = ANY($2)", Args: []any{amount, ids}},
+			Query{SQL: `
+		INSERT INTO history (sender_name, receiver_name, amount, reason)
+		SELECT $1, u, $3, $4
+		FROM unnest($2::text[]) AS u
+	`, Args: []any{entity.SystemAccount, usernames, amount, reason}},
+		)
+		if err != nil {
+			a.l.Error("failed to grant allowance", zap.Error(err))
+			return err
+		}
+
+		_, err = appendAudit(ctx, tx, newAuditEntry(entity.SystemAccount, entity.AuditAllowance, "", map[string]any{
+			"amount": amount,
+			"users":  len(ids),
+		}))
+		if err != nil {
+			a.l.Error("failed to write audit log", zap.Error(err))
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return ids, last, nil
+}
+
+// Expire goes through the users in batches like Grant and records each batch
+// that expired coins. The rows of a batch
+// are locked before the ledger is read, so that the coins to expire can't be
+// spent meanwhile.
+func (a AllowanceRepository) Expire(ctx context.Context, cutoff time.Time, prices map[string]int, reason string) ([]int, int, error) {
+	items := make([]string, 0, len(prices))
+	costs := make([]int, 0, len(prices))
+	for item, price := range prices {
+		items = append(items, item)
+		costs = append(costs, price)
+	}
+
+	var ids []int
+	var total int
+	after := 0
+	for {
+		var batch []int
+		var expired, last int
+		err := retryOnConflict(ctx, a.l, func() error {
+			var err error
+			batch, expired, last, err = a.expire(ctx, after, cutoff, items, costs, reason)
+			return err
+		})
+		if err != nil {
+			return ids, total, err
+		}
+		ids = append(ids, batch...)
+		total += expired
+		if last == 0 {
+			return ids, total, nil
+		}
+		after = last
+	}
+}
+
+// expire takes away the expiring coins of the batch that follows user after.
+// It returns the users and the coins expired and the last user_id of the
+// batch, 0 past the last user.
+func (a AllowanceRepository) expire(ctx context.Context, after int, cutoff time.Time, items []string, costs []int, reason string) ([]int, int, int, error) {
+	var ids []int
+	var total, last int
+	err := withTx(ctx, a.l, a.db, func(tx Tx) error {
+		rows, err := tx.Query(ctx, `
+		SELECT user_id
+		FROM users
+		WHERE user_id > $1 AND deleted_at IS NULL
+		ORDER BY user_id
+		LIMIT $2
+		FOR UPDATE
+	`, after, allowanceBatchSize)
+		if err != nil {
+			a.l.Error("failed to lock balances", zap.Error(err))
+			return err
+		}
+
+		var locked []int
+		for rows.Next() {
+			var id int
+			if err = rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			locked = append(locked, id)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		ids, total, last = nil, 0, 0
+		if len(locked) == 0 {
+			return nil
+		}
+		if len(locked) == allowanceBatchSize {
+			last = locked[len(locked)-1]
+		}
+
+		rows, err = tx.Query(ctx, expiringCoinsQuery, items, costs, cutoff, locked)
+		if err != nil {
+			a.l.Error("failed to find expiring coins", zap.Error(err))
+			return err
+		}
+
+		var usernames []string
+		var amounts []int
+		for rows.Next() {
+			var id, amount int
+			var username string
+			if err = rows.Scan(&id, &username, &amount); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+			usernames = append(usernames, username)
+			amounts = append(amounts, amount)
+			total += amount
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		err = tx.ExecBatch(ctx,
+			Query{SQL: `
+		UPDATE users u
+		SET balance = u.balance - e.amount
+		FROM unnest($1::int[], $2::int[]) AS e(user_id, amount)
+		WHERE u.user_id = e.user_id
+	`, Args: []any{ids, amounts}},
+			Query{SQL: `
+		INSERT INTO history (sender_name, receiver_name, amount, reason)
+		SELECT e.username, $1, e.amount, $4
+		FROM unnest($2::text[], $3::int[]) AS e(username, amount)
+	`, Args: []any{entity.SystemAccount, usernames, amounts, reason}},
+		)
+		if err != nil {
+			a.l.Error("failed to expire coins", zap.Error(err))
+			return err
+		}
+
+		_, err = appendAudit(ctx, tx, newAuditEntry(entity.SystemAccount, entity.AuditExpiry, "", map[string]any{
+			"cutoff": cutoff.UTC(),
+			"coins":  total,
+			"users":  len(ids),
+		}))
+		if err != nil {
+			a.l.Error("failed to write audit log", zap.Error(err))
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return ids, total, last, nil
+}
+
+func NewAllowanceRepository(
+	l *zap.Logger,
+	db DB,
+) repository.AllowanceRepository {
+	return &AllowanceRepository{
+		l:  l,
+		db: db,
+	}
+}
@@ -0,0 +1,138 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestGrantAllowance(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			users := NewUserRepository(logger, conn)
+			allowance := NewAllowanceRepository(logger, conn)
+			accounts := NewAccountRepository(logger, conn)
+
+			active, err := users.InsertUser(ctx, &entity.User{Username: "active_" + name, Password: "pass", Balance: 100})
+			require.NoError(t, err)
+			disabled, err := users.InsertUser(ctx, &entity.User{Username: "idle_" + name, Password: "pass", Balance: 100})
+			require.NoError(t, err)
+			_, err = users.SetDisabled(ctx, disabled.Username, true)
+			require.NoError(t, err)
+
+			ids, err := allowance.Grant(ctx, 25, "periodic allowance")
+			require.NoError(t, err)
+			assert.Contains(t, ids, active.ID)
+			assert.NotContains(t, ids, disabled.ID)
+
+			info, err := accounts.GetAccountInfo(ctx, active.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 125, info.Coins)
+			require.Len(t, info.Received, 1)
+			assert.Equal(t, entity.Operation{ID: info.Received[0].ID, FromUser: entity.SystemAccount, ToUser: active.Username, Amount: 25, Reason: "periodic allowance"}, info.Received[0])
+
+			info, err = accounts.GetAccountInfo(ctx, disabled.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 100, info.Coins)
+		})
+	}
+}
+
+func TestGrantAllowanceInBatches(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	batchSize := allowanceBatchSize
+	allowanceBatchSize = 1
+	defer func() {
+		allowanceBatchSize = batchSize
+	}()
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			users := NewUserRepository(logger, conn)
+			allowance := NewAllowanceRepository(logger, conn)
+
+			first, err := users.InsertUser(ctx, &entity.User{Username: "batch1_" + name, Password: "pass", Balance: 100})
+			require.NoError(t, err)
+			second, err := users.InsertUser(ctx, &entity.User{Username: "batch2_" + name, Password: "pass", Balance: 100})
+			require.NoError(t, err)
+
+			ids, err := allowance.Grant(ctx, 5, "periodic allowance")
+			require.NoError(t, err)
+			assert.Contains(t, ids, first.ID)
+			assert.Contains(t, ids, second.ID)
+			assert.IsIncreasing(t, ids)
+
+			for _, id := range []int{first.ID, second.ID} {
+				user, err := users.FindUserByID(ctx, id)
+				require.NoError(t, err)
+				assert.Equal(t, 105, user.Balance)
+			}
+		})
+	}
+}
+
+func TestExpireCoins(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+	prices := map[string]int{"cup": 20}
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			users := NewUserRepository(logger, conn)
+			history := NewHistoryRepository(logger, conn)
+			allowance := NewAllowanceRepository(logger, conn)
+			accounts := NewAccountRepository(logger, conn)
+
+			// saver signed up with 100 coins three months ago, received 50
+			// since and sent 30: the oldest coins are spent first, so 70 of
+			// the signup coins are left to expire
+			saver, err := users.InsertUser(ctx, &entity.User{Username: "saver_" + name, Password: "pass", Balance: 120})
+			require.NoError(t, err)
+			_, err = conn.Exec(ctx, `
+				UPDATE users
+				SET signup_balance = 100, created_at = now() - interval '3 months'
+				WHERE user_id = $1
+			`, saver.ID)
+			require.NoError(t, err)
+			_, err = history.InsertOperation(ctx, entity.Operation{FromUser: "friend_" + name, ToUser: saver.Username, Amount: 50})
+			require.NoError(t, err)
+			_, err = history.InsertOperation(ctx, entity.Operation{FromUser: saver.Username, ToUser: "friend_" + name, Amount: 30})
+			require.NoError(t, err)
+
+			// newcomer signed up after the cutoff, nothing of theirs expires
+			newcomer, err := users.InsertUser(ctx, &entity.User{Username: "newcomer_" + name, Password: "pass", Balance: 100})
+			require.NoError(t, err)
+
+			cutoff := time.Now().AddDate(0, -1, 0)
+			ids, total, err := allowance.Expire(ctx, cutoff, prices, "expired coins")
+			require.NoError(t, err)
+			assert.Contains(t, ids, saver.ID)
+			assert.NotContains(t, ids, newcomer.ID)
+			assert.GreaterOrEqual(t, total, 70)
+
+			info, err := accounts.GetAccountInfo(ctx, saver.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 50, info.Coins)
+			require.Len(t, info.Sent, 2)
+
+			info, err = accounts.GetAccountInfo(ctx, newcomer.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 100, info.Coins)
+
+			// the expired coins count as spent, a second run finds nothing
+			ids, _, err = allowance.Expire(ctx, cutoff, prices, "expired coins")
+			require.NoError(t, err)
+			assert.NotContains(t, ids, saver.ID)
+		})
+	}
+}
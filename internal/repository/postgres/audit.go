@@ -0,0 +1,183 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go.uber.org/zap"
+	"time"
+)
+
+// auditLockKey is the advisory lock serializing appends to the audit log, so
+// that two entries are never chained to the same predecessor.
+const auditLockKey = 0x617564697400
+
+const auditColumns = "id, created_at, actor, action, target, payload, prev_hash, hash"
+
+type AuditRepository struct {
+	l  *zap.Logger
+	db DB
+}
+
+func (a AuditRepository) Append(ctx context.Context, entry entity.AuditEntry) (*entity.AuditEntry, error) {
+	var stored []entity.AuditEntry
+	err := withTx(ctx, a.l, a.db, func(tx Tx) error {
+		var err error
+		stored, err = appendAudit(ctx, tx, entry)
+		if err != nil {
+			a.l.Error("failed to append audit entry", zap.Error(err))
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &stored[0], nil
+}
+
+// newAuditEntry is the entry of a financial action, with payload encoded as
+// JSON.
+func newAuditEntry(actor, action, target string, payload map[string]any) entity.AuditEntry {
+	// a map of numbers, strings and times always encodes
+	raw, _ := json.Marshal(payload)
+	return entity.AuditEntry{Actor: actor, Action: action, Target: target, Payload: raw}
+}
+
+// appendAudit chains entries to the last entry of the log within the
+// transaction q and returns them as stored. The financial repositories call it
+// in the transaction of the change they record, so that the change and its
+// entries are committed together or not at all.
+//
+// The advisory lock taken here is held until q ends and every append of the
+// service queues on it. Callers append after taking the row locks of their
+// change and take no new row locks afterwards: the lock is then held only for
+// the last statements before the commit, and a transaction holding it never
+// waits for one waiting on it.
+func appendAudit(ctx context.Context, q Querier, entries ...entity.AuditEntry) ([]entity.AuditEntry, error) {
+	_, err := q.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, int64(auditLockKey))
+	if err != nil {
+		return nil, err
+	}
+
+	prev := ""
+	err = q.QueryRow(ctx, `
+		SELECT hash
+		FROM audit_log
+		ORDER BY id DESC
+		LIMIT 1
+	`).Scan(&prev)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	now := time.Now()
+	stored := make([]entity.AuditEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = now
+		}
+		// Postgres keeps microseconds, the hash must be computed over the
+		// value read back later
+		entry.CreatedAt = entry.CreatedAt.UTC().Truncate(time.Microsecond)
+		if len(entry.Payload) == 0 {
+			entry.Payload = []byte("{}")
+		}
+		entry.PrevHash = prev
+		entry.Hash = entry.ComputeHash()
+
+		err = q.QueryRow(ctx, `
+		INSERT INTO audit_log (created_at, actor, action, target, payload, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, entry.CreatedAt, entry.Actor, entry.Action, entry.Target, string(entry.Payload), entry.PrevHash, entry.Hash).Scan(&entry.ID)
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, entry)
+		prev = entry.Hash
+	}
+	return stored, nil
+}
+
+func (a AuditRepository) List(ctx context.Context, filter entity.AuditFilter) ([]entity.AuditEntry, error) {
+	rows, err := a.db.Query(ctx, `
+		SELECT `+auditColumns+`
+		FROM audit_log
+		WHERE ($1 = '' OR actor = $1)
+			AND ($2 = '' OR action = $2)
+			AND ($3 = '' OR target = $3)
+			AND ($4::bigint = 0 OR id < $4)
+		ORDER BY id DESC
+		LIMIT $5
+	`, filter.Actor, filter.Action, filter.Target, filter.BeforeID, filter.Limit)
+	if err != nil {
+		a.l.Error("failed to list audit log", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]entity.AuditEntry, 0)
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			a.l.Error("failed to scan audit entry", zap.Error(err))
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		a.l.Error("failed to list audit log", zap.Error(err))
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (a AuditRepository) Walk(ctx context.Context, fn func(entity.AuditEntry) error) error {
+	rows, err := a.db.Query(ctx, `
+		SELECT `+auditColumns+`
+		FROM audit_log
+		ORDER BY id
+	`)
+	if err != nil {
+		a.l.Error("failed to read audit log", zap.Error(err))
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			a.l.Error("failed to scan audit entry", zap.Error(err))
+			return err
+		}
+		if err = fn(entry); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		a.l.Error("failed to read audit log", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func scanAuditEntry(row Row) (entity.AuditEntry, error) {
+	var entry entity.AuditEntry
+	var payload string
+	err := row.Scan(&entry.ID, &entry.CreatedAt, &entry.Actor, &entry.Action, &entry.Target, &payload, &entry.PrevHash, &entry.Hash)
+	entry.Payload = []byte(payload)
+	return entry, err
+}
+
+func NewAuditRepository(
+	l *zap.Logger,
+	db DB,
+) repository.AuditRepository {
+	return &AuditRepository{
+		l:  l,
+		db: db,
+	}
+}
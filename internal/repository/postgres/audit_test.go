@@ -0,0 +1,163 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAuditLog(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			audit := NewAuditRepository(logger, conn)
+			actor := "auditor_" + name
+
+			first, err := audit.Append(ctx, entity.AuditEntry{
+				Actor:   actor,
+				Action:  "admin.disable",
+				Target:  "target_" + name,
+				Payload: json.RawMessage(`{"reason":"spam"}`),
+			})
+			require.NoError(t, err)
+			assert.Equal(t, first.ComputeHash(), first.Hash)
+
+			second, err := audit.Append(ctx, entity.AuditEntry{Actor: actor, Action: "admin.enable", Target: "target_" + name})
+			require.NoError(t, err)
+			assert.Equal(t, first.Hash, second.PrevHash)
+			assert.Greater(t, second.ID, first.ID)
+
+			entries, err := audit.List(ctx, entity.AuditFilter{Actor: actor, Limit: 10})
+			require.NoError(t, err)
+			require.Len(t, entries, 2)
+			assert.Equal(t, second.ID, entries[0].ID)
+			assert.Equal(t, first.ID, entries[1].ID)
+			assert.JSONEq(t, `{"reason":"spam"}`, string(entries[1].Payload))
+			assert.Equal(t, first.Hash, entries[1].ComputeHash())
+
+			entries, err = audit.List(ctx, entity.AuditFilter{Actor: actor, BeforeID: second.ID, Limit: 10})
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+			assert.Equal(t, first.ID, entries[0].ID)
+
+			entries, err = audit.List(ctx, entity.AuditFilter{Actor: actor, Action: "admin.enable", Limit: 10})
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+			assert.Equal(t, second.ID, entries[0].ID)
+
+			// the chain read back is intact
+			prev := ""
+			err = audit.Walk(ctx, func(entry entity.AuditEntry) error {
+				assert.Equal(t, prev, entry.PrevHash)
+				assert.Equal(t, entry.ComputeHash(), entry.Hash)
+				prev = entry.Hash
+				return nil
+			})
+			require.NoError(t, err)
+
+			_, err = conn.Exec(ctx, `UPDATE audit_log SET actor = 'someone' WHERE id = $1`, first.ID)
+			assert.Error(t, err)
+			_, err = conn.Exec(ctx, `DELETE FROM audit_log WHERE id = $1`, first.ID)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestAuditLog_BalanceChanges(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			users := NewUserRepository(logger, conn)
+			audit := NewAuditRepository(logger, conn)
+
+			sender, err := users.InsertUser(ctx, &entity.User{Username: "audited_sender_" + name, Password: "pass", Balance: 100})
+			require.NoError(t, err)
+			receiver, err := users.InsertUser(ctx, &entity.User{Username: "audited_receiver_" + name, Password: "pass", Balance: 0})
+			require.NoError(t, err)
+
+			require.NoError(t, users.TransferMoney(ctx, sender.ID, receiver.ID, 30, nil))
+			require.NoError(t, users.BuyItem(ctx, sender.ID, "cup", 20, nil))
+
+			// a refused change writes no entry
+			err = users.TransferMoney(ctx, sender.ID, receiver.ID, 1000, nil)
+			assert.ErrorIs(t, err, repository.ErrorInsufficientBalance)
+
+			entries, err := audit.List(ctx, entity.AuditFilter{Actor: sender.Username, Limit: 10})
+			require.NoError(t, err)
+			require.Len(t, entries, 2)
+			assert.Equal(t, entity.AuditPurchase, entries[0].Action)
+			assert.Equal(t, "cup", entries[0].Target)
+			assert.JSONEq(t, `{"cost":20}`, string(entries[0].Payload))
+			assert.Equal(t, entity.AuditTransfer, entries[1].Action)
+			assert.Equal(t, receiver.Username, entries[1].Target)
+			assert.JSONEq(t, `{"amount":30}`, string(entries[1].Payload))
+		})
+	}
+}
+
+func TestAuditLog_Contention(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+	users := NewUserRepository(logger, pgxDB)
+	audit := NewAuditRepository(logger, pgxDB)
+
+	userA, err := users.InsertUser(ctx, &entity.User{Username: "contendedA", Password: "pass", Balance: 1000})
+	require.NoError(t, err)
+	userB, err := users.InsertUser(ctx, &entity.User{Username: "contendedB", Password: "pass", Balance: 1000})
+	require.NoError(t, err)
+
+	// transfers in both directions append while holding their row locks,
+	// logins append on their own, all of them queue on the same lock
+	const rounds = 50
+	errs := make(chan error, 3*rounds)
+	var wg sync.WaitGroup
+	for i := 0; i < rounds; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			errs <- users.TransferMoney(ctx, userA.ID, userB.ID, 1, nil)
+		}()
+		go func() {
+			defer wg.Done()
+			errs <- users.TransferMoney(ctx, userB.ID, userA.ID, 1, nil)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := audit.Append(ctx, entity.AuditEntry{Actor: userA.Username, Action: entity.AuditLogin, Target: userA.Username})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err = range errs {
+		assert.NoError(t, err)
+	}
+
+	entries, err := audit.List(ctx, entity.AuditFilter{Actor: userA.Username, Limit: 3 * rounds})
+	require.NoError(t, err)
+	assert.Len(t, entries, 2*rounds)
+	entries, err = audit.List(ctx, entity.AuditFilter{Actor: userB.Username, Limit: 3 * rounds})
+	require.NoError(t, err)
+	assert.Len(t, entries, rounds)
+
+	prev := ""
+	err = audit.Walk(ctx, func(entry entity.AuditEntry) error {
+		assert.Equal(t, prev, entry.PrevHash)
+		assert.Equal(t, entry.ComputeHash(), entry.Hash)
+		prev = entry.Hash
+		return nil
+	})
+	require.NoError(t, err)
+}
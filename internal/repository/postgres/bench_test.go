@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// drivers lists the pool implementations compared by the benchmarks.
+func drivers() map[string]DB {
+	return map[string]DB{
+		"sql": sqlDB,
+		"pgx": pgxDB,
+	}
+}
+
+func BenchmarkFindUserByID(b *testing.B) {
+	ctx := context.Background()
+	for name, conn := range drivers() {
+		b.Run(name, func(b *testing.B) {
+			repo := NewUserRepository(zap.NewNop(), conn)
+			user, err := repo.InsertUser(ctx, &entity.User{Username: "bench_find_" + name, Password: "pass", Balance: 100})
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err = repo.FindUserByID(ctx, user.ID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkTransferMoney(b *testing.B) {
+	ctx := context.Background()
+	for name, conn := range drivers() {
+		b.Run(name, func(b *testing.B) {
+			repo := NewUserRepository(zap.NewNop(), conn)
+			sender, err := repo.InsertUser(ctx, &entity.User{Username: "bench_from_" + name, Password: "pass", Balance: b.N})
+			if err != nil {
+				b.Fatal(err)
+			}
+			receiver, err := repo.InsertUser(ctx, &entity.User{Username: "bench_to_" + name, Password: "pass"})
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err = repo.TransferMoney(ctx, sender.ID, receiver.ID, 1, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetUsersInventory(b *testing.B) {
+	ctx := context.Background()
+	for name, conn := range drivers() {
+		b.Run(name, func(b *testing.B) {
+			repo := NewInventoryRepository(zap.NewNop(), conn)
+			for _, title := range []string{"cup", "cup", "pen"} {
+				if _, err := repo.InsertItem(ctx, 4242, title, 10); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.GetUsersInventory(ctx, 4242); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"go.uber.org/zap"
+	"time"
+)
+
+// coinRequestsQuery reads the requests with the usernames of both parties.
+// Pending requests past their expiry read as expired.
+const coinRequestsQuery = `
+	SELECT * FROM (
+		SELECT r.id, r.requester_id, q.username, r.payer_id, p.username, r.amount, r.memo,
+			CASE WHEN r.status = 'pending' AND r.expires_at <= now() THEN 'expired' ELSE r.status END AS status,
+			r.created_at, r.expires_at, r.resolved_at
+		FROM coin_requests r
+		JOIN users q ON q.user_id = r.requester_id
+		JOIN users p ON p.user_id = r.payer_id
+	) AS r
+`
+
+type CoinRequestRepository struct {
+	l  *zap.Logger
+	db DB
+}
+
+func (c CoinRequestRepository) Create(ctx context.Context, request entity.CoinRequest, ttl time.Duration) (*entity.CoinRequest, error) {
+	request.Status = entity.CoinRequestPending
+	err := c.db.QueryRow(ctx, `
+		INSERT INTO coin_requests (requester_id, payer_id, amount, memo, expires_at)
+		VALUES ($1, $2, $3, $4, now() + make_interval(secs => $5))
+		RETURNING id, created_at, expires_at
+	`, request.RequesterID, request.PayerID, request.Amount, request.Memo, ttl.Seconds()).
+		Scan(&request.ID, &request.CreatedAt, &request.ExpiresAt)
+	if err != nil {
+		c.l.Error("failed to insert coin request", zap.Error(err))
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (c CoinRequestRepository) Get(ctx context.Context, id int64, userID int) (*entity.CoinRequest, error) {
+	var request entity.CoinRequest
+	err := c.db.QueryRow(ctx, coinRequestsQuery+`
+		WHERE id = $1 AND (requester_id = $2 OR payer_id = $2)
+	`, id, userID).Scan(coinRequestFields(&request)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrorRequestNotFound
+		}
+		c.l.Error("failed to find coin request", zap.Error(err))
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (c CoinRequestRepository) List(ctx context.Context, filter entity.CoinRequestFilter) ([]entity.CoinRequest, error) {
+	rows, err := c.db.Query(ctx, coinRequestsQuery+`
+		WHERE CASE WHEN $2 THEN requester_id ELSE payer_id END = $1
+			AND ($3 = '' OR status = $3)
+		ORDER BY id DESC
+		LIMIT $4
+	`, filter.UserID, filter.Outgoing, string(filter.Status), filter.Limit)
+	if err != nil {
+		c.l.Error("failed to list coin requests", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := make([]entity.CoinRequest, 0)
+	for rows.Next() {
+		var request entity.CoinRequest
+		if err = rows.Scan(coinRequestFields(&request)...); err != nil {
+			c.l.Error("failed to scan coin request", zap.Error(err))
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	if err = rows.Err(); err != nil {
+		c.l.Error("failed to list coin requests", zap.Error(err))
+		return nil, err
+	}
+	return requests, nil
+}
+
+// Decline changes the status with a single conditional update, so a request
+// is resolved once however many times it is resolved concurrently.
+func (c CoinRequestRepository) Decline(ctx context.Context, id int64, payerID int) (*entity.CoinRequest, error) {
+	n, err := c.db.Exec(ctx, `
+		UPDATE coin_requests
+		SET status = 'declined', resolved_at = now()
+		WHERE id = $1 AND payer_id = $2 AND status = 'pending' AND expires_at > now()
+	`, id, payerID)
+	if err != nil {
+		c.l.Error("failed to decline coin request", zap.Error(err))
+		return nil, err
+	}
+
+	request, err := c.Get(ctx, id, payerID)
+	if err != nil {
+		return nil, err
+	}
+	// only the payer resolves a request, it doesn't exist for the requester
+	if request.PayerID != payerID {
+		return nil, repository.ErrorRequestNotFound
+	}
+	if n == 0 {
+		return nil, repository.ErrorRequestNotPending
+	}
+	return request, nil
+}
+
+// Accept locks the request before paying it, so that concurrent accepts of
+// the same request queue up and all but the first find it resolved. The
+// coins move with moveCoins in the same transaction.
+func (c CoinRequestRepository) Accept(ctx context.Context, id int64, payerID int, limits []entity.SpendingLimit) (*entity.CoinRequest, error) {
+	var request *entity.CoinRequest
+	err := retryOnConflict(ctx, c.l, func() error {
+		var err error
+		request, err = c.accept(ctx, id, payerID, limits)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func (c CoinRequestRepository) accept(ctx context.Context, id int64, payerID int, limits []entity.SpendingLimit) (*entity.CoinRequest, error) {
+	var request entity.CoinRequest
+	err := withTx(ctx, c.l, c.db, func(tx Tx) error {
+		var requesterID, amount int
+		var pending, requesterActive bool
+		err := tx.QueryRow(ctx, `
+		SELECT r.requester_id, r.amount, r.status = 'pending' AND r.expires_at > now(), u.deleted_at IS NULL
+		FROM coin_requests r
+		JOIN users u ON u.user_id = r.requester_id
+		WHERE r.id = $1 AND r.payer_id = $2
+		FOR UPDATE OF r
+	`, id, payerID).Scan(&requesterID, &amount, &pending, &requesterActive)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return repository.ErrorRequestNotFound
+			}
+			c.l.Error("failed to lock coin request", zap.Error(err))
+			return err
+		}
+		if !pending {
+			return repository.ErrorRequestNotPending
+		}
+		if !requesterActive {
+			return repository.ErrorUserNotFound
+		}
+
+		err = moveCoins(ctx, tx, payerID, requesterID, amount, limits, nil)
+		if err != nil {
+			if !isRefusal(err) {
+				c.l.Error("failed to move coins", zap.Error(err))
+			}
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+		UPDATE coin_requests
+		SET status = 'accepted', resolved_at = now()
+		WHERE id = $1
+	`, id)
+		if err != nil {
+			c.l.Error("failed to accept coin request", zap.Error(err))
+			return err
+		}
+
+		err = tx.QueryRow(ctx, coinRequestsQuery+`
+		WHERE id = $1
+	`, id).Scan(coinRequestFields(&request)...)
+		if err != nil {
+			c.l.Error("failed to read coin request", zap.Error(err))
+			return err
+		}
+
+		_, err = appendAudit(ctx, tx, newAuditEntry(request.Payer, entity.AuditAcceptRequest, request.Requester, map[string]any{
+			"request": id,
+			"amount":  amount,
+		}))
+		if err != nil {
+			c.l.Error("failed to write audit log", zap.Error(err))
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func coinRequestFields(r *entity.CoinRequest) []any {
+	return []any{&r.ID, &r.RequesterID, &r.Requester, &r.PayerID, &r.Payer, &r.Amount, &r.Memo,
+		&r.Status, &r.CreatedAt, &r.ExpiresAt, &r.ResolvedAt}
+}
+
+func NewCoinRequestRepository(
+	l *zap.Logger,
+	db DB,
+) repository.CoinRequestRepository {
+	return &CoinRequestRepository{
+		l:  l,
+		db: db,
+	}
+}
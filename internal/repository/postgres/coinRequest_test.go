@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCoinRequests(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			users := NewUserRepository(logger, conn)
+			requests := NewCoinRequestRepository(logger, conn)
+
+			alice, err := users.InsertUser(ctx, &entity.User{Username: "asker_" + name, Password: "pass", Balance: 100})
+			require.NoError(t, err)
+			bob, err := users.InsertUser(ctx, &entity.User{Username: "payer_" + name, Password: "pass", Balance: 100})
+			require.NoError(t, err)
+
+			request, err := requests.Create(ctx, entity.CoinRequest{
+				RequesterID: alice.ID,
+				PayerID:     bob.ID,
+				Amount:      30,
+				Memo:        "lunch",
+			}, time.Hour)
+			require.NoError(t, err)
+			assert.Equal(t, entity.CoinRequestPending, request.Status)
+			assert.WithinDuration(t, request.CreatedAt.Add(time.Hour), request.ExpiresAt, time.Second)
+
+			incoming, err := requests.List(ctx, entity.CoinRequestFilter{UserID: bob.ID, Status: entity.CoinRequestPending, Limit: 10})
+			require.NoError(t, err)
+			require.Len(t, incoming, 1)
+			assert.Equal(t, alice.Username, incoming[0].Requester)
+			assert.Equal(t, bob.Username, incoming[0].Payer)
+			assert.Equal(t, "lunch", incoming[0].Memo)
+
+			outgoing, err := requests.List(ctx, entity.CoinRequestFilter{UserID: bob.ID, Outgoing: true, Limit: 10})
+			require.NoError(t, err)
+			assert.Empty(t, outgoing)
+
+			// only the payer resolves a request
+			_, err = requests.Accept(ctx, request.ID, alice.ID, nil)
+			assert.ErrorIs(t, err, repository.ErrorRequestNotFound)
+			_, err = requests.Decline(ctx, request.ID, alice.ID)
+			assert.ErrorIs(t, err, repository.ErrorRequestNotFound)
+
+			// a payment the payer can't afford leaves the request pending
+			_, err = conn.Exec(ctx, "UPDATE users SET balance = 10 WHERE user_id = $1", bob.ID)
+			require.NoError(t, err)
+			_, err = requests.Accept(ctx, request.ID, bob.ID, nil)
+			assert.ErrorIs(t, err, repository.ErrorInsufficientBalance)
+			pending, err := requests.Get(ctx, request.ID, alice.ID)
+			require.NoError(t, err)
+			assert.Equal(t, entity.CoinRequestPending, pending.Status)
+			assert.Nil(t, pending.ResolvedAt)
+
+			_, err = conn.Exec(ctx, "UPDATE users SET balance = 100 WHERE user_id = $1", bob.ID)
+			require.NoError(t, err)
+			accepted, err := requests.Accept(ctx, request.ID, bob.ID, nil)
+			require.NoError(t, err)
+			assert.Equal(t, entity.CoinRequestAccepted, accepted.Status)
+			assert.NotNil(t, accepted.ResolvedAt)
+
+			// the request is paid once, along with its history row
+			_, err = requests.Accept(ctx, request.ID, bob.ID, nil)
+			assert.ErrorIs(t, err, repository.ErrorRequestNotPending)
+			_, err = requests.Decline(ctx, request.ID, bob.ID)
+			assert.ErrorIs(t, err, repository.ErrorRequestNotPending)
+			payer, err := users.FindUserByID(ctx, bob.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 70, payer.Balance)
+			sent, err := NewHistoryRepository(logger, conn).GetSentByUser(ctx, bob.Username)
+			require.NoError(t, err)
+			require.Len(t, sent, 1)
+			assert.Equal(t, alice.Username, sent[0].ToUser)
+			assert.Equal(t, 30, sent[0].Amount)
+
+			_, err = requests.Get(ctx, request.ID, 0)
+			assert.ErrorIs(t, err, repository.ErrorRequestNotFound)
+		})
+	}
+}
+
+func TestCoinRequests_Expired(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			users := NewUserRepository(logger, conn)
+			requests := NewCoinRequestRepository(logger, conn)
+
+			alice, err := users.InsertUser(ctx, &entity.User{Username: "late_asker_" + name, Password: "pass", Balance: 100})
+			require.NoError(t, err)
+			bob, err := users.InsertUser(ctx, &entity.User{Username: "late_payer_" + name, Password: "pass", Balance: 100})
+			require.NoError(t, err)
+
+			request, err := requests.Create(ctx, entity.CoinRequest{RequesterID: alice.ID, PayerID: bob.ID, Amount: 30}, time.Hour)
+			require.NoError(t, err)
+			_, err = conn.Exec(ctx, `UPDATE coin_requests SET expires_at = now() - interval '1 minute' WHERE id = $1`, request.ID)
+			require.NoError(t, err)
+
+			expired, err := requests.List(ctx, entity.CoinRequestFilter{UserID: bob.ID, Status: entity.CoinRequestExpired, Limit: 10})
+			require.NoError(t, err)
+			require.Len(t, expired, 1)
+			assert.Equal(t, request.ID, expired[0].ID)
+
+			pending, err := requests.List(ctx, entity.CoinRequestFilter{UserID: bob.ID, Status: entity.CoinRequestPending, Limit: 10})
+			require.NoError(t, err)
+			assert.Empty(t, pending)
+
+			_, err = requests.Accept(ctx, request.ID, bob.ID, nil)
+			assert.ErrorIs(t, err, repository.ErrorRequestNotPending)
+		})
+	}
+}
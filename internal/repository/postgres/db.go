@@ -0,0 +1,180 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Querier runs statements against Postgres. It is satisfied both by a pool
+// and by an open transaction.
+type Querier interface {
+	Exec(ctx context.Context, query string, args ...any) (int64, error)
+	Query(ctx context.Context, query string, args ...any) (Rows, error)
+	QueryRow(ctx context.Context, query string, args ...any) Row
+	// ExecBatch runs the statements in order, in as few round-trips as the
+	// driver allows, and stops at the first error.
+	ExecBatch(ctx context.Context, queries ...Query) error
+}
+
+// DB is a connection pool the repositories are written against. It has two
+// implementations: NewSQLDB over database/sql and NewPgxDB over a native pgx
+// pool, so the driver can be switched without touching the repositories.
+type DB interface {
+	Querier
+	Begin(ctx context.Context, opts TxOptions) (Tx, error)
+	// Conn takes a connection out of the pool for the exclusive use of the
+	// caller until it is closed. Session state, like advisory locks, stays
+	// with the connection.
+	Conn(ctx context.Context) (Conn, error)
+	Ping(ctx context.Context) error
+	// Stats reports the state of the connection pool in database/sql terms
+	// whichever driver is in use.
+	Stats() sql.DBStats
+	Close() error
+}
+
+type Conn interface {
+	Querier
+	Close() error
+}
+
+type Tx interface {
+	Querier
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+}
+
+type Row interface {
+	Scan(dest ...any) error
+}
+
+type Rows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+	Close()
+}
+
+// Query is a single statement of a batch.
+type Query struct {
+	SQL  string
+	Args []any
+}
+
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+}
+
+type stdlibQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type stdlibConn struct {
+	q stdlibQuerier
+}
+
+func (c stdlibConn) Exec(ctx context.Context, query string, args ...any) (int64, error) {
+	res, err := c.q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (c stdlibConn) Query(ctx context.Context, query string, args ...any) (Rows, error) {
+	rows, err := c.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return stdlibRows{rows}, nil
+}
+
+func (c stdlibConn) QueryRow(ctx context.Context, query string, args ...any) Row {
+	return c.q.QueryRowContext(ctx, query, args...)
+}
+
+// ExecBatch has no pipelining in database/sql, so the statements are sent one
+// by one.
+func (c stdlibConn) ExecBatch(ctx context.Context, queries ...Query) error {
+	for _, q := range queries {
+		if _, err := c.q.ExecContext(ctx, q.SQL, q.Args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type stdlibRows struct {
+	*sql.Rows
+}
+
+func (r stdlibRows) Close() {
+	_ = r.Rows.Close()
+}
+
+type stdlibDB struct {
+	stdlibConn
+	db *sql.DB
+}
+
+// NewSQLDB adapts a database/sql pool. With the pgx stdlib driver the
+// statements are still cached per connection by pgx itself.
+func NewSQLDB(db *sql.DB) DB {
+	return traced(&stdlibDB{
+		stdlibConn: stdlibConn{q: db},
+		db:         db,
+	})
+}
+
+func (d *stdlibDB) Begin(ctx context.Context, opts TxOptions) (Tx, error) {
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	return &stdlibTx{stdlibConn: stdlibConn{q: tx}, tx: tx}, nil
+}
+
+func (d *stdlibDB) Conn(ctx context.Context) (Conn, error) {
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &stdlibSession{stdlibConn: stdlibConn{q: conn}, conn: conn}, nil
+}
+
+func (d *stdlibDB) Ping(ctx context.Context) error {
+	return d.db.PingContext(ctx)
+}
+
+func (d *stdlibDB) Stats() sql.DBStats {
+	return d.db.Stats()
+}
+
+func (d *stdlibDB) Close() error {
+	return d.db.Close()
+}
+
+type stdlibTx struct {
+	stdlibConn
+	tx *sql.Tx
+}
+
+func (t *stdlibTx) Commit(context.Context) error {
+	return t.tx.Commit()
+}
+
+func (t *stdlibTx) Rollback(context.Context) error {
+	return t.tx.Rollback()
+}
+
+type stdlibSession struct {
+	stdlibConn
+	conn *sql.Conn
+}
+
+func (s *stdlibSession) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"AvitoTech/internal/repository"
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+)
+
+// requiredTables are the tables created by init/init.sql. The service is not
+// ready until all of them exist.
+var requiredTables = []string{
+	"users",
+	"history",
+	"inventory",
+	"login_failures",
+	"lockouts",
+	"password_reset_tokens",
+	"audit_log",
+	"reconciliations",
+	"reconciliation_discrepancies",
+	"job_runs",
+	"coin_requests",
+	"scheduled_transfers",
+	"holds",
+	"spending_limits",
+}
+
+// requiredColumns are the columns init/init.sql adds to tables that existed
+// before them. A database initialised by an older version of the file lacks
+// them until it is run again.
+var requiredColumns = []string{
+	"users.signup_balance",
+	"users.held",
+	"users.token_version",
+	"users.role",
+	"users.disabled_at",
+	"users.deleted_at",
+	"users.created_at",
+	"history.reason",
+	"history.created_at",
+	"inventory.price",
+	"inventory.created_at",
+}
+
+type HealthRepository struct {
+	l  *zap.Logger
+	db DB
+}
+
+func (h HealthRepository) Ping(ctx context.Context) error {
+	return h.db.Ping(ctx)
+}
+
+// CheckSchema returns an error naming the required tables or, once all of them
+// exist, the required columns that are missing.
+func (h HealthRepository) CheckSchema(ctx context.Context) error {
+	missing, err := h.missing(ctx, `
+	SELECT name
+	FROM unnest($1::text[]) AS name
+	WHERE to_regclass(name) IS NULL
+`, requiredTables)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing tables: %v", missing)
+	}
+
+	missing, err = h.missing(ctx, `
+	SELECT name
+	FROM unnest($1::text[]) AS name
+	WHERE NOT EXISTS (
+		SELECT 1
+		FROM information_schema.columns c
+		WHERE c.table_schema = current_schema()
+		AND c.table_name || '.' || c.column_name = name
+	)
+`, requiredColumns)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing columns: %v, run init/init.sql again", missing)
+	}
+	return nil
+}
+
+// missing runs query, which selects those of names that are not in the
+// database.
+func (h HealthRepository) missing(ctx context.Context, query string, names []string) ([]string, error) {
+	rows, err := h.db.Query(ctx, query, names)
+	if err != nil {
+		h.l.Error("failed to check schema", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var missing []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		missing = append(missing, name)
+	}
+	return missing, rows.Err()
+}
+
+func NewHealthRepository(
+	l *zap.Logger,
+	db DB,
+) repository.HealthRepository {
+	return &HealthRepository{
+		l:  l,
+		db: db,
+	}
+}
@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestHealthPing(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := NewHealthRepository(logger, sqlDB)
+
+	err := repo.Ping(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestHealthCheckSchema(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := NewHealthRepository(logger, sqlDB)
+
+	err := repo.CheckSchema(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestHealthCheckSchemaMissingTable(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := NewHealthRepository(logger, sqlDB)
+
+	tables := requiredTables
+	requiredTables = append(requiredTables, "not_created")
+	defer func() {
+		requiredTables = tables
+	}()
+
+	err := repo.CheckSchema(context.Background())
+	assert.ErrorContains(t, err, "not_created")
+}
+
+func TestHealthCheckSchemaMissingColumn(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := NewHealthRepository(logger, sqlDB)
+
+	columns := requiredColumns
+	requiredColumns = append(requiredColumns, "users.not_added")
+	defer func() {
+		requiredColumns = columns
+	}()
+
+	err := repo.CheckSchema(context.Background())
+	assert.ErrorContains(t, err, "users.not_added")
+}
+
+func TestInitScriptUpgradesOldSchema(t *testing.T) {
+	ctx := context.Background()
+	script, err := os.ReadFile("../../../init/init.sql")
+	require.NoError(t, err)
+
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+	defer func() {
+		_, err = conn.ExecContext(ctx, "DROP SCHEMA legacy CASCADE; SET search_path TO DEFAULT")
+		assert.NoError(t, err)
+	}()
+
+	// the schema as created by the first version of init.sql
+	_, err = conn.ExecContext(ctx, `
+	CREATE SCHEMA legacy;
+	SET search_path TO legacy;
+	CREATE TABLE users (
+		user_id SERIAL PRIMARY KEY,
+		username TEXT NOT NULL,
+		password TEXT NOT NULL,
+		balance INTEGER NOT NULL
+	);
+	CREATE TABLE history (
+		id SERIAL PRIMARY KEY,
+		sender_name TEXT NOT NULL,
+		receiver_name TEXT NOT NULL,
+		amount INTEGER
+	);
+	CREATE TABLE inventory (
+		id SERIAL PRIMARY KEY,
+		owner_id INTEGER NOT NULL,
+		item TEXT NOT NULL
+	);
+	CREATE INDEX sender ON history(sender_name);
+	CREATE INDEX receiver ON history(receiver_name);
+	CREATE INDEX idx_owner_id_item ON inventory (owner_id, item);
+	INSERT INTO users (username, password, balance) VALUES ('old', 'pass', 700);
+	INSERT INTO inventory (owner_id, item) VALUES (1, 'cup');
+	`)
+	require.NoError(t, err)
+
+	// the script is run twice, it must be safe to repeat
+	for i := 0; i < 2; i++ {
+		_, err = conn.ExecContext(ctx, string(script))
+		require.NoError(t, err)
+	}
+
+	var columns int
+	err = conn.QueryRowContext(ctx, `
+	SELECT count(*)
+	FROM information_schema.columns
+	WHERE table_schema = 'legacy' AND table_name || '.' || column_name = ANY($1)
+`, requiredColumns).Scan(&columns)
+	require.NoError(t, err)
+	assert.Equal(t, len(requiredColumns), columns)
+
+	var role string
+	var held int
+	err = conn.QueryRowContext(ctx, "SELECT role, held FROM users WHERE username = 'old'").Scan(&role, &held)
+	require.NoError(t, err)
+	assert.Equal(t, "user", role)
+	assert.Equal(t, 0, held)
+
+	var recent int
+	err = conn.QueryRowContext(ctx, "SELECT count(*) FROM inventory WHERE created_at > now() - interval '1 day'").Scan(&recent)
+	require.NoError(t, err)
+	assert.Zero(t, recent)
+}
@@ -3,6 +3,7 @@ package postgres
 import (
 	"AvitoTech/internal/entity"
 	"AvitoTech/internal/repository"
+	"context"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 	"testing"
@@ -10,7 +11,7 @@ import (
 
 func TestInsertOperation(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	repo := NewHistoryRepository(logger, db)
+	repo := NewHistoryRepository(logger, sqlDB)
 
 	operation := entity.Operation{
 		FromUser: "user1",
@@ -18,14 +19,14 @@ func TestInsertOperation(t *testing.T) {
 		Amount:   100,
 	}
 
-	insertedOperation, err := repo.InsertOperation(operation)
+	insertedOperation, err := repo.InsertOperation(context.Background(), operation)
 	assert.NoError(t, err)
 	assert.NotNil(t, insertedOperation)
 	assert.Equal(t, "user1", insertedOperation.FromUser)
 	assert.Equal(t, "user2", insertedOperation.ToUser)
 	assert.Equal(t, 100, insertedOperation.Amount)
 	defer func(repo repository.HistoryRepository, id int) {
-		err = repo.DeleteOperation(id)
+		err = repo.DeleteOperation(context.Background(), id)
 		if err != nil {
 			logger.Error("Error deleting operation", zap.Error(err))
 		}
@@ -40,23 +41,23 @@ func TestInsertOperation(t *testing.T) {
 
 func TestGetSentByUser(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	repo := NewHistoryRepository(logger, db)
+	repo := NewHistoryRepository(logger, sqlDB)
 
 	operation := entity.Operation{
 		FromUser: "user1",
 		ToUser:   "user2",
 		Amount:   100,
 	}
-	o, err := repo.InsertOperation(operation)
+	o, err := repo.InsertOperation(context.Background(), operation)
 	assert.NoError(t, err)
 	defer func(repo repository.HistoryRepository, id int) {
-		err = repo.DeleteOperation(id)
+		err = repo.DeleteOperation(context.Background(), id)
 		if err != nil {
 			logger.Error("Error deleting operation", zap.Error(err))
 		}
 	}(repo, o.ID)
 
-	operations, err := repo.GetSentByUser("user1")
+	operations, err := repo.GetSentByUser(context.Background(), "user1")
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(operations))
 	assert.Equal(t, "user1", operations[0].FromUser)
@@ -66,23 +67,23 @@ func TestGetSentByUser(t *testing.T) {
 
 func TestGetReceivedByUser(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	repo := NewHistoryRepository(logger, db)
+	repo := NewHistoryRepository(logger, sqlDB)
 
 	operation := entity.Operation{
 		FromUser: "user1",
 		ToUser:   "user2",
 		Amount:   100,
 	}
-	o, err := repo.InsertOperation(operation)
+	o, err := repo.InsertOperation(context.Background(), operation)
 	assert.NoError(t, err)
 	defer func(repo repository.HistoryRepository, id int) {
-		err = repo.DeleteOperation(id)
+		err = repo.DeleteOperation(context.Background(), id)
 		if err != nil {
 			logger.Error("Error deleting operation", zap.Error(err))
 		}
 	}(repo, o.ID)
 
-	operations, err := repo.GetReceivedByUser("user2")
+	operations, err := repo.GetReceivedByUser(context.Background(), "user2")
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(operations))
 	assert.Equal(t, "user1", operations[0].FromUser)
@@ -92,35 +93,35 @@ func TestGetReceivedByUser(t *testing.T) {
 
 func TestGetSentByUserEmpty(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	repo := NewHistoryRepository(logger, db)
+	repo := NewHistoryRepository(logger, sqlDB)
 
-	operations, err := repo.GetSentByUser("nonexistent_user")
+	operations, err := repo.GetSentByUser(context.Background(), "nonexistent_user")
 	assert.NoError(t, err)
 	assert.Equal(t, 0, len(operations))
 }
 
 func TestGetReceivedByUserEmpty(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	repo := NewHistoryRepository(logger, db)
+	repo := NewHistoryRepository(logger, sqlDB)
 
-	operations, err := repo.GetReceivedByUser("nonexistent_user")
+	operations, err := repo.GetReceivedByUser(context.Background(), "nonexistent_user")
 	assert.NoError(t, err)
 	assert.Equal(t, 0, len(operations))
 }
 
 func TestDeleteOperation(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	repo := NewHistoryRepository(logger, db)
+	repo := NewHistoryRepository(logger, sqlDB)
 
 	operation := entity.Operation{
 		FromUser: "user1",
 		ToUser:   "user2",
 		Amount:   100,
 	}
-	insertedOperation, err := repo.InsertOperation(operation)
+	insertedOperation, err := repo.InsertOperation(context.Background(), operation)
 	assert.NoError(t, err)
 
-	err = repo.DeleteOperation(insertedOperation.ID)
+	err = repo.DeleteOperation(context.Background(), insertedOperation.ID)
 	assert.NoError(t, err)
 
 	var count int
@@ -131,9 +132,9 @@ func TestDeleteOperation(t *testing.T) {
 
 func TestDeleteNonExistentOperation(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	repo := NewHistoryRepository(logger, db)
+	repo := NewHistoryRepository(logger, sqlDB)
 
-	err := repo.DeleteOperation(99999)
+	err := repo.DeleteOperation(context.Background(), 99999)
 	assert.NoError(t, err)
 
 	var count int
@@ -0,0 +1,243 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"go.uber.org/zap"
+)
+
+// holdsQuery reads the holds with the usernames of the sponsor and, once
+// released, of the recipient.
+const holdsQuery = `
+	SELECT h.id, h.sponsor_id, s.username, h.amount, h.memo, h.status,
+		h.recipient_id, COALESCE(r.username, ''), h.created_at, h.resolved_at
+	FROM holds h
+	JOIN users s ON s.user_id = h.sponsor_id
+	LEFT JOIN users r ON r.user_id = h.recipient_id
+`
+
+// Hold checks the available balance under the row lock, like BuyItem,
+// and moves the coins to the held part of the balance.
+func (u UserRepository) Hold(ctx context.Context, user int, amount int, memo string) (*entity.Hold, error) {
+	var hold *entity.Hold
+	err := withTx(ctx, u.l, u.db, func(tx Tx) error {
+		balances, err := lockBalances(ctx, tx, user)
+		if err != nil {
+			u.l.Error("Failed to check balance", zap.Error(err))
+			return err
+		}
+
+		balance, ok := balances[user]
+		if !ok {
+			return repository.ErrorUserNotFound
+		}
+		if balance < amount {
+			return repository.ErrorInsufficientBalance
+		}
+
+		_, err = tx.Exec(ctx, "UPDATE users SET held = held + $1 WHERE user_id = $2", amount, user)
+		if err != nil {
+			u.l.Error("Failed to hold coins", zap.Error(err))
+			return err
+		}
+
+		var id int64
+		err = tx.QueryRow(ctx, `
+		INSERT INTO holds (sponsor_id, amount, memo)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, user, amount, memo).Scan(&id)
+		if err != nil {
+			u.l.Error("Failed to insert hold", zap.Error(err))
+			return err
+		}
+
+		hold, err = u.getHold(ctx, tx, id, user)
+		if err != nil {
+			return err
+		}
+		return u.auditHold(ctx, tx, entity.AuditHold, hold, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// ReleaseHold locks the hold before the balances, so a hold is released or
+// cancelled once however many times it is resolved concurrently. The payout
+// counts against the transfer limits of the sponsor like any transfer.
+func (u UserRepository) ReleaseHold(ctx context.Context, id int64, sponsor int, recipient int, limits []entity.SpendingLimit) (*entity.Hold, error) {
+	var hold *entity.Hold
+	err := retryOnConflict(ctx, u.l, func() error {
+		var err error
+		hold, err = u.releaseHold(ctx, id, sponsor, recipient, limits)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+func (u UserRepository) releaseHold(ctx context.Context, id int64, sponsor int, recipient int, limits []entity.SpendingLimit) (*entity.Hold, error) {
+	var hold *entity.Hold
+	err := withTx(ctx, u.l, u.db, func(tx Tx) error {
+		var err error
+		hold, err = u.getHold(ctx, tx, id, sponsor)
+		if err != nil {
+			return err
+		}
+		if hold.Status != entity.HoldActive {
+			return repository.ErrorHoldNotActive
+		}
+
+		balances, err := lockBalances(ctx, tx, sponsor, recipient)
+		if err != nil {
+			u.l.Error("Failed to lock balances", zap.Error(err))
+			return err
+		}
+		if _, ok := balances[recipient]; !ok {
+			return repository.ErrorUserNotFound
+		}
+		if err = checkLimits(ctx, tx, sponsor, entity.LimitTransfer, hold.Amount, limits); err != nil {
+			if !errors.Is(err, repository.ErrorLimitExceeded) {
+				u.l.Error("Failed to check limits", zap.Error(err))
+			}
+			return err
+		}
+
+		err = tx.ExecBatch(ctx,
+			Query{SQL: "UPDATE users SET balance = balance - $1, held = held - $1 WHERE user_id = $2", Args: []any{hold.Amount, sponsor}},
+			Query{SQL: "UPDATE users SET balance = balance + $1 WHERE user_id = $2", Args: []any{hold.Amount, recipient}},
+			Query{SQL: `
+		INSERT INTO history (sender_name, receiver_name, amount, reason)
+		SELECT $1::text, username, $3::int, NULLIF($4::text, '')
+		FROM users
+		WHERE user_id = $2
+	`, Args: []any{hold.Sponsor, recipient, hold.Amount, hold.Memo}},
+			Query{SQL: `
+		UPDATE holds
+		SET status = 'released', recipient_id = $2, resolved_at = now()
+		WHERE id = $1
+	`, Args: []any{id, recipient}},
+		)
+		if err != nil {
+			u.l.Error("Failed to release hold", zap.Error(err))
+			return err
+		}
+
+		hold, err = u.getHold(ctx, tx, id, sponsor)
+		if err != nil {
+			return err
+		}
+		return u.auditHold(ctx, tx, entity.AuditReleaseHold, hold, hold.Recipient)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+func (u UserRepository) CancelHold(ctx context.Context, id int64, sponsor int) (*entity.Hold, error) {
+	var hold *entity.Hold
+	err := withTx(ctx, u.l, u.db, func(tx Tx) error {
+		var err error
+		hold, err = u.getHold(ctx, tx, id, sponsor)
+		if err != nil {
+			return err
+		}
+		if hold.Status != entity.HoldActive {
+			return repository.ErrorHoldNotActive
+		}
+
+		err = tx.ExecBatch(ctx,
+			Query{SQL: "UPDATE users SET held = held - $1 WHERE user_id = $2", Args: []any{hold.Amount, sponsor}},
+			Query{SQL: `
+		UPDATE holds
+		SET status = 'cancelled', resolved_at = now()
+		WHERE id = $1
+	`, Args: []any{id}},
+		)
+		if err != nil {
+			u.l.Error("Failed to cancel hold", zap.Error(err))
+			return err
+		}
+
+		hold, err = u.getHold(ctx, tx, id, sponsor)
+		if err != nil {
+			return err
+		}
+		return u.auditHold(ctx, tx, entity.AuditCancelHold, hold, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// auditHold records action on hold by its sponsor within the transaction q.
+func (u UserRepository) auditHold(ctx context.Context, q Querier, action string, hold *entity.Hold, target string) error {
+	_, err := appendAudit(ctx, q, newAuditEntry(hold.Sponsor, action, target, map[string]any{
+		"hold":   hold.ID,
+		"amount": hold.Amount,
+	}))
+	if err != nil {
+		u.l.Error("Failed to write audit log", zap.Error(err))
+	}
+	return err
+}
+
+func (u UserRepository) ListHolds(ctx context.Context, sponsor int, limit int) ([]entity.Hold, error) {
+	rows, err := u.db.Query(ctx, holdsQuery+`
+		WHERE h.sponsor_id = $1
+		ORDER BY h.id DESC
+		LIMIT $2
+	`, sponsor, limit)
+	if err != nil {
+		u.l.Error("Failed to list holds", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	holds := make([]entity.Hold, 0)
+	for rows.Next() {
+		var hold entity.Hold
+		if err = rows.Scan(holdFields(&hold)...); err != nil {
+			u.l.Error("Failed to scan hold", zap.Error(err))
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+	if err = rows.Err(); err != nil {
+		u.l.Error("Failed to list holds", zap.Error(err))
+		return nil, err
+	}
+	return holds, nil
+}
+
+// getHold reads and locks hold id of sponsor until the end of the
+// transaction q.
+func (u UserRepository) getHold(ctx context.Context, q Querier, id int64, sponsor int) (*entity.Hold, error) {
+	var hold entity.Hold
+	err := q.QueryRow(ctx, holdsQuery+`
+		WHERE h.id = $1 AND h.sponsor_id = $2
+		FOR UPDATE OF h
+	`, id, sponsor).Scan(holdFields(&hold)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrorHoldNotFound
+		}
+		u.l.Error("Failed to find hold", zap.Error(err))
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func holdFields(h *entity.Hold) []any {
+	return []any{&h.ID, &h.SponsorID, &h.Sponsor, &h.Amount, &h.Memo, &h.Status,
+		&h.RecipientID, &h.Recipient, &h.CreatedAt, &h.ResolvedAt}
+}
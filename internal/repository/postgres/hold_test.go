@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestHolds(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			users := NewUserRepository(logger, conn)
+			accounts := NewAccountRepository(logger, conn)
+
+			alice, err := users.InsertUser(ctx, &entity.User{Username: "sponsor_" + name, Password: "pass", Balance: 100})
+			require.NoError(t, err)
+			bob, err := users.InsertUser(ctx, &entity.User{Username: "hunter_" + name, Password: "pass", Balance: 0})
+			require.NoError(t, err)
+
+			bounty, err := users.Hold(ctx, alice.ID, 60, "fix the build")
+			require.NoError(t, err)
+			assert.Equal(t, entity.HoldActive, bounty.Status)
+			assert.Equal(t, alice.Username, bounty.Sponsor)
+			_, err = users.Hold(ctx, alice.ID, 50, "")
+			assert.ErrorIs(t, err, repository.ErrorInsufficientBalance)
+
+			// held coins can be neither sent nor spent
+			info, err := accounts.GetAccountInfo(ctx, alice.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 40, info.Coins)
+			assert.Equal(t, 60, info.Held)
+			assert.ErrorIs(t, users.TransferMoney(ctx, alice.ID, bob.ID, 50, nil), repository.ErrorInsufficientBalance)
+			assert.ErrorIs(t, users.BuyItem(ctx, alice.ID, "cup", 50, nil), repository.ErrorInsufficientBalance)
+
+			released, err := users.ReleaseHold(ctx, bounty.ID, alice.ID, bob.ID, nil)
+			require.NoError(t, err)
+			assert.Equal(t, entity.HoldReleased, released.Status)
+			assert.Equal(t, bob.Username, released.Recipient)
+			assert.NotNil(t, released.ResolvedAt)
+			_, err = users.ReleaseHold(ctx, bounty.ID, alice.ID, bob.ID, nil)
+			assert.ErrorIs(t, err, repository.ErrorHoldNotActive)
+			_, err = users.CancelHold(ctx, bounty.ID, bob.ID)
+			assert.ErrorIs(t, err, repository.ErrorHoldNotFound)
+
+			info, err = accounts.GetAccountInfo(ctx, alice.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 40, info.Coins)
+			assert.Equal(t, 0, info.Held)
+			info, err = accounts.GetAccountInfo(ctx, bob.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 60, info.Coins)
+			require.Len(t, info.Received, 1)
+			assert.Equal(t, "fix the build", info.Received[0].Reason)
+
+			refund, err := users.Hold(ctx, alice.ID, 40, "")
+			require.NoError(t, err)
+			cancelled, err := users.CancelHold(ctx, refund.ID, alice.ID)
+			require.NoError(t, err)
+			assert.Equal(t, entity.HoldCancelled, cancelled.Status)
+			_, err = users.CancelHold(ctx, refund.ID, alice.ID)
+			assert.ErrorIs(t, err, repository.ErrorHoldNotActive)
+
+			user, err := users.FindUserByID(ctx, alice.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 40, user.Balance)
+
+			holds, err := users.ListHolds(ctx, alice.ID, 10)
+			require.NoError(t, err)
+			require.Len(t, holds, 2)
+			assert.Equal(t, refund.ID, holds[0].ID)
+			assert.Equal(t, bounty.ID, holds[1].ID)
+		})
+	}
+}
@@ -3,34 +3,22 @@ package postgres
 import (
 	"AvitoTech/internal/entity"
 	"AvitoTech/internal/repository"
-	"database/sql"
+	"context"
 	"go.uber.org/zap"
 )
 
 type InventoryRepository struct {
 	l  *zap.Logger
-	db *sql.DB
+	db DB
 }
 
-func (i InventoryRepository) InsertItem(owner int, itemTitle string) (*entity.Item, error) {
-	q, err := i.db.Prepare(`
-	INSERT INTO inventory (owner_id, item)
-	VALUES ($1, $2)
-	RETURNING id, owner_id, item
-`)
-	if err != nil {
-		i.l.Error("failed to prepare inventory query", zap.Error(err))
-		return nil, err
-	}
-	defer func(q *sql.Stmt) {
-		err = q.Close()
-		if err != nil {
-			i.l.Error("failed to close inventory query", zap.Error(err))
-		}
-	}(q)
-
+func (i InventoryRepository) InsertItem(ctx context.Context, owner int, itemTitle string, price int) (*entity.Item, error) {
 	var item entity.Item
-	err = q.QueryRow(owner, itemTitle).Scan(&item.ID, &item.OwnerID, &item.Title)
+	err := i.db.QueryRow(ctx, `
+	INSERT INTO inventory (owner_id, item, price)
+	VALUES ($1, $2, $3)
+	RETURNING id, owner_id, item
+`, owner, itemTitle, price).Scan(&item.ID, &item.OwnerID, &item.Title)
 	if err != nil {
 		i.l.Error("failed to insert item", zap.Error(err))
 		return nil, err
@@ -39,35 +27,18 @@ func (i InventoryRepository) InsertItem(owner int, itemTitle string) (*entity.It
 	return &item, nil
 }
 
-func (i InventoryRepository) GetUsersInventory(userID int) (map[string]int, error) {
-	q, err := i.db.Prepare(`
+func (i InventoryRepository) GetUsersInventory(ctx context.Context, userID int) (map[string]int, error) {
+	rows, err := i.db.Query(ctx, `
 	SELECT item, count(item)
 	FROM inventory
 	WHERE owner_id = $1
 	GROUP BY item
-`)
-	if err != nil {
-		i.l.Error("failed to prepare query", zap.Error(err))
-		return nil, err
-	}
-	defer func(q *sql.Stmt) {
-		err = q.Close()
-		if err != nil {
-			i.l.Error("failed to close inventory query", zap.Error(err))
-		}
-	}(q)
-
-	rows, err := q.Query(userID)
+`, userID)
 	if err != nil {
 		i.l.Error("failed to query", zap.Error(err))
 		return nil, err
 	}
-	defer func(rows *sql.Rows) {
-		err = rows.Close()
-		if err != nil {
-			i.l.Error("failed to close inventory query", zap.Error(err))
-		}
-	}(rows)
+	defer rows.Close()
 
 	var result = make(map[string]int)
 	for rows.Next() {
@@ -82,26 +53,14 @@ func (i InventoryRepository) GetUsersInventory(userID int) (map[string]int, erro
 		result[item] = count
 	}
 
-	return result, nil
+	return result, rows.Err()
 }
 
-func (i InventoryRepository) DeleteItem(id int) error {
-	q, err := i.db.Prepare(`
+func (i InventoryRepository) DeleteItem(ctx context.Context, id int) error {
+	_, err := i.db.Exec(ctx, `
 	DELETE FROM inventory
 	WHERE id = $1
-`)
-	if err != nil {
-		i.l.Error("failed to prepare delete query", zap.Error(err))
-		return err
-	}
-	defer func(q *sql.Stmt) {
-		err = q.Close()
-		if err != nil {
-			i.l.Error("failed to close inventory query", zap.Error(err))
-		}
-	}(q)
-
-	_, err = q.Exec(id)
+`, id)
 	if err != nil {
 		i.l.Error("failed to delete item", zap.Error(err))
 		return err
@@ -111,7 +70,7 @@ func (i InventoryRepository) DeleteItem(id int) error {
 
 func NewInventoryRepository(
 	l *zap.Logger,
-	db *sql.DB,
+	db DB,
 ) repository.InventoryRepository {
 	return &InventoryRepository{
 		l:  l,
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"AvitoTech/internal/repository"
+	"context"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 	"testing"
@@ -9,12 +10,12 @@ import (
 
 func TestInsertItem(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	repo := NewInventoryRepository(logger, db)
+	repo := NewInventoryRepository(logger, sqlDB)
 
-	item, err := repo.InsertItem(1, "item1")
+	item, err := repo.InsertItem(context.Background(), 1, "item1", 10)
 	assert.NoError(t, err)
 	defer func(repo repository.InventoryRepository, id int) {
-		err = repo.DeleteItem(id)
+		err = repo.DeleteItem(context.Background(), id)
 		if err != nil {
 			logger.Error("error deleting item", zap.Error(err))
 		}
@@ -28,36 +29,36 @@ func TestInsertItem(t *testing.T) {
 
 func TestGetUsersInventory(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	repo := NewInventoryRepository(logger, db)
+	repo := NewInventoryRepository(logger, sqlDB)
 
-	item1, err := repo.InsertItem(1, "item1")
+	item1, err := repo.InsertItem(context.Background(), 1, "item1", 10)
 	assert.NoError(t, err)
 	defer func(repo repository.InventoryRepository, id int) {
-		err = repo.DeleteItem(id)
+		err = repo.DeleteItem(context.Background(), id)
 		if err != nil {
 			logger.Error("error deleting item", zap.Error(err))
 		}
 	}(repo, item1.ID)
 
-	item2, err := repo.InsertItem(1, "item1")
+	item2, err := repo.InsertItem(context.Background(), 1, "item1", 10)
 	assert.NoError(t, err)
 	defer func(repo repository.InventoryRepository, id int) {
-		err = repo.DeleteItem(id)
+		err = repo.DeleteItem(context.Background(), id)
 		if err != nil {
 			logger.Error("error deleting item", zap.Error(err))
 		}
 	}(repo, item2.ID)
 
-	item3, err := repo.InsertItem(1, "item2")
+	item3, err := repo.InsertItem(context.Background(), 1, "item2", 10)
 	assert.NoError(t, err)
 	defer func(repo repository.InventoryRepository, id int) {
-		err = repo.DeleteItem(id)
+		err = repo.DeleteItem(context.Background(), id)
 		if err != nil {
 			logger.Error("error deleting item", zap.Error(err))
 		}
 	}(repo, item3.ID)
 
-	inventory, err := repo.GetUsersInventory(1)
+	inventory, err := repo.GetUsersInventory(context.Background(), 1)
 	assert.NoError(t, err)
 	assert.NotNil(t, inventory)
 	assert.Equal(t, 2, inventory["item1"])
@@ -66,12 +67,12 @@ func TestGetUsersInventory(t *testing.T) {
 
 func TestDeleteItem(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	repo := NewInventoryRepository(logger, db)
+	repo := NewInventoryRepository(logger, sqlDB)
 
-	item, err := repo.InsertItem(1, "item1")
+	item, err := repo.InsertItem(context.Background(), 1, "item1", 10)
 	assert.NoError(t, err)
 
-	err = repo.DeleteItem(item.ID)
+	err = repo.DeleteItem(context.Background(), item.ID)
 	assert.NoError(t, err)
 
 	var count int
@@ -82,9 +83,9 @@ func TestDeleteItem(t *testing.T) {
 
 func TestGetUsersInventoryEmpty(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	repo := NewInventoryRepository(logger, db)
+	repo := NewInventoryRepository(logger, sqlDB)
 
-	inventory, err := repo.GetUsersInventory(999)
+	inventory, err := repo.GetUsersInventory(context.Background(), 999)
 	assert.NoError(t, err)
 	assert.NotNil(t, inventory)
 	assert.Equal(t, 0, len(inventory))
@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"go.uber.org/zap"
+	"time"
+)
+
+type JobRunRepository struct {
+	l  *zap.Logger
+	db DB
+}
+
+// Start relies on the unique (job, scheduled_at) pair, so a run is claimed
+// once even by replicas that both believe they lead.
+func (r JobRunRepository) Start(ctx context.Context, job string, scheduledAt time.Time, replica string) (*entity.JobRun, error) {
+	run := entity.JobRun{Job: job, ScheduledAt: scheduledAt, Replica: replica}
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO job_runs (job, scheduled_at, replica)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (job, scheduled_at) DO NOTHING
+		RETURNING id, started_at
+	`, job, scheduledAt, replica).Scan(&run.ID, &run.StartedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrorJobRunClaimed
+		}
+		r.l.Error("failed to start job run", zap.Error(err))
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (r JobRunRepository) Finish(ctx context.Context, id int64, runErr string) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE job_runs
+		SET finished_at = now(), error = NULLIF($2, '')
+		WHERE id = $1
+	`, id, runErr)
+	if err != nil {
+		r.l.Error("failed to finish job run", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r JobRunRepository) Last(ctx context.Context, job string) (*entity.JobRun, error) {
+	runs, err := r.List(ctx, job, 1)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return &runs[0], nil
+}
+
+func (r JobRunRepository) List(ctx context.Context, job string, limit int) ([]entity.JobRun, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, job, scheduled_at, started_at, finished_at, COALESCE(error, ''), replica
+		FROM job_runs
+		WHERE $1 = '' OR job = $1
+		ORDER BY scheduled_at DESC, id DESC
+		LIMIT $2
+	`, job, limit)
+	if err != nil {
+		r.l.Error("failed to list job runs", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make([]entity.JobRun, 0)
+	for rows.Next() {
+		var run entity.JobRun
+		err = rows.Scan(&run.ID, &run.Job, &run.ScheduledAt, &run.StartedAt, &run.FinishedAt, &run.Error, &run.Replica)
+		if err != nil {
+			r.l.Error("failed to scan job run", zap.Error(err))
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	if err = rows.Err(); err != nil {
+		r.l.Error("failed to list job runs", zap.Error(err))
+		return nil, err
+	}
+	return runs, nil
+}
+
+func NewJobRunRepository(
+	l *zap.Logger,
+	db DB,
+) repository.JobRunRepository {
+	return &JobRunRepository{
+		l:  l,
+		db: db,
+	}
+}
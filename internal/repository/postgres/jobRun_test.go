@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"AvitoTech/internal/repository"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestJobRuns(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			runs := NewJobRunRepository(logger, conn)
+			job := "job_" + name
+			due := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			last, err := runs.Last(ctx, job)
+			require.NoError(t, err)
+			assert.Nil(t, last)
+
+			run, err := runs.Start(ctx, job, due, "a")
+			require.NoError(t, err)
+
+			// another replica can't claim the same run
+			_, err = runs.Start(ctx, job, due, "b")
+			assert.ErrorIs(t, err, repository.ErrorJobRunClaimed)
+
+			require.NoError(t, runs.Finish(ctx, run.ID, "boom"))
+			next, err := runs.Start(ctx, job, due.AddDate(0, 0, 1), "b")
+			require.NoError(t, err)
+			require.NoError(t, runs.Finish(ctx, next.ID, ""))
+
+			last, err = runs.Last(ctx, job)
+			require.NoError(t, err)
+			require.NotNil(t, last)
+			assert.Equal(t, next.ID, last.ID)
+			assert.Equal(t, "b", last.Replica)
+			assert.NotNil(t, last.FinishedAt)
+			assert.Empty(t, last.Error)
+
+			list, err := runs.List(ctx, job, 10)
+			require.NoError(t, err)
+			require.Len(t, list, 2)
+			assert.Equal(t, "boom", list[1].Error)
+			assert.True(t, list[1].ScheduledAt.Equal(due))
+		})
+	}
+}
+
+func TestLeader(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+	const key = 42
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			leader := NewLeaderRepository(logger, conn)
+
+			lease, err := leader.TryLead(ctx, key)
+			require.NoError(t, err)
+			require.NotNil(t, lease)
+			assert.NoError(t, lease.Alive(ctx))
+
+			// the leadership is held until released
+			other, err := leader.TryLead(ctx, key)
+			require.NoError(t, err)
+			assert.Nil(t, other)
+
+			require.NoError(t, lease.Release(ctx))
+			assert.Error(t, lease.Alive(ctx))
+
+			other, err = leader.TryLead(ctx, key)
+			require.NoError(t, err)
+			require.NotNil(t, other)
+			require.NoError(t, other.Release(ctx))
+		})
+	}
+}
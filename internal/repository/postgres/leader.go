@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"AvitoTech/internal/repository"
+	"context"
+	"errors"
+	"go.uber.org/zap"
+)
+
+var errLeaseReleased = errors.New("lease released")
+
+type LeaderRepository struct {
+	l  *zap.Logger
+	db DB
+}
+
+// TryLead takes a session-level advisory lock on a connection set aside for
+// the lease. No transaction stays open meanwhile. The lock goes away with the
+// connection, so a replica that crashes or loses the database steps down
+// without releasing it.
+func (r LeaderRepository) TryLead(ctx context.Context, key int64) (repository.Lease, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		r.l.Error("failed to acquire lease connection", zap.Error(err))
+		return nil, err
+	}
+
+	var locked bool
+	if err = conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil || !locked {
+		if closeErr := conn.Close(); closeErr != nil {
+			r.l.Error("failed to close lease connection", zap.Error(closeErr))
+		}
+		if err != nil {
+			r.l.Error("failed to take leadership", zap.Error(err))
+		}
+		return nil, err
+	}
+	return &lease{conn: conn, key: key}, nil
+}
+
+type lease struct {
+	conn     Conn
+	key      int64
+	released bool
+}
+
+func (l *lease) Alive(ctx context.Context) error {
+	if l.released {
+		return errLeaseReleased
+	}
+	var one int
+	return l.conn.QueryRow(ctx, "SELECT 1").Scan(&one)
+}
+
+// Release unlocks the key before the connection goes back to the pool, where
+// the lock would otherwise outlive the lease.
+func (l *lease) Release(ctx context.Context) error {
+	if l.released {
+		return nil
+	}
+	l.released = true
+
+	var unlocked bool
+	err := l.conn.QueryRow(ctx, "SELECT pg_advisory_unlock($1)", l.key).Scan(&unlocked)
+	if closeErr := l.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func NewLeaderRepository(
+	l *zap.Logger,
+	db DB,
+) repository.LeaderRepository {
+	return &LeaderRepository{
+		l:  l,
+		db: db,
+	}
+}
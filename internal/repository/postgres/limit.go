@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"time"
+)
+
+type LimitRepository struct {
+	l  *zap.Logger
+	db DB
+}
+
+func (r LimitRepository) Overrides(ctx context.Context, userID int) ([]entity.SpendingLimit, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT operation, period, amount
+		FROM spending_limits
+		WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		r.l.Error("failed to read spending limits", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	limits := make([]entity.SpendingLimit, 0)
+	for rows.Next() {
+		limit := entity.SpendingLimit{Override: true}
+		if err = rows.Scan(&limit.Operation, &limit.Period, &limit.Amount); err != nil {
+			r.l.Error("failed to scan spending limit", zap.Error(err))
+			return nil, err
+		}
+		limits = append(limits, limit)
+	}
+	if err = rows.Err(); err != nil {
+		r.l.Error("failed to read spending limits", zap.Error(err))
+		return nil, err
+	}
+	return limits, nil
+}
+
+func (r LimitRepository) SetOverride(ctx context.Context, userID int, limit entity.SpendingLimit) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO spending_limits (user_id, operation, period, amount)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, operation, period) DO UPDATE SET amount = EXCLUDED.amount
+	`, userID, string(limit.Operation), string(limit.Period), limit.Amount)
+	if err != nil {
+		r.l.Error("failed to set spending limit", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r LimitRepository) DeleteOverride(ctx context.Context, userID int, operation entity.LimitOperation, period entity.LimitPeriod) error {
+	n, err := r.db.Exec(ctx, `
+		DELETE FROM spending_limits
+		WHERE user_id = $1 AND operation = $2 AND period = $3
+	`, userID, string(operation), string(period))
+	if err != nil {
+		r.l.Error("failed to delete spending limit", zap.Error(err))
+		return err
+	}
+	if n == 0 {
+		return repository.ErrorLimitNotFound
+	}
+	return nil
+}
+
+// Spent counts transfers from the history, whatever sent them, except the
+// coins taken by the system account. Purchases are counted at the price they
+// were bought for.
+func (r LimitRepository) Spent(ctx context.Context, userID int, operation entity.LimitOperation, since time.Time) (int, error) {
+	spent, err := spentSince(ctx, r.db, userID, operation, since)
+	if err != nil {
+		r.l.Error("failed to sum spending", zap.String("operation", string(operation)), zap.Error(err))
+		return 0, err
+	}
+	return spent, nil
+}
+
+func spentSince(ctx context.Context, q Querier, userID int, operation entity.LimitOperation, since time.Time) (int, error) {
+	var query string
+	args := []any{userID, since}
+	switch operation {
+	case entity.LimitTransfer:
+		query = `
+		SELECT COALESCE(sum(h.amount), 0)
+		FROM history h
+		JOIN users u ON u.username = h.sender_name
+		WHERE u.user_id = $1 AND h.created_at > $2 AND h.receiver_name <> $3
+	`
+		args = append(args, entity.SystemAccount)
+	case entity.LimitPurchase:
+		query = `
+		SELECT COALESCE(sum(price), 0)
+		FROM inventory
+		WHERE owner_id = $1 AND created_at > $2
+	`
+	default:
+		return 0, fmt.Errorf("unknown operation %q", operation)
+	}
+
+	var spent int
+	if err := q.QueryRow(ctx, query, args...).Scan(&spent); err != nil {
+		return 0, err
+	}
+	return spent, nil
+}
+
+// checkLimits fails with ErrorLimitExceeded if spending amount on operation
+// would take userID over one of limits. It is called with the row of userID
+// locked, so that operations of the user check their limits one at a time
+// and each one sees what the ones before it spent.
+func checkLimits(ctx context.Context, q Querier, userID int, operation entity.LimitOperation, amount int, limits []entity.SpendingLimit) error {
+	now := time.Now()
+	for _, limit := range limits {
+		if limit.Operation != operation || limit.Amount == 0 {
+			continue
+		}
+		var err error
+		limit.Used, err = spentSince(ctx, q, userID, operation, now.Add(-limit.Period.Window()))
+		if err != nil {
+			return err
+		}
+		if limit.Used+amount > limit.Amount {
+			return fmt.Errorf("%w: %s %s limit is %d, %d left",
+				repository.ErrorLimitExceeded, limit.Period, limit.Operation, limit.Amount, limit.Remaining())
+		}
+	}
+	return nil
+}
+
+func NewLimitRepository(
+	l *zap.Logger,
+	db DB,
+) repository.LimitRepository {
+	return &LimitRepository{
+		l:  l,
+		db: db,
+	}
+}
@@ -0,0 +1,177 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLimits(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			users := NewUserRepository(logger, conn)
+			history := NewHistoryRepository(logger, conn)
+			inventory := NewInventoryRepository(logger, conn)
+			limits := NewLimitRepository(logger, conn)
+
+			alice, err := users.InsertUser(ctx, &entity.User{Username: "spender_" + name, Password: "pass", Balance: 1000})
+			require.NoError(t, err)
+
+			_, err = history.InsertOperation(ctx, entity.Operation{FromUser: alice.Username, ToUser: "friend_" + name, Amount: 30})
+			require.NoError(t, err)
+			// coins taken by the system don't count as spent
+			_, err = history.InsertOperation(ctx, entity.Operation{FromUser: alice.Username, ToUser: entity.SystemAccount, Amount: 500})
+			require.NoError(t, err)
+			_, err = inventory.InsertItem(ctx, alice.ID, "cup", 20)
+			require.NoError(t, err)
+
+			dayAgo := time.Now().Add(-24 * time.Hour)
+			spent, err := limits.Spent(ctx, alice.ID, entity.LimitTransfer, dayAgo)
+			require.NoError(t, err)
+			assert.Equal(t, 30, spent)
+			spent, err = limits.Spent(ctx, alice.ID, entity.LimitPurchase, dayAgo)
+			require.NoError(t, err)
+			assert.Equal(t, 20, spent)
+			spent, err = limits.Spent(ctx, alice.ID, entity.LimitPurchase, time.Now().Add(time.Minute))
+			require.NoError(t, err)
+			assert.Equal(t, 0, spent)
+
+			limit := entity.SpendingLimit{Operation: entity.LimitTransfer, Period: entity.LimitDaily, Amount: 100}
+			require.NoError(t, limits.SetOverride(ctx, alice.ID, limit))
+			limit.Amount = 200
+			require.NoError(t, limits.SetOverride(ctx, alice.ID, limit))
+
+			overrides, err := limits.Overrides(ctx, alice.ID)
+			require.NoError(t, err)
+			limit.Override = true
+			assert.Equal(t, []entity.SpendingLimit{limit}, overrides)
+
+			require.NoError(t, limits.DeleteOverride(ctx, alice.ID, entity.LimitTransfer, entity.LimitDaily))
+			err = limits.DeleteOverride(ctx, alice.ID, entity.LimitTransfer, entity.LimitDaily)
+			assert.ErrorIs(t, err, repository.ErrorLimitNotFound)
+		})
+	}
+}
+
+func TestLimitsCheckedOnEveryPath(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			users := NewUserRepository(logger, conn)
+			requests := NewCoinRequestRepository(logger, conn)
+			transfers := NewScheduledTransferRepository(logger, conn)
+
+			alice, err := users.InsertUser(ctx, &entity.User{Username: "capped_" + name, Password: "pass", Balance: 1000})
+			require.NoError(t, err)
+			bob, err := users.InsertUser(ctx, &entity.User{Username: "capped_friend_" + name, Password: "pass", Balance: 0})
+			require.NoError(t, err)
+
+			limits := []entity.SpendingLimit{
+				{Operation: entity.LimitTransfer, Period: entity.LimitDaily, Amount: 100},
+				{Operation: entity.LimitPurchase, Period: entity.LimitDaily, Amount: 10},
+			}
+			require.NoError(t, users.TransferMoney(ctx, alice.ID, bob.ID, 60, limits))
+			err = users.TransferMoney(ctx, alice.ID, bob.ID, 50, limits)
+			assert.ErrorIs(t, err, repository.ErrorLimitExceeded)
+			assert.EqualError(t, err, "spending limit exceeded: daily transfer limit is 100, 40 left")
+			assert.ErrorIs(t, users.BuyItem(ctx, alice.ID, "cup", 20, limits), repository.ErrorLimitExceeded)
+
+			// releasing a hold counts as a transfer of the sponsor
+			bounty, err := users.Hold(ctx, alice.ID, 50, "")
+			require.NoError(t, err)
+			_, err = users.ReleaseHold(ctx, bounty.ID, alice.ID, bob.ID, limits)
+			assert.ErrorIs(t, err, repository.ErrorLimitExceeded)
+			holds, err := users.ListHolds(ctx, alice.ID, 1)
+			require.NoError(t, err)
+			assert.Equal(t, entity.HoldActive, holds[0].Status)
+
+			request, err := requests.Create(ctx, entity.CoinRequest{RequesterID: bob.ID, PayerID: alice.ID, Amount: 50}, time.Hour)
+			require.NoError(t, err)
+			_, err = requests.Accept(ctx, request.ID, alice.ID, limits)
+			assert.ErrorIs(t, err, repository.ErrorLimitExceeded)
+			pending, err := requests.Get(ctx, request.ID, alice.ID)
+			require.NoError(t, err)
+			assert.Equal(t, entity.CoinRequestPending, pending.Status)
+
+			due := time.Now().Add(-time.Minute)
+			scheduled, err := transfers.Create(ctx, entity.ScheduledTransfer{SenderID: alice.ID, ReceiverID: bob.ID, Amount: 50, NextRunAt: &due})
+			require.NoError(t, err)
+			var failure error
+			for {
+				transfer, err := transfers.RunDue(ctx, func(senderID int) ([]entity.SpendingLimit, error) {
+					if senderID == alice.ID {
+						return limits, nil
+					}
+					return nil, nil
+				}, func(transfer *entity.ScheduledTransfer, runFailure error) {
+					if transfer.ID == scheduled.ID {
+						failure = runFailure
+					}
+					transfer.Status = entity.ScheduledTransferFailed
+					transfer.NextRunAt = nil
+				})
+				require.NoError(t, err)
+				if transfer == nil {
+					break
+				}
+			}
+			assert.ErrorIs(t, failure, repository.ErrorLimitExceeded)
+
+			user, err := users.FindUserByID(ctx, alice.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 940, user.Balance)
+		})
+	}
+}
+
+func TestLimitsConcurrentTransfers(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+	users := NewUserRepository(logger, sqlDB)
+
+	alice, err := users.InsertUser(ctx, &entity.User{Username: "hasty", Password: "pass", Balance: 1000})
+	require.NoError(t, err)
+	bob, err := users.InsertUser(ctx, &entity.User{Username: "hasty_friend", Password: "pass", Balance: 0})
+	require.NoError(t, err)
+
+	// the sends queue on the row of the sender, none of them can overshoot
+	limits := []entity.SpendingLimit{{Operation: entity.LimitTransfer, Period: entity.LimitDaily, Amount: 50}}
+	const sends = 20
+	errs := make(chan error, sends)
+	var wg sync.WaitGroup
+	for range sends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- users.TransferMoney(ctx, alice.ID, bob.ID, 10, limits)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	sent := 0
+	for err = range errs {
+		if err == nil {
+			sent++
+			continue
+		}
+		assert.ErrorIs(t, err, repository.ErrorLimitExceeded)
+	}
+	assert.Equal(t, 5, sent)
+
+	user, err := users.FindUserByID(ctx, bob.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 50, user.Balance)
+}
@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"go.uber.org/zap"
+	"time"
+)
+
+type LoginAttemptRepository struct {
+	l  *zap.Logger
+	db DB
+}
+
+func (r LoginAttemptRepository) RecordFailure(ctx context.Context, username, ip string) error {
+	_, err := r.db.Exec(ctx, `
+	INSERT INTO login_failures (username, ip)
+	VALUES ($1, $2)
+`, username, ip)
+	if err != nil {
+		r.l.Error("failed to record login failure", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r LoginAttemptRepository) CountFailures(ctx context.Context, username, ip string, window time.Duration) (int, int, error) {
+	var byUsername, byIP int
+	err := r.db.QueryRow(ctx, `
+	SELECT
+		count(*) FILTER (WHERE username = $1),
+		count(*) FILTER (WHERE ip = $2)
+	FROM login_failures
+	WHERE (username = $1 OR ip = $2)
+		AND created_at > now() - make_interval(secs => $3)
+`, username, ip, window.Seconds()).Scan(&byUsername, &byIP)
+	if err != nil {
+		r.l.Error("failed to count login failures", zap.Error(err))
+		return 0, 0, err
+	}
+	return byUsername, byIP, nil
+}
+
+func (r LoginAttemptRepository) ClearFailures(ctx context.Context, username string) error {
+	_, err := r.db.Exec(ctx, `
+	DELETE FROM login_failures
+	WHERE username = $1
+`, username)
+	if err != nil {
+		r.l.Error("failed to clear login failures", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r LoginAttemptRepository) Lock(ctx context.Context, lockout entity.Lockout, duration time.Duration) (*entity.Lockout, error) {
+	err := withTx(ctx, r.l, r.db, func(tx Tx) error {
+		err := tx.QueryRow(ctx, `
+		INSERT INTO lockouts (username, ip, failures, locked_until)
+		VALUES ($1, $2, $3, now() + make_interval(secs => $4))
+		RETURNING id, locked_until, created_at
+	`, lockout.Username, lockout.IP, lockout.Failures, duration.Seconds()).Scan(&lockout.ID, &lockout.LockedUntil, &lockout.CreatedAt)
+		if err != nil {
+			r.l.Error("failed to insert lockout", zap.Error(err))
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+		DELETE FROM login_failures
+		WHERE username = $1
+	`, lockout.Username)
+		if err != nil {
+			r.l.Error("failed to clear login failures", zap.Error(err))
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &lockout, nil
+}
+
+func (r LoginAttemptRepository) ActiveLockout(ctx context.Context, username string) (*entity.Lockout, error) {
+	var lockout entity.Lockout
+	err := r.db.QueryRow(ctx, `
+	SELECT id, username, ip, failures, locked_until, created_at
+	FROM lockouts
+	WHERE username = $1 AND unlocked_at IS NULL AND locked_until > now()
+	ORDER BY locked_until DESC
+	LIMIT 1
+`, username).Scan(
+		&lockout.ID,
+		&lockout.Username,
+		&lockout.IP,
+		&lockout.Failures,
+		&lockout.LockedUntil,
+		&lockout.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		r.l.Error("failed to query lockout", zap.Error(err))
+		return nil, err
+	}
+	return &lockout, nil
+}
+
+func (r LoginAttemptRepository) Unlock(ctx context.Context, username, by string) (bool, error) {
+	var lifted int64
+	err := withTx(ctx, r.l, r.db, func(tx Tx) error {
+		var err error
+		lifted, err = tx.Exec(ctx, `
+		UPDATE lockouts
+		SET unlocked_at = now(), unlocked_by = $2
+		WHERE username = $1 AND unlocked_at IS NULL AND locked_until > now()
+	`, username, by)
+		if err != nil {
+			r.l.Error("failed to lift lockout", zap.Error(err))
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+		DELETE FROM login_failures
+		WHERE username = $1
+	`, username)
+		if err != nil {
+			r.l.Error("failed to clear login failures", zap.Error(err))
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return lifted > 0, nil
+}
+
+func NewLoginAttemptRepository(
+	l *zap.Logger,
+	db DB,
+) repository.LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		l:  l,
+		db: db,
+	}
+}
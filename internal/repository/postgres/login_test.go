@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLoginAttempts(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			attempts := NewLoginAttemptRepository(logger, conn)
+			username := "login_" + name
+			ip := "198.51.100." + map[string]string{"sql": "1", "pgx": "2"}[name]
+
+			require.NoError(t, attempts.RecordFailure(ctx, username, ip))
+			require.NoError(t, attempts.RecordFailure(ctx, username, ip))
+			require.NoError(t, attempts.RecordFailure(ctx, "other_"+name, ip))
+
+			byUsername, byIP, err := attempts.CountFailures(ctx, username, ip, time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, 2, byUsername)
+			assert.Equal(t, 3, byIP)
+
+			lockout, err := attempts.ActiveLockout(ctx, username)
+			require.NoError(t, err)
+			assert.Nil(t, lockout)
+
+			lockout, err = attempts.Lock(ctx, entity.Lockout{Username: username, IP: ip, Failures: 2}, time.Minute)
+			require.NoError(t, err)
+			assert.WithinDuration(t, time.Now().Add(time.Minute), lockout.LockedUntil, 10*time.Second)
+
+			// locking starts the count of the username over
+			byUsername, byIP, err = attempts.CountFailures(ctx, username, ip, time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, 0, byUsername)
+			assert.Equal(t, 1, byIP)
+
+			active, err := attempts.ActiveLockout(ctx, username)
+			require.NoError(t, err)
+			require.NotNil(t, active)
+			assert.Equal(t, lockout.ID, active.ID)
+
+			lifted, err := attempts.Unlock(ctx, username, "admin")
+			require.NoError(t, err)
+			assert.True(t, lifted)
+
+			active, err = attempts.ActiveLockout(ctx, username)
+			require.NoError(t, err)
+			assert.Nil(t, active)
+
+			lifted, err = attempts.Unlock(ctx, username, "admin")
+			require.NoError(t, err)
+			assert.False(t, lifted)
+		})
+	}
+}
+
+func TestLockoutExpires(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+	attempts := NewLoginAttemptRepository(logger, sqlDB)
+
+	_, err := attempts.Lock(ctx, entity.Lockout{Username: "login_expired", IP: "198.51.100.9", Failures: 5}, time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+
+	lockout, err := attempts.ActiveLockout(ctx, "login_expired")
+	require.NoError(t, err)
+	assert.Nil(t, lockout)
+}
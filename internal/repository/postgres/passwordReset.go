@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"go.uber.org/zap"
+	"time"
+)
+
+type PasswordResetRepository struct {
+	l  *zap.Logger
+	db DB
+}
+
+func (p PasswordResetRepository) Create(ctx context.Context, userID int, tokenHash string, ttl time.Duration) (*entity.PasswordReset, error) {
+	reset := entity.PasswordReset{UserID: userID, TokenHash: tokenHash}
+	err := withTx(ctx, p.l, p.db, func(tx Tx) error {
+		_, err := tx.Exec(ctx, `
+		DELETE FROM password_reset_tokens
+		WHERE user_id = $1 AND used_at IS NULL
+	`, userID)
+		if err != nil {
+			p.l.Error("failed to discard reset tokens", zap.Error(err))
+			return err
+		}
+
+		err = tx.QueryRow(ctx, `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, now() + make_interval(secs => $3))
+		RETURNING id, expires_at, created_at
+	`, userID, tokenHash, ttl.Seconds()).Scan(&reset.ID, &reset.ExpiresAt, &reset.CreatedAt)
+		if err != nil {
+			p.l.Error("failed to insert reset token", zap.Error(err))
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &reset, nil
+}
+
+func (p PasswordResetRepository) Find(ctx context.Context, tokenHash string) (int, error) {
+	var userID int
+	err := p.db.QueryRow(ctx, `
+	SELECT user_id
+	FROM password_reset_tokens
+	WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+`, tokenHash).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, repository.ErrorResetTokenInvalid
+		}
+		p.l.Error("failed to find reset token", zap.Error(err))
+		return 0, err
+	}
+	return userID, nil
+}
+
+func (p PasswordResetRepository) Consume(ctx context.Context, tokenHash string, passwordHash string) (int, error) {
+	var userID int
+	err := withTx(ctx, p.l, p.db, func(tx Tx) error {
+		err := tx.QueryRow(ctx, `
+		UPDATE password_reset_tokens
+		SET used_at = now()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+		RETURNING user_id
+	`, tokenHash).Scan(&userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return repository.ErrorResetTokenInvalid
+			}
+			p.l.Error("failed to consume reset token", zap.Error(err))
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+		UPDATE users
+		SET password = $2, token_version = token_version + 1
+		WHERE user_id = $1
+	`, userID, passwordHash)
+		if err != nil {
+			p.l.Error("failed to reset password", zap.Error(err))
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+func NewPasswordResetRepository(
+	l *zap.Logger,
+	db DB,
+) repository.PasswordResetRepository {
+	return &PasswordResetRepository{
+		l:  l,
+		db: db,
+	}
+}
@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSetPassword(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			users := NewUserRepository(logger, conn)
+			user, err := users.InsertUser(ctx, &entity.User{Username: "setpassword_" + name, Password: "old", Balance: 100})
+			require.NoError(t, err)
+
+			version, err := users.SetPassword(ctx, user.ID, "rehashed", false)
+			require.NoError(t, err)
+			assert.Equal(t, 0, version)
+
+			version, err = users.SetPassword(ctx, user.ID, "new", true)
+			require.NoError(t, err)
+			assert.Equal(t, 1, version)
+
+			found, err := users.FindUserByID(ctx, user.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "new", found.Password)
+			assert.Equal(t, 1, found.TokenVersion)
+
+			_, err = users.SetPassword(ctx, -1, "new", true)
+			assert.ErrorIs(t, err, repository.ErrorUserNotFound)
+		})
+	}
+}
+
+func TestPasswordResets(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			users := NewUserRepository(logger, conn)
+			resets := NewPasswordResetRepository(logger, conn)
+			user, err := users.InsertUser(ctx, &entity.User{Username: "reset_" + name, Password: "old", Balance: 100})
+			require.NoError(t, err)
+
+			first, err := resets.Create(ctx, user.ID, "first_"+name, time.Hour)
+			require.NoError(t, err)
+			assert.WithinDuration(t, time.Now().Add(time.Hour), first.ExpiresAt, 10*time.Second)
+
+			// a new token replaces the unused one
+			_, err = resets.Create(ctx, user.ID, "second_"+name, time.Hour)
+			require.NoError(t, err)
+			_, err = resets.Consume(ctx, "first_"+name, "new")
+			assert.ErrorIs(t, err, repository.ErrorResetTokenInvalid)
+
+			userID, err := resets.Consume(ctx, "second_"+name, "new")
+			require.NoError(t, err)
+			assert.Equal(t, user.ID, userID)
+
+			found, err := users.FindUserByID(ctx, user.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "new", found.Password)
+			assert.Equal(t, user.TokenVersion+1, found.TokenVersion)
+
+			// tokens are single-use
+			_, err = resets.Consume(ctx, "second_"+name, "newer")
+			assert.ErrorIs(t, err, repository.ErrorResetTokenInvalid)
+
+			_, err = resets.Create(ctx, user.ID, "expired_"+name, -time.Minute)
+			require.NoError(t, err)
+			_, err = resets.Consume(ctx, "expired_"+name, "newer")
+			assert.ErrorIs(t, err, repository.ErrorResetTokenInvalid)
+		})
+	}
+}
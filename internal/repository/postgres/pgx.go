@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type poolQuerier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+type poolConn struct {
+	q poolQuerier
+}
+
+func (c poolConn) Exec(ctx context.Context, query string, args ...any) (int64, error) {
+	tag, err := c.q.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (c poolConn) Query(ctx context.Context, query string, args ...any) (Rows, error) {
+	return c.q.Query(ctx, query, args...)
+}
+
+func (c poolConn) QueryRow(ctx context.Context, query string, args ...any) Row {
+	return c.q.QueryRow(ctx, query, args...)
+}
+
+// ExecBatch pipelines all statements in a single round-trip.
+func (c poolConn) ExecBatch(ctx context.Context, queries ...Query) (err error) {
+	batch := &pgx.Batch{}
+	for _, q := range queries {
+		batch.Queue(q.SQL, q.Args...)
+	}
+
+	results := c.q.SendBatch(ctx, batch)
+	defer func() {
+		if closeErr := results.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	for range queries {
+		if _, err = results.Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type poolDB struct {
+	poolConn
+	pool *pgxpool.Pool
+}
+
+// NewPgxDB adapts a native pgx pool. Statements are prepared once per
+// connection and served from pgx's statement cache afterwards.
+func NewPgxDB(pool *pgxpool.Pool) DB {
+	return traced(&poolDB{
+		poolConn: poolConn{q: pool},
+		pool:     pool,
+	})
+}
+
+func (d *poolDB) Begin(ctx context.Context, opts TxOptions) (Tx, error) {
+	txOpts := pgx.TxOptions{}
+	switch opts.Isolation {
+	case sql.LevelReadCommitted:
+		txOpts.IsoLevel = pgx.ReadCommitted
+	case sql.LevelRepeatableRead, sql.LevelSnapshot:
+		txOpts.IsoLevel = pgx.RepeatableRead
+	case sql.LevelSerializable:
+		txOpts.IsoLevel = pgx.Serializable
+	}
+	if opts.ReadOnly {
+		txOpts.AccessMode = pgx.ReadOnly
+	}
+
+	tx, err := d.pool.BeginTx(ctx, txOpts)
+	if err != nil {
+		return nil, err
+	}
+	return &poolTx{poolConn: poolConn{q: tx}, tx: tx}, nil
+}
+
+func (d *poolDB) Conn(ctx context.Context) (Conn, error) {
+	conn, err := d.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &poolSession{poolConn: poolConn{q: conn}, conn: conn}, nil
+}
+
+func (d *poolDB) Ping(ctx context.Context) error {
+	return d.pool.Ping(ctx)
+}
+
+func (d *poolDB) Stats() sql.DBStats {
+	stat := d.pool.Stat()
+	return sql.DBStats{
+		MaxOpenConnections: int(stat.MaxConns()),
+		OpenConnections:    int(stat.TotalConns()),
+		InUse:              int(stat.AcquiredConns()),
+		Idle:               int(stat.IdleConns()),
+		WaitCount:          stat.EmptyAcquireCount(),
+		WaitDuration:       stat.AcquireDuration(),
+	}
+}
+
+func (d *poolDB) Close() error {
+	d.pool.Close()
+	return nil
+}
+
+type poolTx struct {
+	poolConn
+	tx pgx.Tx
+}
+
+func (t *poolTx) Commit(ctx context.Context) error {
+	return t.tx.Commit(ctx)
+}
+
+func (t *poolTx) Rollback(ctx context.Context) error {
+	return t.tx.Rollback(ctx)
+}
+
+type poolSession struct {
+	poolConn
+	conn *pgxpool.Conn
+}
+
+func (s *poolSession) Close() error {
+	s.conn.Release()
+	return nil
+}
@@ -0,0 +1,354 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"go.uber.org/zap"
+)
+
+// expectedBalancesQuery recomputes the balances of the users $3, or of every
+// user if $3 is NULL. Grants and deductions are in the history as operations
+// of the system account. $1 and $2 are the catalog, it prices the purchases
+// stored without a price.
+const expectedBalancesQuery = `
+	WITH catalog AS (
+		SELECT item, price
+		FROM unnest($1::text[], $2::int[]) AS c(item, price)
+	), received AS (
+		SELECT receiver_name AS username, sum(amount) AS total
+		FROM history
+		GROUP BY receiver_name
+	), sent AS (
+		SELECT sender_name AS username, sum(amount) AS total
+		FROM history
+		GROUP BY sender_name
+	), spent AS (
+		SELECT i.owner_id AS user_id, sum(COALESCE(i.price, c.price, 0)) AS total
+		FROM inventory i
+		LEFT JOIN catalog c ON c.item = i.item
+		GROUP BY i.owner_id
+	)
+	SELECT u.user_id, u.username, u.balance,
+		u.signup_balance + COALESCE(r.total, 0) - COALESCE(s.total, 0) - COALESCE(p.total, 0)
+	FROM users u
+	LEFT JOIN received r ON r.username = u.username
+	LEFT JOIN sent s ON s.username = u.username
+	LEFT JOIN spent p ON p.user_id = u.user_id
+	WHERE $3::int[] IS NULL OR u.user_id = ANY($3)
+`
+
+type ReconciliationRepository struct {
+	l  *zap.Logger
+	db DB
+}
+
+// Reconcile reads the balances and the ledger from one snapshot, so that
+// operations committed meanwhile don't show up as discrepancies.
+func (r ReconciliationRepository) Reconcile(ctx context.Context, prices map[string]int) (*entity.Reconciliation, error) {
+	var report entity.Reconciliation
+	opts := TxOptions{Isolation: sql.LevelRepeatableRead}
+	err := withTxOptions(ctx, r.l, r.db, opts, func(tx Tx) error {
+		var err error
+		report.Discrepancies, report.Users, err = r.discrepancies(ctx, tx, prices, nil)
+		if err != nil {
+			return err
+		}
+
+		report.Status = entity.ReconciliationClean
+		if len(report.Discrepancies) > 0 {
+			report.Status = entity.ReconciliationPending
+		}
+		err = tx.QueryRow(ctx, `
+		INSERT INTO reconciliations (users, status)
+		VALUES ($1, $2)
+		RETURNING id, created_at
+	`, report.Users, report.Status).Scan(&report.ID, &report.CreatedAt)
+		if err != nil {
+			r.l.Error("failed to insert reconciliation", zap.Error(err))
+			return err
+		}
+		if len(report.Discrepancies) == 0 {
+			return nil
+		}
+
+		var ids, balances, expected []int
+		var usernames []string
+		for _, d := range report.Discrepancies {
+			ids = append(ids, d.UserID)
+			usernames = append(usernames, d.Username)
+			balances = append(balances, d.Balance)
+			expected = append(expected, d.Expected)
+		}
+		_, err = tx.Exec(ctx, `
+		INSERT INTO reconciliation_discrepancies (reconciliation_id, user_id, username, balance, expected)
+		SELECT $1, *
+		FROM unnest($2::int[], $3::text[], $4::int[], $5::int[])
+	`, report.ID, ids, usernames, balances, expected)
+		if err != nil {
+			r.l.Error("failed to insert discrepancies", zap.Error(err))
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// discrepancies recomputes the balances of ids, or of every user for nil ids,
+// and returns the ones that differ along with the number of users checked.
+func (r ReconciliationRepository) discrepancies(ctx context.Context, q Querier, prices map[string]int, ids []int) ([]entity.Discrepancy, int, error) {
+	items := make([]string, 0, len(prices))
+	costs := make([]int, 0, len(prices))
+	for item, price := range prices {
+		items = append(items, item)
+		costs = append(costs, price)
+	}
+
+	rows, err := q.Query(ctx, expectedBalancesQuery, items, costs, ids)
+	if err != nil {
+		r.l.Error("failed to recompute balances", zap.Error(err))
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var res []entity.Discrepancy
+	checked := 0
+	for rows.Next() {
+		var d entity.Discrepancy
+		if err = rows.Scan(&d.UserID, &d.Username, &d.Balance, &d.Expected); err != nil {
+			r.l.Error("failed to scan balance", zap.Error(err))
+			return nil, 0, err
+		}
+		checked++
+		if d.Balance != d.Expected {
+			res = append(res, d)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		r.l.Error("failed to recompute balances", zap.Error(err))
+		return nil, 0, err
+	}
+	return res, checked, nil
+}
+
+func (r ReconciliationRepository) Get(ctx context.Context, id int) (*entity.Reconciliation, error) {
+	return r.get(ctx, r.db, id, false)
+}
+
+// get reads report id, with lock it is locked until the end of the
+// transaction q.
+func (r ReconciliationRepository) get(ctx context.Context, q Querier, id int, lock bool) (*entity.Reconciliation, error) {
+	query := `
+		SELECT id, created_at, users, status, COALESCE(approved_by, ''), approved_at
+		FROM reconciliations
+		WHERE id = $1
+	`
+	if lock {
+		query += "FOR UPDATE"
+	}
+
+	var report entity.Reconciliation
+	err := q.QueryRow(ctx, query, id).Scan(reconciliationFields(&report)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrorReportNotFound
+		}
+		r.l.Error("failed to find reconciliation", zap.Error(err))
+		return nil, err
+	}
+
+	rows, err := q.Query(ctx, `
+		SELECT user_id, username, balance, expected, corrected
+		FROM reconciliation_discrepancies
+		WHERE reconciliation_id = $1
+		ORDER BY user_id
+	`, id)
+	if err != nil {
+		r.l.Error("failed to read discrepancies", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d entity.Discrepancy
+		if err = rows.Scan(&d.UserID, &d.Username, &d.Balance, &d.Expected, &d.Corrected); err != nil {
+			r.l.Error("failed to scan discrepancy", zap.Error(err))
+			return nil, err
+		}
+		report.Discrepancies = append(report.Discrepancies, d)
+	}
+	if err = rows.Err(); err != nil {
+		r.l.Error("failed to read discrepancies", zap.Error(err))
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (r ReconciliationRepository) List(ctx context.Context, limit int) ([]entity.Reconciliation, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, created_at, users, status, COALESCE(approved_by, ''), approved_at
+		FROM reconciliations
+		ORDER BY id DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		r.l.Error("failed to list reconciliations", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := make([]entity.Reconciliation, 0)
+	for rows.Next() {
+		var report entity.Reconciliation
+		if err = rows.Scan(reconciliationFields(&report)...); err != nil {
+			r.l.Error("failed to scan reconciliation", zap.Error(err))
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	if err = rows.Err(); err != nil {
+		r.l.Error("failed to list reconciliations", zap.Error(err))
+		return nil, err
+	}
+	return reports, nil
+}
+
+// Apply locks the report and then the rows of its users in ascending user_id
+// order, like TransferMoney, and recomputes their balances under the lock. A
+// balance is never set below the coins held, so a correction that would
+// break users.held <= balance is skipped and logged instead.
+func (r ReconciliationRepository) Apply(ctx context.Context, id int, actor string, prices map[string]int) (*entity.Reconciliation, error) {
+	var report *entity.Reconciliation
+	err := retryOnConflict(ctx, r.l, func() error {
+		var err error
+		report, err = r.apply(ctx, id, actor, prices)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (r ReconciliationRepository) apply(ctx context.Context, id int, actor string, prices map[string]int) (*entity.Reconciliation, error) {
+	var report *entity.Reconciliation
+	err := withTx(ctx, r.l, r.db, func(tx Tx) error {
+		var err error
+		report, err = r.get(ctx, tx, id, true)
+		if err != nil {
+			return err
+		}
+		if report.Status != entity.ReconciliationPending {
+			return repository.ErrorReportNotPending
+		}
+
+		ids := make([]int, len(report.Discrepancies))
+		for i, d := range report.Discrepancies {
+			ids[i] = d.UserID
+		}
+		if _, err = lockBalances(ctx, tx, ids...); err != nil {
+			r.l.Error("failed to lock balances", zap.Error(err))
+			return err
+		}
+		current, _, err := r.discrepancies(ctx, tx, prices, ids)
+		if err != nil {
+			return err
+		}
+		standing := make(map[int]entity.Discrepancy, len(current))
+		for _, d := range current {
+			standing[d.UserID] = d
+		}
+
+		var corrected []int
+		for i, d := range report.Discrepancies {
+			now, ok := standing[d.UserID]
+			if !ok || now.Balance != d.Balance || now.Expected != d.Expected {
+				continue
+			}
+			n, err := tx.Exec(ctx, `
+			UPDATE users
+			SET balance = $2
+			WHERE user_id = $1 AND held <= $2
+		`, d.UserID, d.Expected)
+			if err != nil {
+				r.l.Error("failed to correct balance", zap.Error(err))
+				return err
+			}
+			if n == 0 {
+				r.l.Warn("balance not corrected, the expected balance doesn't cover the held coins",
+					zap.Int("reconciliation", id),
+					zap.String("username", d.Username),
+					zap.Int("expected", d.Expected),
+				)
+				continue
+			}
+			report.Discrepancies[i].Corrected = true
+			corrected = append(corrected, d.UserID)
+		}
+
+		_, err = tx.Exec(ctx, `
+		UPDATE reconciliation_discrepancies
+		SET corrected = true
+		WHERE reconciliation_id = $1 AND user_id = ANY($2)
+	`, id, corrected)
+		if err != nil {
+			r.l.Error("failed to mark discrepancies corrected", zap.Error(err))
+			return err
+		}
+
+		report.Status = entity.ReconciliationApplied
+		report.ApprovedBy = actor
+		err = tx.QueryRow(ctx, `
+		UPDATE reconciliations
+		SET status = $2, approved_by = $3, approved_at = now()
+		WHERE id = $1
+		RETURNING approved_at
+	`, id, report.Status, actor).Scan(&report.ApprovedAt)
+		if err != nil {
+			r.l.Error("failed to approve reconciliation", zap.Error(err))
+			return err
+		}
+
+		entries := make([]entity.AuditEntry, 0, len(corrected))
+		for _, d := range report.Discrepancies {
+			if d.Corrected {
+				entries = append(entries, newAuditEntry(actor, entity.AuditReconcile, d.Username, map[string]any{
+					"reconciliation": id,
+					"from":           d.Balance,
+					"to":             d.Expected,
+				}))
+			}
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		if _, err = appendAudit(ctx, tx, entries...); err != nil {
+			r.l.Error("failed to write audit log", zap.Error(err))
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func reconciliationFields(r *entity.Reconciliation) []any {
+	return []any{&r.ID, &r.CreatedAt, &r.Users, &r.Status, &r.ApprovedBy, &r.ApprovedAt}
+}
+
+func NewReconciliationRepository(
+	l *zap.Logger,
+	db DB,
+) repository.ReconciliationRepository {
+	return &ReconciliationRepository{
+		l:  l,
+		db: db,
+	}
+}
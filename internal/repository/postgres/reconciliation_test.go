@@ -0,0 +1,172 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// discrepancyOf returns the discrepancy of user in report, or nil.
+func discrepancyOf(report *entity.Reconciliation, userID int) *entity.Discrepancy {
+	for i := range report.Discrepancies {
+		if report.Discrepancies[i].UserID == userID {
+			return &report.Discrepancies[i]
+		}
+	}
+	return nil
+}
+
+func TestReconciliation(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+	prices := map[string]int{"cup": 20, "pen": 10}
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			users := NewUserRepository(logger, conn)
+			history := NewHistoryRepository(logger, conn)
+			inventory := NewInventoryRepository(logger, conn)
+			reconciliations := NewReconciliationRepository(logger, conn)
+
+			// the ledger of honest: 100 at signup, a free welcome item, 30
+			// received, a cup bought for 20
+			honest, err := users.Signup(ctx, &entity.User{Username: "honest_" + name, Password: "pass", Balance: 100}, []string{"pen"})
+			require.NoError(t, err)
+			_, err = history.InsertOperation(ctx, entity.Operation{FromUser: "someone_" + name, ToUser: honest.Username, Amount: 30})
+			require.NoError(t, err)
+			_, err = inventory.InsertItem(ctx, honest.ID, "cup", 20)
+			require.NoError(t, err)
+			_, err = conn.Exec(ctx, `UPDATE users SET balance = 110 WHERE user_id = $1`, honest.ID)
+			require.NoError(t, err)
+
+			// drifted bought a pen before prices were kept and was charged
+			// twice
+			drifted, err := users.InsertUser(ctx, &entity.User{Username: "drifted_" + name, Password: "pass", Balance: 50})
+			require.NoError(t, err)
+			_, err = conn.Exec(ctx, `INSERT INTO inventory (owner_id, item) VALUES ($1, 'pen')`, drifted.ID)
+			require.NoError(t, err)
+			_, err = conn.Exec(ctx, `UPDATE users SET balance = 30 WHERE user_id = $1`, drifted.ID)
+			require.NoError(t, err)
+
+			report, err := reconciliations.Reconcile(ctx, prices)
+			require.NoError(t, err)
+			assert.Equal(t, entity.ReconciliationPending, report.Status)
+			assert.Nil(t, discrepancyOf(report, honest.ID))
+			d := discrepancyOf(report, drifted.ID)
+			require.NotNil(t, d)
+			assert.Equal(t, 30, d.Balance)
+			assert.Equal(t, 40, d.Expected)
+
+			stored, err := reconciliations.Get(ctx, report.ID)
+			require.NoError(t, err)
+			assert.Equal(t, report.Users, stored.Users)
+			assert.Len(t, stored.Discrepancies, len(report.Discrepancies))
+
+			applied, err := reconciliations.Apply(ctx, report.ID, "root", prices)
+			require.NoError(t, err)
+			assert.Equal(t, entity.ReconciliationApplied, applied.Status)
+			assert.Equal(t, "root", applied.ApprovedBy)
+			assert.NotNil(t, applied.ApprovedAt)
+			assert.True(t, discrepancyOf(applied, drifted.ID).Corrected)
+
+			found, err := users.FindUserByID(ctx, drifted.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 40, found.Balance)
+
+			_, err = reconciliations.Apply(ctx, report.ID, "root", prices)
+			assert.ErrorIs(t, err, repository.ErrorReportNotPending)
+			_, err = reconciliations.Get(ctx, -1)
+			assert.ErrorIs(t, err, repository.ErrorReportNotFound)
+
+			reports, err := reconciliations.List(ctx, 1)
+			require.NoError(t, err)
+			require.Len(t, reports, 1)
+			assert.Equal(t, report.ID, reports[0].ID)
+		})
+	}
+}
+
+func TestReconciliation_SkipsChangedBalances(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			users := NewUserRepository(logger, conn)
+			reconciliations := NewReconciliationRepository(logger, conn)
+
+			user, err := users.InsertUser(ctx, &entity.User{Username: "moving_" + name, Password: "pass", Balance: 100})
+			require.NoError(t, err)
+			_, err = conn.Exec(ctx, `UPDATE users SET balance = 90 WHERE user_id = $1`, user.ID)
+			require.NoError(t, err)
+
+			report, err := reconciliations.Reconcile(ctx, nil)
+			require.NoError(t, err)
+			require.NotNil(t, discrepancyOf(report, user.ID))
+
+			// the balance moved after the report was made
+			_, err = conn.Exec(ctx, `UPDATE users SET balance = 80 WHERE user_id = $1`, user.ID)
+			require.NoError(t, err)
+
+			applied, err := reconciliations.Apply(ctx, report.ID, "root", nil)
+			require.NoError(t, err)
+			assert.False(t, discrepancyOf(applied, user.ID).Corrected)
+
+			found, err := users.FindUserByID(ctx, user.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 80, found.Balance)
+		})
+	}
+}
+
+func TestReconciliation_SkipsHeldCoins(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			users := NewUserRepository(logger, conn)
+			history := NewHistoryRepository(logger, conn)
+			reconciliations := NewReconciliationRepository(logger, conn)
+
+			// sponsor holds 60 of 100 coins, but the ledger only accounts
+			// for 40 of them
+			sponsor, err := users.InsertUser(ctx, &entity.User{Username: "sponsor_" + name, Password: "pass", Balance: 100})
+			require.NoError(t, err)
+			_, err = users.Hold(ctx, sponsor.ID, 60, "bounty")
+			require.NoError(t, err)
+			_, err = history.InsertOperation(ctx, entity.Operation{FromUser: sponsor.Username, ToUser: "nobody_" + name, Amount: 60})
+			require.NoError(t, err)
+
+			// drifted is corrected in the same run
+			drifted, err := users.InsertUser(ctx, &entity.User{Username: "drifted_held_" + name, Password: "pass", Balance: 100})
+			require.NoError(t, err)
+			_, err = conn.Exec(ctx, `UPDATE users SET balance = 90 WHERE user_id = $1`, drifted.ID)
+			require.NoError(t, err)
+
+			report, err := reconciliations.Reconcile(ctx, nil)
+			require.NoError(t, err)
+			d := discrepancyOf(report, sponsor.ID)
+			require.NotNil(t, d)
+			assert.Equal(t, 40, d.Expected)
+
+			applied, err := reconciliations.Apply(ctx, report.ID, "root", nil)
+			require.NoError(t, err)
+			assert.Equal(t, entity.ReconciliationApplied, applied.Status)
+			assert.False(t, discrepancyOf(applied, sponsor.ID).Corrected)
+			assert.True(t, discrepancyOf(applied, drifted.ID).Corrected)
+
+			found, err := users.FindUserByID(ctx, sponsor.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 100, found.Balance)
+			found, err = users.FindUserByID(ctx, drifted.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 100, found.Balance)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	codeSerializationFailure = "40001"
+	codeDeadlockDetected     = "40P01"
+
+	maxTxAttempts = 5
+	baseTxBackoff = 5 * time.Millisecond
+)
+
+// isRetryable reports whether err aborted the transaction because of a
+// concurrent conflict, in which case the whole transaction can be run again.
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == codeSerializationFailure || pgErr.Code == codeDeadlockDetected
+}
+
+// retryOnConflict runs fn until it succeeds, fails with a non-retryable error,
+// maxTxAttempts is reached or ctx is done. Attempts are separated by
+// exponential backoff with jitter so that the conflicting transactions don't
+// collide again.
+func retryOnConflict(ctx context.Context, l *zap.Logger, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < maxTxAttempts; attempt++ {
+		err = fn()
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+
+		backoff := baseTxBackoff << attempt
+		backoff += rand.N(backoff)
+		l.Warn("transaction conflict, retrying",
+			zap.Int("attempt", attempt+1),
+			zap.Duration("backoff", backoff),
+			zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+	return err
+}
@@ -0,0 +1,229 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+)
+
+// scheduledTransfersQuery reads the transfers with the usernames of both
+// parties.
+const scheduledTransfersQuery = `
+	SELECT t.id, t.sender_id, s.username, t.receiver_id, r.username, t.amount, COALESCE(t.schedule, ''),
+		t.status, t.next_run_at, t.last_run_at, t.failures, COALESCE(t.last_error, ''), t.created_at
+	FROM scheduled_transfers t
+	JOIN users s ON s.user_id = t.sender_id
+	JOIN users r ON r.user_id = t.receiver_id
+`
+
+type ScheduledTransferRepository struct {
+	l  *zap.Logger
+	db DB
+}
+
+func (s ScheduledTransferRepository) Create(ctx context.Context, transfer entity.ScheduledTransfer) (*entity.ScheduledTransfer, error) {
+	transfer.Status = entity.ScheduledTransferActive
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO scheduled_transfers (sender_id, receiver_id, amount, schedule, next_run_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+		RETURNING id, created_at
+	`, transfer.SenderID, transfer.ReceiverID, transfer.Amount, transfer.Schedule, transfer.NextRunAt).
+		Scan(&transfer.ID, &transfer.CreatedAt)
+	if err != nil {
+		s.l.Error("failed to insert scheduled transfer", zap.Error(err))
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+func (s ScheduledTransferRepository) List(ctx context.Context, senderID int, limit int) ([]entity.ScheduledTransfer, error) {
+	rows, err := s.db.Query(ctx, scheduledTransfersQuery+`
+		WHERE t.sender_id = $1
+		ORDER BY t.id DESC
+		LIMIT $2
+	`, senderID, limit)
+	if err != nil {
+		s.l.Error("failed to list scheduled transfers", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := make([]entity.ScheduledTransfer, 0)
+	for rows.Next() {
+		var transfer entity.ScheduledTransfer
+		if err = rows.Scan(scheduledTransferFields(&transfer)...); err != nil {
+			s.l.Error("failed to scan scheduled transfer", zap.Error(err))
+			return nil, err
+		}
+		transfers = append(transfers, transfer)
+	}
+	if err = rows.Err(); err != nil {
+		s.l.Error("failed to list scheduled transfers", zap.Error(err))
+		return nil, err
+	}
+	return transfers, nil
+}
+
+func (s ScheduledTransferRepository) Cancel(ctx context.Context, id int64, senderID int) (*entity.ScheduledTransfer, error) {
+	var transfer *entity.ScheduledTransfer
+	err := withTx(ctx, s.l, s.db, func(tx Tx) error {
+		var err error
+		transfer, err = s.get(ctx, tx, id, senderID)
+		if err != nil {
+			return err
+		}
+		if transfer.Status != entity.ScheduledTransferActive {
+			return repository.ErrorTransferNotActive
+		}
+
+		transfer.Status = entity.ScheduledTransferCancelled
+		transfer.NextRunAt = nil
+		_, err = tx.Exec(ctx, `
+		UPDATE scheduled_transfers
+		SET status = $2, next_run_at = NULL
+		WHERE id = $1
+	`, id, transfer.Status)
+		if err != nil {
+			s.l.Error("failed to cancel scheduled transfer", zap.Error(err))
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// get reads and locks transfer id of senderID until the end of the
+// transaction q.
+func (s ScheduledTransferRepository) get(ctx context.Context, q Querier, id int64, senderID int) (*entity.ScheduledTransfer, error) {
+	var transfer entity.ScheduledTransfer
+	err := q.QueryRow(ctx, scheduledTransfersQuery+`
+		WHERE t.id = $1 AND t.sender_id = $2
+		FOR UPDATE OF t
+	`, id, senderID).Scan(scheduledTransferFields(&transfer)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrorTransferNotFound
+		}
+		s.l.Error("failed to find scheduled transfer", zap.Error(err))
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// RunDue claims the transfer with SKIP LOCKED, so replicas polling at the
+// same time run different transfers, and holds it until the coins have moved
+// and the next run is stored.
+func (s ScheduledTransferRepository) RunDue(
+	ctx context.Context,
+	limits func(senderID int) ([]entity.SpendingLimit, error),
+	reschedule func(transfer *entity.ScheduledTransfer, failure error),
+) (*entity.ScheduledTransfer, error) {
+	var transfer *entity.ScheduledTransfer
+	err := retryOnConflict(ctx, s.l, func() error {
+		var err error
+		transfer, err = s.runDue(ctx, limits, reschedule)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+func (s ScheduledTransferRepository) runDue(
+	ctx context.Context,
+	limits func(senderID int) ([]entity.SpendingLimit, error),
+	reschedule func(transfer *entity.ScheduledTransfer, failure error),
+) (*entity.ScheduledTransfer, error) {
+	var transfer *entity.ScheduledTransfer
+	err := withTx(ctx, s.l, s.db, func(tx Tx) error {
+		var t entity.ScheduledTransfer
+		var senderActive, receiverActive bool
+		err := tx.QueryRow(ctx, scheduledTransfersQuery+`
+		WHERE t.status = 'active' AND t.next_run_at <= now()
+		ORDER BY t.next_run_at
+		LIMIT 1
+		FOR UPDATE OF t SKIP LOCKED
+	`).Scan(scheduledTransferFields(&t)...)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				transfer = nil
+				return nil
+			}
+			s.l.Error("failed to claim scheduled transfer", zap.Error(err))
+			return err
+		}
+
+		err = tx.QueryRow(ctx, `
+		SELECT
+			bool_or(user_id = $1 AND deleted_at IS NULL AND disabled_at IS NULL),
+			bool_or(user_id = $2 AND deleted_at IS NULL)
+		FROM users
+		WHERE user_id IN ($1, $2)
+	`, t.SenderID, t.ReceiverID).Scan(&senderActive, &receiverActive)
+		if err != nil {
+			s.l.Error("failed to check transfer parties", zap.Error(err))
+			return err
+		}
+
+		var failure error
+		switch {
+		case !senderActive:
+			failure = fmt.Errorf("%w: %s", repository.ErrorUserNotFound, t.Sender)
+		case !receiverActive:
+			failure = fmt.Errorf("%w: %s", repository.ErrorUserNotFound, t.Receiver)
+		default:
+			var senderLimits []entity.SpendingLimit
+			senderLimits, err = limits(t.SenderID)
+			if err != nil {
+				return err
+			}
+			failure = moveCoins(ctx, tx, t.SenderID, t.ReceiverID, t.Amount, senderLimits, map[string]any{"transfer": t.ID})
+			if failure != nil && !errors.Is(failure, repository.ErrorInsufficientBalance) &&
+				!errors.Is(failure, repository.ErrorLimitExceeded) {
+				s.l.Error("failed to move coins", zap.Error(failure))
+				return failure
+			}
+		}
+
+		reschedule(&t, failure)
+		err = tx.QueryRow(ctx, `
+		UPDATE scheduled_transfers
+		SET status = $2, next_run_at = $3, failures = $4, last_error = NULLIF($5, ''), last_run_at = now()
+		WHERE id = $1
+		RETURNING last_run_at
+	`, t.ID, t.Status, t.NextRunAt, t.Failures, t.LastError).Scan(&t.LastRunAt)
+		if err != nil {
+			s.l.Error("failed to reschedule transfer", zap.Error(err))
+			return err
+		}
+		transfer = &t
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+func scheduledTransferFields(t *entity.ScheduledTransfer) []any {
+	return []any{&t.ID, &t.SenderID, &t.Sender, &t.ReceiverID, &t.Receiver, &t.Amount, &t.Schedule,
+		&t.Status, &t.NextRunAt, &t.LastRunAt, &t.Failures, &t.LastError, &t.CreatedAt}
+}
+
+func NewScheduledTransferRepository(
+	l *zap.Logger,
+	db DB,
+) repository.ScheduledTransferRepository {
+	return &ScheduledTransferRepository{
+		l:  l,
+		db: db,
+	}
+}
@@ -0,0 +1,141 @@
+package postgres
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// runAllDue runs every due transfer with reschedule and returns the ones of
+// sender. The senders are unlimited.
+func runAllDue(t *testing.T, transfers repository.ScheduledTransferRepository, sender int, reschedule func(*entity.ScheduledTransfer, error)) []entity.ScheduledTransfer {
+	t.Helper()
+
+	var ran []entity.ScheduledTransfer
+	for {
+		transfer, err := transfers.RunDue(context.Background(), func(int) ([]entity.SpendingLimit, error) {
+			return nil, nil
+		}, reschedule)
+		require.NoError(t, err)
+		if transfer == nil {
+			return ran
+		}
+		if transfer.SenderID == sender {
+			ran = append(ran, *transfer)
+		}
+	}
+}
+
+func TestScheduledTransfers(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			users := NewUserRepository(logger, conn)
+			transfers := NewScheduledTransferRepository(logger, conn)
+			accounts := NewAccountRepository(logger, conn)
+
+			alice, err := users.InsertUser(ctx, &entity.User{Username: "planner_" + name, Password: "pass", Balance: 100})
+			require.NoError(t, err)
+			bob, err := users.InsertUser(ctx, &entity.User{Username: "planned_" + name, Password: "pass", Balance: 0})
+			require.NoError(t, err)
+
+			due := time.Now().Add(-time.Minute)
+			later := time.Now().Add(time.Hour)
+			small, err := transfers.Create(ctx, entity.ScheduledTransfer{SenderID: alice.ID, ReceiverID: bob.ID, Amount: 60, NextRunAt: &due})
+			require.NoError(t, err)
+			dueNext := due.Add(time.Second)
+			big, err := transfers.Create(ctx, entity.ScheduledTransfer{SenderID: alice.ID, ReceiverID: bob.ID, Amount: 60, Schedule: "@daily", NextRunAt: &dueNext})
+			require.NoError(t, err)
+			_, err = transfers.Create(ctx, entity.ScheduledTransfer{SenderID: alice.ID, ReceiverID: bob.ID, Amount: 1, NextRunAt: &later})
+			require.NoError(t, err)
+
+			failures := map[int64]error{}
+			ran := runAllDue(t, transfers, alice.ID, func(transfer *entity.ScheduledTransfer, failure error) {
+				failures[transfer.ID] = failure
+				if failure != nil {
+					transfer.Failures++
+					transfer.LastError = failure.Error()
+					next := time.Now().Add(time.Hour)
+					transfer.NextRunAt = &next
+					return
+				}
+				transfer.Status = entity.ScheduledTransferCompleted
+				transfer.NextRunAt = nil
+			})
+
+			// the first transfer due took 60 of the 100 coins, the second
+			// one found too few left
+			require.Len(t, ran, 2)
+			assert.NoError(t, failures[small.ID])
+			assert.ErrorIs(t, failures[big.ID], repository.ErrorInsufficientBalance)
+
+			info, err := accounts.GetAccountInfo(ctx, bob.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 60, info.Coins)
+			require.Len(t, info.Received, 1)
+			assert.Equal(t, alice.Username, info.Received[0].FromUser)
+
+			list, err := transfers.List(ctx, alice.ID, 10)
+			require.NoError(t, err)
+			require.Len(t, list, 3)
+			assert.Equal(t, entity.ScheduledTransferActive, list[1].Status)
+			assert.Equal(t, 1, list[1].Failures)
+			assert.Equal(t, "insufficient balance", list[1].LastError)
+			assert.NotNil(t, list[1].LastRunAt)
+			assert.Equal(t, entity.ScheduledTransferCompleted, list[2].Status)
+			assert.Nil(t, list[2].NextRunAt)
+
+			cancelled, err := transfers.Cancel(ctx, big.ID, alice.ID)
+			require.NoError(t, err)
+			assert.Equal(t, entity.ScheduledTransferCancelled, cancelled.Status)
+			_, err = transfers.Cancel(ctx, big.ID, alice.ID)
+			assert.ErrorIs(t, err, repository.ErrorTransferNotActive)
+			_, err = transfers.Cancel(ctx, big.ID, bob.ID)
+			assert.ErrorIs(t, err, repository.ErrorTransferNotFound)
+		})
+	}
+}
+
+func TestScheduledTransfers_DeletedReceiver(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	for name, conn := range drivers() {
+		t.Run(name, func(t *testing.T) {
+			users := NewUserRepository(logger, conn)
+			transfers := NewScheduledTransferRepository(logger, conn)
+
+			alice, err := users.InsertUser(ctx, &entity.User{Username: "loyal_" + name, Password: "pass", Balance: 100})
+			require.NoError(t, err)
+			bob, err := users.InsertUser(ctx, &entity.User{Username: "leaver_" + name, Password: "pass", Balance: 0})
+			require.NoError(t, err)
+			_, err = users.DeleteUser(ctx, bob.Username)
+			require.NoError(t, err)
+
+			due := time.Now().Add(-time.Minute)
+			_, err = transfers.Create(ctx, entity.ScheduledTransfer{SenderID: alice.ID, ReceiverID: bob.ID, Amount: 10, NextRunAt: &due})
+			require.NoError(t, err)
+
+			var failure error
+			ran := runAllDue(t, transfers, alice.ID, func(transfer *entity.ScheduledTransfer, runFailure error) {
+				failure = runFailure
+				transfer.Status = entity.ScheduledTransferFailed
+				transfer.NextRunAt = nil
+			})
+			require.Len(t, ran, 1)
+			assert.ErrorIs(t, failure, repository.ErrorUserNotFound)
+
+			user, err := users.FindUserByID(ctx, alice.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 100, user.Balance)
+		})
+	}
+}
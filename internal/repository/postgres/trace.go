@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"AvitoTech/internal/tracing"
+	"context"
+	"database/sql"
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+)
+
+// tracedConn opens a client span around every statement, so lock waits and
+// slow queries show up as their own step of a request trace.
+type tracedConn struct {
+	q Querier
+}
+
+func (c tracedConn) Exec(ctx context.Context, query string, args ...any) (_ int64, err error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer tracing.End(span, &err)
+	return c.q.Exec(ctx, query, args...)
+}
+
+func (c tracedConn) Query(ctx context.Context, query string, args ...any) (_ Rows, err error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer tracing.End(span, &err)
+	return c.q.Query(ctx, query, args...)
+}
+
+// QueryRow ends its span on Scan, when the statement has actually run.
+func (c tracedConn) QueryRow(ctx context.Context, query string, args ...any) Row {
+	ctx, span := startQuerySpan(ctx, query)
+	return tracedRow{row: c.q.QueryRow(ctx, query, args...), span: span}
+}
+
+func (c tracedConn) ExecBatch(ctx context.Context, queries ...Query) (err error) {
+	ctx, span := tracing.Start(ctx, "batch",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			attribute.Int("db.batch.size", len(queries)),
+		),
+	)
+	defer tracing.End(span, &err)
+	return c.q.ExecBatch(ctx, queries...)
+}
+
+type tracedRow struct {
+	row  Row
+	span trace.Span
+}
+
+func (r tracedRow) Scan(dest ...any) error {
+	err := r.row.Scan(dest...)
+	if errors.Is(err, sql.ErrNoRows) {
+		// an empty result is an answer, not a failure of the statement
+		r.span.End()
+		return err
+	}
+	tracing.End(r.span, &err)
+	return err
+}
+
+type tracedDB struct {
+	tracedConn
+	db DB
+}
+
+// traced wraps db so that every statement and transaction is traced.
+func traced(db DB) DB {
+	return &tracedDB{tracedConn: tracedConn{q: db}, db: db}
+}
+
+func (d *tracedDB) Begin(ctx context.Context, opts TxOptions) (_ Tx, err error) {
+	ctx, span := tracing.Start(ctx, "transaction",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL),
+	)
+	tx, err := d.db.Begin(ctx, opts)
+	if err != nil {
+		tracing.End(span, &err)
+		return nil, err
+	}
+	return &tracedTx{tracedConn: tracedConn{q: tx}, tx: tx, span: span}, nil
+}
+
+func (d *tracedDB) Conn(ctx context.Context) (Conn, error) {
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedSession{tracedConn: tracedConn{q: conn}, conn: conn}, nil
+}
+
+func (d *tracedDB) Ping(ctx context.Context) error {
+	return d.db.Ping(ctx)
+}
+
+func (d *tracedDB) Stats() sql.DBStats {
+	return d.db.Stats()
+}
+
+func (d *tracedDB) Close() error {
+	return d.db.Close()
+}
+
+type tracedSession struct {
+	tracedConn
+	conn Conn
+}
+
+func (s *tracedSession) Close() error {
+	return s.conn.Close()
+}
+
+// tracedTx keeps the transaction span open until commit or rollback, so the
+// statements run inside it are nested under it.
+type tracedTx struct {
+	tracedConn
+	tx   Tx
+	span trace.Span
+}
+
+func (t *tracedTx) Commit(ctx context.Context) (err error) {
+	defer tracing.End(t.span, &err)
+	return t.tx.Commit(ctx)
+}
+
+func (t *tracedTx) Rollback(ctx context.Context) error {
+	t.span.SetAttributes(attribute.Bool("db.rollback", true))
+	t.span.End()
+	return t.tx.Rollback(ctx)
+}
+
+func startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	query = strings.TrimSpace(query)
+	return tracing.Start(ctx, operation(query),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+// operation names a span after the leading keyword of the statement.
+func operation(query string) string {
+	if i := strings.IndexFunc(query, func(r rune) bool { return r == ' ' || r == '\n' || r == '\t' }); i > 0 {
+		return strings.ToUpper(query[:i])
+	}
+	return strings.ToUpper(query)
+}
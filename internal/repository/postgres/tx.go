@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"context"
+	"go.uber.org/zap"
+)
+
+// withTx runs fn inside a transaction. The transaction is committed when fn
+// returns nil and rolled back on every other path, including a panic in fn,
+// so neither the connection nor the row locks taken by fn can leak.
+func withTx(ctx context.Context, l *zap.Logger, db DB, fn func(tx Tx) error) error {
+	return withTxOptions(ctx, l, db, TxOptions{}, fn)
+}
+
+// withTxOptions is withTx with an explicit isolation level and access mode.
+func withTxOptions(ctx context.Context, l *zap.Logger, db DB, opts TxOptions, fn func(tx Tx) error) (err error) {
+	tx, err := db.Begin(ctx, opts)
+	if err != nil {
+		l.Error("Failed to begin transaction", zap.Error(err))
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		// the request context may already be cancelled, the rollback must
+		// reach the server regardless
+		if rbErr := tx.Rollback(context.WithoutCancel(ctx)); rbErr != nil {
+			l.Error("Failed to rollback transaction", zap.Error(rbErr))
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	committed = true
+	if err = tx.Commit(ctx); err != nil {
+		l.Error("Failed to commit transaction", zap.Error(err))
+		return err
+	}
+	return nil
+}
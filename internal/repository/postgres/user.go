@@ -3,230 +3,400 @@ package postgres
 import (
 	"AvitoTech/internal/entity"
 	"AvitoTech/internal/repository"
+	"context"
 	"database/sql"
 	"errors"
-	"fmt"
 	"go.uber.org/zap"
+	"strings"
 )
 
 type UserRepository struct {
 	l  *zap.Logger
-	db *sql.DB
+	db DB
 }
 
-func (u UserRepository) InsertUser(user *entity.User) (*entity.User, error) {
-	q, err := u.db.Prepare(`
-	INSERT INTO users (username, password, balance)
-	VALUES ($1, $2, $3)
-	RETURNING user_id, username, password, balance
-	`)
+// userColumns are the columns read into an entity.User by userFields.
+const userColumns = "user_id, username, password, balance, token_version, role, disabled_at, deleted_at"
+
+func userFields(u *entity.User) []any {
+	return []any{&u.ID, &u.Username, &u.Password, &u.Balance, &u.TokenVersion, &u.Role, &u.DisabledAt, &u.DeletedAt}
+}
+
+func (u UserRepository) InsertUser(ctx context.Context, user *entity.User) (*entity.User, error) {
+	var resUser entity.User
+	err := u.db.QueryRow(ctx, `
+	INSERT INTO users (username, password, balance, signup_balance)
+	VALUES ($1, $2, $3, $3)
+	RETURNING `+userColumns+`
+	`, user.Username, user.Password, user.Balance).Scan(userFields(&resUser)...)
 	if err != nil {
 		u.l.Error("Failed to insert user", zap.Error(err))
 		return nil, err
 	}
-	defer func(q *sql.Stmt) {
-		err = q.Close()
+
+	return &resUser, nil
+}
+
+func (u UserRepository) Signup(ctx context.Context, user *entity.User, items []string) (*entity.User, error) {
+	var resUser entity.User
+	err := withTx(ctx, u.l, u.db, func(tx Tx) error {
+		err := tx.QueryRow(ctx, `
+		INSERT INTO users (username, password, balance, signup_balance)
+		VALUES ($1, $2, $3, $3)
+		RETURNING `+userColumns+`
+	`, user.Username, user.Password, user.Balance).Scan(userFields(&resUser)...)
 		if err != nil {
-			u.l.Error("Failed to close DB", zap.Error(err))
+			u.l.Error("Failed to insert user", zap.Error(err))
+			return err
+		}
+		if len(items) == 0 {
+			return nil
 		}
-	}(q)
-
-	res := q.QueryRow(user.Username, user.Password, user.Balance)
 
-	if res.Err() != nil {
-		u.l.Error("Failed to insert user", zap.Error(err))
+		_, err = tx.Exec(ctx, `
+		INSERT INTO inventory (owner_id, item, price)
+		SELECT $1, item, 0
+		FROM unnest($2::text[]) AS item
+	`, resUser.ID, items)
+		if err != nil {
+			u.l.Error("Failed to insert welcome items", zap.Error(err))
+			return err
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	return &resUser, nil
+}
+
+func (u UserRepository) FindUserByUsername(ctx context.Context, username string) (*entity.User, error) {
 	var resUser entity.User
-	err = res.Scan(&resUser.ID, &resUser.Username, &resUser.Password, &resUser.Balance)
+	err := u.db.QueryRow(ctx, `
+	SELECT `+userColumns+`
+	FROM users
+	WHERE username = $1
+`, username).Scan(userFields(&resUser)...)
 	if err != nil {
-		u.l.Error("Failed to scan inserted user", zap.Error(err))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrorUserNotFound
+		}
+		u.l.Error("Failed to find user by username", zap.String("username", username), zap.Error(err))
 		return nil, err
 	}
 
 	return &resUser, nil
 }
 
-func (u UserRepository) FindUserByUsername(username string) (*entity.User, error) {
-	q, err := u.db.Prepare(`
-	SELECT user_id, username, password, balance
+func (u UserRepository) FindUserByID(ctx context.Context, id int) (*entity.User, error) {
+	var resUser entity.User
+	err := u.db.QueryRow(ctx, `
+	SELECT `+userColumns+`
 	FROM users
-	WHERE username = $1
-`)
+	WHERE user_id = $1
+`, id).Scan(userFields(&resUser)...)
 	if err != nil {
-		u.l.Error("Failed to prepare query to find user by username", zap.String("username", username))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrorUserNotFound
+		}
+		u.l.Error("Failed to find user by id", zap.Int("user id", id), zap.Error(err))
 		return nil, err
 	}
-	defer func(q *sql.Stmt) {
-		err = q.Close()
-		if err != nil {
-			u.l.Error("Failed to close DB", zap.Error(err))
-		}
-	}(q)
 
-	res := q.QueryRow(username)
-	if res.Err() != nil {
-		u.l.Error("Failed to find user by username", zap.String("username", username))
-		return nil, err
+	return &resUser, nil
+}
+
+func (u UserRepository) SetPassword(ctx context.Context, id int, hash string, revokeSessions bool) (int, error) {
+	var version int
+	err := u.db.QueryRow(ctx, `
+	UPDATE users
+	SET password = $2,
+		token_version = token_version + CASE WHEN $3 THEN 1 ELSE 0 END
+	WHERE user_id = $1
+	RETURNING token_version
+`, id, hash, revokeSessions).Scan(&version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, repository.ErrorUserNotFound
+		}
+		u.l.Error("Failed to set password", zap.Int("user id", id), zap.Error(err))
+		return 0, err
 	}
 
+	return version, nil
+}
+
+func (u UserRepository) SetRole(ctx context.Context, username string, role entity.Role) (*entity.User, error) {
 	var resUser entity.User
-	err = res.Scan(&resUser.ID, &resUser.Username, &resUser.Password, &resUser.Balance)
+	err := u.db.QueryRow(ctx, `
+	UPDATE users
+	SET role = $2,
+		token_version = token_version + CASE WHEN role <> $2 THEN 1 ELSE 0 END
+	WHERE username = $1
+	RETURNING `+userColumns+`
+`, username, string(role)).Scan(userFields(&resUser)...)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, repository.ErrorUserNotFound
 		}
-		u.l.Error("Failed to scan found user by username", zap.String("username", username))
+		u.l.Error("Failed to set role", zap.String("username", username), zap.Error(err))
 		return nil, err
 	}
 
 	return &resUser, nil
 }
 
-func (u UserRepository) FindUserByID(id int) (*entity.User, error) {
-	q, err := u.db.Prepare(`
-	SELECT user_id, username, password, balance
+func (u UserRepository) SearchUsers(ctx context.Context, filter entity.UserFilter) ([]entity.User, int, error) {
+	rows, err := u.db.Query(ctx, `
+	SELECT `+userColumns+`, count(*) OVER ()
 	FROM users
-	WHERE user_id = $1
-`)
+	WHERE username LIKE $1 || '%' AND ($2 OR deleted_at IS NULL)
+	ORDER BY username, user_id
+	LIMIT $3 OFFSET $4
+`, escapeLike(filter.Prefix), filter.IncludeDeleted, filter.Limit, filter.Offset)
 	if err != nil {
-		u.l.Error("Failed to find user by username", zap.Int("user id", id))
-		return nil, err
+		u.l.Error("Failed to search users", zap.Error(err))
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var (
+		users []entity.User
+		total int
+	)
+	for rows.Next() {
+		var user entity.User
+		if err = rows.Scan(append(userFields(&user), &total)...); err != nil {
+			u.l.Error("Failed to scan user", zap.Error(err))
+			return nil, 0, err
+		}
+		users = append(users, user)
 	}
-	defer func(q *sql.Stmt) {
-		err = q.Close()
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	// an offset past the end returns no rows to count with
+	if len(users) == 0 && filter.Offset > 0 {
+		err = u.db.QueryRow(ctx, `
+		SELECT count(*)
+		FROM users
+		WHERE username LIKE $1 || '%' AND ($2 OR deleted_at IS NULL)
+	`, escapeLike(filter.Prefix), filter.IncludeDeleted).Scan(&total)
 		if err != nil {
-			u.l.Error("Failed to close DB", zap.Error(err))
+			u.l.Error("Failed to count users", zap.Error(err))
+			return nil, 0, err
 		}
-	}(q)
+	}
+
+	return users, total, nil
+}
+
+// escapeLike quotes the wildcards of s for a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
 
-	res := q.QueryRow(id)
-	if res.Err() != nil {
-		u.l.Error("Failed to find user by username", zap.Int("user id", id))
+func (u UserRepository) SetDisabled(ctx context.Context, username string, disabled bool) (*entity.User, error) {
+	var resUser entity.User
+	err := u.db.QueryRow(ctx, `
+	UPDATE users
+	SET disabled_at = CASE WHEN $2 THEN COALESCE(disabled_at, now()) END
+	WHERE username = $1 AND deleted_at IS NULL
+	RETURNING `+userColumns+`
+`, username, disabled).Scan(userFields(&resUser)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrorUserNotFound
+		}
+		u.l.Error("Failed to set disabled", zap.String("username", username), zap.Error(err))
 		return nil, err
 	}
 
+	return &resUser, nil
+}
+
+func (u UserRepository) DeleteUser(ctx context.Context, username string) (*entity.User, error) {
 	var resUser entity.User
-	err = res.Scan(&resUser.ID, &resUser.Username, &resUser.Password, &resUser.Balance)
+	err := u.db.QueryRow(ctx, `
+	UPDATE users
+	SET deleted_at = now()
+	WHERE username = $1 AND deleted_at IS NULL
+	RETURNING `+userColumns+`
+`, username).Scan(userFields(&resUser)...)
 	if err != nil {
-		u.l.Error("Failed to scan found user by username", zap.Int("user id", id))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrorUserNotFound
+		}
+		u.l.Error("Failed to delete user", zap.String("username", username), zap.Error(err))
 		return nil, err
 	}
 
 	return &resUser, nil
 }
 
-func (u UserRepository) TransferMoney(userFrom int, userTo int, amount int) error {
-	tx, err := u.db.Begin()
-	if err != nil {
-		u.l.Error("Failed to begin transaction", zap.Error(err))
+// TransferMoney moves amount coins from userFrom to userTo and records the
+// operation in the history within the same transaction. Both rows are locked
+// in ascending user_id order, so concurrent transfers in opposite directions
+// queue up instead of deadlocking. If Postgres still aborts the transaction
+// with a deadlock or serialization failure, the transfer is retried.
+func (u UserRepository) TransferMoney(ctx context.Context, userFrom int, userTo int, amount int, limits []entity.SpendingLimit) error {
+	return retryOnConflict(ctx, u.l, func() error {
+		return u.transferMoney(ctx, userFrom, userTo, amount, limits)
+	})
+}
+
+func (u UserRepository) transferMoney(ctx context.Context, userFrom int, userTo int, amount int, limits []entity.SpendingLimit) error {
+	return withTx(ctx, u.l, u.db, func(tx Tx) error {
+		err := moveCoins(ctx, tx, userFrom, userTo, amount, limits, nil)
+		if err != nil && !isRefusal(err) {
+			u.l.Error("Failed to move coins", zap.Error(err))
+		}
 		return err
-	}
+	})
+}
+
+// isRefusal reports whether err is moveCoins turning the transfer down
+// rather than failing.
+func isRefusal(err error) bool {
+	return errors.Is(err, repository.ErrorUserNotFound) ||
+		errors.Is(err, repository.ErrorInsufficientBalance) ||
+		errors.Is(err, repository.ErrorLimitExceeded)
+}
 
-	var balance int
-	err = tx.QueryRow("SELECT balance FROM users WHERE user_id = $1 FOR UPDATE", userFrom).Scan(&balance)
+// moveCoins moves amount coins from userFrom to userTo within the transaction
+// q, locking both rows like TransferMoney, and inserts the history row and
+// the audit entry. The transfer limits of userFrom are checked under the
+// lock. details are added to the payload of the audit entry next to the
+// amount.
+func moveCoins(ctx context.Context, q Querier, userFrom int, userTo int, amount int, limits []entity.SpendingLimit, details map[string]any) error {
+	balances, err := lockBalances(ctx, q, userFrom, userTo)
 	if err != nil {
-		err = tx.Rollback()
-		if err != nil {
-			u.l.Error("Failed to rollback transaction", zap.Error(err))
-			return err
-		}
-		u.l.Error("Failed to check balance", zap.Error(err))
 		return err
 	}
 
-	if balance < amount {
-		err = tx.Rollback()
-		if err != nil {
-			u.l.Error("Failed to rollback transaction", zap.Error(err))
-			return err
-		}
-		return fmt.Errorf("insufficient balance")
+	balance, ok := balances[userFrom]
+	if _, found := balances[userTo]; !ok || !found {
+		return repository.ErrorUserNotFound
 	}
 
-	_, err = tx.Exec("UPDATE users SET balance = balance - $1 WHERE user_id = $2", amount, userFrom)
-	if err != nil {
-		err = tx.Rollback()
-		if err != nil {
-			u.l.Error("Failed to rollback transaction", zap.Error(err))
-			return err
-		}
-		u.l.Error("Failed to update balance for sender", zap.Error(err))
+	if balance < amount {
+		return repository.ErrorInsufficientBalance
+	}
+	if err = checkLimits(ctx, q, userFrom, entity.LimitTransfer, amount, limits); err != nil {
 		return err
 	}
 
-	_, err = tx.Exec("UPDATE users SET balance = balance + $1 WHERE user_id = $2", amount, userTo)
+	var sender, receiver string
+	err = q.QueryRow(ctx, `
+	SELECT s.username, r.username
+	FROM users s, users r
+	WHERE s.user_id = $1 AND r.user_id = $2
+`, userFrom, userTo).Scan(&sender, &receiver)
 	if err != nil {
-		err = tx.Rollback()
-		if err != nil {
-			u.l.Error("Failed to rollback transaction", zap.Error(err))
-			return err
-		}
-		u.l.Error("Failed to update balance for receiver", zap.Error(err))
 		return err
 	}
 
-	err = tx.Commit()
+	err = q.ExecBatch(ctx,
+		Query{SQL: "UPDATE users SET balance = balance - $1 WHERE user_id = $2", Args: []any{amount, userFrom}},
+		Query{SQL: "UPDATE users SET balance = balance + $1 WHERE user_id = $2", Args: []any{amount, userTo}},
+		Query{SQL: "INSERT INTO history (sender_name, receiver_name, amount) VALUES ($1, $2, $3)", Args: []any{sender, receiver, amount}},
+	)
 	if err != nil {
-		u.l.Error("Failed to commit transaction", zap.Error(err))
 		return err
 	}
 
-	return nil
+	payload := map[string]any{"amount": amount}
+	for key, value := range details {
+		payload[key] = value
+	}
+	_, err = appendAudit(ctx, q, newAuditEntry(sender, entity.AuditTransfer, receiver, payload))
+	return err
 }
 
-func (u UserRepository) WithdrawMoney(user int, amount int) error {
-	tx, err := u.db.Begin()
+// lockBalances locks the rows of the given users in ascending user_id order and
+// returns their available balances, without the held coins, keyed by id.
+// Missing users are absent from the map.
+func lockBalances(ctx context.Context, q Querier, ids ...int) (map[int]int, error) {
+	rows, err := q.Query(ctx, `
+	SELECT user_id, balance - held
+	FROM users
+	WHERE user_id = ANY($1)
+	ORDER BY user_id
+	FOR UPDATE
+`, ids)
 	if err != nil {
-		u.l.Error("Failed to begin transaction", zap.Error(err))
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	var balance int
-	err = tx.QueryRow("SELECT balance FROM users WHERE user_id = $1 FOR UPDATE", user).Scan(&balance)
-	if err != nil {
-		u.l.Error("Failed to check balance", zap.Error(err))
-		err = tx.Rollback()
+	balances := make(map[int]int, len(ids))
+	for rows.Next() {
+		var id, balance int
+		if err = rows.Scan(&id, &balance); err != nil {
+			return nil, err
+		}
+		balances[id] = balance
+	}
+	return balances, rows.Err()
+}
+
+// BuyItem takes price coins from the user's balance, adds item to the
+// inventory and records the purchase in the audit log within one transaction.
+// The row is locked for the duration of the transaction, which is always
+// closed on return, and the purchase limits are checked under the lock.
+func (u UserRepository) BuyItem(ctx context.Context, user int, item string, price int, limits []entity.SpendingLimit) error {
+	return withTx(ctx, u.l, u.db, func(tx Tx) error {
+		balances, err := lockBalances(ctx, tx, user)
 		if err != nil {
-			u.l.Error("Failed to rollback transaction", zap.Error(err))
+			u.l.Error("Failed to check balance", zap.Error(err))
 			return err
 		}
-		return err
-	}
 
-	if balance < amount {
-		return fmt.Errorf("insufficient balance")
-	}
+		balance, ok := balances[user]
+		if !ok {
+			return repository.ErrorUserNotFound
+		}
 
-	_, err = tx.Exec("UPDATE users SET balance = balance - $1 WHERE user_id = $2", amount, user)
-	if err != nil {
-		u.l.Error("Failed to update balance", zap.Error(err))
-		err = tx.Rollback()
-		if err != nil {
-			u.l.Error("Failed to rollback transaction", zap.Error(err))
+		if balance < price {
+			return repository.ErrorInsufficientBalance
+		}
+		if err = checkLimits(ctx, tx, user, entity.LimitPurchase, price, limits); err != nil {
+			if !errors.Is(err, repository.ErrorLimitExceeded) {
+				u.l.Error("Failed to check limits", zap.Error(err))
+			}
 			return err
 		}
-		return err
-	}
 
-	err = tx.Commit()
-	if err != nil {
-		u.l.Error("Failed to commit transaction", zap.Error(err))
-		err = tx.Rollback()
+		var buyer string
+		err = tx.QueryRow(ctx, `
+		UPDATE users
+		SET balance = balance - $1
+		WHERE user_id = $2
+		RETURNING username
+	`, price, user).Scan(&buyer)
 		if err != nil {
-			u.l.Error("Failed to rollback transaction", zap.Error(err))
+			u.l.Error("Failed to buy item", zap.Error(err))
+			return err
+		}
+		_, err = tx.Exec(ctx, "INSERT INTO inventory (owner_id, item, price) VALUES ($1, $2, $3)", user, item, price)
+		if err != nil {
+			u.l.Error("Failed to buy item", zap.Error(err))
 			return err
 		}
-		return err
-	}
 
-	return nil
+		_, err = appendAudit(ctx, tx, newAuditEntry(buyer, entity.AuditPurchase, item, map[string]any{"cost": price}))
+		if err != nil {
+			u.l.Error("Failed to write audit log", zap.Error(err))
+			return err
+		}
+		return nil
+	})
 }
 
 func NewUserRepository(
 	l *zap.Logger,
-	db *sql.DB,
+	db DB,
 ) repository.UserRepository {
 	return &UserRepository{
 		l:  l,
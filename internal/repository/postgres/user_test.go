@@ -2,6 +2,9 @@ package postgres
 
 import (
 	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -12,7 +15,7 @@ import (
 
 func TestInsertUser(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	repo := NewUserRepository(logger, db)
+	repo := NewUserRepository(logger, sqlDB)
 
 	user := &entity.User{
 		Username: "testuser",
@@ -20,7 +23,7 @@ func TestInsertUser(t *testing.T) {
 		Balance:  100,
 	}
 
-	insertedUser, err := repo.InsertUser(user)
+	insertedUser, err := repo.InsertUser(context.Background(), user)
 	assert.NoError(t, err)
 	assert.NotNil(t, insertedUser)
 	assert.Equal(t, user.Username, insertedUser.Username)
@@ -30,7 +33,7 @@ func TestInsertUser(t *testing.T) {
 
 func TestFindUserByUsername(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	repo := NewUserRepository(logger, db)
+	repo := NewUserRepository(logger, sqlDB)
 
 	user := &entity.User{
 		Username: "testuser",
@@ -38,10 +41,10 @@ func TestFindUserByUsername(t *testing.T) {
 		Balance:  100,
 	}
 
-	_, err := repo.InsertUser(user)
+	_, err := repo.InsertUser(context.Background(), user)
 	assert.NoError(t, err)
 
-	foundUser, err := repo.FindUserByUsername("testuser")
+	foundUser, err := repo.FindUserByUsername(context.Background(), "testuser")
 	assert.NoError(t, err)
 	assert.NotNil(t, foundUser)
 	assert.Equal(t, user.Username, foundUser.Username)
@@ -51,7 +54,7 @@ func TestFindUserByUsername(t *testing.T) {
 
 func TestFindUserById(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	repo := NewUserRepository(logger, db)
+	repo := NewUserRepository(logger, sqlDB)
 
 	user := &entity.User{
 		Username: "testuser",
@@ -59,10 +62,10 @@ func TestFindUserById(t *testing.T) {
 		Balance:  100,
 	}
 
-	insertedUser, err := repo.InsertUser(user)
+	insertedUser, err := repo.InsertUser(context.Background(), user)
 	assert.NoError(t, err)
 
-	foundUser, err := repo.FindUserByID(insertedUser.ID)
+	foundUser, err := repo.FindUserByID(context.Background(), insertedUser.ID)
 	assert.NoError(t, err)
 	assert.NotNil(t, foundUser)
 	assert.Equal(t, user.Username, foundUser.Username)
@@ -72,7 +75,7 @@ func TestFindUserById(t *testing.T) {
 
 func TestTransferMoney(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	repo := NewUserRepository(logger, db)
+	repo := NewUserRepository(logger, sqlDB)
 
 	user1 := &entity.User{
 		Username: "user1",
@@ -86,27 +89,32 @@ func TestTransferMoney(t *testing.T) {
 		Balance:  100,
 	}
 
-	insertedUser1, err := repo.InsertUser(user1)
+	insertedUser1, err := repo.InsertUser(context.Background(), user1)
 	assert.NoError(t, err)
 
-	insertedUser2, err := repo.InsertUser(user2)
+	insertedUser2, err := repo.InsertUser(context.Background(), user2)
 	assert.NoError(t, err)
 
-	err = repo.TransferMoney(insertedUser1.ID, insertedUser2.ID, 50)
+	err = repo.TransferMoney(context.Background(), insertedUser1.ID, insertedUser2.ID, 50, nil)
 	assert.NoError(t, err)
 
-	updatedUser1, err := repo.FindUserByID(insertedUser1.ID)
+	sent, err := NewHistoryRepository(logger, sqlDB).GetSentByUser(context.Background(), insertedUser1.Username)
+	assert.NoError(t, err)
+	assert.Len(t, sent, 1)
+	assert.Equal(t, 50, sent[0].Amount)
+
+	updatedUser1, err := repo.FindUserByID(context.Background(), insertedUser1.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, 150, updatedUser1.Balance)
 
-	updatedUser2, err := repo.FindUserByID(insertedUser2.ID)
+	updatedUser2, err := repo.FindUserByID(context.Background(), insertedUser2.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, 150, updatedUser2.Balance)
 }
 
-func TestWithdrawMoney(t *testing.T) {
+func TestBuyItem(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	repo := NewUserRepository(logger, db)
+	repo := NewUserRepository(logger, sqlDB)
 
 	user := &entity.User{
 		Username: "testuser",
@@ -114,13 +122,209 @@ func TestWithdrawMoney(t *testing.T) {
 		Balance:  200,
 	}
 
-	insertedUser, err := repo.InsertUser(user)
+	insertedUser, err := repo.InsertUser(context.Background(), user)
 	assert.NoError(t, err)
 
-	err = repo.WithdrawMoney(insertedUser.ID, 50)
+	err = repo.BuyItem(context.Background(), insertedUser.ID, "cup", 50, nil)
 	assert.NoError(t, err)
 
-	updatedUser, err := repo.FindUserByID(insertedUser.ID)
+	updatedUser, err := repo.FindUserByID(context.Background(), insertedUser.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, 150, updatedUser.Balance)
+
+	items, err := NewInventoryRepository(logger, sqlDB).GetUsersInventory(context.Background(), insertedUser.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"cup": 1}, items)
+}
+
+func TestTransferMoneyInsufficientBalance(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := NewUserRepository(logger, sqlDB)
+
+	sender, err := repo.InsertUser(context.Background(), &entity.User{Username: "poor", Password: "pass", Balance: 10})
+	assert.NoError(t, err)
+	receiver, err := repo.InsertUser(context.Background(), &entity.User{Username: "rich", Password: "pass", Balance: 100})
+	assert.NoError(t, err)
+
+	err = repo.TransferMoney(context.Background(), sender.ID, receiver.ID, 50, nil)
+	assert.ErrorIs(t, err, repository.ErrorInsufficientBalance)
+
+	updatedSender, err := repo.FindUserByID(context.Background(), sender.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, updatedSender.Balance)
+}
+
+func TestTransferMoneyConcurrentOppositeDirections(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := NewUserRepository(logger, sqlDB)
+
+	userA, err := repo.InsertUser(context.Background(), &entity.User{Username: "stressA", Password: "pass", Balance: 1000})
+	assert.NoError(t, err)
+	userB, err := repo.InsertUser(context.Background(), &entity.User{Username: "stressB", Password: "pass", Balance: 1000})
+	assert.NoError(t, err)
+
+	const transfers = 200
+	errs := make(chan error, 2*transfers)
+	var wg sync.WaitGroup
+	for i := 0; i < transfers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- repo.TransferMoney(context.Background(), userA.ID, userB.ID, 1, nil)
+		}()
+		go func() {
+			defer wg.Done()
+			errs <- repo.TransferMoney(context.Background(), userB.ID, userA.ID, 1, nil)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err = range errs {
+		assert.NoError(t, err)
+	}
+
+	updatedA, err := repo.FindUserByID(context.Background(), userA.ID)
+	assert.NoError(t, err)
+	updatedB, err := repo.FindUserByID(context.Background(), userB.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, updatedA.Balance)
+	assert.Equal(t, 1000, updatedB.Balance)
+}
+
+func TestBuyItemInsufficientBalanceReleasesConnections(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := NewUserRepository(logger, sqlDB)
+
+	user, err := repo.InsertUser(context.Background(), &entity.User{Username: "withdrawer", Password: "pass", Balance: 100})
+	assert.NoError(t, err)
+
+	baseline := db.Stats().InUse
+	for i := 0; i < 100; i++ {
+		err = repo.BuyItem(context.Background(), user.ID, "cup", 1000, nil)
+		assert.ErrorIs(t, err, repository.ErrorInsufficientBalance)
+	}
+	assert.Equal(t, baseline, db.Stats().InUse)
+
+	// the row lock must have been released together with the transaction
+	err = repo.BuyItem(context.Background(), user.ID, "cup", 30, nil)
+	assert.NoError(t, err)
+
+	updatedUser, err := repo.FindUserByID(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 70, updatedUser.Balance)
+	assert.Equal(t, baseline, db.Stats().InUse)
+}
+
+func TestSetRole(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := NewUserRepository(logger, sqlDB)
+
+	user, err := repo.InsertUser(context.Background(), &entity.User{Username: "promoted", Password: "pass", Balance: 100})
+	assert.NoError(t, err)
+	assert.Equal(t, entity.RoleUser, user.Role)
+
+	updated, err := repo.SetRole(context.Background(), "promoted", entity.RoleAdmin)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.RoleAdmin, updated.Role)
+	assert.Equal(t, user.TokenVersion+1, updated.TokenVersion)
+
+	// setting the role the user already has keeps the sessions
+	updated, err = repo.SetRole(context.Background(), "promoted", entity.RoleAdmin)
+	assert.NoError(t, err)
+	assert.Equal(t, user.TokenVersion+1, updated.TokenVersion)
+
+	_, err = repo.SetRole(context.Background(), "nobody", entity.RoleAdmin)
+	assert.ErrorIs(t, err, repository.ErrorUserNotFound)
+}
+
+func TestSignup(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := NewUserRepository(logger, sqlDB)
+	inventory := NewInventoryRepository(logger, sqlDB)
+
+	user, err := repo.Signup(context.Background(), &entity.User{Username: "welcomed", Password: "pass", Balance: 1500}, []string{"cup", "pen", "cup"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1500, user.Balance)
+
+	items, err := inventory.GetUsersInventory(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"cup": 2, "pen": 1}, items)
+
+	user, err = repo.Signup(context.Background(), &entity.User{Username: "unwelcomed", Password: "pass", Balance: 1000}, nil)
+	assert.NoError(t, err)
+
+	items, err = inventory.GetUsersInventory(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestSearchUsers(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := NewUserRepository(logger, pgxDB)
+	ctx := context.Background()
+
+	for _, name := range []string{"search_b", "search_a", "search_c", "searc%", "other"} {
+		_, err := repo.InsertUser(ctx, &entity.User{Username: name, Password: "pass", Balance: 100})
+		assert.NoError(t, err)
+	}
+	_, err := repo.DeleteUser(ctx, "search_c")
+	assert.NoError(t, err)
+
+	users, total, err := repo.SearchUsers(ctx, entity.UserFilter{Prefix: "search_", Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "search_a", users[0].Username)
+
+	users, total, err = repo.SearchUsers(ctx, entity.UserFilter{Prefix: "search_", Limit: 10, Offset: 1, IncludeDeleted: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "search_b", users[0].Username)
+	assert.NotNil(t, users[1].DeletedAt)
+
+	// wildcards in the prefix are taken literally
+	users, total, err = repo.SearchUsers(ctx, entity.UserFilter{Prefix: "searc%", Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "searc%", users[0].Username)
+
+	users, total, err = repo.SearchUsers(ctx, entity.UserFilter{Prefix: "search_", Limit: 10, Offset: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Empty(t, users)
+}
+
+func TestSetDisabledAndDeleteUser(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := NewUserRepository(logger, sqlDB)
+	ctx := context.Background()
+
+	_, err := repo.InsertUser(ctx, &entity.User{Username: "banned", Password: "pass", Balance: 100})
+	assert.NoError(t, err)
+
+	user, err := repo.SetDisabled(ctx, "banned", true)
+	assert.NoError(t, err)
+	assert.NotNil(t, user.DisabledAt)
+	assert.False(t, user.Active())
+
+	user, err = repo.SetDisabled(ctx, "banned", false)
+	assert.NoError(t, err)
+	assert.Nil(t, user.DisabledAt)
+	assert.True(t, user.Active())
+
+	user, err = repo.DeleteUser(ctx, "banned")
+	assert.NoError(t, err)
+	assert.NotNil(t, user.DeletedAt)
+
+	// the row stays, so the username can't be signed up again
+	found, err := repo.FindUserByUsername(ctx, "banned")
+	assert.NoError(t, err)
+	assert.False(t, found.Active())
+
+	_, err = repo.DeleteUser(ctx, "banned")
+	assert.ErrorIs(t, err, repository.ErrorUserNotFound)
+	_, err = repo.SetDisabled(ctx, "banned", true)
+	assert.ErrorIs(t, err, repository.ErrorUserNotFound)
 }
@@ -1,8 +1,10 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
 	"log"
@@ -12,6 +14,11 @@ import (
 
 var (
 	db *sql.DB
+
+	// sqlDB and pgxDB point at the same database through the two supported
+	// drivers.
+	sqlDB DB
+	pgxDB DB
 )
 
 func setupTestDB(t *testing.T) func() {
@@ -49,31 +56,167 @@ func setupTestDB(t *testing.T) func() {
 	}); err != nil {
 		log.Fatalf("Could not connect to docker: %s", err)
 	}
+	sqlDB = NewSQLDB(db)
+
+	pgxPool, err := pgxpool.New(context.Background(), databaseURL)
+	if err != nil {
+		log.Fatalf("Could not create pgx pool: %s", err)
+	}
+	pgxDB = NewPgxDB(pgxPool)
 
 	_, err = db.Exec(`
 	CREATE TABLE IF NOT EXISTS users (
 		user_id SERIAL PRIMARY KEY,
 		username TEXT NOT NULL,
 		password TEXT NOT NULL,
-		balance INTEGER NOT NULL
+		balance INTEGER NOT NULL,
+		signup_balance INTEGER NOT NULL DEFAULT 1000,
+		held INTEGER NOT NULL DEFAULT 0 CHECK (held >= 0),
+		token_version INTEGER NOT NULL DEFAULT 0,
+		role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin', 'auditor')),
+		disabled_at TIMESTAMPTZ,
+		-- deleted users keep their row, so that the history naming them stays
+		-- intact
+		deleted_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		CHECK (held <= balance)
 	);
 	CREATE TABLE IF NOT EXISTS inventory (
 		id SERIAL PRIMARY KEY,
 		owner_id INTEGER NOT NULL,
-		item TEXT NOT NULL
+		item TEXT NOT NULL,
+		price INTEGER,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
 	);
 	CREATE TABLE IF NOT EXISTS history (
 		id SERIAL PRIMARY KEY,
 		sender_name TEXT NOT NULL,
 		receiver_name TEXT NOT NULL,
-		amount INTEGER
+		amount INTEGER,
+		-- reason explains operations of the system account and released holds,
+		-- it is NULL for other transfers between users
+		reason TEXT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
 );
+	CREATE TABLE IF NOT EXISTS login_failures (
+		id BIGSERIAL PRIMARY KEY,
+		username TEXT NOT NULL,
+		ip TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE TABLE IF NOT EXISTS lockouts (
+		id SERIAL PRIMARY KEY,
+		username TEXT NOT NULL,
+		ip TEXT NOT NULL,
+		failures INTEGER NOT NULL,
+		locked_until TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		unlocked_at TIMESTAMPTZ,
+		unlocked_by TEXT
+	);
+	CREATE TABLE IF NOT EXISTS password_reset_tokens (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		expires_at TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		used_at TIMESTAMPTZ
+	);
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id BIGSERIAL PRIMARY KEY,
+		created_at TIMESTAMPTZ NOT NULL,
+		actor TEXT NOT NULL,
+		action TEXT NOT NULL,
+		target TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		prev_hash TEXT NOT NULL,
+		hash TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS reconciliations (
+		id SERIAL PRIMARY KEY,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		users INTEGER NOT NULL,
+		status TEXT NOT NULL CHECK (status IN ('clean', 'pending', 'applied')),
+		approved_by TEXT,
+		approved_at TIMESTAMPTZ
+	);
+	CREATE TABLE IF NOT EXISTS reconciliation_discrepancies (
+		reconciliation_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		username TEXT NOT NULL,
+		balance INTEGER NOT NULL,
+		expected INTEGER NOT NULL,
+		corrected BOOLEAN NOT NULL DEFAULT false,
+		PRIMARY KEY (reconciliation_id, user_id)
+	);
+	CREATE TABLE IF NOT EXISTS job_runs (
+		id BIGSERIAL PRIMARY KEY,
+		job TEXT NOT NULL,
+		scheduled_at TIMESTAMPTZ NOT NULL,
+		started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		finished_at TIMESTAMPTZ,
+		error TEXT,
+		replica TEXT NOT NULL,
+		UNIQUE (job, scheduled_at)
+	);
+	CREATE TABLE IF NOT EXISTS coin_requests (
+		id BIGSERIAL PRIMARY KEY,
+		requester_id INTEGER NOT NULL,
+		payer_id INTEGER NOT NULL,
+		amount INTEGER NOT NULL CHECK (amount > 0),
+		memo TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined')),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		expires_at TIMESTAMPTZ NOT NULL,
+		resolved_at TIMESTAMPTZ
+	);
+	CREATE TABLE IF NOT EXISTS scheduled_transfers (
+		id BIGSERIAL PRIMARY KEY,
+		sender_id INTEGER NOT NULL,
+		receiver_id INTEGER NOT NULL,
+		amount INTEGER NOT NULL CHECK (amount > 0),
+		schedule TEXT,
+		status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'completed', 'cancelled', 'failed')),
+		next_run_at TIMESTAMPTZ,
+		last_run_at TIMESTAMPTZ,
+		failures INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE TABLE IF NOT EXISTS holds (
+		id BIGSERIAL PRIMARY KEY,
+		sponsor_id INTEGER NOT NULL,
+		amount INTEGER NOT NULL CHECK (amount > 0),
+		memo TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'released', 'cancelled')),
+		recipient_id INTEGER,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		resolved_at TIMESTAMPTZ
+	);
+	CREATE TABLE IF NOT EXISTS spending_limits (
+		user_id INTEGER NOT NULL,
+		operation TEXT NOT NULL CHECK (operation IN ('transfer', 'purchase')),
+		period TEXT NOT NULL CHECK (period IN ('daily', 'weekly')),
+		amount INTEGER NOT NULL CHECK (amount >= 0),
+		PRIMARY KEY (user_id, operation, period)
+	);
+	CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'audit_log is append-only';
+	END;
+	$$ LANGUAGE plpgsql;
+	CREATE TRIGGER audit_log_append_only
+		BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_log
+		FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
 	`)
 	if err != nil {
 		log.Fatalf("Could not create table: %s", err)
 	}
 
 	return func() {
+		if err = pgxDB.Close(); err != nil {
+			log.Fatalf("Could not close pgx pool: %s", err)
+		}
 		if err = db.Close(); err != nil {
 			log.Fatalf("Could not close database: %s", err)
 		}
@@ -3,27 +3,259 @@ package repository
 
 import (
 	"AvitoTech/internal/entity"
+	"context"
 	"errors"
+	"time"
 )
 
-var ErrorUserNotFound = errors.New("user not found")
+var (
+	ErrorUserNotFound        = errors.New("user not found")
+	ErrorInsufficientBalance = errors.New("insufficient balance")
+	ErrorResetTokenInvalid   = errors.New("reset token is invalid or expired")
+	ErrorReportNotFound      = errors.New("reconciliation report not found")
+	ErrorReportNotPending    = errors.New("reconciliation report is not pending")
+	ErrorJobRunClaimed       = errors.New("job run already claimed")
+	ErrorRequestNotFound     = errors.New("coin request not found")
+	ErrorRequestNotPending   = errors.New("coin request is not pending")
+	ErrorTransferNotFound    = errors.New("scheduled transfer not found")
+	ErrorTransferNotActive   = errors.New("scheduled transfer is not active")
+	ErrorHoldNotFound        = errors.New("hold not found")
+	ErrorHoldNotActive       = errors.New("hold is not active")
+	ErrorLimitNotFound       = errors.New("spending limit not found")
+	ErrorLimitExceeded       = errors.New("spending limit exceeded")
+)
 
 type HistoryRepository interface {
-	InsertOperation(operation entity.Operation) (*entity.Operation, error)
-	GetSentByUser(name string) ([]entity.Operation, error)
-	GetReceivedByUser(name string) ([]entity.Operation, error)
-	DeleteOperation(id int) error
+	InsertOperation(ctx context.Context, operation entity.Operation) (*entity.Operation, error)
+	GetSentByUser(ctx context.Context, name string) ([]entity.Operation, error)
+	GetReceivedByUser(ctx context.Context, name string) ([]entity.Operation, error)
+	DeleteOperation(ctx context.Context, id int) error
 }
 
 type InventoryRepository interface {
-	InsertItem(owner int, item string) (*entity.Item, error)
-	GetUsersInventory(userID int) (map[string]int, error)
-	DeleteItem(id int) error
+	// InsertItem adds item, bought for price, to the inventory of owner.
+	InsertItem(ctx context.Context, owner int, item string, price int) (*entity.Item, error)
+	GetUsersInventory(ctx context.Context, userID int) (map[string]int, error)
+	DeleteItem(ctx context.Context, id int) error
 }
 type UserRepository interface {
-	InsertUser(user *entity.User) (*entity.User, error)
-	FindUserByUsername(username string) (*entity.User, error)
-	FindUserByID(id int) (*entity.User, error)
-	TransferMoney(userFrom int, userTo int, amount int) error
-	WithdrawMoney(user int, amount int) error
+	InsertUser(ctx context.Context, user *entity.User) (*entity.User, error)
+	// Signup inserts user together with the welcome items of the new account.
+	Signup(ctx context.Context, user *entity.User, items []string) (*entity.User, error)
+	FindUserByUsername(ctx context.Context, username string) (*entity.User, error)
+	FindUserByID(ctx context.Context, id int) (*entity.User, error)
+	// TransferMoney moves amount coins and records the operation in the
+	// history and the audit log in one transaction. It fails with ErrorLimitExceeded if the
+	// transfer would take userFrom over one of limits, checked in the same
+	// transaction.
+	TransferMoney(ctx context.Context, userFrom int, userTo int, amount int, limits []entity.SpendingLimit) error
+	// BuyItem takes price coins from the user and adds item to the inventory
+	// in one transaction, checking the purchase limits like TransferMoney.
+	BuyItem(ctx context.Context, user int, item string, price int, limits []entity.SpendingLimit) error
+	// SetPassword replaces the password hash of the user. With revokeSessions
+	// the token version is bumped, so that every token issued before is
+	// refused. The token version in effect is returned.
+	SetPassword(ctx context.Context, id int, hash string, revokeSessions bool) (int, error)
+	// SetRole gives the user role. A change of role revokes the sessions of
+	// the user, whose tokens carry the old one.
+	SetRole(ctx context.Context, username string, role entity.Role) (*entity.User, error)
+	// SearchUsers returns the page of users selected by filter and the number
+	// of users matching it on all pages.
+	SearchUsers(ctx context.Context, filter entity.UserFilter) ([]entity.User, int, error)
+	SetDisabled(ctx context.Context, username string, disabled bool) (*entity.User, error)
+	// DeleteUser marks the user deleted. The row stays and is still found by
+	// FindUserByUsername and FindUserByID, so the username can't be reused.
+	DeleteUser(ctx context.Context, username string) (*entity.User, error)
+	// Hold locks amount coins of the available balance of user. They stay in
+	// the balance but can't be spent until the hold is resolved.
+	Hold(ctx context.Context, user int, amount int, memo string) (*entity.Hold, error)
+	// ReleaseHold pays the coins of the active hold id of sponsor out to
+	// recipient, checking the transfer limits of sponsor like TransferMoney.
+	ReleaseHold(ctx context.Context, id int64, sponsor int, recipient int, limits []entity.SpendingLimit) (*entity.Hold, error)
+	// CancelHold returns the coins of the active hold id to sponsor.
+	CancelHold(ctx context.Context, id int64, sponsor int) (*entity.Hold, error)
+	// ListHolds returns the holds of sponsor, newest first.
+	ListHolds(ctx context.Context, sponsor int, limit int) ([]entity.Hold, error)
+}
+
+// AdjustmentRepository applies operator grants and deductions.
+type AdjustmentRepository interface {
+	// Apply changes every balance of adjustment and records the operations
+	// in the history and the audit log, on behalf of actor, in one
+	// transaction. It fails as a whole if any user is missing or a deduction
+	// would overdraw a balance, and returns the IDs of the users changed
+	// otherwise.
+	Apply(ctx context.Context, adjustment entity.Adjustment, actor string) ([]int, error)
+}
+
+// LimitRepository keeps the spending limits set for single users and sums up
+// what users spent.
+type LimitRepository interface {
+	// Overrides returns the limits set for userID.
+	Overrides(ctx context.Context, userID int) ([]entity.SpendingLimit, error)
+	// SetOverride sets limit for userID, replacing the one set before.
+	SetOverride(ctx context.Context, userID int, limit entity.SpendingLimit) error
+	// DeleteOverride returns userID to the configured limit. It fails with
+	// ErrorLimitNotFound if no limit was set.
+	DeleteOverride(ctx context.Context, userID int, operation entity.LimitOperation, period entity.LimitPeriod) error
+	// Spent sums the coins userID spent on operation since the given time.
+	Spent(ctx context.Context, userID int, operation entity.LimitOperation, since time.Time) (int, error)
+}
+
+// AccountRepository is the read model behind the account overview: balance,
+// coin history and inventory are read together from a single snapshot.
+type AccountRepository interface {
+	GetAccountInfo(ctx context.Context, userID int) (*entity.AccountInfo, error)
+}
+
+// HealthRepository checks that the database is reachable and carries the
+// schema the service expects.
+type HealthRepository interface {
+	Ping(ctx context.Context) error
+	CheckSchema(ctx context.Context) error
+}
+
+// LoginAttemptRepository tracks wrong passwords per username and client IP
+// and the lockouts they lead to.
+type LoginAttemptRepository interface {
+	RecordFailure(ctx context.Context, username, ip string) error
+	// CountFailures returns the failures within window for username and,
+	// across all usernames, for ip.
+	CountFailures(ctx context.Context, username, ip string, window time.Duration) (byUsername int, byIP int, err error)
+	ClearFailures(ctx context.Context, username string) error
+	// Lock records lockout, running for duration from now, and clears the
+	// failures of the username.
+	Lock(ctx context.Context, lockout entity.Lockout, duration time.Duration) (*entity.Lockout, error)
+	// ActiveLockout returns the lockout in force for username, or nil.
+	ActiveLockout(ctx context.Context, username string) (*entity.Lockout, error)
+	// Unlock lifts the lockouts in force for username on behalf of by and
+	// clears its failures. It reports whether there was anything to lift.
+	Unlock(ctx context.Context, username, by string) (bool, error)
+}
+
+// PasswordResetRepository stores the single-use password reset tokens.
+type PasswordResetRepository interface {
+	// Create stores the hash of a new reset token of the user, valid for ttl,
+	// and discards the unused ones issued before.
+	Create(ctx context.Context, userID int, tokenHash string, ttl time.Duration) (*entity.PasswordReset, error)
+	// Find returns the user of the token. ErrorResetTokenInvalid is returned
+	// for unknown, used and expired tokens.
+	Find(ctx context.Context, tokenHash string) (userID int, err error)
+	// Consume spends the token and sets passwordHash as the password of its
+	// user, revoking the user's sessions. ErrorResetTokenInvalid is returned
+	// for unknown, used and expired tokens.
+	Consume(ctx context.Context, tokenHash string, passwordHash string) (userID int, err error)
+}
+
+// AuditRepository is the append-only store of the audit log. The changes of
+// balances are recorded by the repositories making them, in the same
+// transaction, Append is for the actions that move no coins.
+type AuditRepository interface {
+	// Append chains entry to the last entry of the log and stores it. The
+	// ID, PrevHash and Hash of the stored entry are returned.
+	Append(ctx context.Context, entry entity.AuditEntry) (*entity.AuditEntry, error)
+	List(ctx context.Context, filter entity.AuditFilter) ([]entity.AuditEntry, error)
+	// Walk calls fn with every entry of the log in the order they were
+	// appended and stops at the first error returned by fn.
+	Walk(ctx context.Context, fn func(entity.AuditEntry) error) error
+}
+
+// ReconciliationRepository recomputes the balances from the signup grants, the
+// history and the purchases, and keeps the reports.
+type ReconciliationRepository interface {
+	// Reconcile checks the balance of every user and stores the report.
+	// Purchases made before prices were kept are priced at prices.
+	Reconcile(ctx context.Context, prices map[string]int) (*entity.Reconciliation, error)
+	Get(ctx context.Context, id int) (*entity.Reconciliation, error)
+	// List returns the latest limit reports without their discrepancies.
+	List(ctx context.Context, limit int) ([]entity.Reconciliation, error)
+	// Apply corrects the balances of the pending report id, approved by
+	// actor. A discrepancy is corrected only if it still stands as reported
+	// and the expected balance covers the coins held, the others are left
+	// uncorrected for the next run. ErrorReportNotPending is returned for
+	// reports already applied or clean.
+	Apply(ctx context.Context, id int, actor string, prices map[string]int) (*entity.Reconciliation, error)
+}
+
+// LeaderRepository elects the replica running the scheduled jobs.
+type LeaderRepository interface {
+	// TryLead takes the leadership named by key if no other replica holds
+	// it. A nil Lease is returned while another replica leads.
+	TryLead(ctx context.Context, key int64) (Lease, error)
+}
+
+// Lease is leadership held until released or lost along with the
+// connection holding it.
+type Lease interface {
+	// Alive returns an error once the leadership has been lost.
+	Alive(ctx context.Context) error
+	Release(ctx context.Context) error
+}
+
+// JobRunRepository records the runs of the scheduled jobs.
+type JobRunRepository interface {
+	// Start claims the run of job due at scheduledAt. ErrorJobRunClaimed is
+	// returned if the run has been claimed already.
+	Start(ctx context.Context, job string, scheduledAt time.Time, replica string) (*entity.JobRun, error)
+	// Finish marks the run finished, failed with runErr unless it is empty.
+	Finish(ctx context.Context, id int64, runErr string) error
+	// Last returns the latest run of job, or nil if it never ran.
+	Last(ctx context.Context, job string) (*entity.JobRun, error)
+	// List returns the latest runs, of job only unless it is empty.
+	List(ctx context.Context, job string, limit int) ([]entity.JobRun, error)
+}
+
+// AllowanceRepository tops up and expires coins of every user. Both are
+// recorded in the history as operations of the system account.
+type AllowanceRepository interface {
+	// Grant adds amount to the balance of every active user and returns
+	// their IDs. Users are changed in batches, each committed on its own, and
+	// the users of the batches committed before a failure are returned with
+	// the error.
+	Grant(ctx context.Context, amount int, reason string) ([]int, error)
+	// Expire takes away the coins received before cutoff that are still
+	// unspent, counting the oldest coins as spent first. Purchases made
+	// before prices were kept are priced at prices. The IDs of the users
+	// and the coins expired are returned, in batches like Grant.
+	Expire(ctx context.Context, cutoff time.Time, prices map[string]int, reason string) ([]int, int, error)
+}
+
+// CoinRequestRepository stores the requests of coins between users. A
+// pending request past its expiry reads as expired and can't be resolved.
+type CoinRequestRepository interface {
+	Create(ctx context.Context, request entity.CoinRequest, ttl time.Duration) (*entity.CoinRequest, error)
+	// Get returns request id if userID is its requester or payer,
+	// ErrorRequestNotFound otherwise.
+	Get(ctx context.Context, id int64, userID int) (*entity.CoinRequest, error)
+	List(ctx context.Context, filter entity.CoinRequestFilter) ([]entity.CoinRequest, error)
+	// Accept pays the pending request id addressed to payerID and marks it
+	// accepted in one transaction, recording the transfer in the history.
+	// ErrorRequestNotPending is returned if it is resolved or expired
+	// already, ErrorInsufficientBalance if the payer can't afford it and
+	// ErrorLimitExceeded if paying would take the payer over one of limits.
+	Accept(ctx context.Context, id int64, payerID int, limits []entity.SpendingLimit) (*entity.CoinRequest, error)
+	// Decline turns down the pending request id addressed to payerID.
+	// ErrorRequestNotPending is returned if it is resolved or expired
+	// already.
+	Decline(ctx context.Context, id int64, payerID int) (*entity.CoinRequest, error)
+}
+
+// ScheduledTransferRepository stores the transfers run later by the service.
+type ScheduledTransferRepository interface {
+	Create(ctx context.Context, transfer entity.ScheduledTransfer) (*entity.ScheduledTransfer, error)
+	// List returns the transfers of senderID, newest first.
+	List(ctx context.Context, senderID int, limit int) ([]entity.ScheduledTransfer, error)
+	// Cancel stops the active transfer id of senderID. ErrorTransferNotActive
+	// is returned if it is no longer active.
+	Cancel(ctx context.Context, id int64, senderID int) (*entity.ScheduledTransfer, error)
+	// RunDue runs the transfer due the longest that no other replica is
+	// running and hands it to reschedule along with the failure of the run,
+	// such as ErrorInsufficientBalance or ErrorLimitExceeded against the
+	// limits of the sender. Nothing moves on a failed run. The transfer is
+	// stored as reschedule leaves it, nil is returned when no transfer is due.
+	RunDue(
+		ctx context.Context,
+		limits func(senderID int) ([]entity.SpendingLimit, error),
+		reschedule func(transfer *entity.ScheduledTransfer, failure error),
+	) (*entity.ScheduledTransfer, error)
 }
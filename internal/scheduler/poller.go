@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Poller is an app Worker running fn every interval on every replica, the
+// first time one interval after Start. Unlike the jobs of a Scheduler, fn is
+// expected to share the work with the other replicas itself. A run still in
+// progress is waited for on Stop.
+type Poller struct {
+	l        *zap.Logger
+	interval time.Duration
+	fn       func(ctx context.Context) error
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (p *Poller) Start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := p.fn(ctx); err != nil && ctx.Err() == nil {
+					p.l.Error("poll failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+func (p *Poller) Stop(ctx context.Context) error {
+	if p.cancel == nil {
+		return nil
+	}
+	p.cancel()
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func NewPoller(l *zap.Logger, name string, interval time.Duration, fn func(ctx context.Context) error) *Poller {
+	return &Poller{
+		l:        l.With(zap.String("worker", name)),
+		interval: interval,
+		fn:       fn,
+	}
+}
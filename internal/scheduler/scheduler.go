@@ -0,0 +1,218 @@
+// Package scheduler runs jobs on cron schedules. Every replica runs a
+// Scheduler but only the one holding the leadership, a Postgres advisory
+// lock, runs the jobs, and every run is claimed in the database first, so a
+// job runs once per due time across the deployment.
+package scheduler
+
+import (
+	"AvitoTech/internal/metrics"
+	"AvitoTech/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"time"
+)
+
+// leaderKey is the advisory lock of the leadership.
+const leaderKey int64 = 0x7363686564
+
+type job struct {
+	name     string
+	schedule cron.Schedule
+	run      func(ctx context.Context) error
+	// next is the due time of the next run, it is only known while leading.
+	next time.Time
+}
+
+// Scheduler is an app Worker. Every poll it takes or checks the leadership
+// and, while leading, runs the jobs that are due one after another.
+type Scheduler struct {
+	l *zap.Logger
+
+	leader repository.LeaderRepository
+	runs   repository.JobRunRepository
+
+	// replica names this instance in the job runs.
+	replica string
+	poll    time.Duration
+	now     func() time.Time
+
+	jobs  []*job
+	lease repository.Lease
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Add registers run under name with a standard five field cron spec or a
+// descriptor such as @daily or @every 1h. Jobs have to be added before Start.
+func (s *Scheduler) Add(name, spec string, run func(ctx context.Context) error) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", name, err)
+	}
+	s.jobs = append(s.jobs, &job{name: name, schedule: schedule, run: run})
+	return nil
+}
+
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		defer s.stepDown(context.WithoutCancel(ctx))
+		ticker := time.NewTicker(s.poll)
+		defer ticker.Stop()
+
+		for {
+			s.tick(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop waits for a run in progress and gives up the leadership.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) tick(ctx context.Context) {
+	if len(s.jobs) == 0 || !s.lead(ctx) {
+		return
+	}
+	for _, j := range s.jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		now := s.now()
+		if now.Before(j.next) {
+			continue
+		}
+		s.runJob(ctx, j, j.next)
+		j.next = j.schedule.Next(s.now())
+	}
+}
+
+// lead reports whether this replica leads, taking the leadership if it is
+// free and checking it is still held otherwise. The lease lives as long as
+// ctx, the one of the scheduler.
+func (s *Scheduler) lead(ctx context.Context) bool {
+	if s.lease != nil {
+		if err := s.lease.Alive(ctx); err != nil {
+			if ctx.Err() == nil {
+				s.l.Warn("scheduler leadership lost", zap.Error(err))
+			}
+			s.stepDown(context.WithoutCancel(ctx))
+			return false
+		}
+		return true
+	}
+
+	lease, err := s.leader.TryLead(ctx, leaderKey)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.l.Error("failed to take scheduler leadership", zap.Error(err))
+		}
+		return false
+	}
+	if lease == nil {
+		return false
+	}
+	s.lease = lease
+	metrics.SchedulerLeader.Set(1)
+	s.l.Info("scheduler leadership taken", zap.String("replica", s.replica))
+
+	for _, j := range s.jobs {
+		j.next = s.catchUp(ctx, j)
+	}
+	return true
+}
+
+// catchUp returns the first due time after the last run of j. A time missed
+// while no replica was leading is in the past, so the job runs once right
+// away however many times it was missed. A job that never ran waits for its
+// next due time.
+func (s *Scheduler) catchUp(ctx context.Context, j *job) time.Time {
+	last, err := s.runs.Last(ctx, j.name)
+	if err != nil {
+		s.l.Error("failed to find last job run", zap.String("job", j.name), zap.Error(err))
+	}
+	if last == nil {
+		return j.schedule.Next(s.now())
+	}
+	return j.schedule.Next(last.ScheduledAt)
+}
+
+func (s *Scheduler) stepDown(ctx context.Context) {
+	if s.lease == nil {
+		return
+	}
+	if err := s.lease.Release(ctx); err != nil {
+		s.l.Warn("failed to release scheduler leadership", zap.Error(err))
+	}
+	s.lease = nil
+	metrics.SchedulerLeader.Set(0)
+}
+
+// runJob claims the run of j due at scheduledAt and runs it unless another
+// replica already has.
+func (s *Scheduler) runJob(ctx context.Context, j *job, scheduledAt time.Time) {
+	l := s.l.With(zap.String("job", j.name), zap.Time("scheduled_at", scheduledAt))
+
+	run, err := s.runs.Start(ctx, j.name, scheduledAt, s.replica)
+	if err != nil {
+		if errors.Is(err, repository.ErrorJobRunClaimed) {
+			l.Info("job run already claimed")
+		} else {
+			l.Error("failed to claim job run", zap.Error(err))
+		}
+		return
+	}
+
+	status, runErr := "ok", ""
+	if err = j.run(ctx); err != nil {
+		status, runErr = "failed", err.Error()
+		l.Error("job run failed", zap.Error(err))
+	} else {
+		l.Info("job run finished")
+	}
+	metrics.JobRuns.WithLabelValues(j.name, status).Inc()
+
+	// the run has to be marked finished even if it was cut short by Stop
+	if err = s.runs.Finish(context.WithoutCancel(ctx), run.ID, runErr); err != nil {
+		l.Error("failed to finish job run", zap.Error(err))
+	}
+}
+
+func New(
+	l *zap.Logger,
+	leader repository.LeaderRepository,
+	runs repository.JobRunRepository,
+	replica string,
+	poll time.Duration,
+) *Scheduler {
+	return &Scheduler{
+		l:       l.With(zap.String("worker", "scheduler")),
+		leader:  leader,
+		runs:    runs,
+		replica: replica,
+		poll:    poll,
+		now:     time.Now,
+	}
+}
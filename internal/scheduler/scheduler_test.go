@@ -0,0 +1,174 @@
+package scheduler
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	mocks "AvitoTech/test/mock"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// at matches a time equal to t whatever its location.
+func at(t time.Time) any {
+	return mock.MatchedBy(func(v time.Time) bool { return v.Equal(t) })
+}
+
+func newTestScheduler(now time.Time) (*Scheduler, *mocks.MockLeaderRepository, *mocks.MockJobRunRepository) {
+	leader := new(mocks.MockLeaderRepository)
+	runs := new(mocks.MockJobRunRepository)
+	s := New(zap.NewNop(), leader, runs, "replica-1", time.Minute)
+	s.now = func() time.Time { return now }
+	return s, leader, runs
+}
+
+func TestScheduler_Add(t *testing.T) {
+	s, _, _ := newTestScheduler(time.Now())
+
+	assert.NoError(t, s.Add("daily", "@daily", nil))
+	assert.NoError(t, s.Add("monthly", "0 0 1 * *", nil))
+	assert.Error(t, s.Add("broken", "every day", nil))
+	assert.Len(t, s.jobs, 2)
+}
+
+func TestScheduler_FollowerDoesNotRun(t *testing.T) {
+	s, leader, runs := newTestScheduler(time.Now())
+	require.NoError(t, s.Add("job", "@every 1m", func(context.Context) error {
+		t.Fatal("follower ran a job")
+		return nil
+	}))
+
+	leader.On("TryLead", leaderKey).Return(nil, nil)
+
+	s.tick(context.Background())
+	s.tick(context.Background())
+	leader.AssertNumberOfCalls(t, "TryLead", 2)
+	runs.AssertExpectations(t)
+}
+
+func TestScheduler_CatchUp(t *testing.T) {
+	now := time.Date(2024, 1, 3, 12, 0, 0, 0, time.Local)
+	s, leader, runs := newTestScheduler(now)
+
+	var ran int
+	require.NoError(t, s.Add("job", "@daily", func(context.Context) error {
+		ran++
+		return nil
+	}))
+
+	lease := new(mocks.MockLease)
+	leader.On("TryLead", leaderKey).Return(lease, nil).Once()
+	lease.On("Alive").Return(nil)
+
+	// the last run was due on the 1st, the ones of the 2nd and the 3rd were
+	// missed and are caught up with a single run
+	runs.On("Last", "job").Return(&entity.JobRun{ScheduledAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)}, nil)
+	runs.On("Start", "job", at(time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)), "replica-1").
+		Return(&entity.JobRun{ID: 7}, nil).Once()
+	runs.On("Finish", int64(7), "").Return(nil).Once()
+
+	s.tick(context.Background())
+	assert.Equal(t, 1, ran)
+	assert.True(t, s.jobs[0].next.Equal(time.Date(2024, 1, 4, 0, 0, 0, 0, time.Local)))
+
+	// nothing is due until tomorrow
+	s.tick(context.Background())
+	assert.Equal(t, 1, ran)
+	runs.AssertExpectations(t)
+}
+
+func TestScheduler_NeverRunJobWaits(t *testing.T) {
+	now := time.Date(2024, 1, 3, 12, 0, 0, 0, time.Local)
+	s, leader, runs := newTestScheduler(now)
+	require.NoError(t, s.Add("job", "@daily", func(context.Context) error {
+		t.Fatal("job ran before it was due")
+		return nil
+	}))
+
+	leader.On("TryLead", leaderKey).Return(new(mocks.MockLease), nil).Once()
+	runs.On("Last", "job").Return(nil, nil)
+
+	s.tick(context.Background())
+	assert.True(t, s.jobs[0].next.Equal(time.Date(2024, 1, 4, 0, 0, 0, 0, time.Local)))
+}
+
+func TestScheduler_RunOutcomes(t *testing.T) {
+	now := time.Date(2024, 1, 3, 12, 0, 0, 0, time.Local)
+	due := time.Date(2024, 1, 3, 0, 0, 0, 0, time.Local)
+	last := &entity.JobRun{ScheduledAt: due.AddDate(0, 0, -1)}
+
+	t.Run("failed", func(t *testing.T) {
+		s, leader, runs := newTestScheduler(now)
+		require.NoError(t, s.Add("job", "@daily", func(context.Context) error {
+			return errors.New("boom")
+		}))
+
+		leader.On("TryLead", leaderKey).Return(new(mocks.MockLease), nil).Once()
+		runs.On("Last", "job").Return(last, nil)
+		runs.On("Start", "job", at(due), "replica-1").Return(&entity.JobRun{ID: 3}, nil)
+		runs.On("Finish", int64(3), "boom").Return(nil).Once()
+
+		s.tick(context.Background())
+		runs.AssertExpectations(t)
+	})
+
+	t.Run("claimed", func(t *testing.T) {
+		s, leader, runs := newTestScheduler(now)
+		require.NoError(t, s.Add("job", "@daily", func(context.Context) error {
+			t.Fatal("ran a job claimed by another replica")
+			return nil
+		}))
+
+		leader.On("TryLead", leaderKey).Return(new(mocks.MockLease), nil).Once()
+		runs.On("Last", "job").Return(last, nil)
+		runs.On("Start", "job", at(due), "replica-1").Return(nil, repository.ErrorJobRunClaimed)
+
+		s.tick(context.Background())
+		runs.AssertNotCalled(t, "Finish", mock.Anything, mock.Anything)
+	})
+}
+
+func TestScheduler_StepsDownOnLostLease(t *testing.T) {
+	s, leader, runs := newTestScheduler(time.Now())
+	require.NoError(t, s.Add("job", "@daily", func(context.Context) error { return nil }))
+
+	lease := new(mocks.MockLease)
+	leader.On("TryLead", leaderKey).Return(lease, nil).Once()
+	runs.On("Last", "job").Return(nil, nil)
+	lease.On("Alive").Return(errors.New("connection reset"))
+	lease.On("Release").Return(nil).Once()
+
+	s.tick(context.Background())
+	require.NotNil(t, s.lease)
+
+	s.tick(context.Background())
+	assert.Nil(t, s.lease)
+	lease.AssertExpectations(t)
+
+	// the leadership is sought again on the next poll
+	leader.On("TryLead", leaderKey).Return(nil, nil).Once()
+	s.tick(context.Background())
+	leader.AssertExpectations(t)
+}
+
+func TestScheduler_StopReleasesLease(t *testing.T) {
+	s, leader, runs := newTestScheduler(time.Now())
+	require.NoError(t, s.Add("job", "@daily", func(context.Context) error { return nil }))
+
+	lease := new(mocks.MockLease)
+	taken := make(chan struct{})
+	leader.On("TryLead", leaderKey).Return(lease, nil).Once().Run(func(mock.Arguments) { close(taken) })
+	runs.On("Last", "job").Return(nil, nil)
+	lease.On("Release").Return(nil).Once()
+
+	s.Start(context.Background())
+	<-taken
+	require.NoError(t, s.Stop(context.Background()))
+	lease.AssertExpectations(t)
+}
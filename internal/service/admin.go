@@ -0,0 +1,215 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/event"
+	"AvitoTech/internal/logging"
+	"AvitoTech/internal/metrics"
+	"AvitoTech/internal/repository"
+	"AvitoTech/internal/tracing"
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"strings"
+)
+
+var (
+	ErrInvalidRole    = errors.New("unknown role")
+	ErrReasonRequired = errors.New("reason is required")
+	ErrInvalidAmount  = errors.New("amount must not be zero")
+	ErrNoUsers        = errors.New("no users given")
+	ErrSelfAction     = errors.New("operators can't disable or delete themselves")
+)
+
+// MaxUserPageSize bounds UserFilter.Limit in ListUsers.
+const MaxUserPageSize = 100
+
+// AdminService holds the operator actions that are not part of signing in.
+type AdminService struct {
+	l *zap.Logger
+
+	userRepository repository.UserRepository
+	adjustments    repository.AdjustmentRepository
+
+	events event.Publisher
+	audit  AuditRecorder
+}
+
+// SetRole gives the user username role on behalf of actor. The user has to
+// sign in again for the new role to take effect.
+func (a AdminService) SetRole(ctx context.Context, username string, role entity.Role, actor string) (err error) {
+	ctx, span := tracing.Start(ctx, "AdminService.SetRole")
+	defer tracing.End(span, &err)
+
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+
+	user, err := a.userRepository.SetRole(ctx, username, role)
+	if err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx, a.l).Warn("role changed",
+		zap.String("username", user.Username),
+		zap.String("role", string(user.Role)),
+		zap.String("by", actor),
+	)
+	a.audit.Record(ctx, actor, entity.AuditSetRole, user.Username, map[string]any{"role": user.Role})
+	return nil
+}
+
+// Bootstrap makes admins of the listed users, so that a fresh deployment has
+// someone to hand out roles. Users that haven't signed up yet are skipped.
+// Promotions are recorded on behalf of the system account.
+func (a AdminService) Bootstrap(ctx context.Context, usernames []string) error {
+	for _, username := range usernames {
+		user, err := a.userRepository.FindUserByUsername(ctx, username)
+		if errors.Is(err, repository.ErrorUserNotFound) {
+			a.l.Warn("admin has not signed up yet", zap.String("username", username))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if user.Role == entity.RoleAdmin {
+			continue
+		}
+
+		if _, err = a.userRepository.SetRole(ctx, username, entity.RoleAdmin); err != nil {
+			return err
+		}
+		a.audit.Record(ctx, entity.SystemAccount, entity.AuditSetRole, username, map[string]any{"role": entity.RoleAdmin})
+	}
+	return nil
+}
+
+// AdjustBalances grants or, for a negative amount, deducts coins from every
+// user of adjustment on behalf of actor. Either all balances change or none.
+func (a AdminService) AdjustBalances(ctx context.Context, adjustment entity.Adjustment, actor string) (err error) {
+	ctx, span := tracing.Start(ctx, "AdminService.AdjustBalances")
+	defer tracing.End(span, &err)
+
+	adjustment.Reason = strings.TrimSpace(adjustment.Reason)
+	switch {
+	case adjustment.Reason == "":
+		return ErrReasonRequired
+	case adjustment.Amount == 0:
+		return ErrInvalidAmount
+	case len(adjustment.Usernames) == 0:
+		return ErrNoUsers
+	}
+	adjustment.Usernames = unique(adjustment.Usernames)
+
+	ids, err := a.adjustments.Apply(ctx, adjustment, actor)
+	if err != nil {
+		return err
+	}
+	a.events.Publish(ctx, event.BalanceChanged{UserIDs: ids})
+
+	direction, amount := "grant", adjustment.Amount
+	if amount < 0 {
+		direction, amount = "deduct", -amount
+	}
+	metrics.CoinsAdjusted.WithLabelValues(direction).Add(float64(amount * len(ids)))
+
+	logging.FromContext(ctx, a.l).Warn("balances adjusted",
+		zap.Strings("usernames", adjustment.Usernames),
+		zap.Int("amount", adjustment.Amount),
+		zap.String("reason", adjustment.Reason),
+		zap.String("by", actor),
+	)
+	return nil
+}
+
+// ListUsers returns a page of the users selected by filter and how many match
+// it overall. The limit is clamped to 1..MaxUserPageSize.
+func (a AdminService) ListUsers(ctx context.Context, filter entity.UserFilter) (_ []entity.User, _ int, err error) {
+	ctx, span := tracing.Start(ctx, "AdminService.ListUsers")
+	defer tracing.End(span, &err)
+
+	filter.Limit = min(max(filter.Limit, 1), MaxUserPageSize)
+	filter.Offset = max(filter.Offset, 0)
+	return a.userRepository.SearchUsers(ctx, filter)
+}
+
+// GetUser returns the user username, deleted ones included.
+func (a AdminService) GetUser(ctx context.Context, username string) (_ *entity.User, err error) {
+	ctx, span := tracing.Start(ctx, "AdminService.GetUser")
+	defer tracing.End(span, &err)
+
+	return a.userRepository.FindUserByUsername(ctx, username)
+}
+
+// SetDisabled bans the user username from signing in and from the API, or
+// lifts the ban, on behalf of actor.
+func (a AdminService) SetDisabled(ctx context.Context, username string, disabled bool, actor string) (err error) {
+	ctx, span := tracing.Start(ctx, "AdminService.SetDisabled")
+	defer tracing.End(span, &err)
+
+	if username == actor {
+		return ErrSelfAction
+	}
+	if _, err = a.userRepository.SetDisabled(ctx, username, disabled); err != nil {
+		return err
+	}
+	action := entity.AuditEnable
+	if disabled {
+		action = entity.AuditDisable
+	}
+	a.audit.Record(ctx, actor, action, username, nil)
+
+	logging.FromContext(ctx, a.l).Warn("user disabled state changed",
+		zap.String("username", username),
+		zap.Bool("disabled", disabled),
+		zap.String("by", actor),
+	)
+	return nil
+}
+
+// DeleteUser deletes the user username on behalf of actor. The history and the
+// row of the user are kept, the username can't be signed up again.
+func (a AdminService) DeleteUser(ctx context.Context, username, actor string) (err error) {
+	ctx, span := tracing.Start(ctx, "AdminService.DeleteUser")
+	defer tracing.End(span, &err)
+
+	if username == actor {
+		return ErrSelfAction
+	}
+	if _, err = a.userRepository.DeleteUser(ctx, username); err != nil {
+		return err
+	}
+	a.audit.Record(ctx, actor, entity.AuditDelete, username, nil)
+
+	logging.FromContext(ctx, a.l).Warn("user deleted", zap.String("username", username), zap.String("by", actor))
+	return nil
+}
+
+// unique drops the repeated values of s, keeping the order of the rest.
+func unique(s []string) []string {
+	seen := make(map[string]bool, len(s))
+	res := make([]string, 0, len(s))
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func NewAdminService(
+	l *zap.Logger,
+	u repository.UserRepository,
+	adjustments repository.AdjustmentRepository,
+	e event.Publisher,
+	audit AuditRecorder,
+) Admin {
+	return &AdminService{
+		l:              l,
+		userRepository: u,
+		adjustments:    adjustments,
+		events:         e,
+		audit:          audit,
+	}
+}
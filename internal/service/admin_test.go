@@ -0,0 +1,160 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/event"
+	"AvitoTech/internal/repository"
+	mocks "AvitoTech/test/mock"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+func TestAdminService_SetRole(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+
+	adminService := NewAdminService(logger, mockUserRepo, new(mocks.MockAdjustmentRepository), event.NewBus(), new(mocks.AuditLog))
+
+	mockUserRepo.On("SetRole", "someone", entity.RoleAuditor).
+		Return(&entity.User{ID: 1, Username: "someone", Role: entity.RoleAuditor}, nil)
+	mockUserRepo.On("SetRole", "nobody", entity.RoleAuditor).Return(nil, repository.ErrorUserNotFound)
+
+	assert.NoError(t, adminService.SetRole(context.Background(), "someone", entity.RoleAuditor, "root"))
+	assert.ErrorIs(t, adminService.SetRole(context.Background(), "nobody", entity.RoleAuditor, "root"), repository.ErrorUserNotFound)
+	assert.ErrorIs(t, adminService.SetRole(context.Background(), "someone", "superuser", "root"), ErrInvalidRole)
+
+	mockUserRepo.AssertExpectations(t)
+	mockUserRepo.AssertNotCalled(t, "SetRole", mock.Anything, entity.Role("superuser"))
+}
+
+func TestAdminService_Bootstrap(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+
+	audit := new(mocks.AuditLog)
+	adminService := NewAdminService(logger, mockUserRepo, new(mocks.MockAdjustmentRepository), event.NewBus(), audit)
+
+	mockUserRepo.On("FindUserByUsername", "root").Return(&entity.User{ID: 1, Username: "root", Role: entity.RoleUser}, nil)
+	mockUserRepo.On("SetRole", "root", entity.RoleAdmin).Return(&entity.User{ID: 1, Username: "root", Role: entity.RoleAdmin}, nil)
+	mockUserRepo.On("FindUserByUsername", "ops").Return(&entity.User{ID: 2, Username: "ops", Role: entity.RoleAdmin}, nil)
+	mockUserRepo.On("FindUserByUsername", "later").Return(&entity.User{}, repository.ErrorUserNotFound)
+
+	// users that haven't signed up yet don't stop the startup, admins already
+	// are left alone
+	assert.NoError(t, adminService.Bootstrap(context.Background(), []string{"root", "ops", "later"}))
+	mockUserRepo.AssertExpectations(t)
+	mockUserRepo.AssertNotCalled(t, "SetRole", "ops", mock.Anything)
+	assert.Equal(t, []entity.AuditEntry{{
+		Actor:   entity.SystemAccount,
+		Action:  entity.AuditSetRole,
+		Target:  "root",
+		Payload: []byte(`{"role":"admin"}`),
+	}}, audit.Entries())
+
+	mockUserRepo.On("FindUserByUsername", "broken").Return(&entity.User{}, errors.New("connection refused"))
+	assert.Error(t, adminService.Bootstrap(context.Background(), []string{"broken"}))
+}
+
+func TestAdminService_AdjustBalances(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockAdjustments := new(mocks.MockAdjustmentRepository)
+	bus := event.NewBus()
+
+	var changed []int
+	bus.Subscribe(func(_ context.Context, e any) {
+		changed = e.(event.BalanceChanged).UserIDs
+	})
+
+	audit := new(mocks.AuditLog)
+	adminService := NewAdminService(logger, new(mocks.MockUserRepository), mockAdjustments, bus, audit)
+
+	// the reason is trimmed and repeated usernames are adjusted once
+	mockAdjustments.On("Apply", entity.Adjustment{
+		Usernames: []string{"alice", "bob"},
+		Amount:    -50,
+		Reason:    "refund reversal",
+	}, "root").Return([]int{1, 2}, nil)
+
+	err := adminService.AdjustBalances(context.Background(), entity.Adjustment{
+		Usernames: []string{"alice", "bob", "alice"},
+		Amount:    -50,
+		Reason:    "  refund reversal ",
+	}, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, changed)
+	mockAdjustments.AssertExpectations(t)
+
+	// the entries are appended by the repository, with the adjustment
+	assert.Empty(t, audit.Entries())
+}
+
+func TestAdminService_AdjustBalances_Invalid(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockAdjustments := new(mocks.MockAdjustmentRepository)
+
+	adminService := NewAdminService(logger, new(mocks.MockUserRepository), mockAdjustments, event.NewBus(), new(mocks.AuditLog))
+
+	for adjustment, want := range map[*entity.Adjustment]error{
+		{Usernames: []string{"alice"}, Amount: 10, Reason: " "}:   ErrReasonRequired,
+		{Usernames: []string{"alice"}, Amount: 0, Reason: "gift"}: ErrInvalidAmount,
+		{Amount: 10, Reason: "gift"}:                              ErrNoUsers,
+	} {
+		assert.ErrorIs(t, adminService.AdjustBalances(context.Background(), *adjustment, "root"), want)
+	}
+
+	mockAdjustments.On("Apply", mock.Anything, "root").Return(nil, repository.ErrorInsufficientBalance)
+	err := adminService.AdjustBalances(context.Background(), entity.Adjustment{Usernames: []string{"alice"}, Amount: -10, Reason: "fine"}, "root")
+	assert.ErrorIs(t, err, repository.ErrorInsufficientBalance)
+}
+
+func TestAdminService_ListUsers(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+
+	adminService := NewAdminService(logger, mockUserRepo, new(mocks.MockAdjustmentRepository), event.NewBus(), new(mocks.AuditLog))
+
+	users := []entity.User{{ID: 1, Username: "alice"}}
+	mockUserRepo.On("SearchUsers", entity.UserFilter{Prefix: "al", Limit: MaxUserPageSize}).Return(users, 1, nil)
+	mockUserRepo.On("SearchUsers", entity.UserFilter{Prefix: "al", Limit: 1, Offset: 0}).Return(users, 1, nil)
+
+	// the page size is clamped, negative offsets start at the beginning
+	got, total, err := adminService.ListUsers(context.Background(), entity.UserFilter{Prefix: "al", Limit: 1000})
+	assert.NoError(t, err)
+	assert.Equal(t, users, got)
+	assert.Equal(t, 1, total)
+
+	_, _, err = adminService.ListUsers(context.Background(), entity.UserFilter{Prefix: "al", Limit: 0, Offset: -5})
+	assert.NoError(t, err)
+
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestAdminService_SetDisabledAndDelete(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+
+	audit := new(mocks.AuditLog)
+	adminService := NewAdminService(logger, mockUserRepo, new(mocks.MockAdjustmentRepository), event.NewBus(), audit)
+
+	mockUserRepo.On("SetDisabled", "spammer", true).Return(&entity.User{ID: 2, Username: "spammer"}, nil)
+	mockUserRepo.On("DeleteUser", "spammer").Return(&entity.User{ID: 2, Username: "spammer"}, nil)
+	mockUserRepo.On("DeleteUser", "nobody").Return(nil, repository.ErrorUserNotFound)
+
+	assert.NoError(t, adminService.SetDisabled(context.Background(), "spammer", true, "root"))
+	assert.NoError(t, adminService.DeleteUser(context.Background(), "spammer", "root"))
+	assert.ErrorIs(t, adminService.DeleteUser(context.Background(), "nobody", "root"), repository.ErrorUserNotFound)
+
+	// operators can't lock themselves out
+	assert.ErrorIs(t, adminService.SetDisabled(context.Background(), "root", true, "root"), ErrSelfAction)
+	assert.ErrorIs(t, adminService.DeleteUser(context.Background(), "root", "root"), ErrSelfAction)
+
+	mockUserRepo.AssertExpectations(t)
+	// refused actions are not recorded
+	assert.Equal(t, []string{entity.AuditDisable, entity.AuditDelete}, audit.Actions())
+}
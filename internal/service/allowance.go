@@ -0,0 +1,92 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/event"
+	"AvitoTech/internal/logging"
+	"AvitoTech/internal/metrics"
+	"AvitoTech/internal/repository"
+	"AvitoTech/internal/tracing"
+	"context"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	allowanceReason = "periodic allowance"
+	expiryReason    = "expired coins"
+)
+
+// AllowanceService runs the periodic jobs of the coin economy: the allowance
+// topping up every active user and the expiry of coins left unspent for too
+// long.
+type AllowanceService struct {
+	l *zap.Logger
+
+	allowance repository.AllowanceRepository
+
+	// amount is the allowance granted per run.
+	amount int
+	// months is the age at which unspent coins expire.
+	months int
+
+	events event.Publisher
+}
+
+// TopUp grants the allowance to every user neither disabled nor deleted.
+func (a AllowanceService) TopUp(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "AllowanceService.TopUp")
+	defer tracing.End(span, &err)
+
+	// a failed run may still have granted some batches of users
+	ids, err := a.allowance.Grant(ctx, a.amount, allowanceReason)
+	if len(ids) == 0 {
+		return err
+	}
+	metrics.CoinsAllowance.Add(float64(a.amount * len(ids)))
+	a.events.Publish(ctx, event.BalanceChanged{UserIDs: ids})
+
+	logging.FromContext(ctx, a.l).Info("allowance granted",
+		zap.Int("amount", a.amount),
+		zap.Int("users", len(ids)),
+	)
+	return err
+}
+
+// Expire takes away the coins received more than the configured number of
+// months ago that are still unspent.
+func (a AllowanceService) Expire(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "AllowanceService.Expire")
+	defer tracing.End(span, &err)
+
+	cutoff := time.Now().AddDate(0, -a.months, 0)
+	ids, total, err := a.allowance.Expire(ctx, cutoff, entity.Items, expiryReason)
+	if len(ids) == 0 {
+		return err
+	}
+	metrics.CoinsExpired.Add(float64(total))
+	a.events.Publish(ctx, event.BalanceChanged{UserIDs: ids})
+
+	logging.FromContext(ctx, a.l).Info("coins expired",
+		zap.Time("cutoff", cutoff),
+		zap.Int("coins", total),
+		zap.Int("users", len(ids)),
+	)
+	return err
+}
+
+func NewAllowanceService(
+	l *zap.Logger,
+	allowance repository.AllowanceRepository,
+	amount int,
+	months int,
+	e event.Publisher,
+) Allowance {
+	return &AllowanceService{
+		l:         l,
+		allowance: allowance,
+		amount:    amount,
+		months:    months,
+		events:    e,
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/event"
+	"AvitoTech/internal/metrics"
+	mocks "AvitoTech/test/mock"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAllowanceService_TopUp(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockAllowance := new(mocks.MockAllowanceRepository)
+	bus := event.NewBus()
+
+	var changed []int
+	bus.Subscribe(func(_ context.Context, e any) {
+		changed = e.(event.BalanceChanged).UserIDs
+	})
+
+	allowanceService := NewAllowanceService(logger, mockAllowance, 25, 6, bus)
+
+	granted := testutil.ToFloat64(metrics.CoinsAllowance)
+	mockAllowance.On("Grant", 25, "periodic allowance").Return([]int{1, 2}, nil).Once()
+
+	require.NoError(t, allowanceService.TopUp(context.Background()))
+	assert.Equal(t, []int{1, 2}, changed)
+	assert.Equal(t, granted+50, testutil.ToFloat64(metrics.CoinsAllowance))
+
+	mockAllowance.On("Grant", 25, "periodic allowance").Return(nil, errors.New("db down")).Once()
+	assert.Error(t, allowanceService.TopUp(context.Background()))
+	assert.Equal(t, granted+50, testutil.ToFloat64(metrics.CoinsAllowance))
+
+	// the batches granted before a failure are still announced
+	mockAllowance.On("Grant", 25, "periodic allowance").Return([]int{3}, errors.New("db down")).Once()
+	assert.Error(t, allowanceService.TopUp(context.Background()))
+	assert.Equal(t, []int{3}, changed)
+	mockAllowance.AssertExpectations(t)
+}
+
+func TestAllowanceService_Expire(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockAllowance := new(mocks.MockAllowanceRepository)
+
+	allowanceService := NewAllowanceService(logger, mockAllowance, 0, 6, event.NewBus())
+
+	// the cutoff is six months back from now
+	earliest := time.Now().AddDate(0, -6, 0)
+	cutoffInRange := mock.MatchedBy(func(cutoff time.Time) bool {
+		return !cutoff.Before(earliest) && cutoff.Before(time.Now().AddDate(0, -6, 0).Add(time.Second))
+	})
+
+	expired := testutil.ToFloat64(metrics.CoinsExpired)
+	mockAllowance.On("Expire", cutoffInRange, entity.Items, "expired coins").Return([]int{3}, 70, nil).Once()
+
+	require.NoError(t, allowanceService.Expire(context.Background()))
+	assert.Equal(t, expired+70, testutil.ToFloat64(metrics.CoinsExpired))
+
+	mockAllowance.On("Expire", cutoffInRange, entity.Items, "expired coins").Return(nil, 0, nil).Once()
+	require.NoError(t, allowanceService.Expire(context.Background()))
+	assert.Equal(t, expired+70, testutil.ToFloat64(metrics.CoinsExpired))
+	mockAllowance.AssertExpectations(t)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/logging"
+	"AvitoTech/internal/metrics"
+	"AvitoTech/internal/repository"
+	"AvitoTech/internal/tracing"
+	"context"
+	"encoding/json"
+	"errors"
+	"go.uber.org/zap"
+)
+
+// MaxAuditPageSize bounds AuditFilter.Limit in List.
+const MaxAuditPageSize = 500
+
+// errChainBroken stops the walk over the audit log at the first bad entry.
+var errChainBroken = errors.New("audit chain broken")
+
+type AuditService struct {
+	l     *zap.Logger
+	audit repository.AuditRepository
+}
+
+// Record appends an entry to the audit log. payload is stored as JSON. A
+// failure is logged and counted but not returned.
+func (a AuditService) Record(ctx context.Context, actor, action, target string, payload any) {
+	l := logging.FromContext(ctx, a.l)
+
+	raw := []byte("{}")
+	if payload != nil {
+		var err error
+		if raw, err = json.Marshal(payload); err != nil {
+			l.Error("failed to encode audit payload", zap.String("action", action), zap.Error(err))
+			metrics.AuditFailures.Inc()
+			return
+		}
+	}
+
+	// the action is done, a cancelled request must not drop its record
+	_, err := a.audit.Append(context.WithoutCancel(ctx), entity.AuditEntry{
+		Actor:   actor,
+		Action:  action,
+		Target:  target,
+		Payload: raw,
+	})
+	if err != nil {
+		l.Error("failed to write audit log",
+			zap.String("actor", actor),
+			zap.String("action", action),
+			zap.String("target", target),
+			zap.Error(err),
+		)
+		metrics.AuditFailures.Inc()
+	}
+}
+
+// List returns the entries selected by filter, newest first. The limit is
+// clamped to 1..MaxAuditPageSize.
+func (a AuditService) List(ctx context.Context, filter entity.AuditFilter) (_ []entity.AuditEntry, err error) {
+	ctx, span := tracing.Start(ctx, "AuditService.List")
+	defer tracing.End(span, &err)
+
+	filter.Limit = min(max(filter.Limit, 1), MaxAuditPageSize)
+	filter.BeforeID = max(filter.BeforeID, 0)
+	return a.audit.List(ctx, filter)
+}
+
+// Verify walks the whole audit log and checks every entry against its hash
+// and its predecessor. It stops at the first entry that doesn't match.
+func (a AuditService) Verify(ctx context.Context) (res entity.AuditVerification, err error) {
+	ctx, span := tracing.Start(ctx, "AuditService.Verify")
+	defer tracing.End(span, &err)
+
+	prev := ""
+	err = a.audit.Walk(ctx, func(entry entity.AuditEntry) error {
+		if entry.PrevHash != prev || entry.ComputeHash() != entry.Hash {
+			res.BrokenAt = entry.ID
+			return errChainBroken
+		}
+		res.Entries++
+		prev = entry.Hash
+		return nil
+	})
+	if err != nil && !errors.Is(err, errChainBroken) {
+		return entity.AuditVerification{}, err
+	}
+
+	if res.BrokenAt != 0 {
+		logging.FromContext(ctx, a.l).Error("audit log tampered with",
+			zap.Int64("broken at", res.BrokenAt),
+			zap.Int64("intact entries", res.Entries),
+		)
+	}
+	return res, nil
+}
+
+func NewAuditService(
+	l *zap.Logger,
+	audit repository.AuditRepository,
+) Audit {
+	return &AuditService{
+		l:     l,
+		audit: audit,
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/metrics"
+	mocks "AvitoTech/test/mock"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// chain links entries the way the repository does.
+func chain(entries ...entity.AuditEntry) []entity.AuditEntry {
+	prev := ""
+	for i := range entries {
+		entries[i].ID = int64(i + 1)
+		entries[i].CreatedAt = time.Date(2025, 1, 1, 0, 0, i, 0, time.UTC)
+		entries[i].PrevHash = prev
+		entries[i].Hash = entries[i].ComputeHash()
+		prev = entries[i].Hash
+	}
+	return entries
+}
+
+func TestAuditService_Record(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockAudit := new(mocks.MockAuditRepository)
+
+	auditService := NewAuditService(logger, mockAudit)
+
+	mockAudit.On("Append", entity.AuditEntry{
+		Actor:   "root",
+		Action:  entity.AuditDisable,
+		Target:  "spammer",
+		Payload: []byte(`{"reason":"spam"}`),
+	}).Return(&entity.AuditEntry{ID: 1}, nil)
+	mockAudit.On("Append", mock.MatchedBy(func(e entity.AuditEntry) bool { return e.Action == entity.AuditEnable })).
+		Return(nil, errors.New("connection refused"))
+
+	failures := testutil.ToFloat64(metrics.AuditFailures)
+
+	auditService.Record(context.Background(), "root", entity.AuditDisable, "spammer", map[string]string{"reason": "spam"})
+	// a failure is only counted, the caller carries on
+	auditService.Record(context.Background(), "root", entity.AuditEnable, "spammer", nil)
+
+	assert.Equal(t, failures+1, testutil.ToFloat64(metrics.AuditFailures))
+	mockAudit.AssertExpectations(t)
+}
+
+func TestAuditService_Verify(t *testing.T) {
+	logger, _ := zap.NewProduction()
+
+	entries := chain(
+		entity.AuditEntry{Actor: "root", Action: entity.AuditGrant, Target: "alice", Payload: []byte(`{"amount":10}`)},
+		entity.AuditEntry{Actor: "alice", Action: entity.AuditTransfer, Target: "bob", Payload: []byte(`{"amount":5}`)},
+		entity.AuditEntry{Actor: "root", Action: entity.AuditDelete, Target: "bob", Payload: []byte(`{}`)},
+	)
+
+	intact := new(mocks.MockAuditRepository)
+	intact.On("Walk").Return(entries, nil)
+	res, err := NewAuditService(logger, intact).Verify(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, entity.AuditVerification{Entries: 3}, res)
+
+	// an edited payload no longer matches its hash
+	edited := append([]entity.AuditEntry(nil), entries...)
+	edited[1].Payload = []byte(`{"amount":500}`)
+	tampered := new(mocks.MockAuditRepository)
+	tampered.On("Walk").Return(edited, nil)
+	res, err = NewAuditService(logger, tampered).Verify(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, entity.AuditVerification{Entries: 1, BrokenAt: 2}, res)
+
+	// a removed entry breaks the link of the next one
+	removed := new(mocks.MockAuditRepository)
+	removed.On("Walk").Return([]entity.AuditEntry{entries[0], entries[2]}, nil)
+	res, err = NewAuditService(logger, removed).Verify(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, entity.AuditVerification{Entries: 1, BrokenAt: 3}, res)
+
+	failing := new(mocks.MockAuditRepository)
+	failing.On("Walk").Return(nil, errors.New("connection refused"))
+	_, err = NewAuditService(logger, failing).Verify(context.Background())
+	assert.Error(t, err)
+}
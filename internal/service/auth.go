@@ -3,95 +3,330 @@ package service
 
 import (
 	"AvitoTech/internal/entity"
+	"AvitoTech/internal/logging"
+	"AvitoTech/internal/metrics"
 	"AvitoTech/internal/repository"
+	"AvitoTech/internal/tracing"
+	"context"
 	"errors"
+	"fmt"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
+	"time"
 )
 
 var (
 	ErrUnauthorized     = errors.New("unauthorized")
 	ErrUserAlreadyExist = errors.New("user already exist")
+	ErrAccountLocked    = errors.New("account temporarily locked")
+	ErrNotLocked        = errors.New("account is not locked")
+	ErrTokenRevoked     = fmt.Errorf("%w: token revoked", ErrUnauthorized)
+	ErrAccountDisabled  = errors.New("account disabled")
 )
 
+// AccountLockedError is returned while signing in is banned for a username.
+// It matches ErrAccountLocked.
+type AccountLockedError struct {
+	Until time.Time
+}
+
+func (e *AccountLockedError) Error() string {
+	return fmt.Sprintf("%s until %s", ErrAccountLocked, e.Until.Format(time.RFC3339))
+}
+
+func (e *AccountLockedError) Unwrap() error {
+	return ErrAccountLocked
+}
+
+// LoginPolicy is the brute-force protection of Authenticate.
+type LoginPolicy struct {
+	// MaxFailures wrong passwords within Window lock the username for
+	// LockoutDuration. Zero disables lockouts.
+	MaxFailures     int
+	Window          time.Duration
+	LockoutDuration time.Duration
+	// FailureDelay holds back the answer after a recent failure for the
+	// username or the client IP. It doubles with every further failure, up
+	// to MaxDelay.
+	FailureDelay time.Duration
+	MaxDelay     time.Duration
+}
+
+func (p LoginPolicy) delay(failures int) time.Duration {
+	if failures == 0 || p.FailureDelay <= 0 {
+		return 0
+	}
+	d := p.FailureDelay << min(failures-1, 16)
+	return min(d, p.MaxDelay)
+}
+
 type AuthService struct {
 	l              *zap.Logger
 	jwtService     Token
 	userRepository repository.UserRepository
+	attempts       repository.LoginAttemptRepository
+	resets         repository.PasswordResetRepository
+	policy         LoginPolicy
+	passwords      PasswordPolicy
+	signup         SignupPolicy
+	audit          AuditRecorder
 }
 
-func (a AuthService) createUser(username, password string) (*entity.User, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+func (a AuthService) createUser(ctx context.Context, username, password string) (_ *entity.User, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.createUser")
+	defer tracing.End(span, &err)
+	l := logging.FromContext(ctx, a.l)
+
+	if len(password) < a.passwords.MinLength {
+		return nil, ErrWeakPassword
+	}
+
+	_, hashSpan := tracing.Start(ctx, "bcrypt.GenerateFromPassword")
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), a.passwords.Cost)
+	hashSpan.End()
 	if err != nil {
-		a.l.Error("failed to hash password", zap.Error(err))
+		l.Error("failed to hash password", zap.Error(err))
 		return nil, err
 	}
 
+	grant := a.signup.At(time.Now())
 	user := &entity.User{
 		Username: username,
 		Password: string(hashedPassword),
-		Balance:  1000,
+		Balance:  grant.Balance,
 	}
 
-	user, err = a.userRepository.InsertUser(user)
+	user, err = a.userRepository.Signup(ctx, user, grant.Items)
 	if err != nil {
-		a.l.Error("failed to insert user", zap.Error(err))
+		l.Error("failed to insert user", zap.Error(err))
 		return nil, err
 	}
+	metrics.Signups.Inc()
+	if grant.Campaign != "" {
+		l.Info("signup campaign applied", zap.String("username", username), zap.String("campaign", grant.Campaign))
+	}
+	a.audit.Record(ctx, username, entity.AuditSignup, username, map[string]any{
+		"balance":  grant.Balance,
+		"items":    grant.Items,
+		"campaign": grant.Campaign,
+	})
 
 	return user, nil
 }
 
-// Authenticate returns token associated with user
-func (a AuthService) Authenticate(username, password string) (string, error) {
-	user, err := a.userRepository.FindUserByUsername(username)
+// Authenticate returns token associated with user. ip is the address the
+// request came from, failures are tracked per username and per ip.
+func (a AuthService) Authenticate(ctx context.Context, username, password, ip string) (token string, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Authenticate")
+	defer tracing.End(span, &err)
+	l := logging.FromContext(ctx, a.l)
+
+	// the system account only stands for grants and deductions in the history
+	if username == entity.SystemAccount {
+		return "", fmt.Errorf("%w: reserved username", ErrUnauthorized)
+	}
+
+	user, err := a.userRepository.FindUserByUsername(ctx, username)
 
 	if errors.Is(err, repository.ErrorUserNotFound) {
-		user, err = a.createUser(username, password)
+		user, err = a.createUser(ctx, username, password)
 		if err != nil {
 			return "", err
 		}
 
-		token, err := a.jwtService.GenerateToken(user.ID)
+		token, err = a.jwtService.GenerateToken(claimsOf(user))
 		if err != nil {
-			a.l.Error("failed to generate token", zap.Error(err))
+			l.Error("failed to generate token", zap.Error(err))
 			return "", err
 		}
 
 		return token, nil
 	}
 	if err != nil {
-		a.l.Error("failed to find user by username", zap.Error(err))
+		l.Error("failed to find user by username", zap.Error(err))
 		return "", err
 	}
 
+	lockout, err := a.attempts.ActiveLockout(ctx, username)
+	if err != nil {
+		return "", err
+	}
+	if lockout != nil {
+		return "", &AccountLockedError{Until: lockout.LockedUntil}
+	}
+
+	byUsername, byIP, err := a.attempts.CountFailures(ctx, username, ip, a.policy.Window)
+	if err != nil {
+		return "", err
+	}
+	if err = sleep(ctx, a.policy.delay(max(byUsername, byIP))); err != nil {
+		return "", err
+	}
+
+	_, compareSpan := tracing.Start(ctx, "bcrypt.CompareHashAndPassword")
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	compareSpan.End()
 	if err != nil {
-		a.l.Debug("failed to compare password", zap.Error(err))
-		return "", ErrUnauthorized
+		l.Debug("failed to compare password", zap.Error(err))
+		metrics.FailedLogins.Inc()
+		return "", a.recordFailure(ctx, username, ip, byUsername+1)
+	}
+	// only told to whoever knows the password
+	if !user.Active() {
+		return "", ErrAccountDisabled
+	}
+
+	if byUsername > 0 {
+		if err = a.attempts.ClearFailures(ctx, username); err != nil {
+			return "", err
+		}
 	}
+	a.rehash(ctx, user, password)
 
-	token, err := a.jwtService.GenerateToken(user.ID)
+	token, err = a.jwtService.GenerateToken(claimsOf(user))
 	if err != nil {
-		a.l.Error("failed to generate token", zap.Error(err))
+		l.Error("failed to generate token", zap.Error(err))
 		return "", err
 	}
+	a.audit.Record(ctx, username, entity.AuditLogin, username, map[string]any{"ip": ip})
 	return token, nil
 }
 
-// VerifyJWT returns userId if succeeded. If not returns err
-func (a AuthService) VerifyJWT(token string) (int, error) {
-	return a.jwtService.VerifyToken(token)
+// recordFailure stores a wrong password and locks the username once it has
+// failed too often. It returns the error to answer the attempt with.
+func (a AuthService) recordFailure(ctx context.Context, username, ip string, failures int) error {
+	if err := a.attempts.RecordFailure(ctx, username, ip); err != nil {
+		return err
+	}
+	a.audit.Record(ctx, username, entity.AuditLoginFailed, username, map[string]any{"ip": ip})
+	if a.policy.MaxFailures <= 0 || failures < a.policy.MaxFailures {
+		return ErrUnauthorized
+	}
+
+	lockout, err := a.attempts.Lock(ctx, entity.Lockout{
+		Username: username,
+		IP:       ip,
+		Failures: failures,
+	}, a.policy.LockoutDuration)
+	if err != nil {
+		return err
+	}
+
+	metrics.Lockouts.Inc()
+	a.audit.Record(ctx, username, entity.AuditLockout, username, map[string]any{
+		"ip":       ip,
+		"failures": failures,
+		"until":    lockout.LockedUntil,
+	})
+	logging.FromContext(ctx, a.l).Warn("account locked after failed logins",
+		zap.String("username", username),
+		zap.String("ip", ip),
+		zap.Int("failures", failures),
+		zap.Time("until", lockout.LockedUntil),
+	)
+	return &AccountLockedError{Until: lockout.LockedUntil}
+}
+
+// Unlock lifts the lockout of username ahead of time. actor names who asked
+// for it and is kept with the lockout record.
+func (a AuthService) Unlock(ctx context.Context, username, actor string) (err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Unlock")
+	defer tracing.End(span, &err)
+
+	lifted, err := a.attempts.Unlock(ctx, username, actor)
+	if err != nil {
+		return err
+	}
+	if !lifted {
+		return ErrNotLocked
+	}
+	a.audit.Record(ctx, actor, entity.AuditUnlock, username, nil)
+
+	logging.FromContext(ctx, a.l).Warn("account unlocked",
+		zap.String("username", username),
+		zap.String("by", actor),
+	)
+	return nil
+}
+
+// VerifyJWT returns the claims of token if succeeded. If not returns err.
+// Tokens of disabled or deleted users are refused with ErrAccountDisabled.
+// Tokens issued before the sessions of the user were revoked are refused with
+// ErrTokenRevoked, every other refusal matches ErrUnauthorized as well.
+func (a AuthService) VerifyJWT(ctx context.Context, token string) (_ entity.Claims, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.VerifyJWT")
+	defer tracing.End(span, &err)
+
+	claims, err := a.jwtService.VerifyToken(token)
+	if err != nil {
+		return entity.Claims{}, fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	}
+
+	user, err := a.userRepository.FindUserByID(ctx, claims.UserID)
+	if errors.Is(err, repository.ErrorUserNotFound) {
+		return entity.Claims{}, fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	}
+	if err != nil {
+		return entity.Claims{}, err
+	}
+	if !user.Active() {
+		return entity.Claims{}, ErrAccountDisabled
+	}
+	// a change of role bumps the version too, so the role of a current token
+	// is the role of the user
+	if user.TokenVersion != claims.Version {
+		return entity.Claims{}, ErrTokenRevoked
+	}
+	claims.Username = user.Username
+
+	return claims, nil
+}
+
+// claimsOf are the claims of a token issued to user now.
+func claimsOf(user *entity.User) entity.Claims {
+	return entity.Claims{
+		UserID:   user.ID,
+		Username: user.Username,
+		Version:  user.TokenVersion,
+		Role:     user.Role,
+	}
+}
+
+// sleep waits for d unless ctx is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
 }
 
 func NewAuthService(
 	l *zap.Logger,
 	u repository.UserRepository,
 	j Token,
+	attempts repository.LoginAttemptRepository,
+	resets repository.PasswordResetRepository,
+	policy LoginPolicy,
+	passwords PasswordPolicy,
+	signup SignupPolicy,
+	audit AuditRecorder,
 ) Auth {
 	return &AuthService{
 		l:              l,
 		userRepository: u,
 		jwtService:     j,
+		attempts:       attempts,
+		resets:         resets,
+		policy:         policy,
+		passwords:      passwords,
+		signup:         signup,
+		audit:          audit,
 	}
 }
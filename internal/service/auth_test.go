@@ -1,24 +1,47 @@
 package service
 
 import (
+	"AvitoTech/internal/metrics"
 	"AvitoTech/internal/repository"
 	mocks "AvitoTech/test/mock"
+	"context"
 	"errors"
 	"golang.org/x/crypto/bcrypt"
 	"testing"
+	"time"
 
 	"AvitoTech/internal/entity"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
 )
 
+const testIP = "192.0.2.1"
+
+// testLoginPolicy has no delays so that the tests don't sleep.
+var testLoginPolicy = LoginPolicy{
+	MaxFailures:     3,
+	Window:          15 * time.Minute,
+	LockoutDuration: 15 * time.Minute,
+}
+
+// testPasswordPolicy keeps bcrypt cheap, hashes made with bcrypt.MinCost are
+// not rehashed.
+var testPasswordPolicy = PasswordPolicy{
+	Cost:      bcrypt.MinCost,
+	MinLength: 8,
+	ResetTTL:  time.Hour,
+}
+
 func TestAuthService_Authenticate_NewUser(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	mockUserRepo := new(mocks.MockUserRepository)
 	mockToken := new(mocks.MockToken)
+	mockAttempts := new(mocks.MockLoginAttemptRepository)
+	audit := new(mocks.AuditLog)
 
-	authService := NewAuthService(logger, mockUserRepo, mockToken)
+	authService := NewAuthService(logger, mockUserRepo, mockToken, mockAttempts, new(mocks.MockPasswordResetRepository), testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, audit)
 
 	username := "newuser"
 	password := "password"
@@ -30,31 +53,50 @@ func TestAuthService_Authenticate_NewUser(t *testing.T) {
 		Username: username,
 		Password: "hashedpassword",
 		Balance:  1000,
+		Role:     entity.RoleUser,
 	}
-	mockUserRepo.On("InsertUser", mock.AnythingOfType("*entity.User")).Return(newUser, nil)
+	mockUserRepo.On("Signup", mock.MatchedBy(func(u *entity.User) bool {
+		return u.Username == username && u.Balance == DefaultSignupPolicy.StartingBalance
+	}), []string(nil)).Return(newUser, nil)
 
-	mockToken.On("GenerateToken", newUser.ID).Return("generated-token", nil)
+	mockToken.On("GenerateToken", entity.Claims{UserID: newUser.ID, Username: username, Role: entity.RoleUser}).Return("generated-token", nil)
 
-	token, err := authService.Authenticate(username, password)
+	token, err := authService.Authenticate(context.Background(), username, password, testIP)
 
 	assert.NoError(t, err)
 	assert.Equal(t, "generated-token", token)
+	assert.Equal(t, []string{entity.AuditSignup}, audit.Actions())
 
 	mockUserRepo.AssertExpectations(t)
 	mockToken.AssertExpectations(t)
 }
 
+func TestAuthService_Authenticate_NewUserWeakPassword(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+
+	authService := NewAuthService(logger, mockUserRepo, new(mocks.MockToken), new(mocks.MockLoginAttemptRepository), new(mocks.MockPasswordResetRepository), testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, new(mocks.AuditLog))
+
+	mockUserRepo.On("FindUserByUsername", "newuser").Return(&entity.User{}, repository.ErrorUserNotFound)
+
+	_, err := authService.Authenticate(context.Background(), "newuser", "short", testIP)
+
+	assert.ErrorIs(t, err, ErrWeakPassword)
+	mockUserRepo.AssertNotCalled(t, "Signup", mock.Anything, mock.Anything)
+}
+
 func TestAuthService_Authenticate_ExistingUser(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	mockUserRepo := new(mocks.MockUserRepository)
 	mockToken := new(mocks.MockToken)
+	mockAttempts := new(mocks.MockLoginAttemptRepository)
 
-	authService := NewAuthService(logger, mockUserRepo, mockToken)
+	authService := NewAuthService(logger, mockUserRepo, mockToken, mockAttempts, new(mocks.MockPasswordResetRepository), testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, new(mocks.AuditLog))
 
 	username := "existinguser"
 	password := "validpassword123"
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), testPasswordPolicy.Cost)
 	assert.NoError(t, err)
 
 	existingUser := &entity.User{
@@ -64,10 +106,12 @@ func TestAuthService_Authenticate_ExistingUser(t *testing.T) {
 		Balance:  1000,
 	}
 	mockUserRepo.On("FindUserByUsername", username).Return(existingUser, nil)
+	mockAttempts.On("ActiveLockout", username).Return(nil, nil)
+	mockAttempts.On("CountFailures", username, testIP, testLoginPolicy.Window).Return(0, 0, nil)
 
-	mockToken.On("GenerateToken", existingUser.ID).Return("generated-token", nil)
+	mockToken.On("GenerateToken", entity.Claims{UserID: existingUser.ID, Username: username}).Return("generated-token", nil)
 
-	token, err := authService.Authenticate(username, password)
+	token, err := authService.Authenticate(context.Background(), username, password, testIP)
 
 	assert.NoError(t, err)
 	assert.Equal(t, "generated-token", token)
@@ -80,8 +124,9 @@ func TestAuthService_Authenticate_InvalidPassword(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	mockUserRepo := new(mocks.MockUserRepository)
 	mockToken := new(mocks.MockToken)
+	mockAttempts := new(mocks.MockLoginAttemptRepository)
 
-	authService := NewAuthService(logger, mockUserRepo, mockToken)
+	authService := NewAuthService(logger, mockUserRepo, mockToken, mockAttempts, new(mocks.MockPasswordResetRepository), testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, new(mocks.AuditLog))
 
 	username := "existinguser"
 	password := "wrongpassword"
@@ -93,32 +138,159 @@ func TestAuthService_Authenticate_InvalidPassword(t *testing.T) {
 		Balance:  1000,
 	}
 	mockUserRepo.On("FindUserByUsername", username).Return(existingUser, nil)
+	mockAttempts.On("ActiveLockout", username).Return(nil, nil)
+	mockAttempts.On("CountFailures", username, testIP, testLoginPolicy.Window).Return(0, 0, nil)
+	mockAttempts.On("RecordFailure", username, testIP).Return(nil)
+	failedLogins := testutil.ToFloat64(metrics.FailedLogins)
 
-	token, err := authService.Authenticate(username, password)
+	token, err := authService.Authenticate(context.Background(), username, password, testIP)
 
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrUnauthorized)
 	assert.Empty(t, token)
+	assert.Equal(t, failedLogins+1, testutil.ToFloat64(metrics.FailedLogins))
 
 	mockUserRepo.AssertExpectations(t)
 	mockToken.AssertExpectations(t)
+	mockAttempts.AssertExpectations(t)
+}
+
+func TestAuthService_Authenticate_LocksAfterMaxFailures(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockToken := new(mocks.MockToken)
+	mockAttempts := new(mocks.MockLoginAttemptRepository)
+	audit := new(mocks.AuditLog)
+
+	authService := NewAuthService(logger, mockUserRepo, mockToken, mockAttempts, new(mocks.MockPasswordResetRepository), testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, audit)
+
+	username := "existinguser"
+	existingUser := &entity.User{ID: 1, Username: username, Password: "hashedpassword"}
+	lockedUntil := time.Now().Add(testLoginPolicy.LockoutDuration)
+
+	mockUserRepo.On("FindUserByUsername", username).Return(existingUser, nil)
+	mockAttempts.On("ActiveLockout", username).Return(nil, nil)
+	mockAttempts.On("CountFailures", username, testIP, testLoginPolicy.Window).
+		Return(testLoginPolicy.MaxFailures-1, testLoginPolicy.MaxFailures-1, nil)
+	mockAttempts.On("RecordFailure", username, testIP).Return(nil)
+	mockAttempts.On("Lock", entity.Lockout{
+		Username: username,
+		IP:       testIP,
+		Failures: testLoginPolicy.MaxFailures,
+	}, testLoginPolicy.LockoutDuration).Return(&entity.Lockout{ID: 1, Username: username, LockedUntil: lockedUntil}, nil)
+
+	token, err := authService.Authenticate(context.Background(), username, "wrongpassword", testIP)
+
+	var locked *AccountLockedError
+	assert.ErrorAs(t, err, &locked)
+	assert.ErrorIs(t, err, ErrAccountLocked)
+	assert.Equal(t, lockedUntil, locked.Until)
+	assert.Empty(t, token)
+	assert.Equal(t, []string{entity.AuditLoginFailed, entity.AuditLockout}, audit.Actions())
+
+	mockAttempts.AssertExpectations(t)
+}
+
+func TestAuthService_Authenticate_LockedAccount(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockToken := new(mocks.MockToken)
+	mockAttempts := new(mocks.MockLoginAttemptRepository)
+
+	authService := NewAuthService(logger, mockUserRepo, mockToken, mockAttempts, new(mocks.MockPasswordResetRepository), testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, new(mocks.AuditLog))
+
+	username := "existinguser"
+	password := "validpassword123"
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	mockUserRepo.On("FindUserByUsername", username).
+		Return(&entity.User{ID: 1, Username: username, Password: string(hashedPassword)}, nil)
+	mockAttempts.On("ActiveLockout", username).
+		Return(&entity.Lockout{Username: username, LockedUntil: time.Now().Add(time.Minute)}, nil)
+
+	// even the right password is refused while the lockout lasts
+	token, err := authService.Authenticate(context.Background(), username, password, testIP)
+
+	assert.ErrorIs(t, err, ErrAccountLocked)
+	assert.Empty(t, token)
+
+	mockAttempts.AssertExpectations(t)
+	mockToken.AssertNotCalled(t, "GenerateToken", mock.Anything)
+}
+
+func TestAuthService_Authenticate_SuccessClearsFailures(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockToken := new(mocks.MockToken)
+	mockAttempts := new(mocks.MockLoginAttemptRepository)
+
+	authService := NewAuthService(logger, mockUserRepo, mockToken, mockAttempts, new(mocks.MockPasswordResetRepository), testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, new(mocks.AuditLog))
+
+	username := "existinguser"
+	password := "validpassword123"
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	mockUserRepo.On("FindUserByUsername", username).
+		Return(&entity.User{ID: 1, Username: username, Password: string(hashedPassword)}, nil)
+	mockAttempts.On("ActiveLockout", username).Return(nil, nil)
+	mockAttempts.On("CountFailures", username, testIP, testLoginPolicy.Window).Return(2, 2, nil)
+	mockAttempts.On("ClearFailures", username).Return(nil)
+	mockToken.On("GenerateToken", entity.Claims{UserID: 1, Username: username}).Return("generated-token", nil)
+
+	token, err := authService.Authenticate(context.Background(), username, password, testIP)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "generated-token", token)
+
+	mockAttempts.AssertExpectations(t)
+}
+
+func TestAuthService_Unlock(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockAttempts := new(mocks.MockLoginAttemptRepository)
+
+	authService := NewAuthService(logger, new(mocks.MockUserRepository), new(mocks.MockToken), mockAttempts, new(mocks.MockPasswordResetRepository), testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, new(mocks.AuditLog))
+
+	mockAttempts.On("Unlock", "locked", "admin").Return(true, nil)
+	mockAttempts.On("Unlock", "free", "admin").Return(false, nil)
+
+	assert.NoError(t, authService.Unlock(context.Background(), "locked", "admin"))
+	assert.ErrorIs(t, authService.Unlock(context.Background(), "free", "admin"), ErrNotLocked)
+
+	mockAttempts.AssertExpectations(t)
+}
+
+func TestLoginPolicy_Delay(t *testing.T) {
+	policy := LoginPolicy{FailureDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	assert.Equal(t, time.Duration(0), policy.delay(0))
+	assert.Equal(t, 100*time.Millisecond, policy.delay(1))
+	assert.Equal(t, 200*time.Millisecond, policy.delay(2))
+	assert.Equal(t, 800*time.Millisecond, policy.delay(4))
+	assert.Equal(t, time.Second, policy.delay(5))
+	assert.Equal(t, time.Second, policy.delay(1000))
 }
 
 func TestAuthService_VerifyJWT_ValidToken(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	mockUserRepo := new(mocks.MockUserRepository)
 	mockToken := new(mocks.MockToken)
+	mockAttempts := new(mocks.MockLoginAttemptRepository)
 
-	authService := NewAuthService(logger, mockUserRepo, mockToken)
+	authService := NewAuthService(logger, mockUserRepo, mockToken, mockAttempts, new(mocks.MockPasswordResetRepository), testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, new(mocks.AuditLog))
 
 	token := "valid-token"
 	userID := 1
 
-	mockToken.On("VerifyToken", token).Return(userID, nil)
+	mockToken.On("VerifyToken", token).Return(entity.Claims{UserID: userID, Version: 2, Role: entity.RoleAdmin}, nil)
+	mockUserRepo.On("FindUserByID", userID).
+		Return(&entity.User{ID: userID, Username: "someone", TokenVersion: 2, Role: entity.RoleAdmin}, nil)
 
-	verifiedUserID, err := authService.VerifyJWT(token)
+	claims, err := authService.VerifyJWT(context.Background(), token)
 
 	assert.NoError(t, err)
-	assert.Equal(t, userID, verifiedUserID)
+	assert.Equal(t, entity.Claims{UserID: userID, Username: "someone", Version: 2, Role: entity.RoleAdmin}, claims)
 
 	mockToken.AssertExpectations(t)
 }
@@ -127,17 +299,168 @@ func TestAuthService_VerifyJWT_InvalidToken(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	mockUserRepo := new(mocks.MockUserRepository)
 	mockToken := new(mocks.MockToken)
+	mockAttempts := new(mocks.MockLoginAttemptRepository)
 
-	authService := NewAuthService(logger, mockUserRepo, mockToken)
+	authService := NewAuthService(logger, mockUserRepo, mockToken, mockAttempts, new(mocks.MockPasswordResetRepository), testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, new(mocks.AuditLog))
 
 	token := "invalid-token"
 
-	mockToken.On("VerifyToken", token).Return(-1, errors.New("invalid token"))
+	mockToken.On("VerifyToken", token).Return(entity.Claims{}, errors.New("invalid token"))
 
-	verifiedUserID, err := authService.VerifyJWT(token)
+	claims, err := authService.VerifyJWT(context.Background(), token)
 
-	assert.Error(t, err)
-	assert.Equal(t, -1, verifiedUserID)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.Equal(t, entity.Claims{}, claims)
 
 	mockToken.AssertExpectations(t)
 }
+
+func TestAuthService_VerifyJWT_RevokedToken(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockToken := new(mocks.MockToken)
+	mockAttempts := new(mocks.MockLoginAttemptRepository)
+
+	authService := NewAuthService(logger, mockUserRepo, mockToken, mockAttempts, new(mocks.MockPasswordResetRepository), testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, new(mocks.AuditLog))
+
+	// the password was changed after the token had been issued
+	mockToken.On("VerifyToken", "old-token").Return(entity.Claims{UserID: 1, Version: 0}, nil)
+	mockUserRepo.On("FindUserByID", 1).Return(&entity.User{ID: 1, TokenVersion: 1}, nil)
+
+	claims, err := authService.VerifyJWT(context.Background(), "old-token")
+
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.Equal(t, entity.Claims{}, claims)
+}
+
+func TestAuthService_Authenticate_SystemAccount(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+
+	authService := NewAuthService(logger, mockUserRepo, new(mocks.MockToken), new(mocks.MockLoginAttemptRepository), new(mocks.MockPasswordResetRepository), testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, new(mocks.AuditLog))
+
+	token, err := authService.Authenticate(context.Background(), entity.SystemAccount, "password", testIP)
+
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.Empty(t, token)
+	mockUserRepo.AssertNotCalled(t, "FindUserByUsername", mock.Anything)
+}
+
+func TestAuthService_Authenticate_SignupCampaign(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockToken := new(mocks.MockToken)
+
+	policy := SignupPolicy{
+		StartingBalance: 500,
+		WelcomeItems:    []string{"pen"},
+		Campaigns: []Campaign{{
+			Name:         "launch",
+			Start:        time.Now().Add(-time.Hour),
+			End:          time.Now().Add(time.Hour),
+			Bonus:        250,
+			WelcomeItems: []string{"cup"},
+		}},
+	}
+	authService := NewAuthService(logger, mockUserRepo, mockToken, new(mocks.MockLoginAttemptRepository), new(mocks.MockPasswordResetRepository), testLoginPolicy, testPasswordPolicy, policy, new(mocks.AuditLog))
+
+	username := "campaignuser"
+	newUser := &entity.User{ID: 1, Username: username, Balance: 750, Role: entity.RoleUser}
+
+	mockUserRepo.On("FindUserByUsername", username).Return(&entity.User{}, repository.ErrorUserNotFound)
+	mockUserRepo.On("Signup", mock.MatchedBy(func(u *entity.User) bool {
+		return u.Username == username && u.Balance == 750
+	}), []string{"pen", "cup"}).Return(newUser, nil)
+	mockToken.On("GenerateToken", entity.Claims{UserID: 1, Username: username, Role: entity.RoleUser}).Return("generated-token", nil)
+
+	token, err := authService.Authenticate(context.Background(), username, "password", testIP)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "generated-token", token)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestSignupPolicy_At(t *testing.T) {
+	start := time.Date(2026, time.December, 25, 0, 0, 0, 0, time.UTC)
+	policy := SignupPolicy{
+		StartingBalance: 1000,
+		WelcomeItems:    []string{"pen"},
+		Campaigns: []Campaign{
+			{Name: "holidays", Start: start, End: start.AddDate(0, 0, 14), Bonus: 500, WelcomeItems: []string{"cup"}},
+			{Name: "new-year", Start: start.AddDate(0, 0, 7), End: start.AddDate(0, 0, 8), Bonus: 1000},
+		},
+	}
+
+	assert.Equal(t, SignupGrant{Balance: 1000, Items: []string{"pen"}}, policy.At(start.Add(-time.Second)))
+	assert.Equal(t, SignupGrant{Balance: 1500, Items: []string{"pen", "cup"}, Campaign: "holidays"}, policy.At(start))
+	// overlapping campaigns: the one listed first wins
+	assert.Equal(t, "holidays", policy.At(start.AddDate(0, 0, 7)).Campaign)
+	// campaigns end exclusively
+	assert.Equal(t, SignupGrant{Balance: 1000, Items: []string{"pen"}}, policy.At(start.AddDate(0, 0, 14)))
+}
+
+func TestSignupPolicy_Validate(t *testing.T) {
+	items := entity.Items
+	entity.Items = map[string]int{"pen": 10, "cup": 20}
+	defer func() {
+		entity.Items = items
+	}()
+
+	now := time.Now()
+	assert.NoError(t, DefaultSignupPolicy.Validate())
+	assert.NoError(t, SignupPolicy{
+		StartingBalance: 100,
+		WelcomeItems:    []string{"pen"},
+		Campaigns:       []Campaign{{Name: "launch", Start: now, End: now.Add(time.Hour), WelcomeItems: []string{"cup"}}},
+	}.Validate())
+
+	assert.Error(t, SignupPolicy{StartingBalance: -1}.Validate())
+	assert.Error(t, SignupPolicy{WelcomeItems: []string{"yacht"}}.Validate())
+	assert.Error(t, SignupPolicy{Campaigns: []Campaign{{Name: "backwards", Start: now, End: now.Add(-time.Hour)}}}.Validate())
+	assert.Error(t, SignupPolicy{Campaigns: []Campaign{{Name: "launch", Start: now, End: now.Add(time.Hour), WelcomeItems: []string{"yacht"}}}}.Validate())
+}
+
+func TestAuthService_Authenticate_DisabledUser(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockToken := new(mocks.MockToken)
+	mockAttempts := new(mocks.MockLoginAttemptRepository)
+
+	authService := NewAuthService(logger, mockUserRepo, mockToken, mockAttempts, new(mocks.MockPasswordResetRepository), testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, new(mocks.AuditLog))
+
+	username := "disableduser"
+	password := "validpassword123"
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), testPasswordPolicy.Cost)
+	assert.NoError(t, err)
+	disabledAt := time.Now()
+
+	mockUserRepo.On("FindUserByUsername", username).
+		Return(&entity.User{ID: 1, Username: username, Password: string(hashedPassword), DisabledAt: &disabledAt}, nil)
+	mockAttempts.On("ActiveLockout", username).Return(nil, nil)
+	mockAttempts.On("CountFailures", username, testIP, testLoginPolicy.Window).Return(0, 0, nil)
+
+	token, err := authService.Authenticate(context.Background(), username, password, testIP)
+
+	assert.ErrorIs(t, err, ErrAccountDisabled)
+	assert.Empty(t, token)
+	mockToken.AssertNotCalled(t, "GenerateToken", mock.Anything)
+}
+
+func TestAuthService_VerifyJWT_DisabledUser(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockToken := new(mocks.MockToken)
+
+	authService := NewAuthService(logger, mockUserRepo, mockToken, new(mocks.MockLoginAttemptRepository), new(mocks.MockPasswordResetRepository), testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, new(mocks.AuditLog))
+
+	disabledAt := time.Now()
+	mockToken.On("VerifyToken", "token").Return(entity.Claims{UserID: 1}, nil)
+	mockUserRepo.On("FindUserByID", 1).Return(&entity.User{ID: 1, DisabledAt: &disabledAt}, nil)
+
+	claims, err := authService.VerifyJWT(context.Background(), "token")
+
+	assert.ErrorIs(t, err, ErrAccountDisabled)
+	assert.NotErrorIs(t, err, ErrUnauthorized)
+	assert.Equal(t, entity.Claims{}, claims)
+}
@@ -2,68 +2,92 @@ package service
 
 import (
 	"AvitoTech/internal/entity"
+	"AvitoTech/internal/event"
+	"AvitoTech/internal/logging"
+	"AvitoTech/internal/metrics"
 	"AvitoTech/internal/repository"
+	"AvitoTech/internal/tracing"
+	"context"
 	"errors"
 	"go.uber.org/zap"
 )
 
+var ErrCoinAmount = errors.New("amount must be positive")
+
 type CoinService struct {
 	l *zap.Logger
 
-	userRepo      repository.UserRepository
-	inventoryRepo repository.InventoryRepository
-	historyRepo   repository.HistoryRepository
+	userRepo repository.UserRepository
+
+	limits Limits
+
+	events event.Publisher
 }
 
-func (c CoinService) SendCoin(fromUser int, toUser string, amount int) error {
-	sender, err := c.userRepo.FindUserByID(fromUser)
+func (c CoinService) SendCoin(ctx context.Context, fromUser int, toUser string, amount int) (err error) {
+	ctx, span := tracing.Start(ctx, "CoinService.SendCoin")
+	defer tracing.End(span, &err)
+	l := logging.FromContext(ctx, c.l)
+
+	// a negative amount would take coins from the receiver and give back
+	// transfer allowance to the sender
+	if amount <= 0 {
+		return ErrCoinAmount
+	}
+
+	_, err = c.userRepo.FindUserByID(ctx, fromUser)
 	if err != nil {
-		c.l.Debug("fromUser not found", zap.Error(err))
+		l.Debug("fromUser not found", zap.Error(err))
 		return err
 	}
 
-	receiver, err := c.userRepo.FindUserByUsername(toUser)
+	receiver, err := c.userRepo.FindUserByUsername(ctx, toUser)
 	if err != nil {
-		c.l.Debug("toUser not found", zap.Error(err))
+		l.Debug("toUser not found", zap.Error(err))
 		return err
 	}
+	if receiver.DeletedAt != nil {
+		return repository.ErrorUserNotFound
+	}
 
-	err = c.userRepo.TransferMoney(fromUser, receiver.ID, amount)
+	limits, err := c.limits.Active(ctx, fromUser)
 	if err != nil {
-		c.l.Debug("failed to transfer money", zap.Error(err))
 		return err
 	}
 
-	_, err = c.historyRepo.InsertOperation(entity.Operation{
-		FromUser: sender.Username,
-		ToUser:   receiver.Username,
-		Amount:   amount,
-	})
+	err = c.userRepo.TransferMoney(ctx, fromUser, receiver.ID, amount, limits)
 	if err != nil {
-		c.l.Debug("failed to insert history", zap.Error(err))
+		l.Debug("failed to transfer money", zap.Error(err))
 		return err
 	}
+	defer c.events.Publish(ctx, event.BalanceChanged{UserIDs: []int{fromUser, receiver.ID}})
+	metrics.CoinsTransferred.Add(float64(amount))
 
 	return nil
 }
 
-func (c CoinService) BuyItem(id int, item string) error {
+func (c CoinService) BuyItem(ctx context.Context, id int, item string) (err error) {
+	ctx, span := tracing.Start(ctx, "CoinService.BuyItem")
+	defer tracing.End(span, &err)
+	l := logging.FromContext(ctx, c.l)
+
 	cost, exist := entity.Items[item]
 	if !exist {
 		return errors.New("item not found")
 	}
 
-	err := c.userRepo.WithdrawMoney(id, cost)
+	limits, err := c.limits.Active(ctx, id)
 	if err != nil {
-		c.l.Error("failed to withdrawMoney", zap.Error(err))
 		return err
 	}
 
-	_, err = c.inventoryRepo.InsertItem(id, item)
+	err = c.userRepo.BuyItem(ctx, id, item, cost, limits)
 	if err != nil {
-		c.l.Error("failed to insert item", zap.Error(err))
+		l.Error("failed to buy item", zap.Error(err))
 		return err
 	}
+	defer c.events.Publish(ctx, event.BalanceChanged{UserIDs: []int{id}})
+	metrics.Purchases.WithLabelValues(item).Inc()
 
 	return nil
 }
@@ -71,13 +95,13 @@ func (c CoinService) BuyItem(id int, item string) error {
 func NewCoinService(
 	l *zap.Logger,
 	u repository.UserRepository,
-	i repository.InventoryRepository,
-	h repository.HistoryRepository,
+	limits Limits,
+	e event.Publisher,
 ) Coin {
 	return &CoinService{
-		l:             l,
-		userRepo:      u,
-		inventoryRepo: i,
-		historyRepo:   h,
+		l:        l,
+		userRepo: u,
+		limits:   limits,
+		events:   e,
 	}
 }
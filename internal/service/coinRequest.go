@@ -0,0 +1,153 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/event"
+	"AvitoTech/internal/metrics"
+	"AvitoTech/internal/repository"
+	"AvitoTech/internal/tracing"
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	// MaxMemoLength bounds the memo of a coin request, in characters.
+	MaxMemoLength = 200
+	// MaxCoinRequestPageSize bounds the limit of List.
+	MaxCoinRequestPageSize = 100
+)
+
+var (
+	ErrRequestAmount = errors.New("amount must be positive")
+	ErrMemoTooLong   = errors.New("memo is too long")
+	ErrSelfRequest   = errors.New("coins can't be requested from yourself")
+)
+
+// CoinRequestService lets users ask each other for coins. Nothing moves until
+// the payer accepts, which sends the coins like SendCoin.
+type CoinRequestService struct {
+	l *zap.Logger
+
+	requests repository.CoinRequestRepository
+	userRepo repository.UserRepository
+	limits   Limits
+
+	// ttl is how long a request stays pending.
+	ttl time.Duration
+
+	events event.Publisher
+	audit  AuditRecorder
+}
+
+// Request asks payer for amount coins on behalf of requesterID.
+func (c CoinRequestService) Request(ctx context.Context, requesterID int, payer string, amount int, memo string) (_ *entity.CoinRequest, err error) {
+	ctx, span := tracing.Start(ctx, "CoinRequestService.Request")
+	defer tracing.End(span, &err)
+
+	if amount <= 0 {
+		return nil, ErrRequestAmount
+	}
+	if len([]rune(memo)) > MaxMemoLength {
+		return nil, ErrMemoTooLong
+	}
+
+	requester, err := c.userRepo.FindUserByID(ctx, requesterID)
+	if err != nil {
+		return nil, err
+	}
+	payerUser, err := c.userRepo.FindUserByUsername(ctx, payer)
+	if err != nil {
+		return nil, err
+	}
+	if payerUser.DeletedAt != nil {
+		return nil, repository.ErrorUserNotFound
+	}
+	if payerUser.ID == requester.ID {
+		return nil, ErrSelfRequest
+	}
+
+	request, err := c.requests.Create(ctx, entity.CoinRequest{
+		RequesterID: requester.ID,
+		Requester:   requester.Username,
+		PayerID:     payerUser.ID,
+		Payer:       payerUser.Username,
+		Amount:      amount,
+		Memo:        memo,
+	}, c.ttl)
+	if err != nil {
+		return nil, err
+	}
+	c.audit.Record(ctx, requester.Username, entity.AuditRequest, payerUser.Username, map[string]any{
+		"request": request.ID,
+		"amount":  amount,
+	})
+	return request, nil
+}
+
+// List returns the requests of filter.UserID, newest first. The limit is
+// clamped to 1..MaxCoinRequestPageSize.
+func (c CoinRequestService) List(ctx context.Context, filter entity.CoinRequestFilter) (_ []entity.CoinRequest, err error) {
+	ctx, span := tracing.Start(ctx, "CoinRequestService.List")
+	defer tracing.End(span, &err)
+
+	filter.Limit = min(max(filter.Limit, 1), MaxCoinRequestPageSize)
+	return c.requests.List(ctx, filter)
+}
+
+// Accept pays the pending request id addressed to payerID. The request is
+// settled in the transaction that moves the coins, so it is paid once and
+// stays pending if the payment fails.
+func (c CoinRequestService) Accept(ctx context.Context, payerID int, id int64) (_ *entity.CoinRequest, err error) {
+	ctx, span := tracing.Start(ctx, "CoinRequestService.Accept")
+	defer tracing.End(span, &err)
+
+	limits, err := c.limits.Active(ctx, payerID)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := c.requests.Accept(ctx, id, payerID, limits)
+	if err != nil {
+		return nil, err
+	}
+	defer c.events.Publish(ctx, event.BalanceChanged{UserIDs: []int{payerID, request.RequesterID}})
+	metrics.CoinsTransferred.Add(float64(request.Amount))
+	return request, nil
+}
+
+// Decline turns down the pending request id addressed to payerID.
+func (c CoinRequestService) Decline(ctx context.Context, payerID int, id int64) (_ *entity.CoinRequest, err error) {
+	ctx, span := tracing.Start(ctx, "CoinRequestService.Decline")
+	defer tracing.End(span, &err)
+
+	request, err := c.requests.Decline(ctx, id, payerID)
+	if err != nil {
+		return nil, err
+	}
+	c.audit.Record(ctx, request.Payer, entity.AuditDeclineRequest, request.Requester, map[string]any{
+		"request": id,
+	})
+	return request, nil
+}
+
+func NewCoinRequestService(
+	l *zap.Logger,
+	requests repository.CoinRequestRepository,
+	u repository.UserRepository,
+	limits Limits,
+	ttl time.Duration,
+	e event.Publisher,
+	audit AuditRecorder,
+) CoinRequests {
+	return &CoinRequestService{
+		l:        l,
+		requests: requests,
+		userRepo: u,
+		limits:   limits,
+		ttl:      ttl,
+		events:   e,
+		audit:    audit,
+	}
+}
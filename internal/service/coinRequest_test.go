@@ -0,0 +1,133 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/event"
+	"AvitoTech/internal/metrics"
+	"AvitoTech/internal/repository"
+	mocks "AvitoTech/test/mock"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCoinRequestService_Request(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockRequests := new(mocks.MockCoinRequestRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	audit := new(mocks.AuditLog)
+
+	coinRequestService := NewCoinRequestService(logger, mockRequests, mockUserRepo, unlimited(), time.Hour, event.NewBus(), audit)
+
+	deletedAt := time.Now()
+	mockUserRepo.On("FindUserByID", 1).Return(&entity.User{ID: 1, Username: "alice"}, nil)
+	mockUserRepo.On("FindUserByUsername", "bob").Return(&entity.User{ID: 2, Username: "bob"}, nil)
+	mockUserRepo.On("FindUserByUsername", "alice").Return(&entity.User{ID: 1, Username: "alice"}, nil)
+	mockUserRepo.On("FindUserByUsername", "gone").Return(&entity.User{ID: 3, Username: "gone", DeletedAt: &deletedAt}, nil)
+
+	want := entity.CoinRequest{RequesterID: 1, Requester: "alice", PayerID: 2, Payer: "bob", Amount: 50, Memo: "lunch"}
+	created := want
+	created.ID = 7
+	mockRequests.On("Create", want, time.Hour).Return(&created, nil).Once()
+
+	request, err := coinRequestService.Request(context.Background(), 1, "bob", 50, "lunch")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), request.ID)
+	assert.Equal(t, []entity.AuditEntry{{
+		Actor:   "alice",
+		Action:  entity.AuditRequest,
+		Target:  "bob",
+		Payload: []byte(`{"amount":50,"request":7}`),
+	}}, audit.Entries())
+
+	_, err = coinRequestService.Request(context.Background(), 1, "bob", 0, "")
+	assert.ErrorIs(t, err, ErrRequestAmount)
+	_, err = coinRequestService.Request(context.Background(), 1, "bob", 10, strings.Repeat("я", MaxMemoLength+1))
+	assert.ErrorIs(t, err, ErrMemoTooLong)
+	_, err = coinRequestService.Request(context.Background(), 1, "alice", 10, "")
+	assert.ErrorIs(t, err, ErrSelfRequest)
+	_, err = coinRequestService.Request(context.Background(), 1, "gone", 10, "")
+	assert.ErrorIs(t, err, repository.ErrorUserNotFound)
+	mockRequests.AssertExpectations(t)
+}
+
+func TestCoinRequestService_Accept(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockRequests := new(mocks.MockCoinRequestRepository)
+	audit := new(mocks.AuditLog)
+	bus := event.NewBus()
+
+	var changed []int
+	bus.Subscribe(func(_ context.Context, e any) {
+		changed = e.(event.BalanceChanged).UserIDs
+	})
+
+	mockLimits := new(mocks.MockLimits)
+	coinRequestService := NewCoinRequestService(logger, mockRequests, new(mocks.MockUserRepository), mockLimits, time.Hour, bus, audit)
+
+	limits := []entity.SpendingLimit{{Operation: entity.LimitTransfer, Period: entity.LimitDaily, Amount: 100}}
+	mockLimits.On("Active", 2).Return(limits, nil)
+	accepted := &entity.CoinRequest{ID: 7, RequesterID: 1, Requester: "alice", PayerID: 2, Payer: "bob", Amount: 50, Status: entity.CoinRequestAccepted}
+	mockRequests.On("Accept", int64(7), 2, limits).Return(accepted, nil).Once()
+
+	transferred := testutil.ToFloat64(metrics.CoinsTransferred)
+
+	request, err := coinRequestService.Accept(context.Background(), 2, 7)
+	require.NoError(t, err)
+	assert.Equal(t, entity.CoinRequestAccepted, request.Status)
+	// the payment and the acceptance are audited by the repository
+	assert.Empty(t, audit.Entries())
+	assert.Equal(t, []int{2, 1}, changed)
+	assert.Equal(t, transferred+50, testutil.ToFloat64(metrics.CoinsTransferred))
+
+	// a failed payment leaves the request pending
+	mockRequests.On("Accept", int64(7), 2, limits).Return(nil, repository.ErrorInsufficientBalance).Once()
+	_, err = coinRequestService.Accept(context.Background(), 2, 7)
+	assert.ErrorIs(t, err, repository.ErrorInsufficientBalance)
+	mockRequests.On("Accept", int64(7), 2, limits).Return(nil, repository.ErrorLimitExceeded).Once()
+	_, err = coinRequestService.Accept(context.Background(), 2, 7)
+	assert.ErrorIs(t, err, repository.ErrorLimitExceeded)
+
+	mockRequests.On("Accept", int64(8), 2, limits).Return(nil, repository.ErrorRequestNotPending)
+	_, err = coinRequestService.Accept(context.Background(), 2, 8)
+	assert.ErrorIs(t, err, repository.ErrorRequestNotPending)
+	mockRequests.AssertExpectations(t)
+}
+
+func TestCoinRequestService_Decline(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockRequests := new(mocks.MockCoinRequestRepository)
+	audit := new(mocks.AuditLog)
+
+	coinRequestService := NewCoinRequestService(logger, mockRequests, new(mocks.MockUserRepository), unlimited(), time.Hour, event.NewBus(), audit)
+
+	declined := &entity.CoinRequest{ID: 7, Requester: "alice", Payer: "bob", Amount: 50, Status: entity.CoinRequestDeclined}
+	mockRequests.On("Decline", int64(7), 2).Return(declined, nil)
+
+	request, err := coinRequestService.Decline(context.Background(), 2, 7)
+	require.NoError(t, err)
+	assert.Equal(t, entity.CoinRequestDeclined, request.Status)
+	assert.Equal(t, []string{entity.AuditDeclineRequest}, audit.Actions())
+	mockRequests.AssertNotCalled(t, "Accept", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCoinRequestService_List(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockRequests := new(mocks.MockCoinRequestRepository)
+
+	coinRequestService := NewCoinRequestService(logger, mockRequests, new(mocks.MockUserRepository), unlimited(), time.Hour, event.NewBus(), new(mocks.AuditLog))
+
+	mockRequests.On("List", entity.CoinRequestFilter{UserID: 1, Outgoing: true, Limit: MaxCoinRequestPageSize}).
+		Return([]entity.CoinRequest{}, nil)
+
+	_, err := coinRequestService.List(context.Background(), entity.CoinRequestFilter{UserID: 1, Outgoing: true, Limit: 1000})
+	require.NoError(t, err)
+	mockRequests.AssertExpectations(t)
+}
@@ -2,22 +2,55 @@ package service
 
 import (
 	"AvitoTech/internal/entity"
+	"AvitoTech/internal/event"
+	"AvitoTech/internal/metrics"
 	"AvitoTech/internal/repository"
 	mocks "AvitoTech/test/mock"
+	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
 )
 
+func TestCoinService_SendCoin_DeletedReceiver(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+
+	coinService := NewCoinService(logger, mockUserRepo, unlimited(), event.NewBus())
+
+	deletedAt := time.Now()
+	mockUserRepo.On("FindUserByID", 1).Return(&entity.User{ID: 1, Username: "sender"}, nil)
+	mockUserRepo.On("FindUserByUsername", "gone").Return(&entity.User{ID: 2, Username: "gone", DeletedAt: &deletedAt}, nil)
+
+	err := coinService.SendCoin(context.Background(), 1, "gone", 100)
+
+	assert.ErrorIs(t, err, repository.ErrorUserNotFound)
+	mockUserRepo.AssertNotCalled(t, "TransferMoney", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCoinService_SendCoin_InvalidAmount(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+
+	coinService := NewCoinService(logger, mockUserRepo, unlimited(), event.NewBus())
+
+	for _, amount := range []int{0, -100} {
+		err := coinService.SendCoin(context.Background(), 1, "receiver", amount)
+		assert.ErrorIs(t, err, ErrCoinAmount)
+	}
+	mockUserRepo.AssertNotCalled(t, "TransferMoney", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestCoinService_SendCoin_Success(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	mockUserRepo := new(mocks.MockUserRepository)
-	mockInventoryRepo := new(mocks.MockInventoryRepository)
-	mockHistoryRepo := new(mocks.MockHistoryRepository)
 
-	coinService := NewCoinService(logger, mockUserRepo, mockInventoryRepo, mockHistoryRepo)
+	coinService := NewCoinService(logger, mockUserRepo, unlimited(), event.NewBus())
 
 	fromUserID := 1
 	toUsername := "receiver"
@@ -36,34 +69,29 @@ func TestCoinService_SendCoin_Success(t *testing.T) {
 
 	mockUserRepo.On("FindUserByID", fromUserID).Return(sender, nil)
 	mockUserRepo.On("FindUserByUsername", toUsername).Return(receiver, nil)
-	mockUserRepo.On("TransferMoney", fromUserID, receiver.ID, amount).Return(nil)
-	mockHistoryRepo.On("InsertOperation", entity.Operation{
-		FromUser: sender.Username,
-		ToUser:   receiver.Username,
-		Amount:   amount,
-	}).Return(&entity.Operation{ID: 1, FromUser: sender.Username, ToUser: receiver.Username, Amount: amount}, nil)
+	mockUserRepo.On("TransferMoney", fromUserID, receiver.ID, amount, noLimits).Return(nil)
 
-	err := coinService.SendCoin(fromUserID, toUsername, amount)
+	transferred := testutil.ToFloat64(metrics.CoinsTransferred)
+
+	err := coinService.SendCoin(context.Background(), fromUserID, toUsername, amount)
 
 	assert.NoError(t, err)
+	assert.Equal(t, transferred+float64(amount), testutil.ToFloat64(metrics.CoinsTransferred))
 
 	mockUserRepo.AssertExpectations(t)
-	mockHistoryRepo.AssertExpectations(t)
 }
 
 func TestCoinService_SendCoin_SenderNotFound(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	mockUserRepo := new(mocks.MockUserRepository)
-	mockInventoryRepo := new(mocks.MockInventoryRepository)
-	mockHistoryRepo := new(mocks.MockHistoryRepository)
 
-	coinService := NewCoinService(logger, mockUserRepo, mockInventoryRepo, mockHistoryRepo)
+	coinService := NewCoinService(logger, mockUserRepo, unlimited(), event.NewBus())
 
 	fromUserID := 1
 	toUsername := "receiver"
 	mockUserRepo.On("FindUserByID", fromUserID).Return(&entity.User{}, repository.ErrorUserNotFound)
 
-	err := coinService.SendCoin(fromUserID, toUsername, 100)
+	err := coinService.SendCoin(context.Background(), fromUserID, toUsername, 100)
 
 	assert.Error(t, err)
 	assert.True(t, errors.Is(err, repository.ErrorUserNotFound))
@@ -74,10 +102,8 @@ func TestCoinService_SendCoin_SenderNotFound(t *testing.T) {
 func TestCoinService_SendCoin_ReceiverNotFound(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	mockUserRepo := new(mocks.MockUserRepository)
-	mockInventoryRepo := new(mocks.MockInventoryRepository)
-	mockHistoryRepo := new(mocks.MockHistoryRepository)
 
-	coinService := NewCoinService(logger, mockUserRepo, mockInventoryRepo, mockHistoryRepo)
+	coinService := NewCoinService(logger, mockUserRepo, unlimited(), event.NewBus())
 
 	fromUserID := 1
 	toUsername := "receiver"
@@ -91,7 +117,7 @@ func TestCoinService_SendCoin_ReceiverNotFound(t *testing.T) {
 	mockUserRepo.On("FindUserByID", fromUserID).Return(sender, nil)
 	mockUserRepo.On("FindUserByUsername", toUsername).Return(&entity.User{}, repository.ErrorUserNotFound)
 
-	err := coinService.SendCoin(fromUserID, toUsername, 100)
+	err := coinService.SendCoin(context.Background(), fromUserID, toUsername, 100)
 
 	assert.Error(t, err)
 	assert.True(t, errors.Is(err, repository.ErrorUserNotFound))
@@ -102,10 +128,8 @@ func TestCoinService_SendCoin_ReceiverNotFound(t *testing.T) {
 func TestCoinService_SendCoin_TransferFailed(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	mockUserRepo := new(mocks.MockUserRepository)
-	mockInventoryRepo := new(mocks.MockInventoryRepository)
-	mockHistoryRepo := new(mocks.MockHistoryRepository)
 
-	coinService := NewCoinService(logger, mockUserRepo, mockInventoryRepo, mockHistoryRepo)
+	coinService := NewCoinService(logger, mockUserRepo, unlimited(), event.NewBus())
 
 	fromUserID := 1
 	toUsername := "receiver"
@@ -124,9 +148,9 @@ func TestCoinService_SendCoin_TransferFailed(t *testing.T) {
 
 	mockUserRepo.On("FindUserByID", fromUserID).Return(sender, nil)
 	mockUserRepo.On("FindUserByUsername", toUsername).Return(receiver, nil)
-	mockUserRepo.On("TransferMoney", fromUserID, receiver.ID, amount).Return(errors.New("transfer failed"))
+	mockUserRepo.On("TransferMoney", fromUserID, receiver.ID, amount, noLimits).Return(errors.New("transfer failed"))
 
-	err := coinService.SendCoin(fromUserID, toUsername, amount)
+	err := coinService.SendCoin(context.Background(), fromUserID, toUsername, amount)
 
 	assert.Error(t, err)
 	assert.Equal(t, "transfer failed", err.Error())
@@ -137,38 +161,35 @@ func TestCoinService_SendCoin_TransferFailed(t *testing.T) {
 func TestCoinService_BuyItem_Success(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	mockUserRepo := new(mocks.MockUserRepository)
-	mockInventoryRepo := new(mocks.MockInventoryRepository)
-	mockHistoryRepo := new(mocks.MockHistoryRepository)
 
-	coinService := NewCoinService(logger, mockUserRepo, mockInventoryRepo, mockHistoryRepo)
+	coinService := NewCoinService(logger, mockUserRepo, unlimited(), event.NewBus())
 
 	userID := 1
 	item := entity.Item{Title: "cup", OwnerID: userID}
 	cost := entity.Items[item.Title]
 
-	mockUserRepo.On("WithdrawMoney", userID, cost).Return(nil)
-	mockInventoryRepo.On("InsertItem", userID, item.Title).Return(&item, nil)
+	mockUserRepo.On("BuyItem", userID, item.Title, cost, noLimits).Return(nil)
+
+	purchases := testutil.ToFloat64(metrics.Purchases.WithLabelValues(item.Title))
 
-	err := coinService.BuyItem(userID, item.Title)
+	err := coinService.BuyItem(context.Background(), userID, item.Title)
 
 	assert.NoError(t, err)
+	assert.Equal(t, purchases+1, testutil.ToFloat64(metrics.Purchases.WithLabelValues(item.Title)))
 
 	mockUserRepo.AssertExpectations(t)
-	mockInventoryRepo.AssertExpectations(t)
 }
 
 func TestCoinService_BuyItem_ItemNotFound(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	mockUserRepo := new(mocks.MockUserRepository)
-	mockInventoryRepo := new(mocks.MockInventoryRepository)
-	mockHistoryRepo := new(mocks.MockHistoryRepository)
 
-	coinService := NewCoinService(logger, mockUserRepo, mockInventoryRepo, mockHistoryRepo)
+	coinService := NewCoinService(logger, mockUserRepo, unlimited(), event.NewBus())
 
 	userID := 1
 	item := "nonexistent_item"
 
-	err := coinService.BuyItem(userID, item)
+	err := coinService.BuyItem(context.Background(), userID, item)
 
 	assert.Error(t, err)
 	assert.Equal(t, "item not found", err.Error())
@@ -177,18 +198,16 @@ func TestCoinService_BuyItem_ItemNotFound(t *testing.T) {
 func TestCoinService_BuyItem_WithdrawFailed(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	mockUserRepo := new(mocks.MockUserRepository)
-	mockInventoryRepo := new(mocks.MockInventoryRepository)
-	mockHistoryRepo := new(mocks.MockHistoryRepository)
 
-	coinService := NewCoinService(logger, mockUserRepo, mockInventoryRepo, mockHistoryRepo)
+	coinService := NewCoinService(logger, mockUserRepo, unlimited(), event.NewBus())
 
 	userID := 1
 	item := "cup"
 	cost := entity.Items[item]
 
-	mockUserRepo.On("WithdrawMoney", userID, cost).Return(errors.New("insufficient funds"))
+	mockUserRepo.On("BuyItem", userID, item, cost, noLimits).Return(errors.New("insufficient funds"))
 
-	err := coinService.BuyItem(userID, item)
+	err := coinService.BuyItem(context.Background(), userID, item)
 
 	assert.Error(t, err)
 	assert.Equal(t, "insufficient funds", err.Error())
@@ -196,26 +215,29 @@ func TestCoinService_BuyItem_WithdrawFailed(t *testing.T) {
 	mockUserRepo.AssertExpectations(t)
 }
 
-func TestCoinService_BuyItem_InsertItemFailed(t *testing.T) {
+func TestCoinService_LimitExceeded(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	mockUserRepo := new(mocks.MockUserRepository)
-	mockInventoryRepo := new(mocks.MockInventoryRepository)
-	mockHistoryRepo := new(mocks.MockHistoryRepository)
-
-	coinService := NewCoinService(logger, mockUserRepo, mockInventoryRepo, mockHistoryRepo)
+	mockLimits := new(mocks.MockLimits)
 
-	userID := 1
-	item := entity.Item{Title: "cup", OwnerID: userID}
-	cost := entity.Items[item.Title]
+	coinService := NewCoinService(logger, mockUserRepo, mockLimits, event.NewBus())
 
-	mockUserRepo.On("WithdrawMoney", userID, cost).Return(nil)
-	mockInventoryRepo.On("InsertItem", userID, item.Title).Return(nil, errors.New("insert failed"))
-
-	err := coinService.BuyItem(userID, item.Title)
+	// the limits are checked by the repository, within the transaction
+	limits := []entity.SpendingLimit{
+		{Operation: entity.LimitTransfer, Period: entity.LimitDaily, Amount: 100},
+		{Operation: entity.LimitPurchase, Period: entity.LimitDaily, Amount: 10},
+	}
+	mockUserRepo.On("FindUserByID", 1).Return(&entity.User{ID: 1, Username: "sender"}, nil)
+	mockUserRepo.On("FindUserByUsername", "receiver").Return(&entity.User{ID: 2, Username: "receiver"}, nil)
+	mockLimits.On("Active", 1).Return(limits, nil)
+	mockUserRepo.On("TransferMoney", 1, 2, 100, limits).Return(repository.ErrorLimitExceeded).Once()
+	mockUserRepo.On("BuyItem", 1, "cup", entity.Items["cup"], limits).Return(repository.ErrorLimitExceeded).Once()
 
-	assert.Error(t, err)
-	assert.Equal(t, "insert failed", err.Error())
+	err := coinService.SendCoin(context.Background(), 1, "receiver", 100)
+	assert.ErrorIs(t, err, repository.ErrorLimitExceeded)
+	err = coinService.BuyItem(context.Background(), 1, "cup")
+	assert.ErrorIs(t, err, repository.ErrorLimitExceeded)
 
 	mockUserRepo.AssertExpectations(t)
-	mockInventoryRepo.AssertExpectations(t)
+	mockLimits.AssertExpectations(t)
 }
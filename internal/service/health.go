@@ -0,0 +1,72 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrDraining         = errors.New("shutting down")
+	ErrCatalogNotLoaded = errors.New("item catalog is not loaded")
+)
+
+type HealthService struct {
+	l *zap.Logger
+
+	healthRepo repository.HealthRepository
+	timeout    time.Duration
+
+	draining atomic.Bool
+}
+
+// Ready reports whether the instance can serve traffic: it isn't shutting
+// down, the item catalog is loaded and the database answers within the
+// configured timeout with the expected schema in place.
+func (h *HealthService) Ready(ctx context.Context) error {
+	if h.draining.Load() {
+		return ErrDraining
+	}
+
+	if len(entity.Items) == 0 {
+		return ErrCatalogNotLoaded
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	if err := h.healthRepo.Ping(ctx); err != nil {
+		h.l.Warn("database ping failed", zap.Error(err))
+		return fmt.Errorf("database unavailable: %w", err)
+	}
+
+	if err := h.healthRepo.CheckSchema(ctx); err != nil {
+		h.l.Warn("database schema check failed", zap.Error(err))
+		return fmt.Errorf("database schema: %w", err)
+	}
+
+	return nil
+}
+
+// Drain makes every further readiness check fail, so that the orchestrator
+// stops routing traffic while the instance shuts down.
+func (h *HealthService) Drain() {
+	h.draining.Store(true)
+}
+
+func NewHealthService(
+	l *zap.Logger,
+	r repository.HealthRepository,
+	timeout time.Duration,
+) Health {
+	return &HealthService{
+		l:          l,
+		healthRepo: r,
+		timeout:    timeout,
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	mocks "AvitoTech/test/mock"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestHealthService_Ready(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockHealthRepo := new(mocks.MockHealthRepository)
+
+	healthService := NewHealthService(logger, mockHealthRepo, time.Second)
+
+	mockHealthRepo.On("Ping").Return(nil)
+	mockHealthRepo.On("CheckSchema").Return(nil)
+
+	err := healthService.Ready(context.Background())
+
+	assert.NoError(t, err)
+	mockHealthRepo.AssertExpectations(t)
+}
+
+func TestHealthService_Ready_DatabaseDown(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockHealthRepo := new(mocks.MockHealthRepository)
+
+	healthService := NewHealthService(logger, mockHealthRepo, time.Second)
+
+	pingError := errors.New("connection refused")
+	mockHealthRepo.On("Ping").Return(pingError)
+
+	err := healthService.Ready(context.Background())
+
+	assert.ErrorIs(t, err, pingError)
+	mockHealthRepo.AssertExpectations(t)
+}
+
+func TestHealthService_Ready_SchemaMissing(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockHealthRepo := new(mocks.MockHealthRepository)
+
+	healthService := NewHealthService(logger, mockHealthRepo, time.Second)
+
+	schemaError := errors.New("missing tables: [history]")
+	mockHealthRepo.On("Ping").Return(nil)
+	mockHealthRepo.On("CheckSchema").Return(schemaError)
+
+	err := healthService.Ready(context.Background())
+
+	assert.ErrorIs(t, err, schemaError)
+	mockHealthRepo.AssertExpectations(t)
+}
+
+func TestHealthService_Ready_CatalogNotLoaded(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockHealthRepo := new(mocks.MockHealthRepository)
+
+	healthService := NewHealthService(logger, mockHealthRepo, time.Second)
+
+	items := entity.Items
+	entity.Items = nil
+	defer func() {
+		entity.Items = items
+	}()
+
+	err := healthService.Ready(context.Background())
+
+	assert.ErrorIs(t, err, ErrCatalogNotLoaded)
+	mockHealthRepo.AssertNotCalled(t, "Ping")
+}
+
+func TestHealthService_Drain(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockHealthRepo := new(mocks.MockHealthRepository)
+
+	healthService := NewHealthService(logger, mockHealthRepo, time.Second)
+	healthService.Drain()
+
+	err := healthService.Ready(context.Background())
+
+	assert.ErrorIs(t, err, ErrDraining)
+	mockHealthRepo.AssertNotCalled(t, "Ping")
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/event"
+	"AvitoTech/internal/repository"
+	"AvitoTech/internal/tracing"
+	"context"
+	"errors"
+	"go.uber.org/zap"
+)
+
+// MaxHoldPageSize bounds the limit of List.
+const MaxHoldPageSize = 100
+
+var (
+	ErrHoldAmount  = errors.New("amount must be positive")
+	ErrSelfRelease = errors.New("a hold can't be released to its sponsor")
+)
+
+// HoldService runs bounties: the sponsor holds coins, which can't be spent
+// meanwhile, and later releases them to whoever did the task or cancels the
+// hold to get them back.
+type HoldService struct {
+	l *zap.Logger
+
+	userRepo repository.UserRepository
+	limits   Limits
+
+	events event.Publisher
+}
+
+// Hold locks amount coins of sponsorID. The memo, bounded like the memo of a
+// coin request, describes the task.
+func (h HoldService) Hold(ctx context.Context, sponsorID int, amount int, memo string) (_ *entity.Hold, err error) {
+	ctx, span := tracing.Start(ctx, "HoldService.Hold")
+	defer tracing.End(span, &err)
+
+	if amount <= 0 {
+		return nil, ErrHoldAmount
+	}
+	if len([]rune(memo)) > MaxMemoLength {
+		return nil, ErrMemoTooLong
+	}
+
+	hold, err := h.userRepo.Hold(ctx, sponsorID, amount, memo)
+	if err != nil {
+		return nil, err
+	}
+	h.events.Publish(ctx, event.BalanceChanged{UserIDs: []int{sponsorID}})
+	return hold, nil
+}
+
+// List returns the holds of sponsorID, newest first. The limit is clamped to
+// 1..MaxHoldPageSize.
+func (h HoldService) List(ctx context.Context, sponsorID int, limit int) (_ []entity.Hold, err error) {
+	ctx, span := tracing.Start(ctx, "HoldService.List")
+	defer tracing.End(span, &err)
+
+	return h.userRepo.ListHolds(ctx, sponsorID, min(max(limit, 1), MaxHoldPageSize))
+}
+
+// Release pays the active hold id of sponsorID out to recipient. Like
+// SendCoin, the recipient must not be deleted and the payout counts against
+// the transfer limits of the sponsor.
+func (h HoldService) Release(ctx context.Context, sponsorID int, id int64, recipient string) (_ *entity.Hold, err error) {
+	ctx, span := tracing.Start(ctx, "HoldService.Release")
+	defer tracing.End(span, &err)
+
+	recipientUser, err := h.userRepo.FindUserByUsername(ctx, recipient)
+	if err != nil {
+		return nil, err
+	}
+	if recipientUser.DeletedAt != nil {
+		return nil, repository.ErrorUserNotFound
+	}
+	if recipientUser.ID == sponsorID {
+		return nil, ErrSelfRelease
+	}
+
+	limits, err := h.limits.Active(ctx, sponsorID)
+	if err != nil {
+		return nil, err
+	}
+
+	hold, err := h.userRepo.ReleaseHold(ctx, id, sponsorID, recipientUser.ID, limits)
+	if err != nil {
+		return nil, err
+	}
+	h.events.Publish(ctx, event.BalanceChanged{UserIDs: []int{sponsorID, recipientUser.ID}})
+	return hold, nil
+}
+
+// Cancel returns the coins of the active hold id to sponsorID.
+func (h HoldService) Cancel(ctx context.Context, sponsorID int, id int64) (_ *entity.Hold, err error) {
+	ctx, span := tracing.Start(ctx, "HoldService.Cancel")
+	defer tracing.End(span, &err)
+
+	hold, err := h.userRepo.CancelHold(ctx, id, sponsorID)
+	if err != nil {
+		return nil, err
+	}
+	h.events.Publish(ctx, event.BalanceChanged{UserIDs: []int{sponsorID}})
+	return hold, nil
+}
+
+func NewHoldService(
+	l *zap.Logger,
+	u repository.UserRepository,
+	limits Limits,
+	e event.Publisher,
+) Holds {
+	return &HoldService{
+		l:        l,
+		userRepo: u,
+		limits:   limits,
+		events:   e,
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/event"
+	"AvitoTech/internal/repository"
+	mocks "AvitoTech/test/mock"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestHoldService_Hold(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+
+	holdService := NewHoldService(logger, mockUserRepo, unlimited(), event.NewBus())
+
+	held := &entity.Hold{ID: 3, SponsorID: 1, Sponsor: "alice", Amount: 100, Memo: "fix the build", Status: entity.HoldActive}
+	mockUserRepo.On("Hold", 1, 100, "fix the build").Return(held, nil).Once()
+	mockUserRepo.On("Hold", 1, 5000, "").Return(nil, repository.ErrorInsufficientBalance).Once()
+
+	hold, err := holdService.Hold(context.Background(), 1, 100, "fix the build")
+	require.NoError(t, err)
+	assert.Equal(t, held, hold)
+
+	_, err = holdService.Hold(context.Background(), 1, 5000, "")
+	assert.ErrorIs(t, err, repository.ErrorInsufficientBalance)
+	_, err = holdService.Hold(context.Background(), 1, 0, "")
+	assert.ErrorIs(t, err, ErrHoldAmount)
+	_, err = holdService.Hold(context.Background(), 1, 10, strings.Repeat("я", MaxMemoLength+1))
+	assert.ErrorIs(t, err, ErrMemoTooLong)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestHoldService_Release(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockLimits := new(mocks.MockLimits)
+
+	holdService := NewHoldService(logger, mockUserRepo, mockLimits, event.NewBus())
+
+	deletedAt := time.Now()
+	recipient := 2
+	limits := []entity.SpendingLimit{{Operation: entity.LimitTransfer, Period: entity.LimitDaily, Amount: 100}}
+	mockLimits.On("Active", 1).Return(limits, nil)
+	mockUserRepo.On("FindUserByUsername", "bob").Return(&entity.User{ID: 2, Username: "bob"}, nil)
+	mockUserRepo.On("FindUserByUsername", "alice").Return(&entity.User{ID: 1, Username: "alice"}, nil)
+	mockUserRepo.On("FindUserByUsername", "gone").Return(&entity.User{ID: 3, Username: "gone", DeletedAt: &deletedAt}, nil)
+
+	released := &entity.Hold{ID: 3, SponsorID: 1, Sponsor: "alice", Amount: 100, Status: entity.HoldReleased, RecipientID: &recipient, Recipient: "bob"}
+	mockUserRepo.On("ReleaseHold", int64(3), 1, 2, limits).Return(released, nil).Once()
+	mockUserRepo.On("ReleaseHold", int64(3), 1, 2, limits).Return(nil, repository.ErrorHoldNotActive).Once()
+	mockUserRepo.On("ReleaseHold", int64(4), 1, 2, limits).Return(nil, repository.ErrorLimitExceeded).Once()
+
+	hold, err := holdService.Release(context.Background(), 1, 3, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, entity.HoldReleased, hold.Status)
+
+	_, err = holdService.Release(context.Background(), 1, 3, "bob")
+	assert.ErrorIs(t, err, repository.ErrorHoldNotActive)
+	_, err = holdService.Release(context.Background(), 1, 4, "bob")
+	assert.ErrorIs(t, err, repository.ErrorLimitExceeded)
+	_, err = holdService.Release(context.Background(), 1, 3, "alice")
+	assert.ErrorIs(t, err, ErrSelfRelease)
+	_, err = holdService.Release(context.Background(), 1, 3, "gone")
+	assert.ErrorIs(t, err, repository.ErrorUserNotFound)
+	mockUserRepo.AssertNumberOfCalls(t, "ReleaseHold", 3)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestHoldService_Cancel(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+
+	holdService := NewHoldService(logger, mockUserRepo, unlimited(), event.NewBus())
+
+	cancelled := &entity.Hold{ID: 3, SponsorID: 1, Sponsor: "alice", Amount: 100, Status: entity.HoldCancelled}
+	mockUserRepo.On("CancelHold", int64(3), 1).Return(cancelled, nil).Once()
+	mockUserRepo.On("CancelHold", int64(4), 1).Return(nil, repository.ErrorHoldNotFound).Once()
+
+	hold, err := holdService.Cancel(context.Background(), 1, 3)
+	require.NoError(t, err)
+	assert.Equal(t, entity.HoldCancelled, hold.Status)
+
+	_, err = holdService.Cancel(context.Background(), 1, 4)
+	assert.ErrorIs(t, err, repository.ErrorHoldNotFound)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestHoldService_List(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+
+	holdService := NewHoldService(logger, mockUserRepo, unlimited(), event.NewBus())
+
+	mockUserRepo.On("ListHolds", 1, MaxHoldPageSize).Return([]entity.Hold{}, nil).Once()
+	mockUserRepo.On("ListHolds", 1, 1).Return([]entity.Hold{}, nil).Once()
+
+	_, err := holdService.List(context.Background(), 1, 1000)
+	require.NoError(t, err)
+	_, err = holdService.List(context.Background(), 1, 0)
+	require.NoError(t, err)
+	mockUserRepo.AssertExpectations(t)
+}
@@ -2,57 +2,46 @@ package service
 
 import (
 	"AvitoTech/internal/entity"
+	"AvitoTech/internal/logging"
 	"AvitoTech/internal/repository"
+	"AvitoTech/internal/tracing"
+	"context"
 	"go.uber.org/zap"
 )
 
 type InfoService struct {
 	l *zap.Logger
 
-	userRepo      repository.UserRepository
-	historyRepo   repository.HistoryRepository
-	inventoryRepo repository.InventoryRepository
+	accountRepo repository.AccountRepository
+	limits      Limits
 }
 
-func (i InfoService) GetInfo(userID int) (*entity.AccountInfo, error) {
-	user, err := i.userRepo.FindUserByID(userID)
-	if err != nil {
-		i.l.Debug("user not found", zap.Error(err))
-		return nil, err
-	}
+func (i InfoService) GetInfo(ctx context.Context, userID int) (_ *entity.AccountInfo, err error) {
+	ctx, span := tracing.Start(ctx, "InfoService.GetInfo")
+	defer tracing.End(span, &err)
 
-	sent, err := i.historyRepo.GetSentByUser(user.Username)
+	info, err := i.accountRepo.GetAccountInfo(ctx, userID)
 	if err != nil {
-		i.l.Debug("history not found", zap.Error(err))
-	}
-	received, err := i.historyRepo.GetReceivedByUser(user.Username)
-	if err != nil {
-		i.l.Debug("history not found", zap.Error(err))
+		logging.FromContext(ctx, i.l).Debug("failed to get account info", zap.Int("user id", userID), zap.Error(err))
+		return nil, err
 	}
 
-	inventory, err := i.inventoryRepo.GetUsersInventory(user.ID)
+	info.Limits, err = i.limits.Effective(ctx, userID)
 	if err != nil {
-		i.l.Debug("inventory not found", zap.Error(err))
+		return nil, err
 	}
 
-	return &entity.AccountInfo{
-		Coins:     user.Balance,
-		Sent:      sent,
-		Received:  received,
-		Inventory: inventory,
-	}, nil
+	return info, nil
 }
 
 func NewInfoService(
 	l *zap.Logger,
-	u repository.UserRepository,
-	h repository.HistoryRepository,
-	i repository.InventoryRepository,
+	a repository.AccountRepository,
+	limits Limits,
 ) Info {
 	return &InfoService{
-		l:             l,
-		userRepo:      u,
-		historyRepo:   h,
-		inventoryRepo: i,
+		l:           l,
+		accountRepo: a,
+		limits:      limits,
 	}
 }
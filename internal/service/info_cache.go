@@ -0,0 +1,104 @@
+package service
+
+import (
+	"AvitoTech/internal/cache"
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/event"
+	"AvitoTech/internal/logging"
+	"AvitoTech/internal/tracing"
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"sync"
+)
+
+// generationStripes is the number of invalidation counters users are spread
+// over. A fill is discarded if any user of its stripe was invalidated while
+// the overview was being read.
+const generationStripes = 256
+
+// generationStripe guards the entries of its users: a fill checks the
+// generation and stores the overview under mu, and an invalidation bumps the
+// generation and drops the entry under mu, so that one can't slip between the
+// check and the store of the other.
+type generationStripe struct {
+	mu         sync.Mutex
+	generation uint64
+}
+
+// CachedInfoService is a read-through cache in front of another Info service.
+// Entries are dropped as soon as a BalanceChanged event for the user is
+// published.
+type CachedInfoService struct {
+	l *zap.Logger
+
+	next  Info
+	store cache.Store[int, *entity.AccountInfo]
+
+	stripes [generationStripes]generationStripe
+}
+
+func (c *CachedInfoService) GetInfo(ctx context.Context, userID int) (_ *entity.AccountInfo, err error) {
+	ctx, span := tracing.Start(ctx, "CachedInfoService.GetInfo")
+	defer tracing.End(span, &err)
+
+	if info, ok := c.store.Get(ctx, userID); ok {
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		return info, nil
+	}
+	span.SetAttributes(attribute.Bool("cache.hit", false))
+
+	stripe := c.stripe(userID)
+	stripe.mu.Lock()
+	generation := stripe.generation
+	stripe.mu.Unlock()
+
+	info, err := c.next.GetInfo(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// a concurrent change may have been committed after our snapshot was
+	// taken; caching the result then would hide it until the entry expires
+	stripe.mu.Lock()
+	defer stripe.mu.Unlock()
+	if stripe.generation == generation {
+		c.store.Set(ctx, userID, info)
+	}
+	return info, nil
+}
+
+func (c *CachedInfoService) stripe(userID int) *generationStripe {
+	return &c.stripes[uint(userID)%generationStripes]
+}
+
+func (c *CachedInfoService) handle(ctx context.Context, e any) {
+	changed, ok := e.(event.BalanceChanged)
+	if !ok {
+		return
+	}
+
+	for _, id := range changed.UserIDs {
+		stripe := c.stripe(id)
+		stripe.mu.Lock()
+		stripe.generation++
+		c.store.Delete(ctx, id)
+		stripe.mu.Unlock()
+	}
+	logging.FromContext(ctx, c.l).Debug("account info invalidated", zap.Ints("user ids", changed.UserIDs))
+}
+
+func NewCachedInfoService(
+	l *zap.Logger,
+	next Info,
+	store cache.Store[int, *entity.AccountInfo],
+	events event.Subscriber,
+) *CachedInfoService {
+	c := &CachedInfoService{
+		l:     l,
+		next:  next,
+		store: store,
+	}
+	events.Subscribe(c.handle)
+	return c
+}
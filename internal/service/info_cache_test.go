@@ -0,0 +1,166 @@
+package service
+
+import (
+	"AvitoTech/internal/cache"
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/event"
+	mocks "AvitoTech/test/mock"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestCachedInfoService_GetInfo_ServesFromCache(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockAccountRepo := new(mocks.MockAccountRepository)
+	store := cache.NewLRU[int, *entity.AccountInfo](10, time.Minute)
+
+	infoService := NewCachedInfoService(logger, NewInfoService(logger, mockAccountRepo, unlimited()), store, event.NewBus())
+
+	mockAccountRepo.On("GetAccountInfo", 1).Return(&entity.AccountInfo{Coins: 1000}, nil).Once()
+
+	for i := 0; i < 3; i++ {
+		info, err := infoService.GetInfo(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 1000, info.Coins)
+	}
+
+	assert.Equal(t, cache.Stats{Hits: 2, Misses: 1}, store.Stats())
+	mockAccountRepo.AssertExpectations(t)
+}
+
+func TestCachedInfoService_NoStaleBalanceAfterTransfer(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockAccountRepo := new(mocks.MockAccountRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	bus := event.NewBus()
+	store := cache.NewLRU[int, *entity.AccountInfo](10, time.Minute)
+
+	infoService := NewCachedInfoService(logger, NewInfoService(logger, mockAccountRepo, unlimited()), store, bus)
+	coinService := NewCoinService(logger, mockUserRepo, unlimited(), bus)
+
+	sender := &entity.User{ID: 1, Username: "sender", Balance: 1000}
+	receiver := &entity.User{ID: 2, Username: "receiver", Balance: 500}
+
+	mockAccountRepo.On("GetAccountInfo", sender.ID).Return(&entity.AccountInfo{Coins: 1000}, nil).Once()
+	mockAccountRepo.On("GetAccountInfo", receiver.ID).Return(&entity.AccountInfo{Coins: 500}, nil).Once()
+	mockAccountRepo.On("GetAccountInfo", sender.ID).Return(&entity.AccountInfo{Coins: 900}, nil).Once()
+	mockAccountRepo.On("GetAccountInfo", receiver.ID).Return(&entity.AccountInfo{Coins: 600}, nil).Once()
+
+	mockUserRepo.On("FindUserByID", sender.ID).Return(sender, nil)
+	mockUserRepo.On("FindUserByUsername", receiver.Username).Return(receiver, nil)
+	mockUserRepo.On("TransferMoney", sender.ID, receiver.ID, 100, noLimits).Return(nil)
+
+	ctx := context.Background()
+
+	info, err := infoService.GetInfo(ctx, sender.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, info.Coins)
+	info, err = infoService.GetInfo(ctx, receiver.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 500, info.Coins)
+
+	err = coinService.SendCoin(ctx, sender.ID, receiver.Username, 100)
+	assert.NoError(t, err)
+
+	info, err = infoService.GetInfo(ctx, sender.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 900, info.Coins)
+	info, err = infoService.GetInfo(ctx, receiver.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 600, info.Coins)
+
+	mockAccountRepo.AssertExpectations(t)
+}
+
+func TestCachedInfoService_DiscardsFillRacingWithChange(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	bus := event.NewBus()
+	store := cache.NewLRU[int, *entity.AccountInfo](10, time.Minute)
+
+	// the change is committed while the stale overview is being read
+	next := infoFunc(func(ctx context.Context, userID int) (*entity.AccountInfo, error) {
+		bus.Publish(ctx, event.BalanceChanged{UserIDs: []int{userID}})
+		return &entity.AccountInfo{Coins: 1000}, nil
+	})
+	infoService := NewCachedInfoService(logger, next, store, bus)
+
+	_, err := infoService.GetInfo(context.Background(), 1)
+	assert.NoError(t, err)
+
+	_, ok := store.Get(context.Background(), 1)
+	assert.False(t, ok)
+}
+
+func TestCachedInfoService_ChangeBetweenCheckAndStore(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	bus := event.NewBus()
+	lru := cache.NewLRU[int, *entity.AccountInfo](10, time.Minute)
+
+	// the first read returns the overview from before the transfer and
+	// blocks until the test lets it store it
+	release := make(chan struct{})
+	reads := 0
+	next := infoFunc(func(_ context.Context, _ int) (*entity.AccountInfo, error) {
+		reads++
+		if reads == 1 {
+			<-release
+			return &entity.AccountInfo{Coins: 1000}, nil
+		}
+		return &entity.AccountInfo{Coins: 900}, nil
+	})
+
+	// the change is published once the fill has passed its generation
+	// check; the fill gets a moment to store the stale overview first
+	published := make(chan struct{})
+	var once sync.Once
+	store := &hookStore{Store: lru, beforeSet: func() {
+		once.Do(func() {
+			go func() {
+				bus.Publish(context.Background(), event.BalanceChanged{UserIDs: []int{1}})
+				close(published)
+			}()
+			select {
+			case <-published:
+			case <-time.After(50 * time.Millisecond):
+			}
+		})
+	}}
+	infoService := NewCachedInfoService(logger, next, store, bus)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := infoService.GetInfo(context.Background(), 1)
+		assert.NoError(t, err)
+	}()
+	close(release)
+	<-done
+	<-published
+
+	info, err := infoService.GetInfo(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 900, info.Coins)
+	assert.Equal(t, 2, reads)
+}
+
+// hookStore runs beforeSet ahead of every Set of the wrapped store.
+type hookStore struct {
+	cache.Store[int, *entity.AccountInfo]
+	beforeSet func()
+}
+
+func (s *hookStore) Set(ctx context.Context, key int, value *entity.AccountInfo) {
+	s.beforeSet()
+	s.Store.Set(ctx, key, value)
+}
+
+type infoFunc func(ctx context.Context, userID int) (*entity.AccountInfo, error)
+
+func (f infoFunc) GetInfo(ctx context.Context, userID int) (*entity.AccountInfo, error) {
+	return f(ctx, userID)
+}
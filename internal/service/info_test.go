@@ -4,6 +4,7 @@ import (
 	"AvitoTech/internal/entity"
 	"AvitoTech/internal/repository"
 	mocks "AvitoTech/test/mock"
+	"context"
 	"errors"
 	"testing"
 
@@ -13,11 +14,9 @@ import (
 
 func TestInfoService_GetInfo_Success(t *testing.T) {
 	logger, _ := zap.NewProduction()
-	mockUserRepo := new(mocks.MockUserRepository)
-	mockHistoryRepo := new(mocks.MockHistoryRepository)
-	mockInventoryRepo := new(mocks.MockInventoryRepository)
+	mockAccountRepo := new(mocks.MockAccountRepository)
 
-	infoService := NewInfoService(logger, mockUserRepo, mockHistoryRepo, mockInventoryRepo)
+	infoService := NewInfoService(logger, mockAccountRepo, unlimited())
 
 	userID := 1
 	username := "testuser"
@@ -33,16 +32,14 @@ func TestInfoService_GetInfo_Success(t *testing.T) {
 		"item2": 2,
 	}
 
-	mockUserRepo.On("FindUserByID", userID).Return(&entity.User{
-		ID:       userID,
-		Username: username,
-		Balance:  balance,
+	mockAccountRepo.On("GetAccountInfo", userID).Return(&entity.AccountInfo{
+		Coins:     balance,
+		Sent:      sentOperations,
+		Received:  receivedOperations,
+		Inventory: inventory,
 	}, nil)
-	mockHistoryRepo.On("GetSentByUser", username).Return(sentOperations, nil)
-	mockHistoryRepo.On("GetReceivedByUser", username).Return(receivedOperations, nil)
-	mockInventoryRepo.On("GetUsersInventory", userID).Return(inventory, nil)
 
-	info, err := infoService.GetInfo(userID)
+	info, err := infoService.GetInfo(context.Background(), userID)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, info)
@@ -51,61 +48,40 @@ func TestInfoService_GetInfo_Success(t *testing.T) {
 	assert.Equal(t, receivedOperations, info.Received)
 	assert.Equal(t, inventory, info.Inventory)
 
-	mockUserRepo.AssertExpectations(t)
-	mockHistoryRepo.AssertExpectations(t)
-	mockInventoryRepo.AssertExpectations(t)
+	mockAccountRepo.AssertExpectations(t)
 }
 
 func TestInfoService_GetInfo_UserNotFound(t *testing.T) {
 	logger, _ := zap.NewProduction()
-	mockUserRepo := new(mocks.MockUserRepository)
-	mockHistoryRepo := new(mocks.MockHistoryRepository)
-	mockInventoryRepo := new(mocks.MockInventoryRepository)
+	mockAccountRepo := new(mocks.MockAccountRepository)
 
-	infoService := NewInfoService(logger, mockUserRepo, mockHistoryRepo, mockInventoryRepo)
+	infoService := NewInfoService(logger, mockAccountRepo, unlimited())
 
 	userID := 1
-	mockUserRepo.On("FindUserByID", userID).Return(&entity.User{}, repository.ErrorUserNotFound)
+	mockAccountRepo.On("GetAccountInfo", userID).Return(nil, repository.ErrorUserNotFound)
 
-	info, err := infoService.GetInfo(userID)
+	info, err := infoService.GetInfo(context.Background(), userID)
 
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, repository.ErrorUserNotFound)
 	assert.Nil(t, info)
 
-	mockUserRepo.AssertExpectations(t)
+	mockAccountRepo.AssertExpectations(t)
 }
 
-func TestInfoService_GetInfo_HistoryError(t *testing.T) {
+func TestInfoService_GetInfo_RepositoryError(t *testing.T) {
 	logger, _ := zap.NewProduction()
-	mockUserRepo := new(mocks.MockUserRepository)
-	mockHistoryRepo := new(mocks.MockHistoryRepository)
-	mockInventoryRepo := new(mocks.MockInventoryRepository)
+	mockAccountRepo := new(mocks.MockAccountRepository)
 
-	infoService := NewInfoService(logger, mockUserRepo, mockHistoryRepo, mockInventoryRepo)
+	infoService := NewInfoService(logger, mockAccountRepo, unlimited())
 
 	userID := 1
-	username := "testuser"
-	balance := 1000
-	historyError := errors.New("history error")
-
-	mockUserRepo.On("FindUserByID", userID).Return(&entity.User{
-		ID:       userID,
-		Username: username,
-		Balance:  balance,
-	}, nil)
-	mockHistoryRepo.On("GetSentByUser", username).Return([]entity.Operation{}, historyError)
-	mockHistoryRepo.On("GetReceivedByUser", username).Return([]entity.Operation{}, historyError)
-	mockInventoryRepo.On("GetUsersInventory", userID).Return(map[string]int{}, nil)
+	readError := errors.New("history error")
+	mockAccountRepo.On("GetAccountInfo", userID).Return(nil, readError)
 
-	info, err := infoService.GetInfo(userID)
+	info, err := infoService.GetInfo(context.Background(), userID)
 
-	assert.NoError(t, err)
-	assert.NotNil(t, info)
-	assert.Equal(t, balance, info.Coins)
-	assert.Empty(t, info.Sent)
-	assert.Empty(t, info.Received)
+	assert.ErrorIs(t, err, readError)
+	assert.Nil(t, info)
 
-	mockUserRepo.AssertExpectations(t)
-	mockHistoryRepo.AssertExpectations(t)
-	mockInventoryRepo.AssertExpectations(t)
+	mockAccountRepo.AssertExpectations(t)
 }
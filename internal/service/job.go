@@ -0,0 +1,38 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"AvitoTech/internal/tracing"
+	"context"
+	"go.uber.org/zap"
+)
+
+// MaxJobRunPageSize bounds the limit of ListRuns.
+const MaxJobRunPageSize = 100
+
+// JobService reports the runs of the scheduled jobs.
+type JobService struct {
+	l *zap.Logger
+
+	runs repository.JobRunRepository
+}
+
+// ListRuns returns the latest runs, of job only unless it is empty. The limit
+// is clamped to 1..MaxJobRunPageSize.
+func (j JobService) ListRuns(ctx context.Context, job string, limit int) (_ []entity.JobRun, err error) {
+	ctx, span := tracing.Start(ctx, "JobService.ListRuns")
+	defer tracing.End(span, &err)
+
+	return j.runs.List(ctx, job, min(max(limit, 1), MaxJobRunPageSize))
+}
+
+func NewJobService(
+	l *zap.Logger,
+	runs repository.JobRunRepository,
+) Jobs {
+	return &JobService{
+		l:    l,
+		runs: runs,
+	}
+}
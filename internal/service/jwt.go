@@ -1,6 +1,7 @@
 package service
 
 import (
+	"AvitoTech/internal/entity"
 	"errors"
 	"github.com/golang-jwt/jwt/v5"
 	"go.uber.org/zap"
@@ -11,35 +12,60 @@ type JWTService struct {
 	secret []byte
 }
 
-func (s JWTService) GenerateToken(userID int) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"userID": userID,
-	})
+func (s JWTService) GenerateToken(claims entity.Claims) (string, error) {
+	fields := jwt.MapClaims{
+		"userID":   claims.UserID,
+		"username": claims.Username,
+		"ver":      claims.Version,
+	}
+	if claims.Role != "" {
+		fields["role"] = string(claims.Role)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, fields)
 
 	return token.SignedString(s.secret)
 }
 
-// VerifyToken validates token and return userID from claims
-func (s JWTService) VerifyToken(tokenString string) (int, error) {
+// VerifyToken validates token and return the claims it carries. Tokens issued
+// before versions and roles were introduced have version 0 and the user role.
+func (s JWTService) VerifyToken(tokenString string) (entity.Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return s.secret, nil
 	})
 	if err != nil {
 		s.l.Debug("Error parsing token", zap.Error(err))
-		return -1, err
+		return entity.Claims{}, err
 	}
 
 	if claims, ok := token.Claims.(*jwt.MapClaims); ok && token.Valid {
 		id, ok := (*claims)["userID"].(float64)
 		if !ok {
 			s.l.Debug("Invalid userID type")
-			return -1, errors.New("invalid userID type")
+			return entity.Claims{}, errors.New("invalid userID type")
+		}
+		var version float64
+		if v, present := (*claims)["ver"]; present {
+			version, ok = v.(float64)
+			if !ok {
+				s.l.Debug("Invalid ver type")
+				return entity.Claims{}, errors.New("invalid ver type")
+			}
+		}
+		role := entity.RoleUser
+		if v, present := (*claims)["role"]; present {
+			name, ok := v.(string)
+			if !ok || !entity.Role(name).Valid() {
+				s.l.Debug("Invalid role")
+				return entity.Claims{}, errors.New("invalid role")
+			}
+			role = entity.Role(name)
 		}
-		return int(id), nil
+		username, _ := (*claims)["username"].(string)
+		return entity.Claims{UserID: int(id), Username: username, Version: int(version), Role: role}, nil
 	}
 
 	s.l.Error("Error verifying token", zap.Error(err))
-	return -1, errors.New("error verifying token")
+	return entity.Claims{}, errors.New("error verifying token")
 }
 
 func NewJWTService(l *zap.Logger, s string) Token {
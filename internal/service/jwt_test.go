@@ -1,6 +1,7 @@
 package service
 
 import (
+	"AvitoTech/internal/entity"
 	"testing"
 	"time"
 
@@ -14,15 +15,15 @@ func TestJWTService_GenerateToken(t *testing.T) {
 	secret := "my-secret-key"
 	s := NewJWTService(logger, secret)
 
-	userID := 123
-	token, err := s.GenerateToken(userID)
+	claims := entity.Claims{UserID: 123, Username: "someone", Version: 2, Role: entity.RoleAuditor}
+	token, err := s.GenerateToken(claims)
 
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
 
-	parsedUserID, err := s.VerifyToken(token)
+	parsed, err := s.VerifyToken(token)
 	assert.NoError(t, err)
-	assert.Equal(t, userID, parsedUserID)
+	assert.Equal(t, claims, parsed)
 }
 
 func TestJWTService_VerifyToken_ValidToken(t *testing.T) {
@@ -31,12 +32,12 @@ func TestJWTService_VerifyToken_ValidToken(t *testing.T) {
 	s := NewJWTService(logger, secret)
 
 	userID := 123
-	token, err := s.GenerateToken(userID)
+	token, err := s.GenerateToken(entity.Claims{UserID: userID})
 	assert.NoError(t, err)
 
-	parsedUserID, err := s.VerifyToken(token)
+	parsed, err := s.VerifyToken(token)
 	assert.NoError(t, err)
-	assert.Equal(t, userID, parsedUserID)
+	assert.Equal(t, userID, parsed.UserID)
 }
 
 func TestJWTService_VerifyToken_InvalidToken(t *testing.T) {
@@ -45,10 +46,10 @@ func TestJWTService_VerifyToken_InvalidToken(t *testing.T) {
 	s := NewJWTService(logger, secret)
 
 	invalidToken := "invalid.token.here"
-	parsedUserID, err := s.VerifyToken(invalidToken)
+	parsed, err := s.VerifyToken(invalidToken)
 
 	assert.Error(t, err)
-	assert.Equal(t, -1, parsedUserID)
+	assert.Equal(t, entity.Claims{}, parsed)
 }
 
 func TestJWTService_VerifyToken_InvalidUserIDType(t *testing.T) {
@@ -62,9 +63,9 @@ func TestJWTService_VerifyToken_InvalidUserIDType(t *testing.T) {
 	tokenString, err := token.SignedString([]byte(secret))
 	assert.NoError(t, err)
 
-	parsedUserID, err := s.VerifyToken(tokenString)
+	parsed, err := s.VerifyToken(tokenString)
 	assert.Error(t, err)
-	assert.Equal(t, -1, parsedUserID)
+	assert.Equal(t, entity.Claims{}, parsed)
 	assert.Contains(t, err.Error(), "invalid userID type")
 }
 
@@ -81,8 +82,43 @@ func TestJWTService_VerifyToken_ExpiredToken(t *testing.T) {
 	tokenString, err := token.SignedString([]byte(secret))
 	assert.NoError(t, err)
 
-	parsedUserID, err := s.VerifyToken(tokenString)
+	parsed, err := s.VerifyToken(tokenString)
 	assert.Error(t, err)
-	assert.Equal(t, -1, parsedUserID)
+	assert.Equal(t, entity.Claims{}, parsed)
 	assert.Contains(t, err.Error(), "token is expired")
 }
+
+func TestJWTService_VerifyToken_WithoutVersion(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	secret := "my-secret-key"
+	s := NewJWTService(logger, secret)
+
+	// tokens issued before versions and roles were introduced
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userID": 123,
+	})
+	tokenString, err := token.SignedString([]byte(secret))
+	assert.NoError(t, err)
+
+	parsed, err := s.VerifyToken(tokenString)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.Claims{UserID: 123, Role: entity.RoleUser}, parsed)
+}
+
+func TestJWTService_VerifyToken_InvalidRole(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	secret := "my-secret-key"
+	s := NewJWTService(logger, secret)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userID": 123,
+		"role":   "superuser",
+	})
+	tokenString, err := token.SignedString([]byte(secret))
+	assert.NoError(t, err)
+
+	parsed, err := s.VerifyToken(tokenString)
+	assert.Error(t, err)
+	assert.Equal(t, entity.Claims{}, parsed)
+	assert.Contains(t, err.Error(), "invalid role")
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/event"
+	"AvitoTech/internal/repository"
+	"AvitoTech/internal/tracing"
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"time"
+)
+
+var ErrInvalidLimit = errors.New("invalid spending limit")
+
+// limitKey identifies a limit of a user.
+type limitKey struct {
+	operation entity.LimitOperation
+	period    entity.LimitPeriod
+}
+
+// LimitService caps what users send and spend within rolling windows. The
+// limits are configured for everyone and can be overridden per user by an
+// operator.
+type LimitService struct {
+	l *zap.Logger
+
+	limits   repository.LimitRepository
+	userRepo repository.UserRepository
+
+	// defaults are the configured limits, missing ones are unlimited.
+	defaults map[limitKey]int
+
+	events event.Publisher
+	audit  AuditRecorder
+}
+
+// Active returns the limits in effect for userID, leaving out unlimited
+// operations. The repository checks them within the transaction that spends
+// the coins, so that operations of one user running at the same time can't
+// overshoot a limit together.
+func (s LimitService) Active(ctx context.Context, userID int) (_ []entity.SpendingLimit, err error) {
+	ctx, span := tracing.Start(ctx, "LimitService.Active")
+	defer tracing.End(span, &err)
+
+	limits, err := s.list(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	active := make([]entity.SpendingLimit, 0, len(limits))
+	for _, limit := range limits {
+		if limit.Amount != 0 {
+			active = append(active, limit)
+		}
+	}
+	return active, nil
+}
+
+// Effective returns the limits in effect for userID with what it spent
+// against them. Unlimited operations are left out.
+func (s LimitService) Effective(ctx context.Context, userID int) (_ []entity.SpendingLimit, err error) {
+	ctx, span := tracing.Start(ctx, "LimitService.Effective")
+	defer tracing.End(span, &err)
+
+	limits, err := s.list(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	effective := make([]entity.SpendingLimit, 0, len(limits))
+	now := time.Now()
+	for _, limit := range limits {
+		if limit.Amount == 0 {
+			continue
+		}
+		limit.Used, err = s.limits.Spent(ctx, userID, limit.Operation, now.Add(-limit.Period.Window()))
+		if err != nil {
+			return nil, err
+		}
+		effective = append(effective, limit)
+	}
+	return effective, nil
+}
+
+// Get returns every limit of the user, unlimited ones with an amount of 0.
+func (s LimitService) Get(ctx context.Context, username string) (_ []entity.SpendingLimit, err error) {
+	ctx, span := tracing.Start(ctx, "LimitService.Get")
+	defer tracing.End(span, &err)
+
+	user, err := s.findUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return s.list(ctx, user.ID)
+}
+
+// SetOverride sets limit for the user in place of the configured one. An
+// amount of 0 lifts the limit.
+func (s LimitService) SetOverride(ctx context.Context, username string, limit entity.SpendingLimit, actor string) (err error) {
+	ctx, span := tracing.Start(ctx, "LimitService.SetOverride")
+	defer tracing.End(span, &err)
+
+	if !limit.Operation.Valid() || !limit.Period.Valid() || limit.Amount < 0 {
+		return ErrInvalidLimit
+	}
+	user, err := s.findUser(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	if err = s.limits.SetOverride(ctx, user.ID, limit); err != nil {
+		return err
+	}
+	// the account overview shows the remaining allowance
+	s.events.Publish(ctx, event.BalanceChanged{UserIDs: []int{user.ID}})
+	s.audit.Record(ctx, actor, entity.AuditSetLimit, user.Username, map[string]any{
+		"operation": limit.Operation,
+		"period":    limit.Period,
+		"amount":    limit.Amount,
+	})
+	return nil
+}
+
+// ClearOverride returns the user to the configured limit.
+func (s LimitService) ClearOverride(ctx context.Context, username string, operation entity.LimitOperation, period entity.LimitPeriod, actor string) (err error) {
+	ctx, span := tracing.Start(ctx, "LimitService.ClearOverride")
+	defer tracing.End(span, &err)
+
+	if !operation.Valid() || !period.Valid() {
+		return ErrInvalidLimit
+	}
+	user, err := s.findUser(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	if err = s.limits.DeleteOverride(ctx, user.ID, operation, period); err != nil {
+		return err
+	}
+	s.events.Publish(ctx, event.BalanceChanged{UserIDs: []int{user.ID}})
+	s.audit.Record(ctx, actor, entity.AuditClearLimit, user.Username, map[string]any{
+		"operation": operation,
+		"period":    period,
+	})
+	return nil
+}
+
+// list merges the overrides of userID into the configured limits.
+func (s LimitService) list(ctx context.Context, userID int) ([]entity.SpendingLimit, error) {
+	overrides, err := s.limits.Overrides(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[limitKey]entity.SpendingLimit, len(overrides))
+	for _, limit := range overrides {
+		set[limitKey{limit.Operation, limit.Period}] = limit
+	}
+
+	limits := make([]entity.SpendingLimit, 0, len(entity.LimitOperations)*len(entity.LimitPeriods))
+	for _, operation := range entity.LimitOperations {
+		for _, period := range entity.LimitPeriods {
+			key := limitKey{operation, period}
+			limit, ok := set[key]
+			if !ok {
+				limit = entity.SpendingLimit{Operation: operation, Period: period, Amount: s.defaults[key]}
+			}
+			limits = append(limits, limit)
+		}
+	}
+	return limits, nil
+}
+
+func (s LimitService) findUser(ctx context.Context, username string) (*entity.User, error) {
+	user, err := s.userRepo.FindUserByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt != nil {
+		return nil, repository.ErrorUserNotFound
+	}
+	return user, nil
+}
+
+// NewLimitService takes the configured limits, an operation and period
+// missing from defaults or with an amount of 0 is unlimited.
+func NewLimitService(
+	l *zap.Logger,
+	limits repository.LimitRepository,
+	u repository.UserRepository,
+	defaults []entity.SpendingLimit,
+	e event.Publisher,
+	audit AuditRecorder,
+) Limits {
+	s := &LimitService{
+		l:        l,
+		limits:   limits,
+		userRepo: u,
+		defaults: make(map[limitKey]int, len(defaults)),
+		events:   e,
+		audit:    audit,
+	}
+	for _, limit := range defaults {
+		s.defaults[limitKey{limit.Operation, limit.Period}] = limit.Amount
+	}
+	return s
+}
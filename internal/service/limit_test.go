@@ -0,0 +1,93 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/event"
+	"AvitoTech/internal/repository"
+	mocks "AvitoTech/test/mock"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var testLimits = []entity.SpendingLimit{
+	{Operation: entity.LimitTransfer, Period: entity.LimitDaily, Amount: 100},
+	{Operation: entity.LimitTransfer, Period: entity.LimitWeekly, Amount: 300},
+	{Operation: entity.LimitPurchase, Period: entity.LimitWeekly, Amount: 1000},
+}
+
+func TestLimitService_Active(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockLimits := new(mocks.MockLimitRepository)
+
+	limitService := NewLimitService(logger, mockLimits, new(mocks.MockUserRepository), testLimits, event.NewBus(), new(mocks.AuditLog))
+
+	mockLimits.On("Overrides", 1).Return([]entity.SpendingLimit{}, nil)
+
+	// purchases have no daily limit, what was spent is left to the repository
+	limits, err := limitService.Active(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, testLimits, limits)
+	mockLimits.AssertNotCalled(t, "Spent", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestLimitService_Overrides(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockLimits := new(mocks.MockLimitRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	audit := new(mocks.AuditLog)
+
+	limitService := NewLimitService(logger, mockLimits, mockUserRepo, testLimits, event.NewBus(), audit)
+
+	mockUserRepo.On("FindUserByUsername", "alice").Return(&entity.User{ID: 1, Username: "alice"}, nil)
+	mockUserRepo.On("FindUserByUsername", "nobody").Return(&entity.User{}, repository.ErrorUserNotFound)
+
+	// lifting the daily limit leaves only the weekly one
+	mockLimits.On("Overrides", 1).Return([]entity.SpendingLimit{
+		{Operation: entity.LimitTransfer, Period: entity.LimitDaily, Amount: 0, Override: true},
+	}, nil)
+	limits, err := limitService.Active(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, []entity.SpendingLimit{
+		{Operation: entity.LimitTransfer, Period: entity.LimitWeekly, Amount: 300},
+		{Operation: entity.LimitPurchase, Period: entity.LimitWeekly, Amount: 1000},
+	}, limits)
+
+	limits, err = limitService.Get(context.Background(), "alice")
+	require.NoError(t, err)
+	assert.Equal(t, []entity.SpendingLimit{
+		{Operation: entity.LimitTransfer, Period: entity.LimitDaily, Amount: 0, Override: true},
+		{Operation: entity.LimitTransfer, Period: entity.LimitWeekly, Amount: 300},
+		{Operation: entity.LimitPurchase, Period: entity.LimitDaily, Amount: 0},
+		{Operation: entity.LimitPurchase, Period: entity.LimitWeekly, Amount: 1000},
+	}, limits)
+
+	limit := entity.SpendingLimit{Operation: entity.LimitPurchase, Period: entity.LimitDaily, Amount: 50}
+	mockLimits.On("SetOverride", 1, limit).Return(nil).Once()
+	require.NoError(t, limitService.SetOverride(context.Background(), "alice", limit, "admin"))
+	assert.Equal(t, []entity.AuditEntry{{
+		Actor:   "admin",
+		Action:  entity.AuditSetLimit,
+		Target:  "alice",
+		Payload: []byte(`{"amount":50,"operation":"purchase","period":"daily"}`),
+	}}, audit.Entries())
+
+	mockLimits.On("DeleteOverride", 1, entity.LimitPurchase, entity.LimitDaily).Return(nil).Once()
+	mockLimits.On("DeleteOverride", 1, entity.LimitPurchase, entity.LimitWeekly).Return(repository.ErrorLimitNotFound).Once()
+	require.NoError(t, limitService.ClearOverride(context.Background(), "alice", entity.LimitPurchase, entity.LimitDaily, "admin"))
+	err = limitService.ClearOverride(context.Background(), "alice", entity.LimitPurchase, entity.LimitWeekly, "admin")
+	assert.ErrorIs(t, err, repository.ErrorLimitNotFound)
+	assert.Equal(t, []string{entity.AuditSetLimit, entity.AuditClearLimit}, audit.Actions())
+
+	err = limitService.SetOverride(context.Background(), "alice", entity.SpendingLimit{Operation: "gift", Period: entity.LimitDaily}, "admin")
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+	err = limitService.SetOverride(context.Background(), "alice", entity.SpendingLimit{Operation: entity.LimitTransfer, Period: entity.LimitDaily, Amount: -1}, "admin")
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+	err = limitService.SetOverride(context.Background(), "nobody", limit, "admin")
+	assert.ErrorIs(t, err, repository.ErrorUserNotFound)
+	mockLimits.AssertExpectations(t)
+}
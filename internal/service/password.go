@@ -0,0 +1,190 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/logging"
+	"AvitoTech/internal/repository"
+	"AvitoTech/internal/tracing"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"time"
+)
+
+var (
+	ErrWrongPassword      = errors.New("current password is incorrect")
+	ErrWeakPassword       = errors.New("password is too short")
+	ErrInvalidResetToken  = errors.New("reset token is invalid or expired")
+	resetTokenEntropySize = 32
+)
+
+// PasswordPolicy is how passwords are hashed and reset.
+type PasswordPolicy struct {
+	// Cost is the bcrypt cost of new hashes. Hashes of another cost are
+	// rehashed on the next successful login.
+	Cost      int
+	MinLength int
+	// ResetTTL is how long an issued reset token can be used.
+	ResetTTL time.Duration
+}
+
+// ChangePassword replaces the password of the user, who has to confirm the
+// current one. Every session of the user is revoked and a token for the
+// caller's new session is returned.
+func (a AuthService) ChangePassword(ctx context.Context, userID int, current, password string) (token string, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.ChangePassword")
+	defer tracing.End(span, &err)
+
+	if len(password) < a.passwords.MinLength {
+		return "", ErrWeakPassword
+	}
+
+	user, err := a.userRepository.FindUserByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	_, compareSpan := tracing.Start(ctx, "bcrypt.CompareHashAndPassword")
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(current))
+	compareSpan.End()
+	if err != nil {
+		return "", ErrWrongPassword
+	}
+
+	hash, err := a.hash(ctx, password)
+	if err != nil {
+		return "", err
+	}
+	version, err := a.userRepository.SetPassword(ctx, userID, hash, true)
+	if err != nil {
+		return "", err
+	}
+
+	logging.FromContext(ctx, a.l).Info("password changed", zap.Int("user id", userID))
+	a.audit.Record(ctx, user.Username, entity.AuditPasswordChange, user.Username, nil)
+	claims := claimsOf(user)
+	claims.Version = version
+	return a.jwtService.GenerateToken(claims)
+}
+
+// IssuePasswordReset creates a reset token for username on behalf of actor.
+// The token is only ever returned here, the database keeps its hash.
+func (a AuthService) IssuePasswordReset(ctx context.Context, username, actor string) (token string, expiresAt time.Time, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.IssuePasswordReset")
+	defer tracing.End(span, &err)
+
+	user, err := a.userRepository.FindUserByUsername(ctx, username)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	raw := make([]byte, resetTokenEntropySize)
+	if _, err = rand.Read(raw); err != nil {
+		return "", time.Time{}, err
+	}
+	token = base64.RawURLEncoding.EncodeToString(raw)
+
+	reset, err := a.resets.Create(ctx, user.ID, hashResetToken(token), a.passwords.ResetTTL)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	logging.FromContext(ctx, a.l).Warn("password reset issued",
+		zap.String("username", username),
+		zap.String("by", actor),
+		zap.Time("expires at", reset.ExpiresAt),
+	)
+	a.audit.Record(ctx, actor, entity.AuditIssueReset, username, map[string]any{"expiresAt": reset.ExpiresAt})
+	return token, reset.ExpiresAt, nil
+}
+
+// ResetPassword sets password for the user the reset token was issued to and
+// revokes all of the user's sessions. A token works once.
+func (a AuthService) ResetPassword(ctx context.Context, token, password string) (err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.ResetPassword")
+	defer tracing.End(span, &err)
+
+	if len(password) < a.passwords.MinLength {
+		return ErrWeakPassword
+	}
+
+	// bcrypt is only paid for tokens that can be used
+	tokenHash := hashResetToken(token)
+	_, err = a.resets.Find(ctx, tokenHash)
+	if errors.Is(err, repository.ErrorResetTokenInvalid) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+
+	hash, err := a.hash(ctx, password)
+	if err != nil {
+		return err
+	}
+
+	userID, err := a.resets.Consume(ctx, tokenHash, hash)
+	if errors.Is(err, repository.ErrorResetTokenInvalid) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+
+	l := logging.FromContext(ctx, a.l)
+	l.Info("password reset", zap.Int("user id", userID))
+
+	// the token stands in for the user, who is named in the audit log
+	var username string
+	if user, err := a.userRepository.FindUserByID(ctx, userID); err == nil {
+		username = user.Username
+	} else {
+		l.Warn("failed to find user of reset token", zap.Int("user id", userID), zap.Error(err))
+	}
+	a.audit.Record(ctx, username, entity.AuditPasswordReset, username, map[string]any{"userID": userID})
+	return nil
+}
+
+// rehash upgrades the stored hash of user to the configured cost. It runs
+// after a successful login, when the plain password is at hand, and a failure
+// only costs the upgrade.
+func (a AuthService) rehash(ctx context.Context, user *entity.User, password string) {
+	cost, err := bcrypt.Cost([]byte(user.Password))
+	if err != nil || cost == a.passwords.Cost {
+		return
+	}
+
+	l := logging.FromContext(ctx, a.l)
+	hash, err := a.hash(ctx, password)
+	if err != nil {
+		l.Warn("failed to rehash password", zap.Error(err))
+		return
+	}
+	if _, err = a.userRepository.SetPassword(ctx, user.ID, hash, false); err != nil {
+		l.Warn("failed to store rehashed password", zap.Error(err))
+		return
+	}
+	l.Info("password rehashed", zap.Int("user id", user.ID), zap.Int("from cost", cost), zap.Int("to cost", a.passwords.Cost))
+}
+
+func (a AuthService) hash(ctx context.Context, password string) (string, error) {
+	_, span := tracing.Start(ctx, "bcrypt.GenerateFromPassword")
+	defer span.End()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), a.passwords.Cost)
+	if err != nil {
+		return "", fmt.Errorf("hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	mocks "AvitoTech/test/mock"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthService_ChangePassword(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockToken := new(mocks.MockToken)
+
+	authService := NewAuthService(logger, mockUserRepo, mockToken, new(mocks.MockLoginAttemptRepository), new(mocks.MockPasswordResetRepository), testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, new(mocks.AuditLog))
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("oldpassword"), testPasswordPolicy.Cost)
+	assert.NoError(t, err)
+
+	mockUserRepo.On("FindUserByID", 1).Return(&entity.User{ID: 1, Username: "changer", Password: string(hashedPassword), TokenVersion: 3, Role: entity.RoleAuditor}, nil)
+	mockUserRepo.On("SetPassword", 1, mock.MatchedBy(func(hash string) bool {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte("newpassword")) == nil
+	}), true).Return(4, nil)
+	mockToken.On("GenerateToken", entity.Claims{UserID: 1, Username: "changer", Version: 4, Role: entity.RoleAuditor}).Return("new-token", nil)
+
+	token, err := authService.ChangePassword(context.Background(), 1, "oldpassword", "newpassword")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "new-token", token)
+
+	mockUserRepo.AssertExpectations(t)
+	mockToken.AssertExpectations(t)
+}
+
+func TestAuthService_ChangePassword_WrongPassword(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+
+	authService := NewAuthService(logger, mockUserRepo, new(mocks.MockToken), new(mocks.MockLoginAttemptRepository), new(mocks.MockPasswordResetRepository), testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, new(mocks.AuditLog))
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("oldpassword"), testPasswordPolicy.Cost)
+	assert.NoError(t, err)
+
+	mockUserRepo.On("FindUserByID", 1).Return(&entity.User{ID: 1, Password: string(hashedPassword)}, nil)
+
+	_, err = authService.ChangePassword(context.Background(), 1, "notmypassword", "newpassword")
+
+	assert.ErrorIs(t, err, ErrWrongPassword)
+	mockUserRepo.AssertNotCalled(t, "SetPassword", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthService_ChangePassword_WeakPassword(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+
+	authService := NewAuthService(logger, mockUserRepo, new(mocks.MockToken), new(mocks.MockLoginAttemptRepository), new(mocks.MockPasswordResetRepository), testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, new(mocks.AuditLog))
+
+	_, err := authService.ChangePassword(context.Background(), 1, "oldpassword", "short")
+
+	assert.ErrorIs(t, err, ErrWeakPassword)
+	mockUserRepo.AssertNotCalled(t, "FindUserByID", mock.Anything)
+}
+
+func TestAuthService_Authenticate_RehashesOnCostChange(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockToken := new(mocks.MockToken)
+	mockAttempts := new(mocks.MockLoginAttemptRepository)
+
+	authService := NewAuthService(logger, mockUserRepo, mockToken, mockAttempts, new(mocks.MockPasswordResetRepository), testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, new(mocks.AuditLog))
+
+	username := "existinguser"
+	password := "validpassword123"
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), testPasswordPolicy.Cost+1)
+	assert.NoError(t, err)
+
+	mockUserRepo.On("FindUserByUsername", username).
+		Return(&entity.User{ID: 1, Username: username, Password: string(hashedPassword)}, nil)
+	mockAttempts.On("ActiveLockout", username).Return(nil, nil)
+	mockAttempts.On("CountFailures", username, testIP, testLoginPolicy.Window).Return(0, 0, nil)
+	mockUserRepo.On("SetPassword", 1, mock.MatchedBy(func(hash string) bool {
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err == nil && cost == testPasswordPolicy.Cost
+	}), false).Return(0, nil)
+	mockToken.On("GenerateToken", entity.Claims{UserID: 1, Username: username}).Return("generated-token", nil)
+
+	token, err := authService.Authenticate(context.Background(), username, password, testIP)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "generated-token", token)
+
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestAuthService_IssuePasswordReset(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockResets := new(mocks.MockPasswordResetRepository)
+
+	authService := NewAuthService(logger, mockUserRepo, new(mocks.MockToken), new(mocks.MockLoginAttemptRepository), mockResets, testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, new(mocks.AuditLog))
+
+	expiresAt := time.Now().Add(testPasswordPolicy.ResetTTL)
+	var storedHash string
+
+	mockUserRepo.On("FindUserByUsername", "forgetful").Return(&entity.User{ID: 7, Username: "forgetful"}, nil)
+	mockResets.On("Create", 7, mock.AnythingOfType("string"), testPasswordPolicy.ResetTTL).
+		Run(func(args mock.Arguments) { storedHash = args.String(1) }).
+		Return(&entity.PasswordReset{ID: 1, UserID: 7, ExpiresAt: expiresAt}, nil)
+
+	token, gotExpiresAt, err := authService.IssuePasswordReset(context.Background(), "forgetful", "admin")
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.Equal(t, expiresAt, gotExpiresAt)
+	// only the hash of the token reaches the database
+	assert.NotEqual(t, token, storedHash)
+	assert.Equal(t, hashResetToken(token), storedHash)
+
+	mockResets.AssertExpectations(t)
+}
+
+func TestAuthService_ResetPassword(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockResets := new(mocks.MockPasswordResetRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	audit := new(mocks.AuditLog)
+
+	authService := NewAuthService(logger, mockUserRepo, new(mocks.MockToken), new(mocks.MockLoginAttemptRepository), mockResets, testLoginPolicy, testPasswordPolicy, DefaultSignupPolicy, audit)
+
+	mockUserRepo.On("FindUserByID", 7).Return(&entity.User{ID: 7, Username: "forgetful"}, nil)
+	mockResets.On("Find", hashResetToken("good-token")).Return(7, nil)
+	mockResets.On("Consume", hashResetToken("good-token"), mock.MatchedBy(func(hash string) bool {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte("newpassword")) == nil
+	})).Return(7, nil)
+	mockResets.On("Find", hashResetToken("used-token")).Return(0, repository.ErrorResetTokenInvalid)
+
+	assert.NoError(t, authService.ResetPassword(context.Background(), "good-token", "newpassword"))
+	assert.ErrorIs(t, authService.ResetPassword(context.Background(), "used-token", "newpassword"), ErrInvalidResetToken)
+	assert.ErrorIs(t, authService.ResetPassword(context.Background(), "good-token", "short"), ErrWeakPassword)
+
+	mockResets.AssertExpectations(t)
+	mockResets.AssertNotCalled(t, "Consume", hashResetToken("used-token"), mock.Anything)
+	assert.Equal(t, []entity.AuditEntry{{
+		Actor:   "forgetful",
+		Action:  entity.AuditPasswordReset,
+		Target:  "forgetful",
+		Payload: []byte(`{"userID":7}`),
+	}}, audit.Entries())
+}
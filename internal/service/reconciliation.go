@@ -0,0 +1,109 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/event"
+	"AvitoTech/internal/logging"
+	"AvitoTech/internal/metrics"
+	"AvitoTech/internal/repository"
+	"AvitoTech/internal/tracing"
+	"context"
+	"go.uber.org/zap"
+)
+
+// MaxReportPageSize bounds the limit of List.
+const MaxReportPageSize = 100
+
+// ReconciliationService checks the balances against the ledger: the signup
+// grant plus the coins received, minus the coins sent and the purchases.
+// Balances are only corrected once an operator approves a report.
+type ReconciliationService struct {
+	l *zap.Logger
+
+	reports repository.ReconciliationRepository
+
+	events event.Publisher
+}
+
+// Run reconciles every balance and stores the report.
+func (r ReconciliationService) Run(ctx context.Context) (_ *entity.Reconciliation, err error) {
+	ctx, span := tracing.Start(ctx, "ReconciliationService.Run")
+	defer tracing.End(span, &err)
+
+	report, err := r.reports.Reconcile(ctx, entity.Items)
+	if err != nil {
+		return nil, err
+	}
+	metrics.BalanceDiscrepancies.Set(float64(len(report.Discrepancies)))
+
+	l := logging.FromContext(ctx, r.l)
+	if report.Status == entity.ReconciliationPending {
+		l.Warn("balances differ from the ledger",
+			zap.Int("reconciliation", report.ID),
+			zap.Int("users", report.Users),
+			zap.Int("discrepancies", len(report.Discrepancies)),
+		)
+	} else {
+		l.Info("balances reconciled", zap.Int("reconciliation", report.ID), zap.Int("users", report.Users))
+	}
+	return report, nil
+}
+
+// List returns the latest reports, newest first. The limit is clamped to
+// 1..MaxReportPageSize.
+func (r ReconciliationService) List(ctx context.Context, limit int) (_ []entity.Reconciliation, err error) {
+	ctx, span := tracing.Start(ctx, "ReconciliationService.List")
+	defer tracing.End(span, &err)
+
+	return r.reports.List(ctx, min(max(limit, 1), MaxReportPageSize))
+}
+
+func (r ReconciliationService) Get(ctx context.Context, id int) (_ *entity.Reconciliation, err error) {
+	ctx, span := tracing.Start(ctx, "ReconciliationService.Get")
+	defer tracing.End(span, &err)
+
+	return r.reports.Get(ctx, id)
+}
+
+// Approve sets the balances of the pending report id to the ledger on behalf
+// of actor. Discrepancies that changed since the report was made are skipped.
+func (r ReconciliationService) Approve(ctx context.Context, id int, actor string) (_ *entity.Reconciliation, err error) {
+	ctx, span := tracing.Start(ctx, "ReconciliationService.Approve")
+	defer tracing.End(span, &err)
+
+	report, err := r.reports.Apply(ctx, id, actor, entity.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	for _, d := range report.Discrepancies {
+		if !d.Corrected {
+			continue
+		}
+		ids = append(ids, d.UserID)
+	}
+	if len(ids) > 0 {
+		r.events.Publish(ctx, event.BalanceChanged{UserIDs: ids})
+	}
+
+	logging.FromContext(ctx, r.l).Warn("reconciliation applied",
+		zap.Int("reconciliation", id),
+		zap.Int("corrected", len(ids)),
+		zap.Int("skipped", len(report.Discrepancies)-len(ids)),
+		zap.String("by", actor),
+	)
+	return report, nil
+}
+
+func NewReconciliationService(
+	l *zap.Logger,
+	reports repository.ReconciliationRepository,
+	e event.Publisher,
+) Reconciliation {
+	return &ReconciliationService{
+		l:       l,
+		reports: reports,
+		events:  e,
+	}
+}
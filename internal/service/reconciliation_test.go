@@ -0,0 +1,72 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/event"
+	"AvitoTech/internal/metrics"
+	"AvitoTech/internal/repository"
+	mocks "AvitoTech/test/mock"
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestReconciliationService_Run(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockReports := new(mocks.MockReconciliationRepository)
+
+	reconciliationService := NewReconciliationService(logger, mockReports, event.NewBus())
+
+	mockReports.On("Reconcile", mock.Anything).Return(&entity.Reconciliation{
+		ID:     1,
+		Users:  3,
+		Status: entity.ReconciliationPending,
+		Discrepancies: []entity.Discrepancy{
+			{UserID: 1, Username: "alice", Balance: 90, Expected: 100},
+			{UserID: 2, Username: "bob", Balance: 20, Expected: 10},
+		},
+	}, nil)
+
+	report, err := reconciliationService.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.ID)
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.BalanceDiscrepancies))
+	mockReports.AssertExpectations(t)
+}
+
+func TestReconciliationService_Approve(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockReports := new(mocks.MockReconciliationRepository)
+	bus := event.NewBus()
+
+	var changed []int
+	bus.Subscribe(func(_ context.Context, e any) {
+		changed = e.(event.BalanceChanged).UserIDs
+	})
+
+	reconciliationService := NewReconciliationService(logger, mockReports, bus)
+
+	mockReports.On("Apply", 1, "root", mock.Anything).Return(&entity.Reconciliation{
+		ID:     1,
+		Status: entity.ReconciliationApplied,
+		Discrepancies: []entity.Discrepancy{
+			{UserID: 1, Username: "alice", Balance: 90, Expected: 100, Corrected: true},
+			{UserID: 2, Username: "bob", Balance: 20, Expected: 10},
+		},
+	}, nil)
+	mockReports.On("Apply", 2, "root", mock.Anything).Return(nil, repository.ErrorReportNotPending)
+
+	_, err := reconciliationService.Approve(context.Background(), 1, "root")
+	require.NoError(t, err)
+
+	// only the corrected balances are announced
+	assert.Equal(t, []int{1}, changed)
+
+	_, err = reconciliationService.Approve(context.Background(), 2, "root")
+	assert.ErrorIs(t, err, repository.ErrorReportNotPending)
+}
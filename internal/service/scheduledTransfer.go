@@ -0,0 +1,243 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/event"
+	"AvitoTech/internal/logging"
+	"AvitoTech/internal/metrics"
+	"AvitoTech/internal/repository"
+	"AvitoTech/internal/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	// MinTransferInterval is the shortest period of a recurring transfer.
+	MinTransferInterval = time.Minute
+	// MaxScheduledTransferPageSize bounds the limit of List.
+	MaxScheduledTransferPageSize = 100
+
+	// dueTransferBatch bounds the transfers run by one call of RunDue, the
+	// rest wait for the next poll.
+	dueTransferBatch = 100
+)
+
+var (
+	ErrTransferAmount      = errors.New("amount must be positive")
+	ErrInvalidSchedule     = errors.New("either a run time or a schedule is required")
+	ErrScheduleTooFrequent = errors.New("schedule is too frequent")
+	ErrRunAtPast           = errors.New("run time is in the past")
+	ErrSelfTransfer        = errors.New("coins can't be sent to yourself")
+)
+
+// TransferRetryPolicy is what happens to a scheduled transfer that fails,
+// for instance for lack of coins.
+type TransferRetryPolicy struct {
+	// MaxFailures consecutive failed runs give the transfer up.
+	MaxFailures int
+	// RetryDelay is the wait before a failed one-time transfer is run
+	// again. A failed recurring transfer skips to its next run instead.
+	RetryDelay time.Duration
+}
+
+// ScheduledTransferService runs transfers later, once or on a schedule. The
+// runs follow SendCoin: the receiver must not be deleted and the sender must
+// have the coins within its transfer limits, the transfer is in the history
+// of both.
+type ScheduledTransferService struct {
+	l *zap.Logger
+
+	transfers repository.ScheduledTransferRepository
+	userRepo  repository.UserRepository
+	limits    Limits
+
+	policy TransferRetryPolicy
+
+	events event.Publisher
+	audit  AuditRecorder
+}
+
+// Schedule creates a transfer from senderID to receiver run once at runAt or
+// recurring on spec, a cron spec or an @every interval.
+func (s ScheduledTransferService) Schedule(ctx context.Context, senderID int, receiver string, amount int, runAt *time.Time, spec string) (_ *entity.ScheduledTransfer, err error) {
+	ctx, span := tracing.Start(ctx, "ScheduledTransferService.Schedule")
+	defer tracing.End(span, &err)
+
+	if amount <= 0 {
+		return nil, ErrTransferAmount
+	}
+	if (runAt == nil) == (spec == "") {
+		return nil, ErrInvalidSchedule
+	}
+	now := time.Now()
+	if runAt != nil && !runAt.After(now) {
+		return nil, ErrRunAtPast
+	}
+	if spec != "" {
+		schedule, err := cron.ParseStandard(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidSchedule, err)
+		}
+		next := schedule.Next(now)
+		if schedule.Next(next).Sub(next) < MinTransferInterval {
+			return nil, ErrScheduleTooFrequent
+		}
+		runAt = &next
+	}
+
+	sender, err := s.userRepo.FindUserByID(ctx, senderID)
+	if err != nil {
+		return nil, err
+	}
+	receiverUser, err := s.userRepo.FindUserByUsername(ctx, receiver)
+	if err != nil {
+		return nil, err
+	}
+	if receiverUser.DeletedAt != nil {
+		return nil, repository.ErrorUserNotFound
+	}
+	if receiverUser.ID == sender.ID {
+		return nil, ErrSelfTransfer
+	}
+
+	transfer, err := s.transfers.Create(ctx, entity.ScheduledTransfer{
+		SenderID:   sender.ID,
+		Sender:     sender.Username,
+		ReceiverID: receiverUser.ID,
+		Receiver:   receiverUser.Username,
+		Amount:     amount,
+		Schedule:   spec,
+		NextRunAt:  runAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, sender.Username, entity.AuditScheduleTransfer, receiverUser.Username, map[string]any{
+		"transfer": transfer.ID,
+		"amount":   amount,
+		"schedule": spec,
+		"runAt":    runAt.UTC(),
+	})
+	return transfer, nil
+}
+
+// List returns the transfers of senderID, newest first. The limit is clamped
+// to 1..MaxScheduledTransferPageSize.
+func (s ScheduledTransferService) List(ctx context.Context, senderID int, limit int) (_ []entity.ScheduledTransfer, err error) {
+	ctx, span := tracing.Start(ctx, "ScheduledTransferService.List")
+	defer tracing.End(span, &err)
+
+	return s.transfers.List(ctx, senderID, min(max(limit, 1), MaxScheduledTransferPageSize))
+}
+
+func (s ScheduledTransferService) Cancel(ctx context.Context, senderID int, id int64) (_ *entity.ScheduledTransfer, err error) {
+	ctx, span := tracing.Start(ctx, "ScheduledTransferService.Cancel")
+	defer tracing.End(span, &err)
+
+	transfer, err := s.transfers.Cancel(ctx, id, senderID)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, transfer.Sender, entity.AuditCancelTransfer, transfer.Receiver, map[string]any{
+		"transfer": id,
+	})
+	return transfer, nil
+}
+
+// RunDue runs the transfers that are due, one transaction each, until none
+// is left or the batch is done.
+func (s ScheduledTransferService) RunDue(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "ScheduledTransferService.RunDue")
+	defer tracing.End(span, &err)
+	l := logging.FromContext(ctx, s.l)
+
+	for range dueTransferBatch {
+		var failure error
+		transfer, err := s.transfers.RunDue(ctx, func(senderID int) ([]entity.SpendingLimit, error) {
+			return s.limits.Active(ctx, senderID)
+		}, func(t *entity.ScheduledTransfer, runFailure error) {
+			failure = runFailure
+			s.reschedule(t, runFailure, time.Now())
+		})
+		if err != nil {
+			return err
+		}
+		if transfer == nil {
+			return nil
+		}
+
+		if failure != nil {
+			metrics.ScheduledTransferFailures.Inc()
+			l.Warn("scheduled transfer failed",
+				zap.Int64("transfer", transfer.ID),
+				zap.Int("failures", transfer.Failures),
+				zap.String("status", string(transfer.Status)),
+				zap.Error(failure),
+			)
+			continue
+		}
+		s.events.Publish(ctx, event.BalanceChanged{UserIDs: []int{transfer.SenderID, transfer.ReceiverID}})
+		metrics.CoinsTransferred.Add(float64(transfer.Amount))
+	}
+	return nil
+}
+
+// reschedule sets the state of t after a run at now. A successful one-time
+// transfer is completed, a recurring one moves to its next run. A failure
+// is retried after RetryDelay, or at the next run if recurring, until
+// MaxFailures is reached.
+func (s ScheduledTransferService) reschedule(t *entity.ScheduledTransfer, failure error, now time.Time) {
+	if failure != nil {
+		t.Failures, t.LastError = t.Failures+1, failure.Error()
+	} else {
+		t.Failures, t.LastError = 0, ""
+	}
+	if failure != nil && t.Failures >= s.policy.MaxFailures {
+		t.Status, t.NextRunAt = entity.ScheduledTransferFailed, nil
+		return
+	}
+
+	if !t.Recurring() {
+		if failure == nil {
+			t.Status, t.NextRunAt = entity.ScheduledTransferCompleted, nil
+			return
+		}
+		next := now.Add(s.policy.RetryDelay)
+		t.NextRunAt = &next
+		return
+	}
+
+	schedule, err := cron.ParseStandard(t.Schedule)
+	if err != nil {
+		// the spec was checked by Schedule, it can only fail if the parser
+		// changed since
+		t.Status, t.NextRunAt, t.LastError = entity.ScheduledTransferFailed, nil, err.Error()
+		return
+	}
+	next := schedule.Next(now)
+	t.NextRunAt = &next
+}
+
+func NewScheduledTransferService(
+	l *zap.Logger,
+	transfers repository.ScheduledTransferRepository,
+	u repository.UserRepository,
+	limits Limits,
+	policy TransferRetryPolicy,
+	e event.Publisher,
+	audit AuditRecorder,
+) ScheduledTransfers {
+	return &ScheduledTransferService{
+		l:         l,
+		transfers: transfers,
+		userRepo:  u,
+		limits:    limits,
+		policy:    policy,
+		events:    e,
+		audit:     audit,
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"AvitoTech/internal/event"
+	"AvitoTech/internal/metrics"
+	"AvitoTech/internal/repository"
+	mocks "AvitoTech/test/mock"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var testRetryPolicy = TransferRetryPolicy{MaxFailures: 3, RetryDelay: time.Hour}
+
+func TestScheduledTransferService_Schedule(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockTransfers := new(mocks.MockScheduledTransferRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	audit := new(mocks.AuditLog)
+
+	transferService := NewScheduledTransferService(logger, mockTransfers, mockUserRepo, unlimited(), testRetryPolicy, event.NewBus(), audit)
+
+	mockUserRepo.On("FindUserByID", 1).Return(&entity.User{ID: 1, Username: "alice"}, nil)
+	mockUserRepo.On("FindUserByUsername", "bob").Return(&entity.User{ID: 2, Username: "bob"}, nil)
+	mockUserRepo.On("FindUserByUsername", "alice").Return(&entity.User{ID: 1, Username: "alice"}, nil)
+
+	mockTransfers.On("Create", mock.MatchedBy(func(t entity.ScheduledTransfer) bool {
+		return t.SenderID == 1 && t.ReceiverID == 2 && t.Amount == 10 && t.Schedule == "@weekly" &&
+			t.NextRunAt != nil && t.NextRunAt.After(time.Now())
+	})).Return(&entity.ScheduledTransfer{ID: 5, Sender: "alice", Receiver: "bob", Schedule: "@weekly"}, nil).Once()
+
+	transfer, err := transferService.Schedule(context.Background(), 1, "bob", 10, nil, "@weekly")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), transfer.ID)
+	assert.Equal(t, []string{entity.AuditScheduleTransfer}, audit.Actions())
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	for name, tc := range map[string]struct {
+		receiver string
+		amount   int
+		runAt    *time.Time
+		spec     string
+		err      error
+	}{
+		"no amount":     {receiver: "bob", runAt: &future, err: ErrTransferAmount},
+		"no schedule":   {receiver: "bob", amount: 10, err: ErrInvalidSchedule},
+		"both":          {receiver: "bob", amount: 10, runAt: &future, spec: "@daily", err: ErrInvalidSchedule},
+		"bad spec":      {receiver: "bob", amount: 10, spec: "every day", err: ErrInvalidSchedule},
+		"too frequent":  {receiver: "bob", amount: 10, spec: "@every 10s", err: ErrScheduleTooFrequent},
+		"past":          {receiver: "bob", amount: 10, runAt: &past, err: ErrRunAtPast},
+		"self transfer": {receiver: "alice", amount: 10, runAt: &future, err: ErrSelfTransfer},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := transferService.Schedule(context.Background(), 1, tc.receiver, tc.amount, tc.runAt, tc.spec)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+	mockTransfers.AssertExpectations(t)
+}
+
+func TestScheduledTransferService_Reschedule(t *testing.T) {
+	transferService := ScheduledTransferService{policy: testRetryPolicy}
+	now := time.Date(2024, 1, 3, 12, 0, 0, 0, time.Local)
+
+	t.Run("one-time success", func(t *testing.T) {
+		transfer := entity.ScheduledTransfer{Status: entity.ScheduledTransferActive, Failures: 1, LastError: "insufficient balance"}
+		transferService.reschedule(&transfer, nil, now)
+		assert.Equal(t, entity.ScheduledTransferCompleted, transfer.Status)
+		assert.Nil(t, transfer.NextRunAt)
+		assert.Zero(t, transfer.Failures)
+		assert.Empty(t, transfer.LastError)
+	})
+
+	t.Run("one-time failure is retried", func(t *testing.T) {
+		transfer := entity.ScheduledTransfer{Status: entity.ScheduledTransferActive}
+		transferService.reschedule(&transfer, repository.ErrorInsufficientBalance, now)
+		assert.Equal(t, entity.ScheduledTransferActive, transfer.Status)
+		require.NotNil(t, transfer.NextRunAt)
+		assert.True(t, transfer.NextRunAt.Equal(now.Add(time.Hour)))
+		assert.Equal(t, 1, transfer.Failures)
+		assert.Equal(t, "insufficient balance", transfer.LastError)
+	})
+
+	t.Run("recurring failure skips to the next run", func(t *testing.T) {
+		transfer := entity.ScheduledTransfer{Status: entity.ScheduledTransferActive, Schedule: "@daily", Failures: 1}
+		transferService.reschedule(&transfer, repository.ErrorInsufficientBalance, now)
+		assert.Equal(t, entity.ScheduledTransferActive, transfer.Status)
+		require.NotNil(t, transfer.NextRunAt)
+		assert.True(t, transfer.NextRunAt.Equal(time.Date(2024, 1, 4, 0, 0, 0, 0, time.Local)))
+		assert.Equal(t, 2, transfer.Failures)
+	})
+
+	t.Run("gives up after max failures", func(t *testing.T) {
+		transfer := entity.ScheduledTransfer{Status: entity.ScheduledTransferActive, Schedule: "@daily", Failures: 2}
+		transferService.reschedule(&transfer, repository.ErrorInsufficientBalance, now)
+		assert.Equal(t, entity.ScheduledTransferFailed, transfer.Status)
+		assert.Nil(t, transfer.NextRunAt)
+		assert.Equal(t, 3, transfer.Failures)
+	})
+}
+
+func TestScheduledTransferService_RunDue(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	mockTransfers := new(mocks.MockScheduledTransferRepository)
+	audit := new(mocks.AuditLog)
+	bus := event.NewBus()
+
+	var changed [][]int
+	bus.Subscribe(func(_ context.Context, e any) {
+		changed = append(changed, e.(event.BalanceChanged).UserIDs)
+	})
+
+	mockLimits := new(mocks.MockLimits)
+	transferService := NewScheduledTransferService(logger, mockTransfers, new(mocks.MockUserRepository), mockLimits, testRetryPolicy, bus, audit)
+
+	transferred := testutil.ToFloat64(metrics.CoinsTransferred)
+	failures := testutil.ToFloat64(metrics.ScheduledTransferFailures)
+
+	mockTransfers.On("RunDue").Return(&entity.ScheduledTransfer{
+		ID: 1, SenderID: 1, Sender: "alice", ReceiverID: 2, Receiver: "bob", Amount: 10, Schedule: "@weekly",
+	}, nil, nil).Once()
+	mockTransfers.On("RunDue").Return(&entity.ScheduledTransfer{
+		ID: 2, SenderID: 3, Sender: "carol", ReceiverID: 2, Receiver: "bob", Amount: 500,
+	}, repository.ErrorLimitExceeded, nil).Once()
+	// the limits of each sender are read for the repository to check
+	mockLimits.On("Active", 1).Return(noLimits, nil).Once()
+	mockLimits.On("Active", 3).Return([]entity.SpendingLimit{
+		{Operation: entity.LimitTransfer, Period: entity.LimitDaily, Amount: 100},
+	}, nil).Once()
+	mockTransfers.On("RunDue").Return(nil, nil, nil).Once()
+
+	require.NoError(t, transferService.RunDue(context.Background()))
+
+	// only the transfer that went through moved coins
+	assert.Equal(t, [][]int{{1, 2}}, changed)
+	assert.Equal(t, transferred+10, testutil.ToFloat64(metrics.CoinsTransferred))
+	assert.Equal(t, failures+1, testutil.ToFloat64(metrics.ScheduledTransferFailures))
+	// the transfers are audited by the repository, as they run
+	assert.Empty(t, audit.Entries())
+	mockTransfers.AssertExpectations(t)
+	mockLimits.AssertExpectations(t)
+}
@@ -1,20 +1,92 @@
 package service
 
-import "AvitoTech/internal/entity"
+import (
+	"AvitoTech/internal/entity"
+	"context"
+	"time"
+)
 
 type Auth interface {
-	createUser(username, password string) (*entity.User, error)
-	Authenticate(username, password string) (string, error)
-	VerifyJWT(token string) (int, error)
+	createUser(ctx context.Context, username, password string) (*entity.User, error)
+	Authenticate(ctx context.Context, username, password, ip string) (string, error)
+	VerifyJWT(ctx context.Context, token string) (entity.Claims, error)
+	Unlock(ctx context.Context, username, actor string) error
+	ChangePassword(ctx context.Context, userID int, current, password string) (string, error)
+	IssuePasswordReset(ctx context.Context, username, actor string) (string, time.Time, error)
+	ResetPassword(ctx context.Context, token, password string) error
+}
+type Admin interface {
+	SetRole(ctx context.Context, username string, role entity.Role, actor string) error
+	Bootstrap(ctx context.Context, usernames []string) error
+	AdjustBalances(ctx context.Context, adjustment entity.Adjustment, actor string) error
+	ListUsers(ctx context.Context, filter entity.UserFilter) ([]entity.User, int, error)
+	GetUser(ctx context.Context, username string) (*entity.User, error)
+	SetDisabled(ctx context.Context, username string, disabled bool, actor string) error
+	DeleteUser(ctx context.Context, username, actor string) error
 }
 type Token interface {
-	GenerateToken(userID int) (string, error)
-	VerifyToken(tokenString string) (int, error)
+	GenerateToken(claims entity.Claims) (string, error)
+	VerifyToken(tokenString string) (entity.Claims, error)
 }
 type Info interface {
-	GetInfo(userID int) (*entity.AccountInfo, error)
+	GetInfo(ctx context.Context, userID int) (*entity.AccountInfo, error)
 }
 type Coin interface {
-	SendCoin(fromUser int, toUser string, amount int) error
-	BuyItem(id int, item string) error
+	SendCoin(ctx context.Context, fromUser int, toUser string, amount int) error
+	BuyItem(ctx context.Context, id int, item string) error
+}
+type CoinRequests interface {
+	Request(ctx context.Context, requesterID int, payer string, amount int, memo string) (*entity.CoinRequest, error)
+	List(ctx context.Context, filter entity.CoinRequestFilter) ([]entity.CoinRequest, error)
+	Accept(ctx context.Context, payerID int, id int64) (*entity.CoinRequest, error)
+	Decline(ctx context.Context, payerID int, id int64) (*entity.CoinRequest, error)
+}
+type ScheduledTransfers interface {
+	Schedule(ctx context.Context, senderID int, receiver string, amount int, runAt *time.Time, spec string) (*entity.ScheduledTransfer, error)
+	List(ctx context.Context, senderID int, limit int) ([]entity.ScheduledTransfer, error)
+	Cancel(ctx context.Context, senderID int, id int64) (*entity.ScheduledTransfer, error)
+	RunDue(ctx context.Context) error
+}
+type Holds interface {
+	Hold(ctx context.Context, sponsorID int, amount int, memo string) (*entity.Hold, error)
+	List(ctx context.Context, sponsorID int, limit int) ([]entity.Hold, error)
+	Release(ctx context.Context, sponsorID int, id int64, recipient string) (*entity.Hold, error)
+	Cancel(ctx context.Context, sponsorID int, id int64) (*entity.Hold, error)
+}
+type Limits interface {
+	Active(ctx context.Context, userID int) ([]entity.SpendingLimit, error)
+	Effective(ctx context.Context, userID int) ([]entity.SpendingLimit, error)
+	Get(ctx context.Context, username string) ([]entity.SpendingLimit, error)
+	SetOverride(ctx context.Context, username string, limit entity.SpendingLimit, actor string) error
+	ClearOverride(ctx context.Context, username string, operation entity.LimitOperation, period entity.LimitPeriod, actor string) error
+}
+type Health interface {
+	Ready(ctx context.Context) error
+	Drain()
+}
+type Reconciliation interface {
+	Run(ctx context.Context) (*entity.Reconciliation, error)
+	List(ctx context.Context, limit int) ([]entity.Reconciliation, error)
+	Get(ctx context.Context, id int) (*entity.Reconciliation, error)
+	Approve(ctx context.Context, id int, actor string) (*entity.Reconciliation, error)
+}
+type Allowance interface {
+	TopUp(ctx context.Context) error
+	Expire(ctx context.Context) error
+}
+type Jobs interface {
+	ListRuns(ctx context.Context, job string, limit int) ([]entity.JobRun, error)
+}
+
+// AuditRecorder writes to the audit log the actions that move no coins.
+// Recording never fails the action it describes, it has already taken place.
+// The changes of balances are audited by the repositories, in their
+// transaction.
+type AuditRecorder interface {
+	Record(ctx context.Context, actor, action, target string, payload any)
+}
+type Audit interface {
+	AuditRecorder
+	List(ctx context.Context, filter entity.AuditFilter) ([]entity.AuditEntry, error)
+	Verify(ctx context.Context) (entity.AuditVerification, error)
 }
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"AvitoTech/internal/entity"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// SignupPolicy decides what a user starts with. Campaigns running at the
+// moment of signing up add to the defaults.
+type SignupPolicy struct {
+	StartingBalance int        `json:"startingBalance"`
+	WelcomeItems    []string   `json:"welcomeItems"`
+	Campaigns       []Campaign `json:"campaigns"`
+}
+
+// Campaign is a signup bonus running from Start until End.
+type Campaign struct {
+	Name         string    `json:"name"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	Bonus        int       `json:"bonus"`
+	WelcomeItems []string  `json:"welcomeItems"`
+}
+
+// SignupGrant is what a user signing up at a given moment receives.
+type SignupGrant struct {
+	Balance int
+	Items   []string
+	// Campaign is the name of the campaign applied, if any.
+	Campaign string
+}
+
+// DefaultSignupPolicy is the policy used without a policy file.
+var DefaultSignupPolicy = SignupPolicy{StartingBalance: 1000}
+
+// At returns the grant of a user signing up at t. When several campaigns are
+// running the one listed first applies.
+func (p SignupPolicy) At(t time.Time) SignupGrant {
+	grant := SignupGrant{
+		Balance: p.StartingBalance,
+		Items:   append([]string(nil), p.WelcomeItems...),
+	}
+	for _, c := range p.Campaigns {
+		if t.Before(c.Start) || !t.Before(c.End) {
+			continue
+		}
+		grant.Balance += c.Bonus
+		grant.Items = append(grant.Items, c.WelcomeItems...)
+		grant.Campaign = c.Name
+		break
+	}
+	return grant
+}
+
+// Validate checks the policy against the item catalog, which must be loaded.
+func (p SignupPolicy) Validate() error {
+	if p.StartingBalance < 0 {
+		return fmt.Errorf("starting balance %d is negative", p.StartingBalance)
+	}
+	if err := validateItems(p.WelcomeItems); err != nil {
+		return err
+	}
+	for _, c := range p.Campaigns {
+		if c.Name == "" {
+			return fmt.Errorf("campaign without name")
+		}
+		if !c.End.After(c.Start) {
+			return fmt.Errorf("campaign %q ends before it starts", c.Name)
+		}
+		if p.StartingBalance+c.Bonus < 0 {
+			return fmt.Errorf("campaign %q leaves a negative starting balance", c.Name)
+		}
+		if err := validateItems(c.WelcomeItems); err != nil {
+			return fmt.Errorf("campaign %q: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateItems(items []string) error {
+	for _, item := range items {
+		if _, ok := entity.Items[item]; !ok {
+			return fmt.Errorf("welcome item %q is not in the catalog", item)
+		}
+	}
+	return nil
+}
+
+// LoadSignupPolicy reads a policy from the JSON file at path and validates it.
+func LoadSignupPolicy(path string) (SignupPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SignupPolicy{}, err
+	}
+
+	var policy SignupPolicy
+	if err = json.Unmarshal(data, &policy); err != nil {
+		return SignupPolicy{}, fmt.Errorf("decode signup policy: %w", err)
+	}
+	if err = policy.Validate(); err != nil {
+		return SignupPolicy{}, fmt.Errorf("invalid signup policy: %w", err)
+	}
+	return policy, nil
+}
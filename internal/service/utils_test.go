@@ -2,10 +2,13 @@ package service
 
 import (
 	"AvitoTech/internal/entity"
+	mocks "AvitoTech/test/mock"
 	"fmt"
 	"go.uber.org/zap"
 	"os"
 	"testing"
+
+	"github.com/stretchr/testify/mock"
 )
 
 func TestMain(m *testing.M) {
@@ -24,3 +27,14 @@ func TestMain(m *testing.M) {
 
 	os.Exit(code)
 }
+
+// noLimits are the limits unlimited hands to the repositories.
+var noLimits []entity.SpendingLimit
+
+// unlimited is a Limits service letting every operation through.
+func unlimited() *mocks.MockLimits {
+	limits := new(mocks.MockLimits)
+	limits.On("Active", mock.Anything).Return(noLimits, nil)
+	limits.On("Effective", mock.Anything).Return([]entity.SpendingLimit{}, nil)
+	return limits
+}
@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"testing"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+	})
+	return recorder
+}
+
+func TestEnd_RecordsError(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	func() (err error) {
+		_, span := Start(context.Background(), "failing")
+		defer End(span, &err)
+		return errors.New("boom")
+	}()
+
+	func() (err error) {
+		_, span := Start(context.Background(), "cancelled")
+		defer End(span, &err)
+		return context.Canceled
+	}()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "boom", spans[0].Status().Description)
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+}
+
+func TestStart_NestsUnderParent(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	child.End()
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, spans[1].SpanContext().TraceID(), spans[0].SpanContext().TraceID())
+}
+
+func TestLogger_AddsTraceIDs(t *testing.T) {
+	setupRecorder(t)
+	core, logs := observer.New(zap.DebugLevel)
+	l := zap.New(core)
+
+	Logger(context.Background(), l).Info("no span")
+
+	ctx, span := Start(context.Background(), "request")
+	defer span.End()
+	Logger(ctx, l).Info("in span")
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	assert.NotContains(t, entries[0].ContextMap(), "trace_id")
+	assert.Equal(t, span.SpanContext().TraceID().String(), entries[1].ContextMap()["trace_id"])
+	assert.Equal(t, span.SpanContext().SpanID().String(), entries[1].ContextMap()["span_id"])
+}
+
+func TestExtract_ContinuesRemoteTrace(t *testing.T) {
+	recorder := setupRecorder(t)
+	shutdown, err := Setup(context.Background(), Options{Exporter: "stdout", SampleRatio: 1, ServiceName: "test"})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, shutdown(context.Background()))
+	}()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	_, span := Start(Extract(context.Background(), header), "handler")
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), Options{Exporter: "jaeger"})
+	assert.Error(t, err)
+}
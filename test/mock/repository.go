@@ -3,43 +3,118 @@ package mock
 
 import (
 	"AvitoTech/internal/entity"
+	"AvitoTech/internal/repository"
+	"context"
 	"github.com/stretchr/testify/mock"
+	"time"
 )
 
 type MockUserRepository struct {
 	mock.Mock
 }
 
-func (m *MockUserRepository) InsertUser(user *entity.User) (*entity.User, error) {
+func (m *MockUserRepository) InsertUser(_ context.Context, user *entity.User) (*entity.User, error) {
 	args := m.Called(user)
 	return args.Get(0).(*entity.User), args.Error(1)
 }
 
-func (m *MockUserRepository) FindUserByUsername(username string) (*entity.User, error) {
+func (m *MockUserRepository) FindUserByUsername(_ context.Context, username string) (*entity.User, error) {
 	args := m.Called(username)
 	return args.Get(0).(*entity.User), args.Error(1)
 }
 
-func (m *MockUserRepository) FindUserByID(id int) (*entity.User, error) {
+func (m *MockUserRepository) Signup(_ context.Context, user *entity.User, items []string) (*entity.User, error) {
+	args := m.Called(user, items)
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *MockUserRepository) FindUserByID(_ context.Context, id int) (*entity.User, error) {
 	args := m.Called(id)
 	return args.Get(0).(*entity.User), args.Error(1)
 }
 
-func (m *MockUserRepository) TransferMoney(userFrom int, userTo int, amount int) error {
-	args := m.Called(userFrom, userTo, amount)
+func (m *MockUserRepository) TransferMoney(_ context.Context, userFrom int, userTo int, amount int, limits []entity.SpendingLimit) error {
+	args := m.Called(userFrom, userTo, amount, limits)
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) WithdrawMoney(user int, amount int) error {
-	args := m.Called(user, amount)
+func (m *MockUserRepository) BuyItem(_ context.Context, user int, item string, price int, limits []entity.SpendingLimit) error {
+	args := m.Called(user, item, price, limits)
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) SetPassword(_ context.Context, id int, hash string, revokeSessions bool) (int, error) {
+	args := m.Called(id, hash, revokeSessions)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockUserRepository) SetRole(_ context.Context, username string, role entity.Role) (*entity.User, error) {
+	args := m.Called(username, role)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *MockUserRepository) SearchUsers(_ context.Context, filter entity.UserFilter) ([]entity.User, int, error) {
+	args := m.Called(filter)
+	users, _ := args.Get(0).([]entity.User)
+	return users, args.Int(1), args.Error(2)
+}
+
+func (m *MockUserRepository) SetDisabled(_ context.Context, username string, disabled bool) (*entity.User, error) {
+	args := m.Called(username, disabled)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *MockUserRepository) DeleteUser(_ context.Context, username string) (*entity.User, error) {
+	args := m.Called(username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *MockUserRepository) Hold(_ context.Context, user int, amount int, memo string) (*entity.Hold, error) {
+	args := m.Called(user, amount, memo)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Hold), args.Error(1)
+}
+
+func (m *MockUserRepository) ReleaseHold(_ context.Context, id int64, sponsor int, recipient int, limits []entity.SpendingLimit) (*entity.Hold, error) {
+	args := m.Called(id, sponsor, recipient, limits)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Hold), args.Error(1)
+}
+
+func (m *MockUserRepository) CancelHold(_ context.Context, id int64, sponsor int) (*entity.Hold, error) {
+	args := m.Called(id, sponsor)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Hold), args.Error(1)
+}
+
+func (m *MockUserRepository) ListHolds(_ context.Context, sponsor int, limit int) ([]entity.Hold, error) {
+	args := m.Called(sponsor, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.Hold), args.Error(1)
+}
+
 type MockHistoryRepository struct {
 	mock.Mock
 }
 
-func (m *MockHistoryRepository) InsertOperation(operation entity.Operation) (*entity.Operation, error) {
+func (m *MockHistoryRepository) InsertOperation(_ context.Context, operation entity.Operation) (*entity.Operation, error) {
 	args := m.Called(operation)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -47,17 +122,17 @@ func (m *MockHistoryRepository) InsertOperation(operation entity.Operation) (*en
 	return args.Get(0).(*entity.Operation), args.Error(1)
 }
 
-func (m *MockHistoryRepository) GetSentByUser(name string) ([]entity.Operation, error) {
+func (m *MockHistoryRepository) GetSentByUser(_ context.Context, name string) ([]entity.Operation, error) {
 	args := m.Called(name)
 	return args.Get(0).([]entity.Operation), args.Error(1)
 }
 
-func (m *MockHistoryRepository) GetReceivedByUser(name string) ([]entity.Operation, error) {
+func (m *MockHistoryRepository) GetReceivedByUser(_ context.Context, name string) ([]entity.Operation, error) {
 	args := m.Called(name)
 	return args.Get(0).([]entity.Operation), args.Error(1)
 }
 
-func (m *MockHistoryRepository) DeleteOperation(id int) error {
+func (m *MockHistoryRepository) DeleteOperation(_ context.Context, id int) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
@@ -66,20 +141,388 @@ type MockInventoryRepository struct {
 	mock.Mock
 }
 
-func (m *MockInventoryRepository) InsertItem(owner int, item string) (*entity.Item, error) {
-	args := m.Called(owner, item)
+func (m *MockInventoryRepository) InsertItem(_ context.Context, owner int, item string, price int) (*entity.Item, error) {
+	args := m.Called(owner, item, price)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*entity.Item), args.Error(1)
 }
 
-func (m *MockInventoryRepository) GetUsersInventory(userID int) (map[string]int, error) {
+func (m *MockInventoryRepository) GetUsersInventory(_ context.Context, userID int) (map[string]int, error) {
 	args := m.Called(userID)
 	return args.Get(0).(map[string]int), args.Error(1)
 }
 
-func (m *MockInventoryRepository) DeleteItem(id int) error {
+func (m *MockInventoryRepository) DeleteItem(_ context.Context, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+type MockAccountRepository struct {
+	mock.Mock
+}
+
+func (m *MockAccountRepository) GetAccountInfo(_ context.Context, userID int) (*entity.AccountInfo, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.AccountInfo), args.Error(1)
+}
+
+type MockHealthRepository struct {
+	mock.Mock
+}
+
+func (m *MockHealthRepository) Ping(_ context.Context) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockHealthRepository) CheckSchema(_ context.Context) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+type MockLoginAttemptRepository struct {
+	mock.Mock
+}
+
+func (m *MockLoginAttemptRepository) RecordFailure(_ context.Context, username, ip string) error {
+	args := m.Called(username, ip)
+	return args.Error(0)
+}
+
+func (m *MockLoginAttemptRepository) CountFailures(_ context.Context, username, ip string, window time.Duration) (int, int, error) {
+	args := m.Called(username, ip, window)
+	return args.Int(0), args.Int(1), args.Error(2)
+}
+
+func (m *MockLoginAttemptRepository) ClearFailures(_ context.Context, username string) error {
+	args := m.Called(username)
+	return args.Error(0)
+}
+
+func (m *MockLoginAttemptRepository) Lock(_ context.Context, lockout entity.Lockout, duration time.Duration) (*entity.Lockout, error) {
+	args := m.Called(lockout, duration)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Lockout), args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) ActiveLockout(_ context.Context, username string) (*entity.Lockout, error) {
+	args := m.Called(username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Lockout), args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) Unlock(_ context.Context, username, by string) (bool, error) {
+	args := m.Called(username, by)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockPasswordResetRepository struct {
+	mock.Mock
+}
+
+func (m *MockPasswordResetRepository) Create(_ context.Context, userID int, tokenHash string, ttl time.Duration) (*entity.PasswordReset, error) {
+	args := m.Called(userID, tokenHash, ttl)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.PasswordReset), args.Error(1)
+}
+
+func (m *MockPasswordResetRepository) Find(_ context.Context, tokenHash string) (int, error) {
+	args := m.Called(tokenHash)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPasswordResetRepository) Consume(_ context.Context, tokenHash string, passwordHash string) (int, error) {
+	args := m.Called(tokenHash, passwordHash)
+	return args.Int(0), args.Error(1)
+}
+
+type MockAdjustmentRepository struct {
+	mock.Mock
+}
+
+func (m *MockAdjustmentRepository) Apply(_ context.Context, adjustment entity.Adjustment, actor string) ([]int, error) {
+	args := m.Called(adjustment, actor)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]int), args.Error(1)
+}
+
+type MockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditRepository) Append(_ context.Context, entry entity.AuditEntry) (*entity.AuditEntry, error) {
+	args := m.Called(entry)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.AuditEntry), args.Error(1)
+}
+
+func (m *MockAuditRepository) List(_ context.Context, filter entity.AuditFilter) ([]entity.AuditEntry, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.AuditEntry), args.Error(1)
+}
+
+// Walk hands the entries given to Return to fn.
+func (m *MockAuditRepository) Walk(_ context.Context, fn func(entity.AuditEntry) error) error {
+	args := m.Called()
+	if err := args.Error(1); err != nil {
+		return err
+	}
+	for _, entry := range args.Get(0).([]entity.AuditEntry) {
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type MockReconciliationRepository struct {
+	mock.Mock
+}
+
+func (m *MockReconciliationRepository) Reconcile(_ context.Context, prices map[string]int) (*entity.Reconciliation, error) {
+	args := m.Called(prices)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Reconciliation), args.Error(1)
+}
+
+func (m *MockReconciliationRepository) Get(_ context.Context, id int) (*entity.Reconciliation, error) {
 	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Reconciliation), args.Error(1)
+}
+
+func (m *MockReconciliationRepository) List(_ context.Context, limit int) ([]entity.Reconciliation, error) {
+	args := m.Called(limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.Reconciliation), args.Error(1)
+}
+
+func (m *MockReconciliationRepository) Apply(_ context.Context, id int, actor string, prices map[string]int) (*entity.Reconciliation, error) {
+	args := m.Called(id, actor, prices)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Reconciliation), args.Error(1)
+}
+
+type MockLeaderRepository struct {
+	mock.Mock
+}
+
+func (m *MockLeaderRepository) TryLead(_ context.Context, key int64) (repository.Lease, error) {
+	args := m.Called(key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(repository.Lease), args.Error(1)
+}
+
+type MockLease struct {
+	mock.Mock
+}
+
+func (m *MockLease) Alive(_ context.Context) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockLease) Release(_ context.Context) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+type MockJobRunRepository struct {
+	mock.Mock
+}
+
+func (m *MockJobRunRepository) Start(_ context.Context, job string, scheduledAt time.Time, replica string) (*entity.JobRun, error) {
+	args := m.Called(job, scheduledAt, replica)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.JobRun), args.Error(1)
+}
+
+func (m *MockJobRunRepository) Finish(_ context.Context, id int64, runErr string) error {
+	args := m.Called(id, runErr)
+	return args.Error(0)
+}
+
+func (m *MockJobRunRepository) Last(_ context.Context, job string) (*entity.JobRun, error) {
+	args := m.Called(job)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.JobRun), args.Error(1)
+}
+
+func (m *MockJobRunRepository) List(_ context.Context, job string, limit int) ([]entity.JobRun, error) {
+	args := m.Called(job, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.JobRun), args.Error(1)
+}
+
+type MockAllowanceRepository struct {
+	mock.Mock
+}
+
+func (m *MockAllowanceRepository) Grant(_ context.Context, amount int, reason string) ([]int, error) {
+	args := m.Called(amount, reason)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]int), args.Error(1)
+}
+
+func (m *MockAllowanceRepository) Expire(_ context.Context, cutoff time.Time, prices map[string]int, reason string) ([]int, int, error) {
+	args := m.Called(cutoff, prices, reason)
+	if args.Get(0) == nil {
+		return nil, args.Int(1), args.Error(2)
+	}
+	return args.Get(0).([]int), args.Int(1), args.Error(2)
+}
+
+type MockCoinRequestRepository struct {
+	mock.Mock
+}
+
+func (m *MockCoinRequestRepository) Create(_ context.Context, request entity.CoinRequest, ttl time.Duration) (*entity.CoinRequest, error) {
+	args := m.Called(request, ttl)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.CoinRequest), args.Error(1)
+}
+
+func (m *MockCoinRequestRepository) Get(_ context.Context, id int64, userID int) (*entity.CoinRequest, error) {
+	args := m.Called(id, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.CoinRequest), args.Error(1)
+}
+
+func (m *MockCoinRequestRepository) List(_ context.Context, filter entity.CoinRequestFilter) ([]entity.CoinRequest, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.CoinRequest), args.Error(1)
+}
+
+func (m *MockCoinRequestRepository) Accept(_ context.Context, id int64, payerID int, limits []entity.SpendingLimit) (*entity.CoinRequest, error) {
+	args := m.Called(id, payerID, limits)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.CoinRequest), args.Error(1)
+}
+
+func (m *MockCoinRequestRepository) Decline(_ context.Context, id int64, payerID int) (*entity.CoinRequest, error) {
+	args := m.Called(id, payerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.CoinRequest), args.Error(1)
+}
+
+type MockScheduledTransferRepository struct {
+	mock.Mock
+}
+
+func (m *MockScheduledTransferRepository) Create(_ context.Context, transfer entity.ScheduledTransfer) (*entity.ScheduledTransfer, error) {
+	args := m.Called(transfer)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.ScheduledTransfer), args.Error(1)
+}
+
+func (m *MockScheduledTransferRepository) List(_ context.Context, senderID int, limit int) ([]entity.ScheduledTransfer, error) {
+	args := m.Called(senderID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.ScheduledTransfer), args.Error(1)
+}
+
+func (m *MockScheduledTransferRepository) Cancel(_ context.Context, id int64, senderID int) (*entity.ScheduledTransfer, error) {
+	args := m.Called(id, senderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.ScheduledTransfer), args.Error(1)
+}
+
+// RunDue reads the limits of the sender of the transfer given to Return and
+// hands a copy of the transfer to reschedule along with the failure, the
+// second value, and returns it with the error.
+func (m *MockScheduledTransferRepository) RunDue(
+	_ context.Context,
+	limits func(senderID int) ([]entity.SpendingLimit, error),
+	reschedule func(transfer *entity.ScheduledTransfer, failure error),
+) (*entity.ScheduledTransfer, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(2)
+	}
+	transfer := *args.Get(0).(*entity.ScheduledTransfer)
+	if _, err := limits(transfer.SenderID); err != nil {
+		return nil, err
+	}
+	reschedule(&transfer, args.Error(1))
+	return &transfer, args.Error(2)
+}
+
+type MockLimitRepository struct {
+	mock.Mock
+}
+
+func (m *MockLimitRepository) Overrides(_ context.Context, userID int) ([]entity.SpendingLimit, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.SpendingLimit), args.Error(1)
+}
+
+func (m *MockLimitRepository) SetOverride(_ context.Context, userID int, limit entity.SpendingLimit) error {
+	args := m.Called(userID, limit)
+	return args.Error(0)
+}
+
+func (m *MockLimitRepository) DeleteOverride(_ context.Context, userID int, operation entity.LimitOperation, period entity.LimitPeriod) error {
+	args := m.Called(userID, operation, period)
 	return args.Error(0)
 }
+
+func (m *MockLimitRepository) Spent(_ context.Context, userID int, operation entity.LimitOperation, since time.Time) (int, error) {
+	args := m.Called(userID, operation, since)
+	return args.Int(0), args.Error(1)
+}
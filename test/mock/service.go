@@ -1,17 +1,104 @@
 package mock
 
-import "github.com/stretchr/testify/mock"
+import (
+	"AvitoTech/internal/entity"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/mock"
+	"sync"
+)
 
 type MockToken struct {
 	mock.Mock
 }
 
-func (m *MockToken) GenerateToken(userID int) (string, error) {
-	args := m.Called(userID)
+func (m *MockToken) GenerateToken(claims entity.Claims) (string, error) {
+	args := m.Called(claims)
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockToken) VerifyToken(tokenString string) (int, error) {
+func (m *MockToken) VerifyToken(tokenString string) (entity.Claims, error) {
 	args := m.Called(tokenString)
-	return args.Int(0), args.Error(1)
+	return args.Get(0).(entity.Claims), args.Error(1)
+}
+
+type MockCoin struct {
+	mock.Mock
+}
+
+func (m *MockCoin) SendCoin(_ context.Context, fromUser int, toUser string, amount int) error {
+	args := m.Called(fromUser, toUser, amount)
+	return args.Error(0)
+}
+
+func (m *MockCoin) BuyItem(_ context.Context, id int, item string) error {
+	args := m.Called(id, item)
+	return args.Error(0)
+}
+
+type MockLimits struct {
+	mock.Mock
+}
+
+func (m *MockLimits) Active(_ context.Context, userID int) ([]entity.SpendingLimit, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.SpendingLimit), args.Error(1)
+}
+
+func (m *MockLimits) Effective(_ context.Context, userID int) ([]entity.SpendingLimit, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.SpendingLimit), args.Error(1)
+}
+
+func (m *MockLimits) Get(_ context.Context, username string) ([]entity.SpendingLimit, error) {
+	args := m.Called(username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.SpendingLimit), args.Error(1)
+}
+
+func (m *MockLimits) SetOverride(_ context.Context, username string, limit entity.SpendingLimit, actor string) error {
+	args := m.Called(username, limit, actor)
+	return args.Error(0)
+}
+
+func (m *MockLimits) ClearOverride(_ context.Context, username string, operation entity.LimitOperation, period entity.LimitPeriod, actor string) error {
+	args := m.Called(username, operation, period, actor)
+	return args.Error(0)
+}
+
+// AuditLog is an AuditRecorder keeping the entries in memory.
+type AuditLog struct {
+	mu      sync.Mutex
+	entries []entity.AuditEntry
+}
+
+func (a *AuditLog) Record(_ context.Context, actor, action, target string, payload any) {
+	raw, _ := json.Marshal(payload)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries = append(a.entries, entity.AuditEntry{Actor: actor, Action: action, Target: target, Payload: raw})
+}
+
+// Entries returns the entries recorded so far.
+func (a *AuditLog) Entries() []entity.AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]entity.AuditEntry(nil), a.entries...)
+}
+
+// Actions returns the actions recorded so far, in order.
+func (a *AuditLog) Actions() []string {
+	var actions []string
+	for _, e := range a.Entries() {
+		actions = append(actions, e.Action)
+	}
+	return actions
 }